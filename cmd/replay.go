@@ -0,0 +1,110 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server"
+	"github.com/spf13/cobra"
+)
+
+// ReplayCmd re-sends a webhook delivery that was stored by a server running
+// with --store-webhook-payloads. It's used for debugging.
+type ReplayCmd struct {
+	// HTTPClient is used to send the delivery to the server.
+	HTTPClient *http.Client
+}
+
+// Init returns the runnable cobra command.
+func (r *ReplayCmd) Init() *cobra.Command {
+	var dataDir string
+	var atlantisURL string
+	c := &cobra.Command{
+		Use:   "replay DELIVERY_ID",
+		Short: "Replay a stored webhook delivery",
+		Long: "Re-send a webhook delivery stored by a server running with --" + StoreWebhooksFlag + " to that server." +
+			" The delivery is processed again even though it was already received.",
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := r.Replay(args[0], dataDir, atlantisURL)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s[ERROR] %s%s\n\n", RedTermStart, err.Error(), RedTermEnd)
+			}
+			return err
+		},
+	}
+	c.Flags().StringVar(&dataDir, DataDirFlag, "~/.atlantis", "> Path to the data dir of the Atlantis server that stored the delivery.")
+	c.Flags().StringVar(&atlantisURL, AtlantisURLFlag, "http://localhost:4141", "> URL of the Atlantis server to send the delivery to.")
+	return c
+}
+
+// Replay loads the delivery with id from dataDir and posts it to the events
+// endpoint of the Atlantis server at atlantisURL.
+func (r *ReplayCmd) Replay(id string, dataDir string, atlantisURL string) error {
+	if strings.HasPrefix(dataDir, "~/") {
+		var err error
+		dataDir, err = homedir.Expand(dataDir)
+		if err != nil {
+			return errors.Wrap(err, "determining home directory")
+		}
+	}
+	archive := &server.DeliveryArchive{Dir: filepath.Join(dataDir, server.DeliveriesDirName)}
+	delivery, err := archive.Load(id)
+	if err != nil {
+		return err
+	}
+	// The server only skips its duplicate delivery check if we send its
+	// replay token which proves we have access to its data dir.
+	token, err := archive.ReplayToken()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(atlantisURL, "/")+"/events", bytes.NewReader(delivery.Body))
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	for name, values := range delivery.Header {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	req.Header.Set(server.ReplayHeader, token)
+
+	client := r.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "sending delivery")
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server responded with %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	fmt.Printf("replayed delivery %s: %s\n", id, strings.TrimSpace(string(respBody)))
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package cmd_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/cmd"
+	"github.com/runatlantis/atlantis/server"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestReplay_MissingDelivery(t *testing.T) {
+	t.Log("replaying a delivery that wasn't stored should error")
+	dataDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dataDir) // nolint: errcheck
+
+	r := &cmd.ReplayCmd{}
+	err = r.Replay("abc-123", dataDir, "http://localhost:4141")
+	Assert(t, err != nil, "exp err")
+}
+
+func TestReplay_SendsDelivery(t *testing.T) {
+	t.Log("replaying should post the stored body and headers to /events with the replay header")
+	dataDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dataDir) // nolint: errcheck
+	archive := &server.DeliveryArchive{Dir: filepath.Join(dataDir, server.DeliveriesDirName)}
+	Ok(t, archive.Save(server.ArchivedDelivery{
+		ID:         "abc-123",
+		Header:     http.Header{"X-Github-Event": []string{"issue_comment"}},
+		Body:       []byte(`{"action": "created"}`),
+		ReceivedAt: time.Now(),
+	}))
+
+	var gotReq *http.Request
+	var gotBody []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotReq = r
+		gotBody, _ = ioutil.ReadAll(r.Body)
+		w.Write([]byte("Processing...")) // nolint: errcheck
+	}))
+	defer ts.Close()
+
+	r := &cmd.ReplayCmd{}
+	Ok(t, r.Replay("abc-123", dataDir, ts.URL+"/"))
+	Equals(t, "POST", gotReq.Method)
+	Equals(t, "/events", gotReq.URL.Path)
+	Equals(t, "issue_comment", gotReq.Header.Get("X-Github-Event"))
+	token, err := archive.ReplayToken()
+	Ok(t, err)
+	Equals(t, token, gotReq.Header.Get(server.ReplayHeader))
+	Equals(t, `{"action": "created"}`, string(gotBody))
+}
+
+func TestReplay_ServerError(t *testing.T) {
+	t.Log("if the server doesn't respond with a 200 we should error")
+	dataDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dataDir) // nolint: errcheck
+	archive := &server.DeliveryArchive{Dir: filepath.Join(dataDir, server.DeliveriesDirName)}
+	Ok(t, archive.Save(server.ArchivedDelivery{ID: "abc-123"}))
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Ignoring request")) // nolint: errcheck
+	}))
+	defer ts.Close()
+
+	r := &cmd.ReplayCmd{}
+	err = r.Replay("abc-123", dataDir, ts.URL)
+	ErrEquals(t, "server responded with 400: Ignoring request", err)
+}
@@ -18,6 +18,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/pkg/errors"
@@ -35,6 +36,11 @@ const (
 	AllowForkPRsFlag    = "allow-fork-prs"
 	ConfigFlag          = "config"
 	DataDirFlag         = "data-dir"
+	DriftIntervalFlag   = "drift-interval"
+	GHAppIDFlag         = "gh-app-id"
+	GHAppInstallIDFlag  = "gh-app-installation-id"
+	GHAppKeyFileFlag    = "gh-app-key-file"
+	GHChecksFlag        = "gh-checks"
 	GHHostnameFlag      = "gh-hostname"
 	GHTokenFlag         = "gh-token"
 	GHUserFlag          = "gh-user"
@@ -43,12 +49,17 @@ const (
 	GitlabTokenFlag     = "gitlab-token"
 	GitlabUserFlag      = "gitlab-user"
 	GitlabWebHookSecret = "gitlab-webhook-secret"
+	LogFormatFlag       = "log-format"
 	LogLevelFlag        = "log-level"
+	PolicyDirFlag       = "policy-dir"
+	PolicyOwnersFlag    = "policy-owners"
 	PortFlag            = "port"
 	RepoWhitelistFlag   = "repo-whitelist"
 	RequireApprovalFlag = "require-approval"
 	SSLCertFileFlag     = "ssl-cert-file"
 	SSLKeyFileFlag      = "ssl-key-file"
+	StoreWebhooksFlag   = "store-webhook-payloads"
+	WorkflowsFileFlag   = "workflows-file"
 )
 
 const RedTermStart = "\033[31m"
@@ -68,6 +79,15 @@ var stringFlags = []stringFlag{
 		description: "Path to directory to store Atlantis data.",
 		value:       "~/.atlantis",
 	},
+	{
+		name: DriftIntervalFlag,
+		description: "How often to check for drift, ex. 6h. Each project and workspace that Atlantis has planned is planned again against its repo's default branch, " +
+			"without taking locks, and the projects with changes are reported. If not set, drift isn't checked.",
+	},
+	{
+		name:        GHAppKeyFileFlag,
+		description: fmt.Sprintf("Path to the PEM encoded private key of the GitHub App set by --%s.", GHAppIDFlag),
+	},
 	{
 		name:        GHHostnameFlag,
 		description: "Hostname of your Github Enterprise installation. If using github.com, no need to set.",
@@ -108,11 +128,26 @@ var stringFlags = []stringFlag{
 			"This means that an attacker could spoof calls to Atlantis and cause it to perform malicious actions. " +
 			"Should be specified via the ATLANTIS_GITLAB_WEBHOOK_SECRET environment variable.",
 	},
+	{
+		name: LogFormatFlag,
+		description: "Log format. Either text or json. In json, each log entry is a JSON object with fields for the repo, pull request, " +
+			"command, workspace, project, webhook delivery ID and run ID.",
+		value: "text",
+	},
 	{
 		name:        LogLevelFlag,
 		description: "Log level. Either debug, info, warn, or error.",
 		value:       "info",
 	},
+	{
+		name: PolicyDirFlag,
+		description: "Directory of YAML policy files to check plans against. Plans that fail their policy checks can't be applied " +
+			fmt.Sprintf("unless one of --%s overrides them. If not set, plans aren't checked.", PolicyOwnersFlag),
+	},
+	{
+		name:        PolicyOwnersFlag,
+		description: "Comma separated list of usernames that can apply plans that failed their policy checks by running 'atlantis apply --override-policies'.",
+	},
 	{
 		name: RepoWhitelistFlag,
 		description: "Comma separated list of repositories that Atlantis will operate on. " +
@@ -127,6 +162,11 @@ var stringFlags = []stringFlag{
 		name:        SSLKeyFileFlag,
 		description: fmt.Sprintf("File containing x509 private key matching --%s.", SSLCertFileFlag),
 	},
+	{
+		name: WorkflowsFileFlag,
+		description: "YAML file of workflows that projects can use by name in their atlantis.yaml. " +
+			"A workflow named 'default' is used by projects that don't name one.",
+	},
 }
 var boolFlags = []boolFlag{
 	{
@@ -134,13 +174,31 @@ var boolFlags = []boolFlag{
 		description: "Allow Atlantis to run on pull requests from forks. A security issue for public repos.",
 		value:       false,
 	},
+	{
+		name:        GHChecksFlag,
+		description: "Create a GitHub check run for each project with the plan or apply output and a button to apply the plan.",
+		value:       false,
+	},
 	{
 		name:        RequireApprovalFlag,
 		description: "Require pull requests to be \"Approved\" before allowing the apply command to be run.",
 		value:       false,
 	},
+	{
+		name:        StoreWebhooksFlag,
+		description: "Store the raw payloads of incoming webhooks in the data dir so they can be replayed for debugging with 'atlantis replay'.",
+		value:       false,
+	},
 }
 var intFlags = []intFlag{
+	{
+		name:        GHAppIDFlag,
+		description: fmt.Sprintf("ID of a GitHub App to authenticate as instead of a user. Requires --%s.", GHAppKeyFileFlag),
+	},
+	{
+		name:        GHAppInstallIDFlag,
+		description: fmt.Sprintf("ID of the installation of the GitHub App set by --%s. Only needed if the app is installed more than once.", GHAppIDFlag),
+	},
 	{
 		name:        PortFlag,
 		description: "Port to bind to.",
@@ -296,6 +354,9 @@ func (s *ServerCmd) validate(userConfig server.UserConfig) error {
 	if logLevel != "debug" && logLevel != "info" && logLevel != "warn" && logLevel != "error" {
 		return errors.New("invalid log level: not one of debug, info, warn, error")
 	}
+	if userConfig.LogFormat != "text" && userConfig.LogFormat != "json" {
+		return errors.New("invalid log format: not one of text, json")
+	}
 
 	if (userConfig.SSLKeyFile == "") != (userConfig.SSLCertFile == "") {
 		return fmt.Errorf("--%s and --%s are both required for ssl", SSLKeyFileFlag, SSLCertFileFlag)
@@ -303,15 +364,23 @@ func (s *ServerCmd) validate(userConfig server.UserConfig) error {
 
 	// The following combinations are valid.
 	// 1. github user and token set
-	// 2. gitlab user and token set
-	// 3. all 4 set
-	vcsErr := fmt.Errorf("--%s and --%s or --%s and --%s must be set", GHUserFlag, GHTokenFlag, GitlabUserFlag, GitlabTokenFlag)
-	if ((userConfig.GithubUser == "") != (userConfig.GithubToken == "")) || ((userConfig.GitlabUser == "") != (userConfig.GitlabToken == "")) {
+	// 2. github app id and key file set
+	// 3. gitlab user and token set
+	// 4. one of the github combinations and the gitlab user and token set
+	// 5. none of the above but hosts set in the vcs-hosts config section,
+	//    which are validated when the server is created
+	vcsErr := fmt.Errorf("--%s and --%s, --%s and --%s, or --%s and --%s must be set", GHUserFlag, GHTokenFlag, GHAppIDFlag, GHAppKeyFileFlag, GitlabUserFlag, GitlabTokenFlag)
+	if ((userConfig.GithubUser == "") != (userConfig.GithubToken == "")) ||
+		((userConfig.GithubAppID == 0) != (userConfig.GithubAppKeyFile == "")) ||
+		((userConfig.GitlabUser == "") != (userConfig.GitlabToken == "")) {
 		return vcsErr
 	}
+	if userConfig.GithubUser != "" && userConfig.GithubAppID != 0 {
+		return fmt.Errorf("only one of --%s or --%s can be set", GHUserFlag, GHAppIDFlag)
+	}
 	// At this point, we know that there can't be a single user/token without
-	// its partner, but we haven't checked if any user/token is set at all.
-	if userConfig.GithubUser == "" && userConfig.GitlabUser == "" {
+	// its partner, but we haven't checked if any credentials are set at all.
+	if userConfig.GithubUser == "" && userConfig.GithubAppID == 0 && userConfig.GitlabUser == "" && len(userConfig.VCSHosts) == 0 {
 		return vcsErr
 	}
 
@@ -319,6 +388,13 @@ func (s *ServerCmd) validate(userConfig server.UserConfig) error {
 		return fmt.Errorf("--%s must be set for security purposes", RepoWhitelistFlag)
 	}
 
+	if userConfig.DriftInterval != "" {
+		interval, err := time.ParseDuration(userConfig.DriftInterval)
+		if err != nil || interval <= 0 {
+			return fmt.Errorf("--%s must be a positive duration, ex. 6h", DriftIntervalFlag)
+		}
+	}
+
 	return nil
 }
 
@@ -365,12 +441,18 @@ func (s *ServerCmd) trimAtSymbolFromUsers(userConfig *server.UserConfig) {
 }
 
 func (s *ServerCmd) securityWarnings(userConfig *server.UserConfig) {
-	if userConfig.GithubUser != "" && userConfig.GithubWebHookSecret == "" && !s.SilenceOutput {
+	usingGithub := userConfig.GithubUser != "" || userConfig.GithubAppID != 0
+	if usingGithub && userConfig.GithubWebHookSecret == "" && !s.SilenceOutput {
 		fmt.Fprintf(os.Stderr, "%s[WARN] No GitHub webhook secret set. This could allow attackers to spoof requests from GitHub. See https://git.io/vAF3t%s\n", RedTermStart, RedTermEnd)
 	}
 	if userConfig.GitlabUser != "" && userConfig.GitlabWebHookSecret == "" && !s.SilenceOutput {
 		fmt.Fprintf(os.Stderr, "%s[WARN] No GitLab webhook secret set. This could allow attackers to spoof requests from GitLab. See https://git.io/vAF3t%s\n", RedTermStart, RedTermEnd)
 	}
+	for _, h := range userConfig.VCSHosts {
+		if h.WebhookSecret == "" && !s.SilenceOutput {
+			fmt.Fprintf(os.Stderr, "%s[WARN] No webhook secret set for %s host %q. This could allow attackers to spoof requests from it. See https://git.io/vAF3t%s\n", RedTermStart, h.Kind, h.Hostname, RedTermEnd)
+		}
+	}
 }
 
 // withErrPrint prints out any errors to a terminal in red.
@@ -115,6 +115,28 @@ func TestExecute_ValidateLogLevel(t *testing.T) {
 	Equals(t, "invalid log level: not one of debug, info, warn, error", err.Error())
 }
 
+func TestExecute_ValidateLogFormat(t *testing.T) {
+	t.Log("Should validate log format.")
+	c := setupWithDefaults(map[string]interface{}{
+		cmd.LogFormatFlag: "invalid",
+	})
+	err := c.Execute()
+	Assert(t, err != nil, "should be an error")
+	Equals(t, "invalid log format: not one of text, json", err.Error())
+}
+
+func TestExecute_ValidateDriftInterval(t *testing.T) {
+	t.Log("Should validate the drift interval.")
+	for _, interval := range []string{"invalid", "0s", "-1h"} {
+		c := setupWithDefaults(map[string]interface{}{
+			cmd.DriftIntervalFlag: interval,
+		})
+		err := c.Execute()
+		Assert(t, err != nil, "should be an error for %q", interval)
+		Equals(t, "--drift-interval must be a positive duration, ex. 6h", err.Error())
+	}
+}
+
 func TestExecute_ValidateSSLConfig(t *testing.T) {
 	expErr := "--ssl-key-file and --ssl-cert-file are both required for ssl"
 	cases := []struct {
@@ -164,7 +186,7 @@ func TestExecute_ValidateSSLConfig(t *testing.T) {
 }
 
 func TestExecute_ValidateVCSConfig(t *testing.T) {
-	expErr := "--gh-user and --gh-token or --gitlab-user and --gitlab-token must be set"
+	expErr := "--gh-user and --gh-token, --gh-app-id and --gh-app-key-file, or --gitlab-user and --gitlab-token must be set"
 	cases := []struct {
 		description string
 		flags       map[string]interface{}
@@ -219,6 +241,28 @@ func TestExecute_ValidateVCSConfig(t *testing.T) {
 			},
 			true,
 		},
+		{
+			"just github app id set",
+			map[string]interface{}{
+				cmd.GHAppIDFlag: 1,
+			},
+			true,
+		},
+		{
+			"just github app key file set",
+			map[string]interface{}{
+				cmd.GHAppKeyFileFlag: "key.pem",
+			},
+			true,
+		},
+		{
+			"github app id and key file set and should be successful",
+			map[string]interface{}{
+				cmd.GHAppIDFlag:      1,
+				cmd.GHAppKeyFileFlag: "key.pem",
+			},
+			false,
+		},
 		{
 			"github user and github token set and should be successful",
 			map[string]interface{}{
@@ -283,7 +327,11 @@ func TestExecute_Defaults(t *testing.T) {
 	dataDir, err := homedir.Expand("~/.atlantis")
 	Ok(t, err)
 	Equals(t, dataDir, passedConfig.DataDir)
+	Equals(t, "", passedConfig.DriftInterval)
 
+	Equals(t, 0, passedConfig.GithubAppID)
+	Equals(t, 0, passedConfig.GithubAppInstallationID)
+	Equals(t, "", passedConfig.GithubAppKeyFile)
 	Equals(t, "github.com", passedConfig.GithubHostname)
 	Equals(t, "token", passedConfig.GithubToken)
 	Equals(t, "user", passedConfig.GithubUser)
@@ -292,11 +340,17 @@ func TestExecute_Defaults(t *testing.T) {
 	Equals(t, "gitlab-token", passedConfig.GitlabToken)
 	Equals(t, "gitlab-user", passedConfig.GitlabUser)
 	Equals(t, "", passedConfig.GitlabWebHookSecret)
+	Equals(t, "text", passedConfig.LogFormat)
 	Equals(t, "info", passedConfig.LogLevel)
+	Equals(t, "", passedConfig.PolicyDir)
+	Equals(t, "", passedConfig.PolicyOwners)
+	Equals(t, "", passedConfig.WorkflowsFile)
 	Equals(t, 4141, passedConfig.Port)
 	Equals(t, false, passedConfig.RequireApproval)
 	Equals(t, "", passedConfig.SSLCertFile)
 	Equals(t, "", passedConfig.SSLKeyFile)
+	Equals(t, false, passedConfig.StoreWebhookPayloads)
+	Equals(t, false, passedConfig.GithubChecks)
 }
 
 func TestExecute_ExpandHomeInDataDir(t *testing.T) {
@@ -343,6 +397,35 @@ func TestExecute_GithubUser(t *testing.T) {
 	Equals(t, "user", passedConfig.GithubUser)
 }
 
+func TestExecute_GithubUserAndApp(t *testing.T) {
+	t.Log("Should error if both a github user and app are set.")
+	c := setup(map[string]interface{}{
+		cmd.GHUserFlag:        "user",
+		cmd.GHTokenFlag:       "token",
+		cmd.GHAppIDFlag:       1,
+		cmd.GHAppKeyFileFlag:  "key.pem",
+		cmd.RepoWhitelistFlag: "*",
+	})
+	err := c.Execute()
+	ErrEquals(t, "only one of --gh-user or --gh-app-id can be set", err)
+}
+
+func TestExecute_GithubApp(t *testing.T) {
+	t.Log("Should use the github app flags.")
+	c := setup(map[string]interface{}{
+		cmd.GHAppIDFlag:        1,
+		cmd.GHAppInstallIDFlag: 2,
+		cmd.GHAppKeyFileFlag:   "key.pem",
+		cmd.RepoWhitelistFlag:  "*",
+	})
+	err := c.Execute()
+	Ok(t, err)
+
+	Equals(t, 1, passedConfig.GithubAppID)
+	Equals(t, 2, passedConfig.GithubAppInstallationID)
+	Equals(t, "key.pem", passedConfig.GithubAppKeyFile)
+}
+
 func TestExecute_GitlabUser(t *testing.T) {
 	t.Log("Should remove the @ from the gitlab username if it's passed.")
 	c := setup(map[string]interface{}{
@@ -362,6 +445,7 @@ func TestExecute_Flags(t *testing.T) {
 		cmd.AtlantisURLFlag:     "url",
 		cmd.AllowForkPRsFlag:    true,
 		cmd.DataDirFlag:         "/path",
+		cmd.DriftIntervalFlag:   "6h",
 		cmd.GHHostnameFlag:      "ghhostname",
 		cmd.GHTokenFlag:         "token",
 		cmd.GHUserFlag:          "user",
@@ -370,12 +454,18 @@ func TestExecute_Flags(t *testing.T) {
 		cmd.GitlabTokenFlag:     "gitlab-token",
 		cmd.GitlabUserFlag:      "gitlab-user",
 		cmd.GitlabWebHookSecret: "gitlab-secret",
+		cmd.LogFormatFlag:       "json",
 		cmd.LogLevelFlag:        "debug",
+		cmd.PolicyDirFlag:       "/policies",
+		cmd.PolicyOwnersFlag:    "alice,bob",
 		cmd.PortFlag:            8181,
 		cmd.RepoWhitelistFlag:   "github.com/runatlantis/atlantis",
 		cmd.RequireApprovalFlag: true,
 		cmd.SSLCertFileFlag:     "cert-file",
 		cmd.SSLKeyFileFlag:      "key-file",
+		cmd.StoreWebhooksFlag:   true,
+		cmd.WorkflowsFileFlag:   "/workflows.yaml",
+		cmd.GHChecksFlag:        true,
 	})
 	err := c.Execute()
 	Ok(t, err)
@@ -383,6 +473,7 @@ func TestExecute_Flags(t *testing.T) {
 	Equals(t, "url", passedConfig.AtlantisURL)
 	Equals(t, true, passedConfig.AllowForkPRs)
 	Equals(t, "/path", passedConfig.DataDir)
+	Equals(t, "6h", passedConfig.DriftInterval)
 	Equals(t, "ghhostname", passedConfig.GithubHostname)
 	Equals(t, "token", passedConfig.GithubToken)
 	Equals(t, "user", passedConfig.GithubUser)
@@ -391,12 +482,18 @@ func TestExecute_Flags(t *testing.T) {
 	Equals(t, "gitlab-token", passedConfig.GitlabToken)
 	Equals(t, "gitlab-user", passedConfig.GitlabUser)
 	Equals(t, "gitlab-secret", passedConfig.GitlabWebHookSecret)
+	Equals(t, "json", passedConfig.LogFormat)
 	Equals(t, "debug", passedConfig.LogLevel)
+	Equals(t, "/policies", passedConfig.PolicyDir)
+	Equals(t, "alice,bob", passedConfig.PolicyOwners)
 	Equals(t, 8181, passedConfig.Port)
 	Equals(t, "github.com/runatlantis/atlantis", passedConfig.RepoWhitelist)
 	Equals(t, true, passedConfig.RequireApproval)
 	Equals(t, "cert-file", passedConfig.SSLCertFile)
 	Equals(t, "key-file", passedConfig.SSLKeyFile)
+	Equals(t, true, passedConfig.StoreWebhookPayloads)
+	Equals(t, "/workflows.yaml", passedConfig.WorkflowsFile)
+	Equals(t, true, passedConfig.GithubChecks)
 }
 
 func TestExecute_ConfigFile(t *testing.T) {
@@ -405,6 +502,7 @@ func TestExecute_ConfigFile(t *testing.T) {
 atlantis-url: "url"
 allow-fork-prs: true
 data-dir: "/path"
+drift-interval: "6h"
 gh-hostname: "ghhostname"
 gh-token: "token"
 gh-user: "user"
@@ -413,12 +511,16 @@ gitlab-hostname: "gitlab-hostname"
 gitlab-token: "gitlab-token"
 gitlab-user: "gitlab-user"
 gitlab-webhook-secret: "gitlab-secret"
+log-format: "json"
 log-level: "debug"
 port: 8181
 repo-whitelist: "github.com/runatlantis/atlantis"
 require-approval: true
 ssl-cert-file: cert-file
 ssl-key-file: key-file
+store-webhook-payloads: true
+workflows-file: "/workflows.yaml"
+gh-checks: true
 `)
 	defer os.Remove(tmpFile) // nolint: errcheck
 	c := setup(map[string]interface{}{
@@ -430,6 +532,7 @@ ssl-key-file: key-file
 	Equals(t, "url", passedConfig.AtlantisURL)
 	Equals(t, true, passedConfig.AllowForkPRs)
 	Equals(t, "/path", passedConfig.DataDir)
+	Equals(t, "6h", passedConfig.DriftInterval)
 	Equals(t, "ghhostname", passedConfig.GithubHostname)
 	Equals(t, "token", passedConfig.GithubToken)
 	Equals(t, "user", passedConfig.GithubUser)
@@ -438,12 +541,122 @@ ssl-key-file: key-file
 	Equals(t, "gitlab-token", passedConfig.GitlabToken)
 	Equals(t, "gitlab-user", passedConfig.GitlabUser)
 	Equals(t, "gitlab-secret", passedConfig.GitlabWebHookSecret)
+	Equals(t, "json", passedConfig.LogFormat)
 	Equals(t, "debug", passedConfig.LogLevel)
 	Equals(t, 8181, passedConfig.Port)
 	Equals(t, "github.com/runatlantis/atlantis", passedConfig.RepoWhitelist)
 	Equals(t, true, passedConfig.RequireApproval)
 	Equals(t, "cert-file", passedConfig.SSLCertFile)
 	Equals(t, "key-file", passedConfig.SSLKeyFile)
+	Equals(t, true, passedConfig.StoreWebhookPayloads)
+	Equals(t, "/workflows.yaml", passedConfig.WorkflowsFile)
+	Equals(t, true, passedConfig.GithubChecks)
+}
+
+func TestExecute_VCSHostsConfigFile(t *testing.T) {
+	t.Log("Hosts in the vcs-hosts config section should be passed through and can be used without the gh-* or gitlab-* flags.")
+	tmpFile := tempFile(t, `---
+repo-whitelist: "*"
+vcs-hosts:
+- kind: github
+  hostname: github.mycompany.com
+  user: ghe-user
+  token: ghe-token
+  webhook-secret: ghe-secret
+- kind: gitlab
+  hostname: gitlab.mycompany.com
+  user: gl-user
+  token: gl-token
+`)
+	defer os.Remove(tmpFile) // nolint: errcheck
+	c := setup(map[string]interface{}{
+		cmd.ConfigFlag: tmpFile,
+	})
+
+	err := c.Execute()
+	Ok(t, err)
+	Equals(t, []server.VCSHostConfig{
+		{
+			Kind:          "github",
+			Hostname:      "github.mycompany.com",
+			User:          "ghe-user",
+			Token:         "ghe-token",
+			WebhookSecret: "ghe-secret",
+		},
+		{
+			Kind:     "gitlab",
+			Hostname: "gitlab.mycompany.com",
+			User:     "gl-user",
+			Token:    "gl-token",
+		},
+	}, passedConfig.VCSHosts)
+}
+
+func TestExecute_RedactPatternsConfigFile(t *testing.T) {
+	t.Log("Patterns in the redact-patterns config section should be passed through.")
+	tmpFile := tempFile(t, `---
+gh-user: user
+gh-token: token
+repo-whitelist: "*"
+redact-patterns:
+- 'password=\S+'
+- 'AKIA[0-9A-Z]{16}'
+`)
+	defer os.Remove(tmpFile) // nolint: errcheck
+	c := setup(map[string]interface{}{
+		cmd.ConfigFlag: tmpFile,
+	})
+
+	err := c.Execute()
+	Ok(t, err)
+	Equals(t, []string{`password=\S+`, "AKIA[0-9A-Z]{16}"}, passedConfig.RedactPatterns)
+}
+
+func TestExecute_SMTPConfigFile(t *testing.T) {
+	t.Log("The smtp config section and email webhooks should be passed through.")
+	tmpFile := tempFile(t, `---
+gh-user: user
+gh-token: token
+repo-whitelist: "*"
+smtp:
+  host: smtp.example.com
+  port: 465
+  username: atlantis
+  password: password
+  from: Atlantis <atlantis@example.com>
+  tls: tls
+webhooks:
+- event: apply
+  workspace-regex: ^prod
+  kind: email
+  to:
+  - cab@example.com
+  subject: "[CAB] {{.Repo.FullName}}"
+`)
+	defer os.Remove(tmpFile) // nolint: errcheck
+	c := setup(map[string]interface{}{
+		cmd.ConfigFlag: tmpFile,
+	})
+
+	err := c.Execute()
+	Ok(t, err)
+	Equals(t, server.SMTPConfig{
+		Host:     "smtp.example.com",
+		Port:     465,
+		Username: "atlantis",
+		Password: "password",
+		From:     "Atlantis <atlantis@example.com>",
+		TLS:      "tls",
+	}, passedConfig.SMTP)
+	Equals(t, []server.WebhookConfig{
+		{
+			Event:          "apply",
+			WorkspaceRegex: "^prod",
+			Kind:           "email",
+			To:             []string{"cab@example.com"},
+			Subject:        "[CAB] {{.Repo.FullName}}",
+		},
+	}, passedConfig.Webhooks)
 }
 
 func TestExecute_EnvironmentOverride(t *testing.T) {
@@ -33,8 +33,10 @@ func main() {
 	}
 	version := &cmd.VersionCmd{AtlantisVersion: atlantisVersion}
 	bootstrap := &cmd.BootstrapCmd{}
+	replay := &cmd.ReplayCmd{}
 	cmd.RootCmd.AddCommand(server.Init())
 	cmd.RootCmd.AddCommand(version.Init())
 	cmd.RootCmd.AddCommand(bootstrap.Init())
+	cmd.RootCmd.AddCommand(replay.Init())
 	cmd.Execute()
 }
@@ -0,0 +1,197 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DeliveriesDirName is the name of the directory inside the data dir where
+// webhook payloads are archived.
+const DeliveriesDirName = "deliveries"
+
+// ReplayHeader is set on requests sent by "atlantis replay" to the archive's
+// replay token. Requests with a valid token skip the duplicate delivery check
+// so an archived delivery can be processed again.
+const ReplayHeader = "X-Atlantis-Replay"
+
+// replayTokenFile is the name of the file in the archive dir that holds the
+// replay token.
+const replayTokenFile = "replay-token"
+
+// unarchivedHeaders are the request headers that hold secrets. They aren't
+// written to disk so replayed GitLab deliveries are authenticated with the
+// replay token instead.
+var unarchivedHeaders = []string{secretHeader, "Authorization"}
+
+// deliveryIDRegex matches the delivery ids we're willing to use as filenames.
+// GitHub's ids are UUIDs and for GitLab we use either a UUID or a hex hash.
+var deliveryIDRegex = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_delivery_recorder.go DeliveryRecorder
+
+// DeliveryRecorder records which webhook deliveries have been received so
+// that redeliveries from the VCS host aren't processed twice.
+type DeliveryRecorder interface {
+	// RecordDelivery records that the delivery with id was received. It
+	// returns true if id was already recorded less than ttl ago.
+	RecordDelivery(id string, ttl time.Duration) (bool, error)
+	// PruneDeliveries forgets the deliveries recorded ttl or more ago.
+	PruneDeliveries(ttl time.Duration) error
+}
+
+// ArchivedDelivery is a raw webhook request as it was received.
+type ArchivedDelivery struct {
+	// ID is the delivery id, ex. the X-Github-Delivery header.
+	ID         string
+	Header     http.Header
+	Body       []byte
+	ReceivedAt time.Time
+}
+
+// DeliveryArchive stores raw webhook payloads on disk so they can be replayed
+// later for debugging with "atlantis replay".
+type DeliveryArchive struct {
+	// Dir is the directory the payloads are written to.
+	Dir string
+}
+
+// Save writes d to disk, overwriting any previous delivery with the same id.
+// Headers that hold secrets aren't saved.
+func (a *DeliveryArchive) Save(d ArchivedDelivery) error {
+	path, err := a.path(d.ID)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	for name, values := range d.Header {
+		header[name] = values
+	}
+	for _, name := range unarchivedHeaders {
+		header.Del(name)
+	}
+	d.Header = header
+	if err := os.MkdirAll(a.Dir, 0700); err != nil {
+		return errors.Wrap(err, "creating deliveries dir")
+	}
+	serialized, err := json.Marshal(d)
+	if err != nil {
+		return errors.Wrap(err, "serializing delivery")
+	}
+	return errors.Wrapf(ioutil.WriteFile(path, serialized, 0600), "writing %s", path)
+}
+
+// Load reads the delivery with id from disk.
+func (a *DeliveryArchive) Load(id string) (ArchivedDelivery, error) {
+	var d ArchivedDelivery
+	path, err := a.path(id)
+	if err != nil {
+		return d, err
+	}
+	serialized, err := ioutil.ReadFile(path)
+	if err != nil {
+		return d, errors.Wrapf(err, "reading delivery %s", id)
+	}
+	if err := json.Unmarshal(serialized, &d); err != nil {
+		return d, errors.Wrapf(err, "deserializing delivery %s", id)
+	}
+	return d, nil
+}
+
+// Prune removes the deliveries that were saved more than ttl ago so the
+// archive doesn't grow forever.
+func (a *DeliveryArchive) Prune(ttl time.Duration) error {
+	files, err := ioutil.ReadDir(a.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrap(err, "reading deliveries dir")
+	}
+	now := time.Now()
+	for _, f := range files {
+		if filepath.Ext(f.Name()) != ".json" || now.Sub(f.ModTime()) < ttl {
+			continue
+		}
+		if err := os.Remove(filepath.Join(a.Dir, f.Name())); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "removing %s", f.Name())
+		}
+	}
+	return nil
+}
+
+// ReplayToken returns the token that "atlantis replay" sends in the
+// ReplayHeader. It's generated the first time it's needed and stored in the
+// archive dir, which only the Atlantis user can read, so only someone with
+// access to the server's data dir can replay deliveries.
+func (a *DeliveryArchive) ReplayToken() (string, error) {
+	path := filepath.Join(a.Dir, replayTokenFile)
+	token, err := ioutil.ReadFile(path)
+	if err == nil {
+		return string(token), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", errors.Wrap(err, "reading replay token")
+	}
+
+	if err := os.MkdirAll(a.Dir, 0700); err != nil {
+		return "", errors.Wrap(err, "creating deliveries dir")
+	}
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", errors.Wrap(err, "generating replay token")
+	}
+	newToken := hex.EncodeToString(random)
+
+	// We write the token to a temporary file and then link it into place so
+	// that if the server and "atlantis replay" race to create the token, they
+	// both end up using the complete token that was linked first.
+	tmp, err := ioutil.TempFile(a.Dir, replayTokenFile)
+	if err != nil {
+		return "", errors.Wrap(err, "creating replay token")
+	}
+	defer os.Remove(tmp.Name()) // nolint: errcheck
+	_, err = tmp.WriteString(newToken)
+	tmp.Close() // nolint: errcheck
+	if err != nil {
+		return "", errors.Wrap(err, "writing replay token")
+	}
+	if err := os.Link(tmp.Name(), path); err != nil {
+		if os.IsExist(err) {
+			return a.ReplayToken()
+		}
+		return "", errors.Wrap(err, "creating replay token")
+	}
+	return newToken, nil
+}
+
+func (a *DeliveryArchive) path(id string) (string, error) {
+	// The id comes from a request header so we need to make sure it can't
+	// be used to write outside of our directory.
+	if !deliveryIDRegex.MatchString(id) {
+		return "", fmt.Errorf("invalid delivery id %q", id)
+	}
+	return filepath.Join(a.Dir, id+".json"), nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package server_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestDeliveryArchive_SaveLoad(t *testing.T) {
+	t.Log("a saved delivery should be loaded back the same")
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	a := &server.DeliveryArchive{Dir: dir}
+
+	received := time.Now().UTC().Round(time.Second)
+	d := server.ArchivedDelivery{
+		ID:         "72d3162e-cc78-11e3-81ab-4c9367dc0958",
+		Header:     http.Header{"X-Github-Event": []string{"issue_comment"}},
+		Body:       []byte(`{"action": "created"}`),
+		ReceivedAt: received,
+	}
+	Ok(t, a.Save(d))
+	loaded, err := a.Load(d.ID)
+	Ok(t, err)
+	Equals(t, d, loaded)
+}
+
+func TestDeliveryArchive_InvalidID(t *testing.T) {
+	t.Log("ids that could escape the archive dir should be rejected")
+	a := &server.DeliveryArchive{Dir: "/tmp"}
+	err := a.Save(server.ArchivedDelivery{ID: "../../etc/passwd"})
+	ErrEquals(t, `invalid delivery id "../../etc/passwd"`, err)
+	_, err = a.Load("../id")
+	ErrEquals(t, `invalid delivery id "../id"`, err)
+}
+
+func TestDeliveryArchive_LoadMissing(t *testing.T) {
+	t.Log("loading a delivery that doesn't exist should error")
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	a := &server.DeliveryArchive{Dir: dir}
+	_, err = a.Load("abc")
+	Assert(t, err != nil, "exp err")
+}
+
+func TestDeliveryArchive_ReplayToken(t *testing.T) {
+	t.Log("the replay token should be random, private and stay the same once generated")
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	a := &server.DeliveryArchive{Dir: filepath.Join(dir, "deliveries")}
+
+	token, err := a.ReplayToken()
+	Ok(t, err)
+	Equals(t, 64, len(token))
+	again, err := a.ReplayToken()
+	Ok(t, err)
+	Equals(t, token, again)
+	info, err := os.Stat(filepath.Join(a.Dir, "replay-token"))
+	Ok(t, err)
+	Equals(t, os.FileMode(0600), info.Mode().Perm())
+	files, err := ioutil.ReadDir(a.Dir)
+	Ok(t, err)
+	Equals(t, 1, len(files))
+
+	other, err := (&server.DeliveryArchive{Dir: filepath.Join(dir, "other")}).ReplayToken()
+	Ok(t, err)
+	Assert(t, token != other, "exp different archives to have different tokens")
+}
+
+func TestDeliveryArchive_SecretHeaders(t *testing.T) {
+	t.Log("headers that hold secrets shouldn't be saved")
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	a := &server.DeliveryArchive{Dir: dir}
+
+	header := http.Header{}
+	header.Set("X-Gitlab-Event", "Note Hook")
+	header.Set("X-Gitlab-Token", "secret")
+	header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	Ok(t, a.Save(server.ArchivedDelivery{ID: "abc", Header: header}))
+
+	serialized, err := ioutil.ReadFile(filepath.Join(dir, "abc.json"))
+	Ok(t, err)
+	Assert(t, !strings.Contains(string(serialized), "secret"), "exp secret not to be saved")
+	Assert(t, !strings.Contains(string(serialized), "dXNlcjpwYXNz"), "exp authorization not to be saved")
+	loaded, err := a.Load("abc")
+	Ok(t, err)
+	Equals(t, http.Header{"X-Gitlab-Event": []string{"Note Hook"}}, loaded.Header)
+	Equals(t, "secret", header.Get("X-Gitlab-Token"))
+}
+
+func TestDeliveryArchive_Prune(t *testing.T) {
+	t.Log("deliveries older than the ttl should be removed")
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	a := &server.DeliveryArchive{Dir: dir}
+	Ok(t, a.Save(server.ArchivedDelivery{ID: "old"}))
+	Ok(t, a.Save(server.ArchivedDelivery{ID: "new"}))
+	token, err := a.ReplayToken()
+	Ok(t, err)
+	old := time.Now().Add(-25 * time.Hour)
+	Ok(t, os.Chtimes(filepath.Join(dir, "old.json"), old, old))
+	Ok(t, os.Chtimes(filepath.Join(dir, "replay-token"), old, old))
+
+	Ok(t, a.Prune(24*time.Hour))
+	_, err = a.Load("old")
+	Assert(t, err != nil, "exp old delivery to be pruned")
+	_, err = a.Load("new")
+	Ok(t, err)
+	again, err := a.ReplayToken()
+	Ok(t, err)
+	Equals(t, token, again)
+}
+
+func TestDeliveryArchive_PruneMissingDir(t *testing.T) {
+	t.Log("pruning before anything was saved shouldn't error")
+	a := &server.DeliveryArchive{Dir: "/does/not/exist"}
+	Ok(t, a.Prune(time.Hour))
+}
@@ -15,8 +15,11 @@ package events
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
@@ -24,6 +27,7 @@ import (
 	"github.com/runatlantis/atlantis/server/events/terraform"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/metrics"
 )
 
 // ApplyExecutor handles executing terraform apply.
@@ -35,6 +39,14 @@ type ApplyExecutor struct {
 	AtlantisWorkspace AtlantisWorkspace
 	ProjectPreExecute *DefaultProjectPreExecutor
 	Webhooks          webhooks.Sender
+	// ApplyStartWebhooks are sent before each project is applied. If nil,
+	// they aren't sent.
+	ApplyStartWebhooks webhooks.ApplyStartSender
+	// PolicyOwners are the usernames of users that can apply plans that
+	// failed their policy checks by running apply with --override-policies.
+	PolicyOwners []string
+	// Metrics records how long applies take. If nil, they aren't recorded.
+	Metrics *metrics.Metrics
 }
 
 // Execute executes apply for the ctx.
@@ -59,39 +71,39 @@ func (a *ApplyExecutor) Execute(ctx *CommandContext) CommandResponse {
 	// Plans are stored at project roots by their workspace names. We just
 	// need to find them.
 	var plans []models.Plan
-	// If they didn't specify a directory, we apply all plans we can find for
+	// If they didn't specify any projects, we apply all plans we can find for
 	// this workspace.
-	if ctx.Command.Dir == "" {
-		err = filepath.Walk(repoDir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			// Check if the plan is for the right workspace,
-			if !info.IsDir() && info.Name() == ctx.Command.Workspace+".tfplan" {
-				rel, _ := filepath.Rel(repoDir, filepath.Dir(path))
-				plans = append(plans, models.Plan{
-					Project:   models.NewProject(ctx.BaseRepo.FullName, rel),
-					LocalPath: path,
-				})
-			}
-			return nil
-		})
+	if !ctx.Command.selectsProjects() {
+		plans, err = a.findPlans(ctx, repoDir)
 		if err != nil {
-			return CommandResponse{Error: errors.Wrap(err, "finding plans")}
+			return CommandResponse{Error: err}
 		}
 	} else {
-		// If they did specify a dir, we apply just the plan in that directory
-		// for this workspace.
-		planPath := filepath.Join(repoDir, ctx.Command.Dir, ctx.Command.Workspace+".tfplan")
-		stat, err := os.Stat(planPath)
-		if err != nil || stat.IsDir() {
-			return CommandResponse{Error: fmt.Errorf("no plan found at path %q and workspace %q–did you run plan?", ctx.Command.Dir, ctx.Command.Workspace)}
-		}
-		relProjectPath, _ := filepath.Rel(repoDir, filepath.Dir(planPath))
-		plans = append(plans, models.Plan{
-			Project:   models.NewProject(ctx.BaseRepo.FullName, relProjectPath),
-			LocalPath: planPath,
-		})
+		// If they did, we apply just the plans in those projects for this
+		// workspace. Globs match the projects we have plans for.
+		candidates := func() ([]models.Project, error) {
+			allPlans, err := a.findPlans(ctx, repoDir)
+			var projects []models.Project
+			for _, plan := range allPlans {
+				projects = append(projects, plan.Project)
+			}
+			return projects, err
+		}
+		projects, err := selectProjects(ctx.Command, ctx.BaseRepo, repoDir, candidates)
+		if err != nil {
+			return CommandResponse{Failure: err.Error()}
+		}
+		for _, project := range projects {
+			planPath := filepath.Join(repoDir, project.Path, ctx.Command.Workspace+".tfplan")
+			stat, err := os.Stat(planPath)
+			if err != nil || stat.IsDir() {
+				return CommandResponse{Error: fmt.Errorf("no plan found at path %q and workspace %q–did you run plan?", project.Path, ctx.Command.Workspace)}
+			}
+			plans = append(plans, models.Plan{
+				Project:   project,
+				LocalPath: planPath,
+			})
+		}
 	}
 	if len(plans) == 0 {
 		return CommandResponse{Failure: "No plans found for that workspace."}
@@ -102,49 +114,247 @@ func (a *ApplyExecutor) Execute(ctx *CommandContext) CommandResponse {
 	}
 	ctx.Log.Info("found %d plan(s) in our workspace: %v", len(plans), paths)
 
+	dependencies, err := a.readDependencies(repoDir, plans)
+	if err != nil {
+		return CommandResponse{Error: err}
+	}
+	plans, err = orderPlans(plans, dependencies)
+	if err != nil {
+		return CommandResponse{Failure: err.Error()}
+	}
+
 	var results []ProjectResult
+	// failed holds the projects that didn't apply successfully so we can skip
+	// the projects that depend on them.
+	failed := make(map[string]bool)
 	for _, plan := range plans {
-		ctx.Log.Info("running apply for project at path %q", plan.Project.Path)
-		result := a.apply(ctx, repoDir, plan)
-		result.Path = plan.LocalPath
+		var result ProjectResult
+		if dep := firstFailed(dependencies[plan.Project.Path], failed); dep != "" {
+			ctx.Log.Info("skipping apply for project at path %q because %q didn't apply", plan.Project.Path, dep)
+			result = ProjectResult{Skipped: fmt.Sprintf("this project depends on `%s` which didn't apply successfully.", dep)}
+		} else {
+			ctx.Log.Info("running apply for project at path %q", plan.Project.Path)
+			done := logProject(ctx, plan.Project.Path, ctx.Command.Workspace)
+			result = a.apply(ctx, repoDir, plan)
+			done()
+		}
+		if result.Status() != vcs.Success {
+			failed[plan.Project.Path] = true
+		}
+		result.Path = plan.Project.Path
+		result.Workspace = ctx.Command.Workspace
 		results = append(results, result)
 	}
 	return CommandResponse{ProjectResults: results}
 }
 
+// readDependencies returns the depends_on config of each project that has a
+// plan, by project path.
+func (a *ApplyExecutor) readDependencies(repoDir string, plans []models.Plan) (map[string][]string, error) {
+	dependencies := make(map[string][]string)
+	configReader := a.ProjectPreExecute.ConfigReader
+	if configReader == nil {
+		return dependencies, nil
+	}
+	for _, plan := range plans {
+		absolutePath := filepath.Join(repoDir, plan.Project.Path)
+		if !configReader.Exists(absolutePath) {
+			continue
+		}
+		config, err := configReader.Read(absolutePath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading config for project at path %q", plan.Project.Path)
+		}
+		dependencies[plan.Project.Path] = config.DependsOn
+	}
+	return dependencies, nil
+}
+
+// orderPlans sorts plans so each project comes after the projects it depends
+// on. Dependencies on projects without plans are ignored. Otherwise the
+// original order is kept. It returns an error if there's a dependency cycle.
+func orderPlans(plans []models.Plan, dependencies map[string][]string) ([]models.Plan, error) {
+	byPath := make(map[string]models.Plan)
+	for _, plan := range plans {
+		byPath[plan.Project.Path] = plan
+	}
+
+	var ordered []models.Plan
+	// state is 1 while a project's dependencies are being visited and 2 once
+	// it's been added to ordered.
+	state := make(map[string]int)
+	var visit func(p string, chain []string) error
+	visit = func(p string, chain []string) error {
+		switch state[p] {
+		case 1:
+			return fmt.Errorf("projects can't depend on each other in a cycle: %s", strings.Join(append(chain, p), " -> "))
+		case 2:
+			return nil
+		}
+		state[p] = 1
+		for _, dep := range dependencies[p] {
+			if _, ok := byPath[dep]; !ok {
+				continue
+			}
+			if err := visit(dep, append(chain, p)); err != nil {
+				return err
+			}
+		}
+		state[p] = 2
+		ordered = append(ordered, byPath[p])
+		return nil
+	}
+	for _, plan := range plans {
+		if err := visit(plan.Project.Path, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// firstFailed returns the first of deps that's in failed or "" if none are.
+func firstFailed(deps []string, failed map[string]bool) string {
+	for _, dep := range deps {
+		if failed[dep] {
+			return dep
+		}
+	}
+	return ""
+}
+
+// findPlans returns all the plans in repoDir for the command's workspace.
+func (a *ApplyExecutor) findPlans(ctx *CommandContext, repoDir string) ([]models.Plan, error) {
+	var plans []models.Plan
+	err := filepath.Walk(repoDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Check if the plan is for the right workspace,
+		if !info.IsDir() && info.Name() == ctx.Command.Workspace+".tfplan" {
+			rel, _ := filepath.Rel(repoDir, filepath.Dir(path))
+			plans = append(plans, models.Plan{
+				Project:   models.NewProject(ctx.BaseRepo.Hostname, ctx.BaseRepo.FullName, rel),
+				LocalPath: path,
+			})
+		}
+		return nil
+	})
+	return plans, errors.Wrap(err, "finding plans")
+}
+
 func (a *ApplyExecutor) apply(ctx *CommandContext, repoDir string, plan models.Plan) ProjectResult {
+	if failure := a.checkPolicyFailures(ctx, plan); failure != "" {
+		return ProjectResult{Failure: failure}
+	}
+	if failure := a.checkDestroyConfirmation(ctx, plan); failure != "" {
+		return ProjectResult{Failure: failure}
+	}
 	preExecute := a.ProjectPreExecute.Execute(ctx, repoDir, plan.Project)
-	if preExecute.ProjectResult != (ProjectResult{}) {
+	if preExecute.ProjectResult.Error != nil || preExecute.ProjectResult.Failure != "" {
 		return preExecute.ProjectResult
 	}
 	config := preExecute.ProjectConfig
 	terraformVersion := preExecute.TerraformVersion
+	hookOutputs := preExecute.HookOutputs
 
-	applyExtraArgs := config.GetExtraArguments(ctx.Command.Name.String())
 	absolutePath := filepath.Join(repoDir, plan.Project.Path)
 	workspace := ctx.Command.Workspace
-	tfApplyCmd := append(append(append([]string{"apply", "-no-color"}, applyExtraArgs...), ctx.Command.Flags...), plan.LocalPath)
-	output, err := a.Terraform.RunCommandWithVersion(ctx.Log, absolutePath, tfApplyCmd, terraformVersion, workspace)
+	if a.ApplyStartWebhooks != nil {
+		a.ApplyStartWebhooks.SendApplyStart(ctx.Log, webhooks.ApplyStart{ // nolint: errcheck
+			Repo:      ctx.BaseRepo,
+			Pull:      ctx.Pull,
+			User:      ctx.User,
+			Path:      plan.Project.Path,
+			Workspace: workspace,
+		})
+	}
+	var output string
+	var err error
+	start := time.Now()
+	if config.Workflow != nil {
+		w := &workflowRun{
+			ctx:       ctx,
+			terraform: a.Terraform,
+			run:       a.Run,
+			config:    config,
+			env:       runEnv(ctx, repoDir, plan.Project.Path, terraformVersion),
+		}
+		output, err = w.runSteps(config.Workflow.ApplySteps())
+	} else {
+		applyExtraArgs := config.GetExtraArguments(ctx.Command.Name.String())
+		tfApplyCmd := append(append(append([]string{"apply", "-no-color"}, applyExtraArgs...), ctx.Command.Flags...), plan.LocalPath)
+		output, err = a.Terraform.RunCommandWithVersion(ctx.Log, absolutePath, tfApplyCmd, terraformVersion, workspace)
+		if err != nil {
+			err = fmt.Errorf("%s\n%s", err.Error(), output)
+		}
+	}
+	a.Metrics.TerraformRan("apply", ctx.BaseRepo.FullName, time.Since(start))
 
-	a.Webhooks.Send(ctx.Log, webhooks.ApplyResult{ // nolint: errcheck
+	applyResult := webhooks.ApplyResult{
+		Path:      plan.Project.Path,
 		Workspace: workspace,
 		User:      ctx.User,
 		Repo:      ctx.BaseRepo,
 		Pull:      ctx.Pull,
 		Success:   err == nil,
-	})
+	}
+	if err == nil {
+		applyResult.Summary = ctx.Redactor.Redact(outputSummary(output, ""))
+	}
+	a.Webhooks.Send(ctx.Log, applyResult) // nolint: errcheck
 
 	if err != nil {
-		return ProjectResult{Error: fmt.Errorf("%s\n%s", err.Error(), output)}
+		return ProjectResult{Error: err, HookOutputs: hookOutputs}
 	}
 	ctx.Log.Info("apply succeeded")
 
 	if len(config.PostApply) > 0 {
-		_, err := a.Run.Execute(ctx.Log, config.PostApply, absolutePath, workspace, terraformVersion, "post_apply")
+		hookOutputs, err = runHook(a.Run, ctx, config, config.PostApply, runEnv(ctx, repoDir, plan.Project.Path, terraformVersion), "post_apply", hookOutputs)
 		if err != nil {
-			return ProjectResult{Error: errors.Wrap(err, "running post apply commands")}
+			return ProjectResult{Error: errors.Wrap(err, "running post apply commands"), HookOutputs: hookOutputs}
 		}
 	}
 
-	return ProjectResult{ApplySuccess: output}
+	return ProjectResult{ApplySuccess: output, HookOutputs: hookOutputs}
+}
+
+// checkPolicyFailures returns a failure message if plan failed its policy
+// checks and the user can't override them. Otherwise it returns an empty
+// string.
+func (a *ApplyExecutor) checkPolicyFailures(ctx *CommandContext, plan models.Plan) string {
+	failures, err := ioutil.ReadFile(policyFailuresFile(plan.LocalPath))
+	if os.IsNotExist(err) {
+		return ""
+	}
+	if err != nil {
+		return fmt.Sprintf("Unable to read policy check results: %s.", err)
+	}
+	if !ctx.Command.OverridePolicies {
+		return fmt.Sprintf("This plan failed its policy checks:\n```\n%s```\nFix the violations and run plan again or ask a policy owner to run apply with --%s.", failures, OverridePoliciesFlagLong)
+	}
+	for _, owner := range a.PolicyOwners {
+		if owner == ctx.User.Username {
+			ctx.Log.Info("policy owner %q is overriding failed policy checks for %q", ctx.User.Username, plan.Project.Path)
+			return ""
+		}
+	}
+	return fmt.Sprintf("Only policy owners can override failed policy checks and %q isn't one.", ctx.User.Username)
+}
+
+// checkDestroyConfirmation returns a failure message if plan destroys more
+// resources than its project allows and the user didn't confirm that by
+// running apply with --allow-destroy. Otherwise it returns an empty string.
+func (a *ApplyExecutor) checkDestroyConfirmation(ctx *CommandContext, plan models.Plan) string {
+	destroys, err := ioutil.ReadFile(destroyConfirmationFile(plan.LocalPath))
+	if os.IsNotExist(err) {
+		return ""
+	}
+	if err != nil {
+		return fmt.Sprintf("Unable to read destroy confirmation: %s.", err)
+	}
+	if !ctx.Command.AllowDestroy {
+		return fmt.Sprintf("This plan destroys %s resource(s). To confirm, run apply with --%s.", destroys, AllowDestroyFlagLong)
+	}
+	ctx.Log.Info("%q confirmed destroying %s resource(s) in %q", ctx.User.Username, destroys, plan.Project.Path)
+	return ""
 }
@@ -0,0 +1,155 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/locking"
+	lmocks "github.com/runatlantis/atlantis/server/events/locking/mocks"
+	lmatchers "github.com/runatlantis/atlantis/server/events/locking/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestApplyExecute_PolicyCheckFailed(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	planFile := filepath.Join(tmp, "default.tfplan")
+	Ok(t, ioutil.WriteFile(planFile, nil, 0600))
+	Ok(t, ioutil.WriteFile(planFile+".policy-failures", []byte("null_resource.a: no deletes\n"), 0600))
+
+	w := mocks.NewMockAtlantisWorkspace()
+	When(w.GetWorkspace(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString())).ThenReturn(tmp, nil)
+	locker := lmocks.NewMockLocker()
+	When(locker.TryLock(lmatchers.AnyModelsProject(), AnyString(), lmatchers.AnyModelsPullRequest(), lmatchers.AnyModelsUser())).
+		ThenReturn(locking.TryLockResponse{}, errors.New("lock err"))
+	a := events.ApplyExecutor{
+		AtlantisWorkspace: w,
+		ProjectPreExecute: &events.DefaultProjectPreExecutor{Locker: locker},
+		PolicyOwners:      []string{"owner"},
+	}
+	ctx := func(user string, override bool) *events.CommandContext {
+		return &events.CommandContext{
+			Command: &events.Command{Name: events.Apply, Workspace: "default", OverridePolicies: override},
+			Log:     logging.NewNoopLogger(),
+			User:    models.User{Username: user},
+		}
+	}
+
+	t.Log("should refuse to apply without an override")
+	r := a.Execute(ctx("owner", false))
+	Equals(t, 1, len(r.ProjectResults))
+	Assert(t, strings.Contains(r.ProjectResults[0].Failure, "null_resource.a: no deletes"), "exp failure to contain violations, got %q", r.ProjectResults[0].Failure)
+	locker.VerifyWasCalled(Never()).TryLock(lmatchers.AnyModelsProject(), AnyString(), lmatchers.AnyModelsPullRequest(), lmatchers.AnyModelsUser())
+
+	t.Log("should refuse to let users that aren't policy owners override")
+	r = a.Execute(ctx("someone", true))
+	Equals(t, `Only policy owners can override failed policy checks and "someone" isn't one.`, r.ProjectResults[0].Failure)
+
+	t.Log("should continue applying if a policy owner overrides")
+	r = a.Execute(ctx("owner", true))
+	ErrEquals(t, "acquiring lock: lock err", r.ProjectResults[0].Error)
+}
+
+func TestApplyExecute_DestroyConfirmation(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	planFile := filepath.Join(tmp, "default.tfplan")
+	Ok(t, ioutil.WriteFile(planFile, nil, 0600))
+	Ok(t, ioutil.WriteFile(planFile+".destroys", []byte("3"), 0600))
+
+	w := mocks.NewMockAtlantisWorkspace()
+	When(w.GetWorkspace(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString())).ThenReturn(tmp, nil)
+	locker := lmocks.NewMockLocker()
+	When(locker.TryLock(lmatchers.AnyModelsProject(), AnyString(), lmatchers.AnyModelsPullRequest(), lmatchers.AnyModelsUser())).
+		ThenReturn(locking.TryLockResponse{}, errors.New("lock err"))
+	a := events.ApplyExecutor{
+		AtlantisWorkspace: w,
+		ProjectPreExecute: &events.DefaultProjectPreExecutor{Locker: locker},
+	}
+	ctx := func(allowDestroy bool) *events.CommandContext {
+		return &events.CommandContext{
+			Command: &events.Command{Name: events.Apply, Workspace: "default", AllowDestroy: allowDestroy},
+			Log:     logging.NewNoopLogger(),
+			User:    models.User{Username: "user"},
+		}
+	}
+
+	t.Log("should refuse to apply without --allow-destroy")
+	r := a.Execute(ctx(false))
+	Equals(t, 1, len(r.ProjectResults))
+	Equals(t, "This plan destroys 3 resource(s). To confirm, run apply with --allow-destroy.", r.ProjectResults[0].Failure)
+	locker.VerifyWasCalled(Never()).TryLock(lmatchers.AnyModelsProject(), AnyString(), lmatchers.AnyModelsPullRequest(), lmatchers.AnyModelsUser())
+
+	t.Log("should continue applying with --allow-destroy")
+	r = a.Execute(ctx(true))
+	ErrEquals(t, "acquiring lock: lock err", r.ProjectResults[0].Error)
+}
+
+func TestApplyExecute_DependsOn(t *testing.T) {
+	RegisterMockTestingT(t)
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	for _, dir := range []string{"app", "network", "other"} {
+		Ok(t, os.MkdirAll(filepath.Join(tmp, dir), 0700))
+		Ok(t, ioutil.WriteFile(filepath.Join(tmp, dir, "default.tfplan"), nil, 0600))
+	}
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "app", "atlantis.yaml"), []byte("depends_on: [network]"), 0600))
+
+	w := mocks.NewMockAtlantisWorkspace()
+	When(w.GetWorkspace(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), AnyString())).ThenReturn(tmp, nil)
+	locker := lmocks.NewMockLocker()
+	When(locker.TryLock(lmatchers.AnyModelsProject(), AnyString(), lmatchers.AnyModelsPullRequest(), lmatchers.AnyModelsUser())).
+		ThenReturn(locking.TryLockResponse{}, errors.New("lock err"))
+	a := events.ApplyExecutor{
+		AtlantisWorkspace: w,
+		ProjectPreExecute: &events.DefaultProjectPreExecutor{Locker: locker, ConfigReader: &events.ProjectConfigManager{}},
+	}
+	ctx := &events.CommandContext{
+		Command: &events.Command{Name: events.Apply, Workspace: "default"},
+		Log:     logging.NewNoopLogger(),
+		User:    models.User{Username: "user"},
+	}
+
+	t.Log("network should be applied before app and app should be skipped when network fails")
+	r := a.Execute(ctx)
+	Equals(t, 3, len(r.ProjectResults))
+	Equals(t, "network", r.ProjectResults[0].Path)
+	ErrEquals(t, "acquiring lock: lock err", r.ProjectResults[0].Error)
+	Equals(t, "app", r.ProjectResults[1].Path)
+	Equals(t, "this project depends on `network` which didn't apply successfully.", r.ProjectResults[1].Skipped)
+	Equals(t, "other", r.ProjectResults[2].Path)
+	ErrEquals(t, "acquiring lock: lock err", r.ProjectResults[2].Error)
+
+	t.Log("a dependency cycle should fail the apply")
+	Ok(t, ioutil.WriteFile(filepath.Join(tmp, "network", "atlantis.yaml"), []byte("depends_on: [app]"), 0600))
+	r = a.Execute(ctx)
+	Equals(t, "projects can't depend on each other in a cycle: app -> network -> app", r.Failure)
+}
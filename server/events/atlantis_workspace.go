@@ -14,10 +14,13 @@
 package events
 
 import (
+	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
@@ -52,6 +55,9 @@ func (w *FileWorkspace) Clone(
 	headRepo models.Repo,
 	p models.PullRequest,
 	workspace string) (string, error) {
+	if !validWorkspace(workspace) {
+		return "", fmt.Errorf("invalid workspace: %q", workspace)
+	}
 	cloneDir := w.cloneDir(baseRepo, p, workspace)
 
 	// This is safe to do because we lock runs on repo/pull/workspace so no one else
@@ -85,6 +91,9 @@ func (w *FileWorkspace) Clone(
 
 // GetWorkspace returns the path to the workspace for this repo and pull.
 func (w *FileWorkspace) GetWorkspace(r models.Repo, p models.PullRequest, workspace string) (string, error) {
+	if !validWorkspace(workspace) {
+		return "", fmt.Errorf("invalid workspace: %q", workspace)
+	}
 	repoDir := w.cloneDir(r, p, workspace)
 	if _, err := os.Stat(repoDir); err != nil {
 		return "", errors.Wrap(err, "checking if workspace exists")
@@ -97,10 +106,22 @@ func (w *FileWorkspace) Delete(r models.Repo, p models.PullRequest) error {
 	return os.RemoveAll(w.repoPullDir(r, p))
 }
 
+// repoPullDir returns the directory all workspaces for this pull are cloned
+// into. It includes the hostname so repos with the same name on different VCS
+// hosts don't collide.
 func (w *FileWorkspace) repoPullDir(r models.Repo, p models.PullRequest) string {
-	return filepath.Join(w.DataDir, workspacePrefix, r.FullName, strconv.Itoa(p.Num))
+	return filepath.Join(w.DataDir, workspacePrefix, r.Hostname, r.FullName, strconv.Itoa(p.Num))
 }
 
 func (w *FileWorkspace) cloneDir(r models.Repo, p models.PullRequest, workspace string) string {
 	return filepath.Join(w.repoPullDir(r, p), workspace)
 }
+
+// validWorkspace returns true if workspace can be used as a Terraform
+// workspace. It uses the same validation that Terraform uses:
+// https://git.io/vxGhU. Plus we also don't allow '..', '.' or empty names. We
+// don't want the workspace to contain a path since we create files based on
+// the name.
+func validWorkspace(workspace string) bool {
+	return workspace != "" && workspace != "." && workspace == url.PathEscape(workspace) && !strings.Contains(workspace, "..")
+}
@@ -29,10 +29,12 @@ import (
 // Terraform concept of workspaces, not directories on disk managed by Atlantis.
 type AtlantisWorkspaceLocker interface {
 	// TryLock tries to acquire a lock for this repo, workspace and pull.
-	TryLock(repoFullName string, workspace string, pullNum int) bool
+	// hostname is the repo's VCS hostname since repos with the same name can
+	// be on different hosts.
+	TryLock(hostname string, repoFullName string, workspace string, pullNum int) bool
 	// Unlock deletes the lock for this repo, workspace and pull. If there was no
 	// lock it will do nothing.
-	Unlock(repoFullName, workspace string, pullNum int)
+	Unlock(hostname string, repoFullName, workspace string, pullNum int)
 }
 
 // DefaultAtlantisWorkspaceLocker implements AtlantisWorkspaceLocker.
@@ -50,11 +52,11 @@ func NewDefaultAtlantisWorkspaceLocker() *DefaultAtlantisWorkspaceLocker {
 
 // TryLock returns true if a lock is acquired for this repo, pull and workspace and
 // false otherwise.
-func (d *DefaultAtlantisWorkspaceLocker) TryLock(repoFullName string, workspace string, pullNum int) bool {
+func (d *DefaultAtlantisWorkspaceLocker) TryLock(hostname string, repoFullName string, workspace string, pullNum int) bool {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 
-	key := d.key(repoFullName, workspace, pullNum)
+	key := d.key(hostname, repoFullName, workspace, pullNum)
 	if _, ok := d.locks[key]; !ok {
 		d.locks[key] = true
 		return true
@@ -63,12 +65,12 @@ func (d *DefaultAtlantisWorkspaceLocker) TryLock(repoFullName string, workspace
 }
 
 // Unlock unlocks the repo, pull and workspace.
-func (d *DefaultAtlantisWorkspaceLocker) Unlock(repoFullName, workspace string, pullNum int) {
+func (d *DefaultAtlantisWorkspaceLocker) Unlock(hostname string, repoFullName, workspace string, pullNum int) {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
-	delete(d.locks, d.key(repoFullName, workspace, pullNum))
+	delete(d.locks, d.key(hostname, repoFullName, workspace, pullNum))
 }
 
-func (d *DefaultAtlantisWorkspaceLocker) key(repo string, workspace string, pull int) string {
-	return fmt.Sprintf("%s/%s/%d", repo, workspace, pull)
+func (d *DefaultAtlantisWorkspaceLocker) key(hostname string, repo string, workspace string, pull int) string {
+	return fmt.Sprintf("%s/%s/%s/%d", hostname, repo, workspace, pull)
 }
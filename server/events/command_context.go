@@ -14,9 +14,15 @@
 package events
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/redact"
 )
 
 // CommandContext represents the context of a command that came from a comment
@@ -36,4 +42,41 @@ type CommandContext struct {
 	Log     *logging.SimpleLogger
 	// VCSHost is the host that the command came from.
 	VCSHost vcs.Host
+	// Trace identifies the webhook and run that the command is for.
+	Trace Trace
+	// Redactor masks the server's secrets and the ones found while running
+	// the command, ex. the values of sensitive variables, in its output.
+	// It's a child of the server's Redactor so the command's secrets are
+	// forgotten once it finishes. If nil, nothing is masked.
+	Redactor *redact.Redactor
+}
+
+// logProject adds fields for the project at projectPath and workspace to
+// the entries ctx logs until the returned function is called.
+func logProject(ctx *CommandContext, projectPath string, workspace string) (done func()) {
+	ctx.Log.SetField("project", projectPath)
+	ctx.Log.SetField("workspace", workspace)
+	return func() {
+		ctx.Log.SetField("project", "")
+		ctx.Log.SetField("workspace", ctx.Command.Workspace)
+	}
+}
+
+// Trace identifies the webhook delivery that a command came from and the
+// run of the command so everything it logged can be found.
+type Trace struct {
+	// DeliveryID is the VCS host's ID for the webhook delivery.
+	DeliveryID string
+	// RunID is generated for each command that's run.
+	RunID string
+}
+
+// NewTrace returns a trace for deliveryID with a new run ID.
+func NewTrace(deliveryID string) Trace {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// Fall back to something that's still unlikely to collide.
+		return Trace{DeliveryID: deliveryID, RunID: fmt.Sprintf("%x", time.Now().UnixNano())}
+	}
+	return Trace{DeliveryID: deliveryID, RunID: hex.EncodeToString(b)}
 }
@@ -15,6 +15,8 @@ package events
 
 import (
 	"fmt"
+	"log"
+	"strconv"
 
 	"github.com/google/go-github/github"
 	"github.com/lkysow/go-gitlab"
@@ -22,7 +24,9 @@ import (
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/metrics"
 	"github.com/runatlantis/atlantis/server/recovery"
+	"github.com/runatlantis/atlantis/server/redact"
 )
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_command_runner.go CommandRunner
@@ -32,7 +36,8 @@ type CommandRunner interface {
 	// ExecuteCommand is the first step after a command request has been parsed.
 	// It handles gathering additional information needed to execute the command
 	// and then calling the appropriate services to finish executing the command.
-	ExecuteCommand(baseRepo models.Repo, headRepo models.Repo, user models.User, pullNum int, cmd *Command, vcsHost vcs.Host)
+	// trace identifies the webhook the command came from in the logs.
+	ExecuteCommand(baseRepo models.Repo, headRepo models.Repo, user models.User, pullNum int, cmd *Command, vcsHost vcs.Host, trace Trace)
 }
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_github_pull_getter.go GithubPullGetter
@@ -48,7 +53,7 @@ type GithubPullGetter interface {
 // GitlabMergeRequestGetter makes API calls to get merge requests.
 type GitlabMergeRequestGetter interface {
 	// GetMergeRequest gets the pull request with the id pullNum for the repo.
-	GetMergeRequest(repoFullName string, pullNum int) (*gitlab.MergeRequest, error)
+	GetMergeRequest(repo models.Repo, pullNum int) (*gitlab.MergeRequest, error)
 }
 
 // CommandHandler is the first step when processing a comment command.
@@ -60,16 +65,25 @@ type CommandHandler struct {
 	GithubPullGetter         GithubPullGetter
 	GitlabMergeRequestGetter GitlabMergeRequestGetter
 	CommitStatusUpdater      CommitStatusUpdater
-	EventParser              EventParsing
-	AtlantisWorkspaceLocker  AtlantisWorkspaceLocker
-	MarkdownRenderer         *MarkdownRenderer
-	Logger                   logging.SimpleLogging
+	// GithubChecks creates GitHub check runs for each project. If nil, check
+	// runs aren't created.
+	GithubChecks            *GithubChecksUpdater
+	EventParser             EventParsing
+	AtlantisWorkspaceLocker AtlantisWorkspaceLocker
+	MarkdownRenderer        *MarkdownRenderer
+	Logger                  logging.SimpleLogging
 	// AllowForkPRs controls whether we operate on pull requests from forks.
 	AllowForkPRs bool
 	// AllowForkPRsFlag is the name of the flag that controls fork PR's. We use
 	// this in our error message back to the user on a forked PR so they know
 	// how to enable this functionality.
 	AllowForkPRsFlag string
+	// Metrics records the commands that are run. If nil, they aren't
+	// recorded.
+	Metrics *metrics.Metrics
+	// Redactor masks secrets in the logs and comments of commands. Each
+	// command gets a child of it. If nil, they aren't masked.
+	Redactor *redact.Redactor
 }
 
 // ExecuteCommand executes the command.
@@ -77,16 +91,20 @@ type CommandHandler struct {
 // to get the headRepo. This is because the caller is unable to pass in a
 // headRepo since there's not enough data available on the initial webhook
 // payload.
-func (c *CommandHandler) ExecuteCommand(baseRepo models.Repo, headRepo models.Repo, user models.User, pullNum int, cmd *Command, vcsHost vcs.Host) {
+func (c *CommandHandler) ExecuteCommand(baseRepo models.Repo, headRepo models.Repo, user models.User, pullNum int, cmd *Command, vcsHost vcs.Host, trace Trace) {
+	c.Metrics.CommandStarted()
+	defer c.Metrics.CommandEnded()
+
 	var err error
 	var pull models.PullRequest
 	if vcsHost == vcs.Github {
 		pull, headRepo, err = c.getGithubData(baseRepo, pullNum)
 	} else if vcsHost == vcs.Gitlab {
-		pull, err = c.getGitlabData(baseRepo.FullName, pullNum)
+		pull, err = c.getGitlabData(baseRepo, pullNum)
 	}
 
-	log := c.buildLogger(baseRepo.FullName, pullNum)
+	redactor := c.Redactor.Child()
+	log := c.buildLogger(baseRepo.FullName, pullNum, cmd, trace, redactor)
 	if err != nil {
 		log.Err(err.Error())
 		return
@@ -99,6 +117,8 @@ func (c *CommandHandler) ExecuteCommand(baseRepo models.Repo, headRepo models.Re
 		Command:  cmd,
 		VCSHost:  vcsHost,
 		BaseRepo: baseRepo,
+		Trace:    trace,
+		Redactor: redactor,
 	}
 	c.run(ctx)
 }
@@ -118,11 +138,11 @@ func (c *CommandHandler) getGithubData(baseRepo models.Repo, pullNum int) (model
 	return pull, repo, nil
 }
 
-func (c *CommandHandler) getGitlabData(repoFullName string, pullNum int) (models.PullRequest, error) {
+func (c *CommandHandler) getGitlabData(repo models.Repo, pullNum int) (models.PullRequest, error) {
 	if c.GitlabMergeRequestGetter == nil {
 		return models.PullRequest{}, errors.New("Atlantis not configured to support GitLab")
 	}
-	mr, err := c.GitlabMergeRequestGetter.GetMergeRequest(repoFullName, pullNum)
+	mr, err := c.GitlabMergeRequestGetter.GetMergeRequest(repo, pullNum)
 	if err != nil {
 		return models.PullRequest{}, errors.Wrap(err, "making merge request API call to GitLab")
 	}
@@ -130,9 +150,31 @@ func (c *CommandHandler) getGitlabData(repoFullName string, pullNum int) (models
 	return pull, nil
 }
 
-func (c *CommandHandler) buildLogger(repoFullName string, pullNum int) *logging.SimpleLogger {
+// buildLogger returns a logger for a command. In the JSON format, each entry
+// has fields for the repo, pull request, command and trace so everything a
+// comment triggered can be found. cmd can be nil. Entries are masked by
+// redactor, if it isn't nil, before they're written.
+func (c *CommandHandler) buildLogger(repoFullName string, pullNum int, cmd *Command, trace Trace, redactor *redact.Redactor) *logging.SimpleLogger {
 	src := fmt.Sprintf("%s#%d", repoFullName, pullNum)
-	return logging.NewSimpleLogger(src, c.Logger.Underlying(), true, c.Logger.GetLevel())
+	log := logging.NewSimpleLogger(src, redactedLogger(c.Logger.Underlying(), redactor), true, c.Logger.GetLevel())
+	log.Format = c.Logger.GetFormat()
+	log = log.With("repo", repoFullName).
+		With("pull", strconv.Itoa(pullNum)).
+		With("delivery_id", trace.DeliveryID).
+		With("run_id", trace.RunID)
+	if cmd != nil {
+		log = log.With("command", cmd.Name.String()).With("workspace", cmd.Workspace)
+	}
+	return log
+}
+
+// redactedLogger returns a logger that writes to the same place as l after
+// masking the secrets of redactor. It returns l if redactor is nil.
+func redactedLogger(l *log.Logger, redactor *redact.Redactor) *log.Logger {
+	if redactor == nil {
+		return l
+	}
+	return log.New(redactor.Writer(l.Writer()), l.Prefix(), l.Flags())
 }
 
 // SetLockURL sets a function that's used to return the URL for a lock.
@@ -141,8 +183,6 @@ func (c *CommandHandler) SetLockURL(f func(id string) (url string)) {
 }
 
 func (c *CommandHandler) run(ctx *CommandContext) {
-	log := c.buildLogger(ctx.BaseRepo.FullName, ctx.Pull.Num)
-	ctx.Log = log
 	defer c.logPanics(ctx)
 
 	if !c.AllowForkPRs && ctx.HeadRepo.Owner != ctx.BaseRepo.Owner {
@@ -160,7 +200,7 @@ func (c *CommandHandler) run(ctx *CommandContext) {
 	if err := c.CommitStatusUpdater.Update(ctx.BaseRepo, ctx.Pull, vcs.Pending, ctx.Command, ctx.VCSHost); err != nil {
 		ctx.Log.Warn("unable to update commit status: %s", err)
 	}
-	if !c.AtlantisWorkspaceLocker.TryLock(ctx.BaseRepo.FullName, ctx.Command.Workspace, ctx.Pull.Num) {
+	if !c.AtlantisWorkspaceLocker.TryLock(ctx.BaseRepo.Hostname, ctx.BaseRepo.FullName, ctx.Command.Workspace, ctx.Pull.Num) {
 		errMsg := fmt.Sprintf(
 			"The %s workspace is currently locked by another"+
 				" command that is running for this pull request."+
@@ -170,7 +210,7 @@ func (c *CommandHandler) run(ctx *CommandContext) {
 		c.updatePull(ctx, CommandResponse{Failure: errMsg})
 		return
 	}
-	defer c.AtlantisWorkspaceLocker.Unlock(ctx.BaseRepo.FullName, ctx.Command.Workspace, ctx.Pull.Num)
+	defer c.AtlantisWorkspaceLocker.Unlock(ctx.BaseRepo.Hostname, ctx.BaseRepo.FullName, ctx.Command.Workspace, ctx.Pull.Num)
 
 	var cr CommandResponse
 	switch ctx.Command.Name {
@@ -191,21 +231,47 @@ func (c *CommandHandler) updatePull(ctx *CommandContext, res CommandResponse) {
 	} else if res.Failure != "" {
 		ctx.Log.Warn(res.Failure)
 	}
+	c.Metrics.CommandFinished(ctx.Command.Name.String(), commandResult(res))
 
 	// Update the pull request's status icon and comment back.
 	if err := c.CommitStatusUpdater.UpdateProjectResult(ctx, res); err != nil {
 		ctx.Log.Warn("unable to update commit status: %s", err)
 	}
-	comment := c.MarkdownRenderer.Render(res, ctx.Command.Name, ctx.Log.History.String(), ctx.Command.Verbose)
+	if c.GithubChecks != nil && ctx.VCSHost == vcs.Github {
+		if err := c.GithubChecks.Update(ctx, res); err != nil {
+			ctx.Log.Warn("unable to create check runs: %s", err)
+		}
+	}
+	comment := ctx.Redactor.Redact(c.MarkdownRenderer.Render(res, ctx.Command.Name, ctx.Log.History.String(), ctx.Command.Verbose))
 	c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, comment, ctx.VCSHost) // nolint: errcheck
 }
 
+// commandResult returns the result of res for metrics.
+func commandResult(res CommandResponse) string {
+	if res.Error != nil {
+		return metrics.ErrorResult
+	}
+	if res.Failure != "" {
+		return metrics.FailureResult
+	}
+	result := metrics.SuccessResult
+	for _, p := range res.ProjectResults {
+		if p.Error != nil {
+			return metrics.ErrorResult
+		}
+		if p.Status() == vcs.Failed {
+			result = metrics.FailureResult
+		}
+	}
+	return result
+}
+
 // logPanics logs and creates a comment on the pull request for panics.
 func (c *CommandHandler) logPanics(ctx *CommandContext) {
 	if err := recover(); err != nil {
 		stack := recovery.Stack(3)
 		c.VCSClient.CreateComment(ctx.BaseRepo, ctx.Pull.Num, // nolint: errcheck
-			fmt.Sprintf("**Error: goroutine panic. This is a bug.**\n```\n%s\n%s```", err, stack), ctx.VCSHost)
+			ctx.Redactor.Redact(fmt.Sprintf("**Error: goroutine panic. This is a bug.**\n```\n%s\n%s```", err, stack)), ctx.VCSHost)
 		ctx.Log.Err("PANIC: %s\n%s", err, stack)
 	}
 }
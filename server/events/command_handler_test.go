@@ -15,6 +15,7 @@ package events_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"log"
 	"strings"
@@ -29,7 +30,9 @@ import (
 	"github.com/runatlantis/atlantis/server/events/models/fixtures"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
 	logmocks "github.com/runatlantis/atlantis/server/logging/mocks"
+	"github.com/runatlantis/atlantis/server/redact"
 	. "github.com/runatlantis/atlantis/testing"
 )
 
@@ -67,9 +70,9 @@ func setup(t *testing.T) {
 		MarkdownRenderer:         &events.MarkdownRenderer{},
 		GithubPullGetter:         githubGetter,
 		GitlabMergeRequestGetter: gitlabGetter,
-		Logger:           logger,
-		AllowForkPRs:     false,
-		AllowForkPRsFlag: "allow-fork-prs-flag",
+		Logger:                   logger,
+		AllowForkPRs:             false,
+		AllowForkPRsFlag:         "allow-fork-prs-flag",
 	}
 }
 
@@ -79,7 +82,7 @@ func TestExecuteCommand_LogPanics(t *testing.T) {
 	ch.AllowForkPRs = true // Lets us get to the panic code.
 	defer func() { ch.AllowForkPRs = false }()
 	When(ghStatus.Update(fixtures.Repo, fixtures.Pull, vcs.Pending, nil, vcs.Github)).ThenPanic("panic")
-	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, 1, nil, vcs.Github)
+	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, 1, nil, vcs.Github, events.Trace{})
 	_, _, comment, _ := vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), matchers.AnyVcsHost()).GetCapturedArguments()
 	Assert(t, strings.Contains(comment, "Error: goroutine panic"), "comment should be about a goroutine panic")
 }
@@ -88,7 +91,7 @@ func TestExecuteCommand_NoGithubPullGetter(t *testing.T) {
 	t.Log("if CommandHandler was constructed with a nil GithubPullGetter an error should be logged")
 	setup(t)
 	ch.GithubPullGetter = nil
-	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, 1, nil, vcs.Github)
+	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, 1, nil, vcs.Github, events.Trace{})
 	Equals(t, "[ERROR] runatlantis/atlantis#1: Atlantis not configured to support GitHub\n", logBytes.String())
 }
 
@@ -96,23 +99,47 @@ func TestExecuteCommand_NoGitlabMergeGetter(t *testing.T) {
 	t.Log("if CommandHandler was constructed with a nil GitlabMergeRequestGetter an error should be logged")
 	setup(t)
 	ch.GitlabMergeRequestGetter = nil
-	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, 1, nil, vcs.Gitlab)
+	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, 1, nil, vcs.Gitlab, events.Trace{})
 	Equals(t, "[ERROR] runatlantis/atlantis#1: Atlantis not configured to support GitLab\n", logBytes.String())
 }
 
+func TestExecuteCommand_JSONLogs(t *testing.T) {
+	t.Log("in the JSON format, log entries should have fields for the pull request, command and trace")
+	setup(t)
+	When(ch.Logger.GetFormat()).ThenReturn(logging.JSON)
+	ch.GithubPullGetter = nil
+	cmd := events.Command{Name: events.Plan, Workspace: "default"}
+	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, 1, &cmd, vcs.Github, events.Trace{DeliveryID: "delivery", RunID: "run"})
+
+	var entry map[string]string
+	Ok(t, json.Unmarshal(logBytes.Bytes(), &entry))
+	delete(entry, "time")
+	Equals(t, map[string]string{
+		"level":       "error",
+		"source":      "runatlantis/atlantis#1",
+		"msg":         "Atlantis not configured to support GitHub",
+		"repo":        "runatlantis/atlantis",
+		"pull":        "1",
+		"command":     "plan",
+		"workspace":   "default",
+		"delivery_id": "delivery",
+		"run_id":      "run",
+	}, entry)
+}
+
 func TestExecuteCommand_GithubPullErr(t *testing.T) {
 	t.Log("if getting the github pull request fails an error should be logged")
 	setup(t)
 	When(githubGetter.GetPullRequest(fixtures.Repo, fixtures.Pull.Num)).ThenReturn(nil, errors.New("err"))
-	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, nil, vcs.Github)
+	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, nil, vcs.Github, events.Trace{})
 	Equals(t, "[ERROR] runatlantis/atlantis#1: Making pull request API call to GitHub: err\n", logBytes.String())
 }
 
 func TestExecuteCommand_GitlabMergeRequestErr(t *testing.T) {
 	t.Log("if getting the gitlab merge request fails an error should be logged")
 	setup(t)
-	When(gitlabGetter.GetMergeRequest(fixtures.Repo.FullName, fixtures.Pull.Num)).ThenReturn(nil, errors.New("err"))
-	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, nil, vcs.Gitlab)
+	When(gitlabGetter.GetMergeRequest(fixtures.Repo, fixtures.Pull.Num)).ThenReturn(nil, errors.New("err"))
+	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, nil, vcs.Gitlab, events.Trace{})
 	Equals(t, "[ERROR] runatlantis/atlantis#1: Making merge request API call to GitLab: err\n", logBytes.String())
 }
 
@@ -123,7 +150,7 @@ func TestExecuteCommand_GithubPullParseErr(t *testing.T) {
 	When(githubGetter.GetPullRequest(fixtures.Repo, fixtures.Pull.Num)).ThenReturn(&pull, nil)
 	When(eventParsing.ParseGithubPull(&pull)).ThenReturn(fixtures.Pull, fixtures.Repo, errors.New("err"))
 
-	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, nil, vcs.Github)
+	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, nil, vcs.Github, events.Trace{})
 	Equals(t, "[ERROR] runatlantis/atlantis#1: Extracting required fields from comment data: err\n", logBytes.String())
 }
 
@@ -141,7 +168,7 @@ func TestExecuteCommand_ForkPRDisabled(t *testing.T) {
 	headRepo.Owner = "forkrepo"
 	When(eventParsing.ParseGithubPull(&pull)).ThenReturn(modelPull, headRepo, nil)
 
-	ch.ExecuteCommand(fixtures.Repo, models.Repo{} /* this isn't used */, fixtures.User, fixtures.Pull.Num, nil, vcs.Github)
+	ch.ExecuteCommand(fixtures.Repo, models.Repo{} /* this isn't used */, fixtures.User, fixtures.Pull.Num, nil, vcs.Github, events.Trace{})
 	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.Repo, modelPull.Num, "Atlantis commands can't be run on fork pull requests. To enable, set --"+ch.AllowForkPRsFlag, vcs.Github)
 }
 
@@ -156,7 +183,7 @@ func TestExecuteCommand_ClosedPull(t *testing.T) {
 	When(githubGetter.GetPullRequest(fixtures.Repo, fixtures.Pull.Num)).ThenReturn(pull, nil)
 	When(eventParsing.ParseGithubPull(pull)).ThenReturn(modelPull, fixtures.Repo, nil)
 
-	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, nil, vcs.Github)
+	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, nil, vcs.Github, events.Trace{})
 	vcsClient.VerifyWasCalledOnce().CreateComment(fixtures.Repo, modelPull.Num, "Atlantis commands can't be run on closed pull requests", vcs.Github)
 }
 
@@ -173,8 +200,8 @@ func TestExecuteCommand_WorkspaceLocked(t *testing.T) {
 
 	When(githubGetter.GetPullRequest(fixtures.Repo, fixtures.Pull.Num)).ThenReturn(pull, nil)
 	When(eventParsing.ParseGithubPull(pull)).ThenReturn(fixtures.Pull, fixtures.Repo, nil)
-	When(workspaceLocker.TryLock(fixtures.Repo.FullName, cmd.Workspace, fixtures.Pull.Num)).ThenReturn(false)
-	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, &cmd, vcs.Github)
+	When(workspaceLocker.TryLock(fixtures.Repo.Hostname, fixtures.Repo.FullName, cmd.Workspace, fixtures.Pull.Num)).ThenReturn(false)
+	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, &cmd, vcs.Github, events.Trace{})
 
 	msg := "The workspace workspace is currently locked by another" +
 		" command that is running for this pull request." +
@@ -200,7 +227,7 @@ func TestExecuteCommand_FullRun(t *testing.T) {
 		}
 		When(githubGetter.GetPullRequest(fixtures.Repo, fixtures.Pull.Num)).ThenReturn(pull, nil)
 		When(eventParsing.ParseGithubPull(pull)).ThenReturn(fixtures.Pull, fixtures.Repo, nil)
-		When(workspaceLocker.TryLock(fixtures.Repo.FullName, cmd.Workspace, fixtures.Pull.Num)).ThenReturn(true)
+		When(workspaceLocker.TryLock(fixtures.Repo.Hostname, fixtures.Repo.FullName, cmd.Workspace, fixtures.Pull.Num)).ThenReturn(true)
 		switch c {
 		case events.Plan:
 			When(planner.Execute(matchers.AnyPtrToEventsCommandContext())).ThenReturn(cmdResponse)
@@ -208,16 +235,45 @@ func TestExecuteCommand_FullRun(t *testing.T) {
 			When(applier.Execute(matchers.AnyPtrToEventsCommandContext())).ThenReturn(cmdResponse)
 		}
 
-		ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, &cmd, vcs.Github)
+		ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, &cmd, vcs.Github, events.Trace{})
 
 		ghStatus.VerifyWasCalledOnce().Update(fixtures.Repo, fixtures.Pull, vcs.Pending, &cmd, vcs.Github)
 		_, response := ghStatus.VerifyWasCalledOnce().UpdateProjectResult(matchers.AnyPtrToEventsCommandContext(), matchers.AnyEventsCommandResponse()).GetCapturedArguments()
 		Equals(t, cmdResponse, response)
 		vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), matchers.AnyVcsHost())
-		workspaceLocker.VerifyWasCalledOnce().Unlock(fixtures.Repo.FullName, cmd.Workspace, fixtures.Pull.Num)
+		workspaceLocker.VerifyWasCalledOnce().Unlock(fixtures.Repo.Hostname, fixtures.Repo.FullName, cmd.Workspace, fixtures.Pull.Num)
 	}
 }
 
+func TestExecuteCommand_CommandSecrets(t *testing.T) {
+	t.Log("secrets found while running a command should be masked in its comment and logs but not in other commands")
+	setup(t)
+	var err error
+	ch.Redactor, err = redact.New(nil)
+	Ok(t, err)
+	ch.AllowForkPRs = true
+	cmd := events.Command{Name: events.Plan, Workspace: "default"}
+	pull := &github.PullRequest{}
+	When(githubGetter.GetPullRequest(fixtures.Repo, fixtures.Pull.Num)).ThenReturn(pull, nil)
+	When(eventParsing.ParseGithubPull(pull)).ThenReturn(fixtures.Pull, fixtures.Repo, nil)
+	When(workspaceLocker.TryLock(fixtures.Repo.Hostname, fixtures.Repo.FullName, cmd.Workspace, fixtures.Pull.Num)).ThenReturn(true)
+	When(planner.Execute(matchers.AnyPtrToEventsCommandContext())).Then(func(params []Param) ReturnValues {
+		ctx := params[0].(*events.CommandContext)
+		ctx.Redactor.AddSecret("hunter2")
+		ctx.Log.Info("password is hunter2")
+		return ReturnValues{events.CommandResponse{Failure: "password is hunter2"}}
+	})
+
+	ch.ExecuteCommand(fixtures.Repo, fixtures.Repo, fixtures.User, fixtures.Pull.Num, &cmd, vcs.Github, events.Trace{})
+
+	_, _, comment, _ := vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), matchers.AnyVcsHost()).GetCapturedArguments()
+	Assert(t, strings.Contains(comment, "password is [REDACTED]"), "exp secret masked in comment %q", comment)
+	Assert(t, !strings.Contains(comment, "hunter2"), "exp no secret in comment %q", comment)
+	Assert(t, strings.Contains(logBytes.String(), "Password is [REDACTED]"), "exp secret masked in logs %q", logBytes.String())
+	Assert(t, !strings.Contains(logBytes.String(), "hunter2"), "exp no secret in logs %q", logBytes.String())
+	Equals(t, "hunter2", ch.Redactor.Redact("hunter2"))
+}
+
 func TestExecuteCommand_ForkPREnabled(t *testing.T) {
 	t.Log("when running a plan on a fork PR, it should succeed")
 	setup(t)
@@ -237,14 +293,14 @@ func TestExecuteCommand_ForkPREnabled(t *testing.T) {
 	headRepo.FullName = "forkrepo/atlantis"
 	headRepo.Owner = "forkrepo"
 	When(eventParsing.ParseGithubPull(&pull)).ThenReturn(fixtures.Pull, headRepo, nil)
-	When(workspaceLocker.TryLock(fixtures.Repo.FullName, cmd.Workspace, fixtures.Pull.Num)).ThenReturn(true)
+	When(workspaceLocker.TryLock(fixtures.Repo.Hostname, fixtures.Repo.FullName, cmd.Workspace, fixtures.Pull.Num)).ThenReturn(true)
 	When(planner.Execute(matchers.AnyPtrToEventsCommandContext())).ThenReturn(cmdResponse)
 
-	ch.ExecuteCommand(fixtures.Repo, models.Repo{} /* this isn't used */, fixtures.User, fixtures.Pull.Num, &cmd, vcs.Github)
+	ch.ExecuteCommand(fixtures.Repo, models.Repo{} /* this isn't used */, fixtures.User, fixtures.Pull.Num, &cmd, vcs.Github, events.Trace{})
 
 	ghStatus.VerifyWasCalledOnce().Update(fixtures.Repo, fixtures.Pull, vcs.Pending, &cmd, vcs.Github)
 	_, response := ghStatus.VerifyWasCalledOnce().UpdateProjectResult(matchers.AnyPtrToEventsCommandContext(), matchers.AnyEventsCommandResponse()).GetCapturedArguments()
 	Equals(t, cmdResponse, response)
 	vcsClient.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), matchers.AnyVcsHost())
-	workspaceLocker.VerifyWasCalledOnce().Unlock(fixtures.Repo.FullName, cmd.Workspace, fixtures.Pull.Num)
+	workspaceLocker.VerifyWasCalledOnce().Unlock(fixtures.Repo.Hostname, fixtures.Repo.FullName, cmd.Workspace, fixtures.Pull.Num)
 }
@@ -16,7 +16,6 @@ package events
 import (
 	"fmt"
 	"io/ioutil"
-	"net/url"
 	"path/filepath"
 	"strings"
 
@@ -29,8 +28,23 @@ const (
 	WorkspaceFlagShort = "w"
 	DirFlagLong        = "dir"
 	DirFlagShort       = "d"
+	ProjectFlagLong    = "project"
+	ProjectFlagShort   = "p"
 	VerboseFlagLong    = "verbose"
 	VerboseFlagShort   = ""
+	// OverridePoliciesFlagLong is the apply flag that policy owners use to
+	// apply plans that failed their policy checks.
+	OverridePoliciesFlagLong = "override-policies"
+	// AllowDestroyFlagLong is the apply flag that confirms applying plans
+	// that destroy resources.
+	AllowDestroyFlagLong = "allow-destroy"
+	// AllWorkspacesFlagLong is the plan flag that plans every workspace that
+	// has an env/{workspace}.tfvars file.
+	AllWorkspacesFlagLong = "all-workspaces"
+	// AllFlagLong is the plan flag that plans every project in the repo.
+	AllFlagLong = "all"
+	// DefaultWorkspace is the workspace commands run in if one isn't specified.
+	DefaultWorkspace = "default"
 )
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_comment_parsing.go CommentParsing
@@ -38,16 +52,20 @@ const (
 // CommentParsing handles parsing pull request comments.
 type CommentParsing interface {
 	// Parse attempts to parse a pull request comment to see if it's an Atlantis
-	// commmand.
-	Parse(comment string, vcsHost vcs.Host) CommentParseResult
+	// commmand. hostname is the hostname of the install the comment was made
+	// on.
+	Parse(comment string, vcsHost vcs.Host, hostname string) CommentParseResult
 }
 
 // CommentParser implements CommentParsing
 type CommentParser struct {
-	GithubUser  string
-	GithubToken string
-	GitlabUser  string
-	GitlabToken string
+	// GithubUsers are the usernames Atlantis runs as on each GitHub install
+	// we support, by hostname. The one for the install a comment was made on
+	// can be used to run commands, ex. @user plan.
+	GithubUsers map[string]string
+	// GitlabUsers are the usernames Atlantis runs as on each GitLab install
+	// we support, by hostname.
+	GitlabUsers map[string]string
 }
 
 // CommentParseResult describes the result of parsing a comment as a command.
@@ -66,7 +84,8 @@ type CommentParseResult struct {
 //
 // Valid commands contain:
 // - The initial "executable" name, 'run' or 'atlantis' or '@GithubUser'
-//   where GithubUser is the API user Atlantis is running as.
+//   where GithubUser is the API user Atlantis is running as on the install
+//   the comment was made on.
 // - Then a command, either 'plan', 'apply', or 'help'.
 // - Then optional flags, then an optional separator '--' followed by optional
 //   extra flags to be appended to the terraform plan/apply command.
@@ -77,9 +96,10 @@ type CommentParseResult struct {
 // - @GithubUser plan -w staging
 // - atlantis plan -w staging -d dir --verbose
 // - atlantis plan --verbose -- -key=value -key2 value2
+// - atlantis plan -d 'envs/*' -p app
 //
 // nolint: gocyclo
-func (e *CommentParser) Parse(comment string, vcsHost vcs.Host) CommentParseResult {
+func (e *CommentParser) Parse(comment string, vcsHost vcs.Host, hostname string) CommentParseResult {
 	if multiLineRegex.MatchString(comment) {
 		return CommentParseResult{Ignore: true}
 	}
@@ -97,12 +117,16 @@ func (e *CommentParser) Parse(comment string, vcsHost vcs.Host) CommentParseResu
 	}
 
 	// Atlantis can be invoked using the name of the VCS host user we're
-	// running under. Need to be able to match against that user.
-	vcsUser := e.GithubUser
+	// running under on the install the comment was made on. The users of
+	// other installs can't be used since they might be real people there.
+	vcsUsers := e.GithubUsers
 	if vcsHost == vcs.Gitlab {
-		vcsUser = e.GitlabUser
+		vcsUsers = e.GitlabUsers
+	}
+	executableNames := []string{"run", "atlantis"}
+	if installHostname, ok := vcs.InstallHostname(vcsUsers, hostname); ok {
+		executableNames = append(executableNames, "@"+vcsUsers[installHostname])
 	}
-	executableNames := []string{"run", "atlantis", "@" + vcsUser}
 
 	// If the comment doesn't start with the name of our 'executable' then
 	// ignore it.
@@ -128,29 +152,39 @@ func (e *CommentParser) Parse(comment string, vcsHost vcs.Host) CommentParseResu
 	}
 
 	var workspace string
-	var dir string
+	var dirs []string
+	var projectNames []string
+	var all bool
 	var verbose bool
+	var overridePolicies bool
+	var allowDestroy bool
+	var allWorkspaces bool
 	var extraArgs []string
 	var flagSet *pflag.FlagSet
 	var name CommandName
 
 	// Set up the flag parsing depending on the command.
-	const defaultWorkspace = "default"
 	switch command {
 	case Plan.String():
 		name = Plan
 		flagSet = pflag.NewFlagSet(Plan.String(), pflag.ContinueOnError)
 		flagSet.SetOutput(ioutil.Discard)
-		flagSet.StringVarP(&workspace, WorkspaceFlagLong, WorkspaceFlagShort, defaultWorkspace, "Switch to this Terraform workspace before planning.")
-		flagSet.StringVarP(&dir, DirFlagLong, DirFlagShort, "", "Which directory to run plan in relative to root of repo. Use '.' for root. If not specified, will attempt to run plan for all Terraform projects we think were modified in this changeset.")
+		flagSet.StringVarP(&workspace, WorkspaceFlagLong, WorkspaceFlagShort, DefaultWorkspace, "Switch to this Terraform workspace before planning.")
+		flagSet.StringArrayVarP(&dirs, DirFlagLong, DirFlagShort, nil, "Which directory to run plan in relative to root of repo. Use '.' for root. Can be repeated and can be a glob, ex. 'envs/*'. If not specified, will attempt to run plan for all Terraform projects we think were modified in this changeset.")
+		flagSet.StringArrayVarP(&projectNames, ProjectFlagLong, ProjectFlagShort, nil, "Which project to run plan for, by the name in its atlantis.yaml. Can be repeated.")
+		flagSet.BoolVar(&all, AllFlagLong, false, "Run plan for every project in the repo.")
 		flagSet.BoolVarP(&verbose, VerboseFlagLong, VerboseFlagShort, false, "Append Atlantis log to comment.")
+		flagSet.BoolVar(&allWorkspaces, AllWorkspacesFlagLong, false, "Plan every workspace that has an env/{workspace}.tfvars file.")
 	case Apply.String():
 		name = Apply
 		flagSet = pflag.NewFlagSet(Apply.String(), pflag.ContinueOnError)
 		flagSet.SetOutput(ioutil.Discard)
-		flagSet.StringVarP(&workspace, WorkspaceFlagLong, WorkspaceFlagShort, defaultWorkspace, "Apply the plan for this Terraform workspace.")
-		flagSet.StringVarP(&dir, DirFlagLong, DirFlagShort, "", "Apply the plan for this directory, relative to root of repo. Use '.' for root. If not specified, will run apply against all plans created for this workspace.")
+		flagSet.StringVarP(&workspace, WorkspaceFlagLong, WorkspaceFlagShort, DefaultWorkspace, "Apply the plan for this Terraform workspace.")
+		flagSet.StringArrayVarP(&dirs, DirFlagLong, DirFlagShort, nil, "Apply the plan for this directory, relative to root of repo. Use '.' for root. Can be repeated and can be a glob, ex. 'envs/*'. If not specified, will run apply against all plans created for this workspace.")
+		flagSet.StringArrayVarP(&projectNames, ProjectFlagLong, ProjectFlagShort, nil, "Apply the plan for this project, by the name in its atlantis.yaml. Can be repeated.")
 		flagSet.BoolVarP(&verbose, VerboseFlagLong, VerboseFlagShort, false, "Append Atlantis log to comment.")
+		flagSet.BoolVar(&overridePolicies, OverridePoliciesFlagLong, false, "Apply plans that failed their policy checks. Only policy owners can use this.")
+		flagSet.BoolVar(&allowDestroy, AllowDestroyFlagLong, false, "Confirm applying plans that destroy resources.")
 	default:
 		return CommentParseResult{CommentResponse: fmt.Sprintf("Error: unknown command %q – this is a bug", command)}
 	}
@@ -185,20 +219,27 @@ func (e *CommentParser) Parse(comment string, vcsHost vcs.Host) CommentParseResu
 		}
 	}
 
-	dir, err = e.validateDir(dir)
-	if err != nil {
-		return CommentParseResult{CommentResponse: e.errMarkdown(err.Error(), command, flagSet)}
+	for i, dir := range dirs {
+		dirs[i], err = e.validateDir(dir)
+		if err != nil {
+			return CommentParseResult{CommentResponse: e.errMarkdown(err.Error(), command, flagSet)}
+		}
+	}
+	if all && (len(dirs) > 0 || len(projectNames) > 0) {
+		return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("--%s can't be used with --%s or --%s", AllFlagLong, DirFlagLong, ProjectFlagLong), command, flagSet)}
 	}
 
-	// Use the same validation that Terraform uses: https://git.io/vxGhU. Plus
-	// we also don't allow '..'. We don't want the workspace to contain a path
-	// since we create files based on the name.
-	if workspace != url.PathEscape(workspace) || strings.Contains(workspace, "..") {
+	if !validWorkspace(workspace) {
 		return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("invalid workspace: %q", workspace), command, flagSet)}
 	}
 
+	workspaceSet := flagSet.Changed(WorkspaceFlagLong)
+	if allWorkspaces && workspaceSet {
+		return CommentParseResult{CommentResponse: e.errMarkdown(fmt.Sprintf("--%s can't be used with --%s", AllWorkspacesFlagLong, WorkspaceFlagLong), command, flagSet)}
+	}
+
 	return CommentParseResult{
-		Command: &Command{Name: name, Verbose: verbose, Workspace: workspace, Dir: dir, Flags: extraArgs},
+		Command: &Command{Name: name, Verbose: verbose, Workspace: workspace, WorkspaceSet: workspaceSet, AllWorkspaces: allWorkspaces, Dirs: dirs, ProjectNames: projectNames, All: all, Flags: extraArgs, OverridePolicies: overridePolicies, AllowDestroy: allowDestroy},
 	}
 }
 
@@ -206,6 +247,10 @@ func (e *CommentParser) validateDir(dir string) (string, error) {
 	if dir == "" {
 		return dir, nil
 	}
+	// Globs are usually quoted in comments as they would be in a shell.
+	if len(dir) > 1 && (dir[0] == '\'' || dir[0] == '"') && dir[len(dir)-1] == dir[0] {
+		dir = dir[1 : len(dir)-1]
+	}
 	validatedDir := filepath.Clean(dir)
 	// Join with . so the path is relative. This helps us if they use '/',
 	// and is safe to do if their path is relative since it's a no-op.
@@ -216,6 +261,9 @@ func (e *CommentParser) validateDir(dir string) (string, error) {
 	if strings.HasPrefix(validatedDir, "..") {
 		return "", fmt.Errorf("using a relative path %q with -%s/--%s is not allowed", dir, DirFlagShort, DirFlagLong)
 	}
+	if _, err := filepath.Match(validatedDir, ""); err != nil {
+		return "", fmt.Errorf("invalid glob %q with -%s/--%s", dir, DirFlagShort, DirFlagLong)
+	}
 
 	return validatedDir, nil
 }
@@ -24,10 +24,8 @@ import (
 )
 
 var commentParser = events.CommentParser{
-	GithubUser:  "github-user",
-	GithubToken: "github-token",
-	GitlabUser:  "gitlab-user",
-	GitlabToken: "gitlab-token",
+	GithubUsers: map[string]string{"github.com": "github-user", "github.mycompany.com": "ghe-user"},
+	GitlabUsers: map[string]string{"gitlab.com": "gitlab-user"},
 }
 
 func TestParse_Ignored(t *testing.T) {
@@ -41,7 +39,7 @@ func TestParse_Ignored(t *testing.T) {
 		"terraform plan\nbut with newlines",
 	}
 	for _, c := range ignoreComments {
-		r := commentParser.Parse(c, vcs.Github)
+		r := commentParser.Parse(c, vcs.Github, "github.com")
 		Assert(t, r.Ignore, "expected Ignore to be true for comment %q", c)
 	}
 }
@@ -60,11 +58,33 @@ func TestParse_HelpResponse(t *testing.T) {
 		"atlantis help plan",
 	}
 	for _, c := range helpComments {
-		r := commentParser.Parse(c, vcs.Github)
+		r := commentParser.Parse(c, vcs.Github, "github.com")
 		Equals(t, events.HelpComment, r.CommentResponse)
 	}
 }
 
+func TestParse_OtherHostsUser(t *testing.T) {
+	t.Log("should ignore comments mentioning the user of a different VCS host")
+	r := commentParser.Parse("@gitlab-user plan", vcs.Github, "github.com")
+	Assert(t, r.Ignore, "expected Ignore to be true")
+	r = commentParser.Parse("@github-user plan", vcs.Gitlab, "gitlab.com")
+	Assert(t, r.Ignore, "expected Ignore to be true")
+}
+
+func TestParse_OtherInstallsUser(t *testing.T) {
+	t.Log("should only accept the user of the install the comment was made on")
+	r := commentParser.Parse("@ghe-user plan", vcs.Github, "github.com")
+	Assert(t, r.Ignore, "expected Ignore to be true")
+	r = commentParser.Parse("@github-user plan", vcs.Github, "github.mycompany.com")
+	Assert(t, r.Ignore, "expected Ignore to be true")
+	r = commentParser.Parse("@ghe-user plan", vcs.Github, "github.mycompany.com")
+	Assert(t, r.Command != nil, "expected a command")
+
+	t.Log("if there's only one install its user should be accepted for any hostname")
+	r = commentParser.Parse("@gitlab-user plan", vcs.Gitlab, "gitlab.mycompany.com")
+	Assert(t, r.Command != nil, "expected a command")
+}
+
 func TestParse_UnusedArguments(t *testing.T) {
 	t.Log("if there are unused flags we return an error")
 	cases := []struct {
@@ -121,7 +141,7 @@ func TestParse_UnusedArguments(t *testing.T) {
 	for _, c := range cases {
 		comment := fmt.Sprintf("atlantis %s %s", c.Command.String(), c.Args)
 		t.Run(comment, func(t *testing.T) {
-			r := commentParser.Parse(comment, vcs.Github)
+			r := commentParser.Parse(comment, vcs.Github, "github.com")
 			usage := PlanUsage
 			if c.Command == events.Apply {
 				usage = ApplyUsage
@@ -144,7 +164,7 @@ func TestParse_DidYouMeanAtlantis(t *testing.T) {
 		"terraform plan -w workspace -d . -- test",
 	}
 	for _, c := range comments {
-		r := commentParser.Parse(c, vcs.Github)
+		r := commentParser.Parse(c, vcs.Github, "github.com")
 		Assert(t, r.CommentResponse == events.DidYouMeanAtlantisComment,
 			"For comment %q expected CommentResponse==%q but got %q", c, events.DidYouMeanAtlantisComment, r.CommentResponse)
 	}
@@ -159,7 +179,7 @@ func TestParse_InvalidCommand(t *testing.T) {
 		"atlantis appely apply",
 	}
 	for _, c := range comments {
-		r := commentParser.Parse(c, vcs.Github)
+		r := commentParser.Parse(c, vcs.Github, "github.com")
 		exp := fmt.Sprintf("```\nError: unknown command %q.\nRun 'atlantis --help' for usage.\n```", strings.Fields(c)[1])
 		Assert(t, r.CommentResponse == exp,
 			"For comment %q expected CommentResponse==%q but got %q", c, exp, r.CommentResponse)
@@ -176,7 +196,7 @@ func TestParse_SubcommandUsage(t *testing.T) {
 		"atlantis apply --help",
 	}
 	for _, c := range comments {
-		r := commentParser.Parse(c, vcs.Github)
+		r := commentParser.Parse(c, vcs.Github, "github.com")
 		exp := "Usage of " + strings.Fields(c)[1]
 		Assert(t, strings.Contains(r.CommentResponse, exp),
 			"For comment %q expected CommentResponse %q to contain %q", c, r.CommentResponse, exp)
@@ -210,7 +230,7 @@ func TestParse_InvalidFlags(t *testing.T) {
 		},
 	}
 	for _, c := range cases {
-		r := commentParser.Parse(c.comment, vcs.Github)
+		r := commentParser.Parse(c.comment, vcs.Github, "github.com")
 		Assert(t, strings.Contains(r.CommentResponse, c.exp),
 			"For comment %q expected CommentResponse %q to contain %q", c.comment, r.CommentResponse, c.exp)
 		Assert(t, strings.Contains(r.CommentResponse, "Usage of "),
@@ -218,6 +238,77 @@ func TestParse_InvalidFlags(t *testing.T) {
 	}
 }
 
+func TestParse_OverridePolicies(t *testing.T) {
+	t.Log("apply should accept --override-policies but plan shouldn't")
+	r := commentParser.Parse("atlantis apply --override-policies", vcs.Github, "github.com")
+	Equals(t, "", r.CommentResponse)
+	Equals(t, true, r.Command.OverridePolicies)
+
+	r = commentParser.Parse("atlantis apply", vcs.Github, "github.com")
+	Equals(t, false, r.Command.OverridePolicies)
+
+	r = commentParser.Parse("atlantis plan --override-policies", vcs.Github, "github.com")
+	Assert(t, strings.Contains(r.CommentResponse, "Error: unknown flag: --override-policies"), "got %q", r.CommentResponse)
+}
+
+func TestParse_AllowDestroy(t *testing.T) {
+	t.Log("apply should accept --allow-destroy but plan shouldn't")
+	r := commentParser.Parse("atlantis apply --allow-destroy", vcs.Github, "github.com")
+	Equals(t, "", r.CommentResponse)
+	Equals(t, true, r.Command.AllowDestroy)
+
+	r = commentParser.Parse("atlantis apply", vcs.Github, "github.com")
+	Equals(t, false, r.Command.AllowDestroy)
+
+	r = commentParser.Parse("atlantis plan --allow-destroy", vcs.Github, "github.com")
+	Assert(t, strings.Contains(r.CommentResponse, "Error: unknown flag: --allow-destroy"), "got %q", r.CommentResponse)
+}
+
+func TestParse_Workspaces(t *testing.T) {
+	t.Log("plan should accept --all-workspaces but not with -w and should record if -w was set")
+	r := commentParser.Parse("atlantis plan", vcs.Github, "github.com")
+	Equals(t, "", r.CommentResponse)
+	Equals(t, "default", r.Command.Workspace)
+	Equals(t, false, r.Command.WorkspaceSet)
+	Equals(t, false, r.Command.AllWorkspaces)
+
+	r = commentParser.Parse("atlantis plan -w default", vcs.Github, "github.com")
+	Equals(t, true, r.Command.WorkspaceSet)
+
+	r = commentParser.Parse("atlantis plan --all-workspaces", vcs.Github, "github.com")
+	Equals(t, "", r.CommentResponse)
+	Equals(t, true, r.Command.AllWorkspaces)
+
+	r = commentParser.Parse("atlantis plan --all-workspaces -w staging", vcs.Github, "github.com")
+	Assert(t, strings.Contains(r.CommentResponse, "Error: --all-workspaces can't be used with --workspace"), "got %q", r.CommentResponse)
+
+	r = commentParser.Parse("atlantis apply --all-workspaces", vcs.Github, "github.com")
+	Assert(t, strings.Contains(r.CommentResponse, "Error: unknown flag: --all-workspaces"), "got %q", r.CommentResponse)
+}
+
+func TestParse_ProjectSelection(t *testing.T) {
+	t.Log("-d and -p should be repeatable, -d should accept globs and plan should accept --all")
+	for _, cmdName := range []string{"plan", "apply"} {
+		r := commentParser.Parse(fmt.Sprintf("atlantis %s -d dir1 -d 'envs/*' -p app -p db", cmdName), vcs.Github, "github.com")
+		Equals(t, "", r.CommentResponse)
+		Equals(t, []string{"dir1", "envs/*"}, r.Command.Dirs)
+		Equals(t, []string{"app", "db"}, r.Command.ProjectNames)
+
+		r = commentParser.Parse(fmt.Sprintf("atlantis %s -d 'envs/[a'", cmdName), vcs.Github, "github.com")
+		Assert(t, strings.Contains(r.CommentResponse, `Error: invalid glob "envs/[a" with -d/--dir`), "got %q", r.CommentResponse)
+	}
+
+	r := commentParser.Parse("atlantis plan --all", vcs.Github, "github.com")
+	Equals(t, "", r.CommentResponse)
+	Equals(t, true, r.Command.All)
+
+	r = commentParser.Parse("atlantis plan --all -p app", vcs.Github, "github.com")
+	Assert(t, strings.Contains(r.CommentResponse, "Error: --all can't be used with --dir or --project"), "got %q", r.CommentResponse)
+
+	r = commentParser.Parse("atlantis apply --all", vcs.Github, "github.com")
+	Assert(t, strings.Contains(r.CommentResponse, "Error: unknown flag: --all"), "got %q", r.CommentResponse)
+}
+
 func TestParse_RelativeDirPath(t *testing.T) {
 	t.Log("if -d is used with a relative path, should return an error")
 	comments := []string{
@@ -232,7 +323,7 @@ func TestParse_RelativeDirPath(t *testing.T) {
 		"atlantis apply -d a/../..",
 	}
 	for _, c := range comments {
-		r := commentParser.Parse(c, vcs.Github)
+		r := commentParser.Parse(c, vcs.Github, "github.com")
 		exp := "Error: using a relative path"
 		Assert(t, strings.Contains(r.CommentResponse, exp),
 			"For comment %q expected CommentResponse %q to contain %q", c, r.CommentResponse, exp)
@@ -240,7 +331,7 @@ func TestParse_RelativeDirPath(t *testing.T) {
 }
 
 func TestParse_InvalidWorkspace(t *testing.T) {
-	t.Log("if -w is used with '..' or '/', should return an error")
+	t.Log("if -w is used with '..', '.' or '/', should return an error")
 	comments := []string{
 		"atlantis plan -w ..",
 		"atlantis apply -w ..",
@@ -250,9 +341,10 @@ func TestParse_InvalidWorkspace(t *testing.T) {
 		"atlantis apply -w abc..",
 		"atlantis plan -w abc..abc",
 		"atlantis apply -w ../../../etc/passwd",
+		"atlantis plan -w .",
 	}
 	for _, c := range comments {
-		r := commentParser.Parse(c, vcs.Github)
+		r := commentParser.Parse(c, vcs.Github, "github.com")
 		exp := "Error: invalid workspace"
 		Assert(t, strings.Contains(r.CommentResponse, exp),
 			"For comment %q expected CommentResponse %q to contain %q", c, r.CommentResponse, exp)
@@ -412,9 +504,10 @@ func TestParse_Parsing(t *testing.T) {
 	for _, test := range cases {
 		for _, cmdName := range []string{"plan", "apply"} {
 			comment := fmt.Sprintf("atlantis %s %s", cmdName, test.flags)
-			r := commentParser.Parse(comment, vcs.Github)
+			r := commentParser.Parse(comment, vcs.Github, "github.com")
 			Assert(t, r.CommentResponse == "", "CommentResponse should have been empty but was %q for comment %q", r.CommentResponse, comment)
-			Assert(t, test.expDir == r.Command.Dir, "exp dir to equal %q but was %q for comment %q", test.expDir, r.Command.Dir, comment)
+			actDir := strings.Join(r.Command.Dirs, " ")
+			Assert(t, test.expDir == actDir, "exp dir to equal %q but was %q for comment %q", test.expDir, actDir, comment)
 			Assert(t, test.expWorkspace == r.Command.Workspace, "exp workspace to equal %q but was %q for comment %q", test.expWorkspace, r.Command.Workspace, comment)
 			Assert(t, test.expVerbose == r.Command.Verbose, "exp verbose to equal %v but was %v for comment %q", test.expVerbose, r.Command.Verbose, comment)
 			actExtraArgs := strings.Join(r.Command.Flags, " ")
@@ -430,20 +523,32 @@ func TestParse_Parsing(t *testing.T) {
 }
 
 var PlanUsage = `Usage of plan:
-  -d, --dir string         Which directory to run plan in relative to root of repo.
-                           Use '.' for root. If not specified, will attempt to run
-                           plan for all Terraform projects we think were modified in
-                           this changeset.
-      --verbose            Append Atlantis log to comment.
-  -w, --workspace string   Switch to this Terraform workspace before planning.
-                           (default "default")
+      --all                   Run plan for every project in the repo.
+      --all-workspaces        Plan every workspace that has an
+                              env/{workspace}.tfvars file.
+  -d, --dir stringArray       Which directory to run plan in relative to root of
+                              repo. Use '.' for root. Can be repeated and can be a
+                              glob, ex. 'envs/*'. If not specified, will attempt to
+                              run plan for all Terraform projects we think were
+                              modified in this changeset.
+  -p, --project stringArray   Which project to run plan for, by the name in its
+                              atlantis.yaml. Can be repeated.
+      --verbose               Append Atlantis log to comment.
+  -w, --workspace string      Switch to this Terraform workspace before planning.
+                              (default "default")
 `
 
 var ApplyUsage = `Usage of apply:
-  -d, --dir string         Apply the plan for this directory, relative to root of
-                           repo. Use '.' for root. If not specified, will run apply
-                           against all plans created for this workspace.
-      --verbose            Append Atlantis log to comment.
-  -w, --workspace string   Apply the plan for this Terraform workspace. (default
-                           "default")
+      --allow-destroy         Confirm applying plans that destroy resources.
+  -d, --dir stringArray       Apply the plan for this directory, relative to root of
+                              repo. Use '.' for root. Can be repeated and can be a
+                              glob, ex. 'envs/*'. If not specified, will run apply
+                              against all plans created for this workspace.
+      --override-policies     Apply plans that failed their policy checks. Only
+                              policy owners can use this.
+  -p, --project stringArray   Apply the plan for this project, by the name in its
+                              atlantis.yaml. Can be repeated.
+      --verbose               Append Atlantis log to comment.
+  -w, --workspace string      Apply the plan for this Terraform workspace. (default
+                              "default")
 `
@@ -17,6 +17,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 )
@@ -41,7 +42,7 @@ type DefaultCommitStatusUpdater struct {
 // Update updates the commit status.
 func (d *DefaultCommitStatusUpdater) Update(repo models.Repo, pull models.PullRequest, status vcs.CommitStatus, cmd *Command, host vcs.Host) error {
 	description := fmt.Sprintf("%s %s", strings.Title(cmd.Name.String()), strings.Title(status.String()))
-	return d.Client.UpdateStatus(repo, pull, status, description, host)
+	return d.Client.UpdateStatus(repo, pull, status, vcs.DefaultStatusContext, description, host)
 }
 
 // UpdateProjectResult updates the commit status based on the status of res.
@@ -56,7 +57,58 @@ func (d *DefaultCommitStatusUpdater) UpdateProjectResult(ctx *CommandContext, re
 		}
 		status = d.worstStatus(statuses)
 	}
-	return d.Update(ctx.BaseRepo, ctx.Pull, status, ctx.Command, ctx.VCSHost)
+	if err := d.Update(ctx.BaseRepo, ctx.Pull, status, ctx.Command, ctx.VCSHost); err != nil {
+		return err
+	}
+	if err := d.updateProjects(ctx, res); err != nil {
+		return err
+	}
+	return d.updatePolicyCheck(ctx, res)
+}
+
+// updateProjects sets a separate commit status for each project and
+// workspace in res so branch protection can require specific projects.
+func (d *DefaultCommitStatusUpdater) updateProjects(ctx *CommandContext, res CommandResponse) error {
+	var firstErr error
+	for _, p := range res.ProjectResults {
+		workspace := p.Workspace
+		if workspace == "" {
+			workspace = ctx.Command.Workspace
+		}
+		statusContext := vcs.ProjectStatusContext(ctx.Command.Name.String(), p.Path, workspace)
+		status := p.Status()
+		description := fmt.Sprintf("%s %s", strings.Title(ctx.Command.Name.String()), strings.Title(status.String()))
+		if p.Skipped != "" {
+			description = fmt.Sprintf("%s Skipped", strings.Title(ctx.Command.Name.String()))
+		}
+		// Keep going so one failure doesn't leave the other projects'
+		// statuses out of date.
+		if err := d.Client.UpdateStatus(ctx.BaseRepo, ctx.Pull, status, statusContext, description, ctx.VCSHost); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "updating status for %s", statusContext)
+		}
+	}
+	return firstErr
+}
+
+// updatePolicyCheck sets a separate commit status for the policy checks of
+// res if there were any.
+func (d *DefaultCommitStatusUpdater) updatePolicyCheck(ctx *CommandContext, res CommandResponse) error {
+	checked := false
+	status := vcs.Success
+	for _, p := range res.ProjectResults {
+		if p.PlanSuccess == nil || p.PlanSuccess.PolicyCheck == nil {
+			continue
+		}
+		checked = true
+		if !p.PlanSuccess.PolicyCheck.Passed() {
+			status = vcs.Failed
+		}
+	}
+	if !checked {
+		return nil
+	}
+	description := fmt.Sprintf("Policy Check %s", strings.Title(status.String()))
+	return d.Client.UpdateStatus(ctx.BaseRepo, ctx.Pull, status, vcs.PolicyCheckStatusContext, description, ctx.VCSHost)
 }
 
 func (d *DefaultCommitStatusUpdater) worstStatus(ss []vcs.CommitStatus) vcs.CommitStatus {
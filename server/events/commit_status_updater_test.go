@@ -50,7 +50,7 @@ func TestUpdate(t *testing.T) {
 	s := events.DefaultCommitStatusUpdater{Client: client}
 	err := s.Update(repoModel, pullModel, status, &cmd, vcs.Github)
 	Ok(t, err)
-	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, status, "Plan Success", vcs.Github)
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, status, vcs.DefaultStatusContext, "Plan Success", vcs.Github)
 }
 
 func TestUpdateProjectResult_Error(t *testing.T) {
@@ -65,7 +65,7 @@ func TestUpdateProjectResult_Error(t *testing.T) {
 	s := events.DefaultCommitStatusUpdater{Client: client}
 	err := s.UpdateProjectResult(ctx, events.CommandResponse{Error: errors.New("err")})
 	Ok(t, err)
-	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, vcs.Failed, "Plan Failed", vcs.Github)
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, vcs.Failed, vcs.DefaultStatusContext, "Plan Failed", vcs.Github)
 }
 
 func TestUpdateProjectResult_Failure(t *testing.T) {
@@ -80,7 +80,7 @@ func TestUpdateProjectResult_Failure(t *testing.T) {
 	s := events.DefaultCommitStatusUpdater{Client: client}
 	err := s.UpdateProjectResult(ctx, events.CommandResponse{Failure: "failure"})
 	Ok(t, err)
-	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, vcs.Failed, "Plan Failed", vcs.Github)
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, vcs.Failed, vcs.DefaultStatusContext, "Plan Failed", vcs.Github)
 }
 
 func TestUpdateProjectResult(t *testing.T) {
@@ -148,6 +148,49 @@ func TestUpdateProjectResult(t *testing.T) {
 		s := events.DefaultCommitStatusUpdater{Client: client}
 		err := s.UpdateProjectResult(ctx, resp)
 		Ok(t, err)
-		client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, c.Expected, "Plan "+strings.Title(c.Expected.String()), vcs.Github)
+		client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, c.Expected, vcs.DefaultStatusContext, "Plan "+strings.Title(c.Expected.String()), vcs.Github)
 	}
 }
+
+func TestUpdateProjectResult_PolicyCheck(t *testing.T) {
+	t.Log("should set a separate status for policy checks")
+	RegisterMockTestingT(t)
+	ctx := &events.CommandContext{
+		BaseRepo: repoModel,
+		Pull:     pullModel,
+		Command:  &events.Command{Name: events.Plan},
+		VCSHost:  vcs.Github,
+	}
+	client := mocks.NewMockClientProxy()
+	s := events.DefaultCommitStatusUpdater{Client: client}
+	err := s.UpdateProjectResult(ctx, events.CommandResponse{ProjectResults: []events.ProjectResult{
+		{PlanSuccess: &events.PlanSuccess{PolicyCheck: &events.PolicyCheckResult{}}},
+		{PlanSuccess: &events.PlanSuccess{PolicyCheck: &events.PolicyCheckResult{Error: errors.New("err")}}},
+	}})
+	Ok(t, err)
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, vcs.Success, vcs.DefaultStatusContext, "Plan Success", vcs.Github)
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, vcs.Failed, vcs.PolicyCheckStatusContext, "Policy Check Failed", vcs.Github)
+}
+
+func TestUpdateProjectResult_Projects(t *testing.T) {
+	t.Log("should set a status for each project and workspace")
+	RegisterMockTestingT(t)
+	ctx := &events.CommandContext{
+		BaseRepo: repoModel,
+		Pull:     pullModel,
+		Command:  &events.Command{Name: events.Apply, Workspace: "default"},
+		VCSHost:  vcs.Gitlab,
+	}
+	client := mocks.NewMockClientProxy()
+	s := events.DefaultCommitStatusUpdater{Client: client}
+	err := s.UpdateProjectResult(ctx, events.CommandResponse{ProjectResults: []events.ProjectResult{
+		{Path: "vpc", Workspace: "default", ApplySuccess: "success"},
+		{Path: "vpc", Workspace: "staging", Error: errors.New("err")},
+		{Path: "app", Skipped: "skipped"},
+	}})
+	Ok(t, err)
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, vcs.Failed, vcs.DefaultStatusContext, "Apply Failed", vcs.Gitlab)
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, vcs.Success, "atlantis/apply: vpc/default", "Apply Success", vcs.Gitlab)
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, vcs.Failed, "atlantis/apply: vpc/staging", "Apply Failed", vcs.Gitlab)
+	client.VerifyWasCalledOnce().UpdateStatus(repoModel, pullModel, vcs.Failed, "atlantis/apply: app/default", "Apply Skipped", vcs.Gitlab)
+}
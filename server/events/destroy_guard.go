@@ -0,0 +1,60 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// planSummaryRegex matches the summary line at the end of terraform plan
+// output, ex. "Plan: 1 to add, 2 to change, 3 to destroy.". Resources that
+// will be replaced are counted in both "to add" and "to destroy".
+var planSummaryRegex = regexp.MustCompile(`(?m)^Plan: (\d+) to add, (\d+) to change, (\d+) to destroy\.`)
+
+// replaceRegex matches the lines of terraform plan output for resources that
+// will be replaced, ex. "-/+ aws_instance.web (new resource required)".
+var replaceRegex = regexp.MustCompile(`(?m)^\s*(-/\+|\+/-) `)
+
+// DestroySummary describes the resources a plan destroys.
+type DestroySummary struct {
+	// Destroys is the number of resources the plan destroys, including
+	// replacements.
+	Destroys int
+	// Replacements is the number of those destroys that are replacements.
+	Replacements int
+	// RequiresConfirmation is true if Destroys is over the project's
+	// threshold so applying requires --allow-destroy.
+	RequiresConfirmation bool
+}
+
+// summarizeDestroys parses the terraform plan output for the resources it
+// destroys. threshold is the project's ProjectConfig.DestroyThreshold.
+func summarizeDestroys(planOutput string, threshold int) DestroySummary {
+	var summary DestroySummary
+	if match := planSummaryRegex.FindStringSubmatch(planOutput); match != nil {
+		summary.Destroys, _ = strconv.Atoi(match[3])
+	}
+	summary.Replacements = len(replaceRegex.FindAllString(planOutput, -1))
+	summary.RequiresConfirmation = threshold >= 0 && summary.Destroys > threshold
+	return summary
+}
+
+// destroyConfirmationFile returns the path to the file next to planFile that
+// holds the number of resources it destroys. The file only exists if applying
+// the plan requires confirmation. ApplyExecutor uses it to refuse to apply
+// the plan without --allow-destroy.
+func destroyConfirmationFile(planFile string) string {
+	return planFile + ".destroys"
+}
@@ -0,0 +1,255 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/terraform"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/metrics"
+	"github.com/runatlantis/atlantis/server/redact"
+)
+
+// driftUser is the user that drift checks are run as. It's used for the
+// atlantis_user variable.
+const driftUser = "atlantis-drift-detection"
+
+// driftPull is the pull request that drift checks are cloned for. VCS hosts
+// number pull requests from 1 so its workspaces never collide with a real
+// pull request's. Cloning checks out the repo's default branch so checking
+// out HEAD keeps it.
+var driftPull = models.PullRequest{Num: 0, Branch: "HEAD"}
+
+// KnownProjectStore stores the projects and workspaces Atlantis has planned.
+type KnownProjectStore interface {
+	// RecordProject records that the project and workspace in p was planned.
+	RecordProject(p models.KnownProject) error
+	// ListProjects lists all recorded projects and workspaces.
+	ListProjects() ([]models.KnownProject, error)
+}
+
+// DriftResult is the result of checking a project and workspace for drift.
+type DriftResult struct {
+	Project   models.Project
+	Workspace string
+	// Drifted is true if planning the default branch had changes.
+	Drifted bool
+	// Summary is the plan's summary line if Drifted is true, ex.
+	// "Plan: 1 to add, 0 to change, 0 to destroy."
+	Summary string
+	// Output is the output of the plan.
+	Output string
+	// Error is set if the check failed.
+	Error string
+	Time  time.Time
+}
+
+// DriftDetector finds infrastructure that no longer matches what's in the
+// repo, ex. because someone changed it manually. It plans every known project
+// and workspace against its repo's default branch, without taking locks, and
+// reports the ones with changes.
+type DriftDetector struct {
+	Projects          KnownProjectStore
+	RepoWhitelist     *RepoWhitelist
+	EventParser       *EventParser
+	Workspace         AtlantisWorkspace
+	ProjectPreExecute *DefaultProjectPreExecutor
+	Terraform         terraform.Client
+	Webhooks          webhooks.DriftSender
+	Logger            *logging.SimpleLogger
+	// Metrics records the results of each run. If nil, they aren't
+	// recorded.
+	Metrics *metrics.Metrics
+	// Redactor masks secrets in the logs and results. Each check gets a
+	// child of it that's told the values of the project's sensitive
+	// variables. If nil, they aren't masked.
+	Redactor *redact.Redactor
+
+	mutex   sync.Mutex
+	lastRun time.Time
+	results map[string]DriftResult
+}
+
+// Start runs drift detection every interval. It never returns.
+func (d *DriftDetector) Start(interval time.Duration) {
+	d.Logger.Info("running drift detection every %s", interval)
+	for range time.Tick(interval) {
+		d.Run()
+	}
+}
+
+// Run checks every known project and workspace for drift once.
+func (d *DriftDetector) Run() {
+	projects, err := d.Projects.ListProjects()
+	if err != nil {
+		d.Logger.Err("listing projects for drift detection: %s", err)
+		return
+	}
+	d.Logger.Info("checking %d project workspace(s) for drift", len(projects))
+
+	// Projects in the same repo and workspace share a clone.
+	cloneDirs := make(map[string]string)
+	results := make(map[string]DriftResult)
+	for _, p := range projects {
+		if !d.RepoWhitelist.IsWhitelisted(p.Project.RepoFullName, p.Project.Hostname) {
+			continue
+		}
+		redactor := d.Redactor.Child()
+		log := logging.NewSimpleLogger(fmt.Sprintf("%s/%s drift", p.Project.Hostname, p.Project.RepoFullName), redactedLogger(d.Logger.Underlying(), redactor), false, d.Logger.GetLevel())
+		log.Format = d.Logger.GetFormat()
+		log = log.With("repo", p.Project.RepoFullName).With("project", p.Project.Path).With("workspace", p.Workspace)
+		result := DriftResult{Project: p.Project, Workspace: p.Workspace}
+		repo, err := d.EventParser.ParseRepo(p.Project.RepoFullName, p.SanitizedCloneURL)
+		if err != nil {
+			result.Error = err.Error()
+			results[d.key(p)] = d.finish(log, repo, result)
+			continue
+		}
+
+		cloneKey := fmt.Sprintf("%s/%s/%s", p.Project.Hostname, p.Project.RepoFullName, p.Workspace)
+		cloneDir, ok := cloneDirs[cloneKey]
+		if !ok {
+			cloneDir, err = d.Workspace.Clone(log, repo, repo, driftPull, p.Workspace)
+			if err != nil {
+				result.Error = err.Error()
+				results[d.key(p)] = d.finish(log, repo, result)
+				continue
+			}
+			cloneDirs[cloneKey] = cloneDir
+		}
+
+		// The project may only have existed in a pull request that was never
+		// merged or it may have since been deleted.
+		if _, err := os.Stat(filepath.Join(cloneDir, p.Project.Path)); err != nil {
+			log.Debug("skipping %q since it doesn't exist on the default branch", p.Project.Path)
+			continue
+		}
+		result = d.check(log, repo, cloneDir, result, redactor)
+		result.Output = redactor.Redact(result.Output)
+		result.Error = redactor.Redact(result.Error)
+		results[d.key(p)] = d.finish(log, repo, result)
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.results = results
+	d.lastRun = time.Now()
+
+	var drifted, clean, errored int
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			errored++
+		case r.Drifted:
+			drifted++
+		default:
+			clean++
+		}
+	}
+	d.Metrics.DriftChecked(drifted, clean, errored, d.lastRun)
+}
+
+// Results returns the results of the last run sorted by project and
+// workspace, and when it finished. The time is zero if it hasn't run yet.
+func (d *DriftDetector) Results() ([]DriftResult, time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	var keys []string
+	for k := range d.results {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var results []DriftResult
+	for _, k := range keys {
+		results = append(results, d.results[k])
+	}
+	return results, d.lastRun
+}
+
+// check plans the project in cloneDir and fills in result. The values of the
+// project's sensitive variables are added to redactor.
+func (d *DriftDetector) check(log *logging.SimpleLogger, repo models.Repo, cloneDir string, result DriftResult, redactor *redact.Redactor) DriftResult {
+	ctx := &CommandContext{
+		BaseRepo: repo,
+		HeadRepo: repo,
+		Pull:     driftPull,
+		User:     models.User{Username: driftUser},
+		Command:  &Command{Name: Plan, Workspace: result.Workspace},
+		Log:      log,
+		Redactor: redactor,
+	}
+	config, terraformVersion, _, err := d.ProjectPreExecute.executeWithLock(ctx, cloneDir, result.Project)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	projectDir := filepath.Join(cloneDir, result.Project.Path)
+	if config.Workflow.runsInit(Plan) {
+		// The workflow's steps aren't run since they might have side
+		// effects but we still need to init.
+		if _, err := d.ProjectPreExecute.init(ctx, cloneDir, result.Project, config, terraformVersion); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+	}
+
+	// We don't use -out since the plan is never applied and -lock=false so
+	// we don't block anyone's plans or applies on the state lock.
+	userVar := fmt.Sprintf("%s=%s", atlantisUserTFVar, driftUser)
+	tfPlanCmd := append([]string{"plan", "-refresh", "-no-color", "-lock=false", "-var", userVar}, planArgs(ctx, projectDir, config.GetExtraArguments(Plan.String()))...)
+	output, err := d.Terraform.RunCommandWithVersion(log, projectDir, tfPlanCmd, terraformVersion, result.Workspace)
+	result.Output = output
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if summary := planSummaryRegex.FindString(output); summary != "" {
+		result.Drifted = true
+		result.Summary = summary
+	}
+	return result
+}
+
+// finish logs result, sends webhooks if it drifted and returns it with its
+// time set.
+func (d *DriftDetector) finish(log *logging.SimpleLogger, repo models.Repo, result DriftResult) DriftResult {
+	result.Time = time.Now()
+	switch {
+	case result.Error != "":
+		log.Err("checking %q in workspace %q for drift: %s", result.Project.Path, result.Workspace, result.Error)
+	case result.Drifted:
+		log.Warn("drift detected in %q in workspace %q: %s", result.Project.Path, result.Workspace, result.Summary)
+		d.Webhooks.SendDrift(log, webhooks.DriftResult{ // nolint: errcheck
+			Repo:      repo,
+			Path:      result.Project.Path,
+			Workspace: result.Workspace,
+			Summary:   result.Summary,
+		})
+	default:
+		log.Info("no drift in %q in workspace %q", result.Project.Path, result.Workspace)
+	}
+	return result
+}
+
+func (d *DriftDetector) key(p models.KnownProject) string {
+	return fmt.Sprintf("%s/%s/%s/%s", p.Project.Hostname, p.Project.RepoFullName, p.Project.Path, p.Workspace)
+}
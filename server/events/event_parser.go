@@ -14,12 +14,15 @@
 package events
 
 import (
+	"fmt"
+	"net/url"
 	"regexp"
 
 	"github.com/google/go-github/github"
 	"github.com/lkysow/go-gitlab"
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
 )
 
 const gitlabPullOpened = "opened"
@@ -34,12 +37,30 @@ var multiLineRegex = regexp.MustCompile(`.*\r?\n.+`)
 type Command struct {
 	Name      CommandName
 	Workspace string
-	Verbose   bool
-	Flags     []string
-	// Dir is the path relative to the repo root to run the command in.
-	// If empty string then it wasn't specified. "." is the root of the repo.
-	// Dir will never end in "/".
-	Dir string
+	// WorkspaceSet is true if the workspace was set with -w. If it wasn't,
+	// plan also runs in the workspaces whose env/{workspace}.tfvars files
+	// were modified.
+	WorkspaceSet bool
+	// AllWorkspaces is true if the user wants to plan every workspace that
+	// has an env/{workspace}.tfvars file.
+	AllWorkspaces bool
+	Verbose       bool
+	Flags         []string
+	// Dirs are the paths relative to the repo root to run the command in.
+	// They can be globs. If empty then none were specified. "." is the root
+	// of the repo. Dirs will never end in "/".
+	Dirs []string
+	// ProjectNames are the names of the projects to run the command in, from
+	// their atlantis.yaml files.
+	ProjectNames []string
+	// All is true if the user wants to plan every project in the repo.
+	All bool
+	// OverridePolicies is true if the user wants to apply plans that failed
+	// their policy checks.
+	OverridePolicies bool
+	// AllowDestroy is true if the user confirmed applying plans that destroy
+	// resources.
+	AllowDestroy bool
 }
 
 type EventParsing interface {
@@ -52,10 +73,14 @@ type EventParsing interface {
 }
 
 type EventParser struct {
-	GithubUser  string
-	GithubToken string
-	GitlabUser  string
-	GitlabToken string
+	// GithubCreds maps the hostname of each GitHub install we support to the
+	// credentials embedded in its clone urls. If there's only one, it's used
+	// for every repo.
+	GithubCreds map[string]vcs.GithubCredentials
+	// GitlabCreds maps the hostname of each GitLab install we support to the
+	// credentials embedded in its clone urls. If there's only one, it's used
+	// for every repo.
+	GitlabCreds map[string]vcs.GitlabCredentials
 }
 
 func (e *EventParser) ParseGithubIssueCommentEvent(comment *github.IssueCommentEvent) (baseRepo models.Repo, user models.User, pullNum int, err error) {
@@ -125,7 +150,16 @@ func (e *EventParser) ParseGithubPull(pull *github.PullRequest) (models.PullRequ
 }
 
 func (e *EventParser) ParseGithubRepo(ghRepo *github.Repository) (models.Repo, error) {
-	return models.NewRepo(ghRepo.GetFullName(), ghRepo.GetCloneURL(), e.GithubUser, e.GithubToken)
+	hostname, ok := vcs.InstallHostname(e.GithubCreds, cloneURLHostname(ghRepo.GetCloneURL()))
+	if !ok {
+		return models.Repo{}, fmt.Errorf("no GitHub credentials configured for host %q", hostname)
+	}
+	creds := e.GithubCreds[hostname]
+	token, err := creds.GetToken()
+	if err != nil {
+		return models.Repo{}, errors.Wrap(err, "getting github token")
+	}
+	return models.NewRepo(ghRepo.GetFullName(), ghRepo.GetCloneURL(), creds.GetUser(), token)
 }
 
 func (e *EventParser) ParseGitlabMergeEvent(event gitlab.MergeEvent) (models.PullRequest, models.Repo, error) {
@@ -145,7 +179,7 @@ func (e *EventParser) ParseGitlabMergeEvent(event gitlab.MergeEvent) (models.Pul
 		State:      modelState,
 	}
 
-	repo, err := models.NewRepo(event.Project.PathWithNamespace, event.Project.GitHTTPURL, e.GitlabUser, e.GitlabToken)
+	repo, err := e.newGitlabRepo(event.Project.PathWithNamespace, event.Project.GitHTTPURL)
 	return pull, repo, err
 }
 
@@ -154,7 +188,7 @@ func (e *EventParser) ParseGitlabMergeCommentEvent(event gitlab.MergeCommentEven
 	// Parse the base repo first.
 	repoFullName := event.Project.PathWithNamespace
 	cloneURL := event.Project.GitHTTPURL
-	baseRepo, err = models.NewRepo(repoFullName, cloneURL, e.GitlabUser, e.GitlabToken)
+	baseRepo, err = e.newGitlabRepo(repoFullName, cloneURL)
 	if err != nil {
 		return
 	}
@@ -165,10 +199,57 @@ func (e *EventParser) ParseGitlabMergeCommentEvent(event gitlab.MergeCommentEven
 	// Now parse the head repo.
 	headRepoFullName := event.MergeRequest.Source.PathWithNamespace
 	headCloneURL := event.MergeRequest.Source.GitHTTPURL
-	headRepo, err = models.NewRepo(headRepoFullName, headCloneURL, e.GitlabUser, e.GitlabToken)
+	headRepo, err = e.newGitlabRepo(headRepoFullName, headCloneURL)
 	return
 }
 
+// newGitlabRepo constructs a repo using the credentials for the GitLab
+// install at cloneURL's host.
+func (e *EventParser) newGitlabRepo(repoFullName string, cloneURL string) (models.Repo, error) {
+	hostname, ok := vcs.InstallHostname(e.GitlabCreds, cloneURLHostname(cloneURL))
+	if !ok {
+		return models.Repo{}, fmt.Errorf("no GitLab credentials configured for host %q", hostname)
+	}
+	creds := e.GitlabCreds[hostname]
+	return models.NewRepo(repoFullName, cloneURL, creds.User, creds.Token)
+}
+
+// ParseRepo constructs a repo from its full name and clone url using the
+// credentials for the GitHub or GitLab install at the clone url's host. If
+// only one install is configured, its credentials are used for every repo.
+func (e *EventParser) ParseRepo(repoFullName string, cloneURL string) (models.Repo, error) {
+	hostname := cloneURLHostname(cloneURL)
+	_, isGithub := e.GithubCreds[hostname]
+	_, isGitlab := e.GitlabCreds[hostname]
+	// We can only fall back to a single install if there's no install of the
+	// other VCS host, otherwise we can't tell which one the repo is on.
+	if !isGithub && !isGitlab {
+		if len(e.GitlabCreds) == 0 {
+			_, isGithub = vcs.InstallHostname(e.GithubCreds, hostname)
+		} else if len(e.GithubCreds) == 0 {
+			_, isGitlab = vcs.InstallHostname(e.GitlabCreds, hostname)
+		}
+	}
+	switch {
+	case isGithub:
+		return e.ParseGithubRepo(&github.Repository{FullName: &repoFullName, CloneURL: &cloneURL})
+	case isGitlab:
+		return e.newGitlabRepo(repoFullName, cloneURL)
+	default:
+		return models.Repo{}, fmt.Errorf("no credentials configured for host %q", hostname)
+	}
+}
+
+// cloneURLHostname returns the hostname of cloneURL or an empty string if it
+// can't be parsed.
+func cloneURLHostname(cloneURL string) string {
+	u, err := url.Parse(cloneURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
 func (e *EventParser) ParseGitlabMergeRequest(mr *gitlab.MergeRequest) models.PullRequest {
 	pullState := models.Closed
 	if mr.State == gitlabPullOpened {
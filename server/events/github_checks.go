@@ -0,0 +1,228 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events
+
+import (
+	"bufio"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/redact"
+)
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_github_check_run_creator.go GithubCheckRunCreator
+
+// GithubCheckRunCreator makes API calls to create GitHub check runs.
+type GithubCheckRunCreator interface {
+	// CreateCheckRun creates run on the head commit of pull.
+	CreateCheckRun(repo models.Repo, pull models.PullRequest, run vcs.CheckRun) error
+}
+
+// ApplyCheckRunAction is the identifier of the check run action that applies
+// the project's plan.
+const ApplyCheckRunAction = "apply"
+
+// maxCheckRunText is the most characters GitHub allows in a check run's text.
+const maxCheckRunText = 65535
+
+// summaryRegex matches the line of terraform output that summarizes a plan or
+// apply.
+var summaryRegex = regexp.MustCompile(`(?m)^(Plan: .*|No changes\..*|Apply complete!.*)$`)
+
+// terraformErrorRegex and terraformErrorLocationRegex match the error
+// messages of Terraform 0.12+ and the line after them with the file and line
+// number, ex. "  on main.tf line 3, in resource ...".
+var terraformErrorRegex = regexp.MustCompile(`^Error: (.+)$`)
+var terraformErrorLocationRegex = regexp.MustCompile(`^\s*on (\S+\.tf) line (\d+)`)
+
+// GithubChecksUpdater creates a GitHub check run for each project result
+// with the terraform output, so it's easier to read than in a comment.
+type GithubChecksUpdater struct {
+	Creator GithubCheckRunCreator
+	// Redactor masks secrets in the output of commands without their own
+	// Redactor. If nil, it's sent as is.
+	Redactor *redact.Redactor
+}
+
+// Update creates a check run for each project in res.
+func (g *GithubChecksUpdater) Update(ctx *CommandContext, res CommandResponse) error {
+	var firstErr error
+	for _, result := range res.ProjectResults {
+		run := g.checkRun(ctx, result)
+		// Keep going so one failure doesn't stop the other projects' check
+		// runs from being created.
+		if err := g.Creator.CreateCheckRun(ctx.BaseRepo, ctx.Pull, run); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "creating check run %q", run.Name)
+		}
+	}
+	return firstErr
+}
+
+func (g *GithubChecksUpdater) checkRun(ctx *CommandContext, result ProjectResult) vcs.CheckRun {
+	result = g.redact(ctx, result)
+	command := ctx.Command.Name.String()
+	commandTitle := strings.Title(command)
+	workspace := result.Workspace
+	if workspace == "" {
+		workspace = ctx.Command.Workspace
+	}
+	run := vcs.CheckRun{
+		Name:       vcs.ProjectStatusContext(command, result.Path, workspace),
+		ExternalID: CheckRunExternalID(result.Path, workspace),
+		Status:     "completed",
+	}
+
+	switch {
+	case result.Error != nil:
+		run.Conclusion = "failure"
+		run.Output = vcs.CheckRunOutput{
+			Title:       commandTitle + " Error",
+			Summary:     fmt.Sprintf("%s failed with an error.", commandTitle),
+			Text:        checkRunText("", result.Error.Error()),
+			Annotations: terraformErrorAnnotations(result.Path, result.Error.Error()),
+		}
+	case result.Failure != "":
+		run.Conclusion = "failure"
+		run.Output = vcs.CheckRunOutput{Title: commandTitle + " Failed", Summary: result.Failure}
+	case result.Skipped != "":
+		run.Conclusion = "neutral"
+		run.Output = vcs.CheckRunOutput{Title: commandTitle + " Skipped", Summary: result.Skipped}
+	case result.PlanSuccess != nil:
+		run.Conclusion = "success"
+		run.Output = vcs.CheckRunOutput{
+			Title:   "Plan Succeeded",
+			Summary: outputSummary(result.PlanSuccess.TerraformOutput, "Plan succeeded."),
+			Text:    checkRunText("diff", result.PlanSuccess.TerraformOutput),
+		}
+		if check := result.PlanSuccess.PolicyCheck; check != nil && !check.Passed() {
+			run.Output.Summary += "\n\nThe plan failed its policy checks."
+		} else {
+			run.Actions = []vcs.CheckRunAction{{
+				Label:       "Apply",
+				Description: "Apply this plan.",
+				Identifier:  ApplyCheckRunAction,
+			}}
+		}
+	default:
+		run.Conclusion = "success"
+		run.Output = vcs.CheckRunOutput{
+			Title:   "Apply Succeeded",
+			Summary: outputSummary(result.ApplySuccess, "Apply succeeded."),
+			Text:    checkRunText("diff", result.ApplySuccess),
+		}
+	}
+	return run
+}
+
+// redact returns result with its secrets masked, using ctx's Redactor if it
+// has one. It's done before the check run is built so truncating the output
+// can't leave part of a secret.
+func (g *GithubChecksUpdater) redact(ctx *CommandContext, result ProjectResult) ProjectResult {
+	redactor := g.Redactor
+	if ctx.Redactor != nil {
+		redactor = ctx.Redactor
+	}
+	if redactor == nil {
+		return result
+	}
+	if result.Error != nil {
+		result.Error = errors.New(redactor.Redact(result.Error.Error()))
+	}
+	result.Failure = redactor.Redact(result.Failure)
+	result.Skipped = redactor.Redact(result.Skipped)
+	result.ApplySuccess = redactor.Redact(result.ApplySuccess)
+	if result.PlanSuccess != nil {
+		planSuccess := *result.PlanSuccess
+		planSuccess.TerraformOutput = redactor.Redact(planSuccess.TerraformOutput)
+		result.PlanSuccess = &planSuccess
+	}
+	return result
+}
+
+// CheckRunExternalID returns the external ID of the check run for the project
+// at projectPath in workspace. Workspaces can't contain "/" so it's used as
+// the separator.
+func CheckRunExternalID(projectPath string, workspace string) string {
+	return workspace + "/" + projectPath
+}
+
+// ParseCheckRunExternalID returns the project path and workspace from an ID
+// created by CheckRunExternalID. ok is false if id isn't valid.
+func ParseCheckRunExternalID(id string) (projectPath string, workspace string, ok bool) {
+	parts := strings.SplitN(id, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[0], true
+}
+
+// outputSummary returns the line of terraform output that summarizes it or
+// fallback if there isn't one.
+func outputSummary(output string, fallback string) string {
+	if summary := summaryRegex.FindString(output); summary != "" {
+		return summary
+	}
+	return fallback
+}
+
+// checkRunText returns output in a code block, truncated to fit in a check
+// run.
+func checkRunText(lang string, output string) string {
+	const truncated = "\n...truncated"
+	wrapperLen := len("```"+lang+"\n") + len("\n```")
+	if len(output)+wrapperLen > maxCheckRunText {
+		output = output[:maxCheckRunText-wrapperLen-len(truncated)] + truncated
+	}
+	return fmt.Sprintf("```%s\n%s\n```", lang, output)
+}
+
+// terraformErrorAnnotations returns annotations for the errors in terraform
+// output that say which file and line they're for. projectPath is the path
+// of the project relative to the repo root.
+func terraformErrorAnnotations(projectPath string, output string) []vcs.CheckRunAnnotation {
+	var annotations []vcs.CheckRunAnnotation
+	var message string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := terraformErrorRegex.FindStringSubmatch(line); match != nil {
+			message = match[1]
+			continue
+		}
+		match := terraformErrorLocationRegex.FindStringSubmatch(line)
+		if match == nil || message == "" {
+			continue
+		}
+		lineNum, err := strconv.Atoi(match[2])
+		if err != nil {
+			continue
+		}
+		annotations = append(annotations, vcs.CheckRunAnnotation{
+			Path:      path.Join(projectPath, match[1]),
+			StartLine: lineNum,
+			EndLine:   lineNum,
+			Level:     "failure",
+			Title:     "Terraform Error",
+			Message:   message,
+		})
+		message = ""
+	}
+	return annotations
+}
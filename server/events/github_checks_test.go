@@ -0,0 +1,139 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/mocks"
+	"github.com/runatlantis/atlantis/server/events/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/redact"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestGithubChecksUpdater_Update(t *testing.T) {
+	t.Log("should create a check run for each project with its output")
+	RegisterMockTestingT(t)
+	creator := mocks.NewMockGithubCheckRunCreator()
+	g := events.GithubChecksUpdater{Creator: creator}
+	ctx := &events.CommandContext{
+		BaseRepo: repoModel,
+		Pull:     pullModel,
+		Command:  &events.Command{Name: events.Plan, Workspace: "default"},
+	}
+	planOutput := "+ null_resource.a\n\nPlan: 1 to add, 0 to change, 0 to destroy."
+	errOutput := "exit status 1\nError: Unsupported argument\n\n  on main.tf line 3, in resource \"null_resource\" \"a\":\n   3:   foo = \"bar\"\n"
+	err := g.Update(ctx, events.CommandResponse{ProjectResults: []events.ProjectResult{
+		{Path: "vpc", Workspace: "default", PlanSuccess: &events.PlanSuccess{TerraformOutput: planOutput}},
+		{Path: "app", Workspace: "staging", Error: errors.New(errOutput)},
+	}})
+	Ok(t, err)
+
+	creator.VerifyWasCalledOnce().CreateCheckRun(repoModel, pullModel, vcs.CheckRun{
+		Name:       "atlantis/plan: vpc/default",
+		ExternalID: "default/vpc",
+		Status:     "completed",
+		Conclusion: "success",
+		Output: vcs.CheckRunOutput{
+			Title:   "Plan Succeeded",
+			Summary: "Plan: 1 to add, 0 to change, 0 to destroy.",
+			Text:    "```diff\n" + planOutput + "\n```",
+		},
+		Actions: []vcs.CheckRunAction{{Label: "Apply", Description: "Apply this plan.", Identifier: "apply"}},
+	})
+	creator.VerifyWasCalledOnce().CreateCheckRun(repoModel, pullModel, vcs.CheckRun{
+		Name:       "atlantis/plan: app/staging",
+		ExternalID: "staging/app",
+		Status:     "completed",
+		Conclusion: "failure",
+		Output: vcs.CheckRunOutput{
+			Title:   "Plan Error",
+			Summary: "Plan failed with an error.",
+			Text:    "```\n" + errOutput + "\n```",
+			Annotations: []vcs.CheckRunAnnotation{{
+				Path:      "app/main.tf",
+				StartLine: 3,
+				EndLine:   3,
+				Level:     "failure",
+				Title:     "Terraform Error",
+				Message:   "Unsupported argument",
+			}},
+		},
+	})
+}
+
+func TestGithubChecksUpdater_TruncatesText(t *testing.T) {
+	t.Log("should truncate output that's too long for a check run")
+	RegisterMockTestingT(t)
+	creator := mocks.NewMockGithubCheckRunCreator()
+	g := events.GithubChecksUpdater{Creator: creator}
+	ctx := &events.CommandContext{
+		Command: &events.Command{Name: events.Apply, Workspace: "default"},
+	}
+	err := g.Update(ctx, events.CommandResponse{ProjectResults: []events.ProjectResult{
+		{Path: ".", ApplySuccess: strings.Repeat("a", 70000)},
+	}})
+	Ok(t, err)
+	_, _, run := creator.VerifyWasCalledOnce().CreateCheckRun(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsCheckRun()).GetCapturedArguments()
+	Equals(t, 65535, len(run.Output.Text))
+	Assert(t, strings.HasSuffix(run.Output.Text, "...truncated\n```"), "exp text to be truncated")
+}
+
+func TestGithubChecksUpdater_Redactor(t *testing.T) {
+	t.Log("should mask secrets in the output")
+	RegisterMockTestingT(t)
+	creator := mocks.NewMockGithubCheckRunCreator()
+	redactor, err := redact.New(nil)
+	Ok(t, err)
+	redactor.AddSecret("hunter2")
+	g := events.GithubChecksUpdater{Creator: creator, Redactor: redactor}
+	ctx := &events.CommandContext{
+		Command: &events.Command{Name: events.Plan, Workspace: "default"},
+	}
+	planSuccess := &events.PlanSuccess{TerraformOutput: "password: hunter2\n\nPlan: 1 to add, 0 to change, 0 to destroy."}
+	err = g.Update(ctx, events.CommandResponse{ProjectResults: []events.ProjectResult{
+		{Path: ".", PlanSuccess: planSuccess},
+	}})
+	Ok(t, err)
+	_, _, run := creator.VerifyWasCalledOnce().CreateCheckRun(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsCheckRun()).GetCapturedArguments()
+	Equals(t, "```diff\npassword: [REDACTED]\n\nPlan: 1 to add, 0 to change, 0 to destroy.\n```", run.Output.Text)
+	t.Log("the result itself shouldn't be modified")
+	Equals(t, "password: hunter2\n\nPlan: 1 to add, 0 to change, 0 to destroy.", planSuccess.TerraformOutput)
+
+	t.Log("the command's secrets should be masked too")
+	ctx.Redactor = redactor.Child()
+	ctx.Redactor.AddSecret("swordfish")
+	planSuccess = &events.PlanSuccess{TerraformOutput: "hunter2 swordfish"}
+	err = g.Update(ctx, events.CommandResponse{ProjectResults: []events.ProjectResult{
+		{Path: ".", PlanSuccess: planSuccess},
+	}})
+	Ok(t, err)
+	_, _, run = creator.VerifyWasCalled(Times(2)).CreateCheckRun(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsCheckRun()).GetCapturedArguments()
+	Equals(t, "```diff\n[REDACTED] [REDACTED]\n```", run.Output.Text)
+}
+
+func TestParseCheckRunExternalID(t *testing.T) {
+	projectPath, workspace, ok := events.ParseCheckRunExternalID(events.CheckRunExternalID("dir/subdir", "staging"))
+	Equals(t, true, ok)
+	Equals(t, "dir/subdir", projectPath)
+	Equals(t, "staging", workspace)
+
+	_, _, ok = events.ParseCheckRunExternalID("nope")
+	Equals(t, false, ok)
+}
@@ -0,0 +1,416 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// hclBody is the body of a Terraform file or of one of its blocks as found
+// by scanHCL.
+type hclBody struct {
+	// Attributes maps the names of the body's attributes to the source of
+	// their expressions, ex. `"../modules/vpc"` or `var.cidr`.
+	Attributes map[string]string
+	Blocks     []hclBlock
+}
+
+// hclBlock is a block in a Terraform file, ex. module "vpc" { ... }.
+type hclBlock struct {
+	Type   string
+	Labels []string
+	Body   hclBody
+}
+
+// scanHCLFile scans the Terraform file at filename with scanHCL.
+func scanHCLFile(filename string) (hclBody, error) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return hclBody{}, err
+	}
+	return scanHCL(src)
+}
+
+// scanHCL finds the attributes and blocks in the Terraform configuration src.
+// It isn't a full parser: it only understands enough of the syntax, ex.
+// strings, heredocs, comments and brackets, to find where blocks and
+// attributes start and end so it works with both the 0.11 and 0.12+ syntax.
+// Expressions are returned as source rather than evaluated. It errors if src
+// has an unterminated string, heredoc, comment or block.
+//
+// We don't use a parser library since the vendored github.com/hashicorp/hcl
+// only parses the 0.11 syntax and fails on 0.12 expressions like for and
+// conditionals, while HCL2, which parses 0.12+, doesn't understand 0.11
+// files, would be a large new dependency and we only need module sources
+// and variable declarations.
+func scanHCL(src []byte) (hclBody, error) {
+	tokens, err := hclTokens(string(src))
+	if err != nil {
+		return hclBody{}, err
+	}
+	s := &hclScanner{src: string(src), tokens: tokens}
+	body := s.body()
+	if s.err != nil {
+		return hclBody{}, s.err
+	}
+	if s.pos < len(s.tokens) {
+		return hclBody{}, errors.Errorf("unexpected %q on line %d", s.tokens[s.pos].text, s.tokens[s.pos].line)
+	}
+	return body, nil
+}
+
+// hclString returns the value of expr if it's a string literal without any
+// interpolation, ex. `"../modules/vpc"`.
+func hclString(expr string) (string, bool) {
+	if len(expr) < 2 || expr[0] != '"' || strings.Contains(expr, "${") || strings.Contains(expr, "%{") {
+		return "", false
+	}
+	value, err := strconv.Unquote(expr)
+	if err != nil {
+		return "", false
+	}
+	return value, true
+}
+
+type hclTokenKind int
+
+const (
+	hclIdentToken hclTokenKind = iota
+	hclStringToken
+	hclNewlineToken
+	hclPunctToken
+	hclOtherToken
+)
+
+type hclToken struct {
+	kind hclTokenKind
+	text string
+	// start and end are the token's offsets in the source.
+	start int
+	end   int
+	line  int
+}
+
+// hclTokens splits src into tokens. Comments are dropped and strings and
+// heredocs, including any interpolations in them, are single tokens.
+func hclTokens(src string) ([]hclToken, error) {
+	var tokens []hclToken
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		start := i
+		switch {
+		case c == '\n':
+			tokens = append(tokens, hclToken{kind: hclNewlineToken, text: "\n", start: i, end: i + 1, line: line})
+			line++
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+			continue
+		case c == '#' || strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end == -1 {
+				return nil, errors.Errorf("unterminated comment on line %d", line)
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += 2 + end + 2
+			continue
+		case c == '"':
+			end, err := hclStringEnd(src, i)
+			if err != nil {
+				return nil, errors.Wrapf(err, "line %d", line)
+			}
+			i = end
+			tokens = append(tokens, hclToken{kind: hclStringToken, text: src[start:i], start: start, end: i, line: line})
+			line += strings.Count(src[start:i], "\n")
+			continue
+		case strings.HasPrefix(src[i:], "<<") && hclHeredocMarker(src[i:]) != "":
+			marker := hclHeredocMarker(src[i:])
+			nl := strings.IndexByte(src[i:], '\n')
+			if nl == -1 {
+				return nil, errors.Errorf("unterminated heredoc on line %d", line)
+			}
+			i += nl + 1
+			found := false
+			for i < len(src) {
+				lineEnd := strings.IndexByte(src[i:], '\n')
+				if lineEnd == -1 {
+					lineEnd = len(src) - i
+				}
+				text := strings.TrimSpace(src[i : i+lineEnd])
+				i += lineEnd
+				if text == marker {
+					found = true
+					break
+				}
+				i++
+			}
+			if !found {
+				return nil, errors.Errorf("unterminated heredoc on line %d", line)
+			}
+			tokens = append(tokens, hclToken{kind: hclOtherToken, text: src[start:i], start: start, end: i, line: line})
+			line += strings.Count(src[start:i], "\n")
+			continue
+		case isHCLIdentStart(c):
+			for i < len(src) && isHCLIdentChar(src[i]) {
+				i++
+			}
+			tokens = append(tokens, hclToken{kind: hclIdentToken, text: src[start:i], start: start, end: i, line: line})
+			continue
+		case strings.ContainsRune("<>!=", rune(c)) && i+1 < len(src) && (src[i+1] == '=' || (c == '=' && src[i+1] == '>')):
+			// Operators like == and => aren't assignments.
+			i += 2
+			tokens = append(tokens, hclToken{kind: hclOtherToken, text: src[start:i], start: start, end: i, line: line})
+			continue
+		case strings.ContainsRune("{}[]()=", rune(c)):
+			i++
+			tokens = append(tokens, hclToken{kind: hclPunctToken, text: src[start:i], start: start, end: i, line: line})
+			continue
+		default:
+			i++
+			tokens = append(tokens, hclToken{kind: hclOtherToken, text: src[start:i], start: start, end: i, line: line})
+		}
+	}
+	return tokens, nil
+}
+
+// hclStringEnd returns the offset just past the end of the string starting
+// at src[start], skipping over escapes and ${ } and %{ } sequences, which can
+// contain strings themselves.
+func hclStringEnd(src string, start int) (int, error) {
+	for i := start + 1; i < len(src); i++ {
+		switch {
+		case src[i] == '\\':
+			i++
+		case src[i] == '"':
+			return i + 1, nil
+		case src[i] == '\n':
+			return 0, errors.New("unterminated string")
+		case strings.HasPrefix(src[i:], "$${") || strings.HasPrefix(src[i:], "%%{"):
+			i += 2
+		case strings.HasPrefix(src[i:], "${") || strings.HasPrefix(src[i:], "%{"):
+			end, err := hclTemplateEnd(src, i+2)
+			if err != nil {
+				return 0, err
+			}
+			i = end - 1
+		}
+	}
+	return 0, errors.New("unterminated string")
+}
+
+// hclTemplateEnd returns the offset just past the } that closes the
+// interpolation whose expression starts at src[start].
+func hclTemplateEnd(src string, start int) (int, error) {
+	depth := 0
+	for i := start; i < len(src); i++ {
+		switch src[i] {
+		case '"':
+			end, err := hclStringEnd(src, i)
+			if err != nil {
+				return 0, err
+			}
+			i = end - 1
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i + 1, nil
+			}
+			depth--
+		}
+	}
+	return 0, errors.New("unterminated interpolation")
+}
+
+// hclHeredocMarker returns the marker of the heredoc starting at src, ex.
+// EOF for <<EOF or <<-EOF, or "" if src doesn't start a heredoc.
+func hclHeredocMarker(src string) string {
+	i := 2
+	if i < len(src) && src[i] == '-' {
+		i++
+	}
+	start := i
+	for i < len(src) && isHCLIdentChar(src[i]) {
+		i++
+	}
+	if i == start || !isHCLIdentStart(src[start]) {
+		return ""
+	}
+	if rest := strings.TrimRight(src[i:strings.IndexAny(src+"\n", "\n")], " \t\r"); rest != "" {
+		return ""
+	}
+	return src[start:i]
+}
+
+func isHCLIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isHCLIdentChar(c byte) bool {
+	return isHCLIdentStart(c) || c == '-' || (c >= '0' && c <= '9')
+}
+
+// hclScanner finds the attributes and blocks in a list of tokens.
+type hclScanner struct {
+	src    string
+	tokens []hclToken
+	pos    int
+	// err is set if a block isn't closed.
+	err error
+}
+
+func (s *hclScanner) peek(offset int) (hclToken, bool) {
+	if s.pos+offset >= len(s.tokens) {
+		return hclToken{}, false
+	}
+	return s.tokens[s.pos+offset], true
+}
+
+// body scans attributes and blocks until the } that closes the body, which
+// isn't consumed, or the end of the tokens.
+func (s *hclScanner) body() hclBody {
+	body := hclBody{Attributes: make(map[string]string)}
+	for {
+		tok, ok := s.peek(0)
+		if !ok || (tok.kind == hclPunctToken && tok.text == "}") {
+			return body
+		}
+		if tok.kind == hclNewlineToken || tok.text == "," {
+			s.pos++
+			continue
+		}
+		next, _ := s.peek(1)
+		if (tok.kind == hclIdentToken || tok.kind == hclStringToken) && next.kind == hclPunctToken && next.text == "=" {
+			name := tok.text
+			if tok.kind == hclStringToken {
+				name, _ = hclString(name)
+			}
+			s.pos += 2
+			body.Attributes[name] = s.expression()
+			continue
+		}
+		if tok.kind == hclIdentToken {
+			if block, ok := s.block(); ok {
+				body.Blocks = append(body.Blocks, block)
+				continue
+			}
+		}
+		// We don't understand this line so skip it.
+		s.skipLine()
+	}
+}
+
+// block scans a block starting at the current token. If the tokens aren't a
+// block it returns false and doesn't move.
+func (s *hclScanner) block() (hclBlock, bool) {
+	start := s.pos
+	block := hclBlock{Type: s.tokens[s.pos].text}
+	s.pos++
+	for {
+		tok, ok := s.peek(0)
+		if !ok {
+			s.pos = start
+			return hclBlock{}, false
+		}
+		switch {
+		case tok.kind == hclStringToken:
+			label, _ := hclString(tok.text)
+			block.Labels = append(block.Labels, label)
+		case tok.kind == hclIdentToken:
+			block.Labels = append(block.Labels, tok.text)
+		case tok.kind == hclPunctToken && tok.text == "{":
+			s.pos++
+			block.Body = s.body()
+			if _, ok := s.peek(0); !ok {
+				s.err = errors.Errorf("unterminated %s block on line %d", block.Type, s.tokens[start].line)
+				return block, true
+			}
+			// Skip the }.
+			s.pos++
+			return block, true
+		default:
+			s.pos = start
+			return hclBlock{}, false
+		}
+		s.pos++
+	}
+}
+
+// expression returns the source of the expression starting at the current
+// token. It ends at a newline, comma or } that isn't inside brackets.
+func (s *hclScanner) expression() string {
+	start := -1
+	end := -1
+	depth := 0
+	for ; s.pos < len(s.tokens); s.pos++ {
+		tok := s.tokens[s.pos]
+		if depth == 0 && (tok.kind == hclNewlineToken || tok.text == "," || (tok.kind == hclPunctToken && strings.Contains("}])", tok.text))) {
+			break
+		}
+		if tok.kind == hclPunctToken {
+			switch tok.text {
+			case "{", "[", "(":
+				depth++
+			case "}", "]", ")":
+				depth--
+			}
+		}
+		if start == -1 {
+			start = tok.start
+		}
+		end = tok.end
+	}
+	if start == -1 {
+		return ""
+	}
+	return s.src[start:end]
+}
+
+// skipLine skips to the next newline that isn't inside brackets, or the }
+// that closes the current body. It always skips at least one token.
+func (s *hclScanner) skipLine() {
+	depth := 0
+	for ; s.pos < len(s.tokens); s.pos++ {
+		tok := s.tokens[s.pos]
+		if tok.kind == hclPunctToken {
+			switch tok.text {
+			case "{", "[", "(":
+				depth++
+			case "}":
+				if depth == 0 {
+					return
+				}
+				depth--
+			case "]", ")":
+				if depth > 0 {
+					depth--
+				}
+			}
+		}
+		if depth == 0 && tok.kind == hclNewlineToken {
+			return
+		}
+	}
+}
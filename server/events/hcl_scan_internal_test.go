@@ -0,0 +1,105 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events
+
+import (
+	"testing"
+
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestScanHCL(t *testing.T) {
+	body, err := scanHCL([]byte(`# comment
+region = "us-east-1"
+"quoted" = 1
+list = [
+  "a", # }
+  "b",
+]
+
+variable "password" {
+  type      = string
+  sensitive = true
+  validation {
+    condition     = length(var.password) >= 8
+    error_message = "Too short."
+  }
+}
+
+variable "inline" { default = "${var.a}" }
+
+variable legacy {
+  type = "map"
+  default = {
+    a = "b"
+  }
+}
+`))
+	Ok(t, err)
+	Equals(t, map[string]string{
+		"region": `"us-east-1"`,
+		"quoted": "1",
+		"list":   "[\n  \"a\", # }\n  \"b\",\n]",
+	}, body.Attributes)
+	Equals(t, 3, len(body.Blocks))
+
+	password := body.Blocks[0]
+	Equals(t, "variable", password.Type)
+	Equals(t, []string{"password"}, password.Labels)
+	Equals(t, map[string]string{"type": "string", "sensitive": "true"}, password.Body.Attributes)
+	Equals(t, 1, len(password.Body.Blocks))
+	Equals(t, "length(var.password) >= 8", password.Body.Blocks[0].Body.Attributes["condition"])
+
+	inline := body.Blocks[1]
+	Equals(t, []string{"inline"}, inline.Labels)
+	Equals(t, `"${var.a}"`, inline.Body.Attributes["default"])
+
+	legacy := body.Blocks[2]
+	Equals(t, []string{"legacy"}, legacy.Labels)
+	Equals(t, "{\n    a = \"b\"\n  }", legacy.Body.Attributes["default"])
+}
+
+func TestScanHCL_Errors(t *testing.T) {
+	cases := map[string]string{
+		"a = \"unterminated\n":      "line 1: unterminated string",
+		"a = \"${b(\"c\")\"\n":      "line 1: unterminated string",
+		"/* a = 1\n":                "unterminated comment on line 1",
+		"a = <<EOF\nb\n":            "unterminated heredoc on line 1",
+		"module \"a\" {\n  b = 1\n": "unterminated module block on line 1",
+		"a = 1\n}\n":                "unexpected \"}\" on line 2",
+	}
+	for src, expErr := range cases {
+		_, err := scanHCL([]byte(src))
+		ErrEquals(t, expErr, err)
+	}
+}
+
+func TestHCLString(t *testing.T) {
+	cases := []struct {
+		expr  string
+		exp   string
+		expOK bool
+	}{
+		{`"../modules/vpc"`, "../modules/vpc", true},
+		{`"a \"quoted\" value"`, `a "quoted" value`, true},
+		{`"${var.source}"`, "", false},
+		{`var.source`, "", false},
+		{`true`, "", false},
+	}
+	for _, c := range cases {
+		value, ok := hclString(c.expr)
+		Equals(t, c.exp, value)
+		Equals(t, c.expOK, ok)
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events
+
+import (
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/events/run"
+)
+
+// The values of a hook's show setting in the project config file. They
+// control when the output of the hook's commands is shown in the pull
+// request comment.
+const (
+	ShowHookOutputAlways    = "always"
+	ShowHookOutputOnFailure = "on_failure"
+	ShowHookOutputNever     = "never"
+)
+
+// HookOutput is the output of the commands of a hook, ex. pre_plan.
+type HookOutput struct {
+	// Stage is the name of the hook, ex. pre_plan.
+	Stage  string
+	Output string
+	// Failed is true if the commands failed.
+	Failed bool
+}
+
+// runHook runs the commands of the hook for stage and returns outputs with
+// the hook's output appended if the project config says to show it.
+func runHook(r run.Runner, ctx *CommandContext, config ProjectConfig, commands []string, env run.Env, stage string, outputs []HookOutput) ([]HookOutput, error) {
+	output, err := r.Execute(ctx.Log, commands, env, stage)
+	if config.showHookOutput(stage, err != nil) && (output != "" || err != nil) {
+		outputs = append(outputs, HookOutput{Stage: stage, Output: strings.TrimRight(output, "\n"), Failed: err != nil})
+	}
+	return outputs, err
+}
@@ -31,12 +31,22 @@ import (
 
 // BoltLocker is a locking backend using BoltDB
 type BoltLocker struct {
-	db     *bolt.DB
-	bucket []byte
+	db             *bolt.DB
+	bucket         []byte
+	deliveryBucket []byte
+	projectBucket  []byte
+	// unmigrated describes the locks that migrateLockKeys couldn't migrate.
+	unmigrated []string
 }
 
 const bucketName = "runLocks"
 
+// keySeparator separates the repo from the path in lock keys. It's the same
+// as in the keys the locking package gives to the UI.
+const keySeparator = "/-/"
+const deliveryBucketName = "deliveries"
+const projectBucketName = "knownProjects"
+
 // New returns a valid locker. We need to be able to write to dataDir
 // since bolt stores its data as a file
 func New(dataDir string) (*BoltLocker, error) {
@@ -54,18 +64,49 @@ func New(dataDir string) (*BoltLocker, error) {
 		if _, err = tx.CreateBucketIfNotExists([]byte(bucketName)); err != nil {
 			return errors.Wrapf(err, "creating %q bucketName", bucketName)
 		}
+		if _, err = tx.CreateBucketIfNotExists([]byte(deliveryBucketName)); err != nil {
+			return errors.Wrapf(err, "creating %q bucketName", deliveryBucketName)
+		}
+		if _, err = tx.CreateBucketIfNotExists([]byte(projectBucketName)); err != nil {
+			return errors.Wrapf(err, "creating %q bucketName", projectBucketName)
+		}
 		return nil
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "starting BoltDB")
 	}
+	b := &BoltLocker{db: db, bucket: []byte(bucketName), deliveryBucket: []byte(deliveryBucketName), projectBucket: []byte(projectBucketName)}
+	if err := b.migrateLockKeys(); err != nil {
+		return nil, errors.Wrap(err, "starting BoltDB")
+	}
 	// todo: close BoltDB when server is sigtermed
-	return &BoltLocker{db, []byte(bucketName)}, nil
+	return b, nil
 }
 
 // NewWithDB is used for testing.
 func NewWithDB(db *bolt.DB, bucket string) (*BoltLocker, error) {
-	return &BoltLocker{db, []byte(bucket)}, nil
+	err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists([]byte(deliveryBucketName)); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists([]byte(projectBucketName))
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating buckets")
+	}
+	b := &BoltLocker{db: db, bucket: []byte(bucket), deliveryBucket: []byte(deliveryBucketName), projectBucket: []byte(projectBucketName)}
+	if err := b.migrateLockKeys(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// UnmigratedLocks describes the locks from before lock keys included the VCS
+// hostname that couldn't be migrated on startup. They still block their
+// projects until they're discarded in the UI.
+func (b *BoltLocker) UnmigratedLocks() []string {
+	return b.unmigrated
 }
 
 // TryLock attempts to create a new lock. If the lock is
@@ -160,18 +201,22 @@ func (b BoltLocker) List() ([]models.ProjectLock, error) {
 }
 
 // UnlockByPull deletes all locks associated with that pull request and returns them.
-func (b BoltLocker) UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error) {
+func (b BoltLocker) UnlockByPull(hostname string, repoFullName string, pullNum int) ([]models.ProjectLock, error) {
 	var locks []models.ProjectLock
 	err := b.db.View(func(tx *bolt.Tx) error {
 		c := tx.Bucket(b.bucket).Cursor()
 
-		// we can use the repoFullName as a prefix search since that's the first part of the key
-		for k, v := c.Seek([]byte(repoFullName)); k != nil && bytes.HasPrefix(k, []byte(repoFullName)); k, v = c.Next() {
+		// we can use the hostname and repoFullName as a prefix search since
+		// that's the first part of the key. The separator after the repo
+		// means locks of nested GitLab repos, ex. group/repo/subrepo, don't
+		// match group/repo.
+		prefix := []byte(hostname + "/" + repoFullName + keySeparator)
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
 			var lock models.ProjectLock
 			if err := json.Unmarshal(v, &lock); err != nil {
 				return errors.Wrapf(err, "deserializing lock at key %q", string(k))
 			}
-			if lock.Pull.Num == pullNum {
+			if lock.Project.RepoFullName == repoFullName && lock.Pull.Num == pullNum {
 				locks = append(locks, lock)
 			}
 		}
@@ -219,5 +264,13 @@ func (b BoltLocker) GetLock(p models.Project, workspace string) (*models.Project
 }
 
 func (b BoltLocker) key(p models.Project, workspace string) string {
-	return fmt.Sprintf("%s/%s/%s", p.RepoFullName, p.Path, workspace)
+	// Locks without a hostname are from before it was part of the key and
+	// couldn't be migrated. They're still stored at their old key.
+	if p.Hostname == "" {
+		return fmt.Sprintf("%s/%s/%s", p.RepoFullName, p.Path, workspace)
+	}
+	// The separator between the repo and the path is needed because GitLab
+	// repos can be nested, ex. group/repo at path sub/x and group/repo/sub at
+	// path x.
+	return fmt.Sprintf("%s/%s%s%s/%s", p.Hostname, p.RepoFullName, keySeparator, p.Path, workspace)
 }
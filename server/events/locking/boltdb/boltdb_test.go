@@ -27,7 +27,7 @@ import (
 )
 
 var lockBucket = "bucket"
-var project = models.NewProject("owner/repo", "parent/child")
+var project = models.NewProject("github.com", "owner/repo", "parent/child")
 var workspace = "default"
 var pullNum = 1
 var lock = models.ProjectLock{
@@ -77,7 +77,7 @@ func TestListMultipleLocks(t *testing.T) {
 
 	for _, r := range repos {
 		newLock := lock
-		newLock.Project = models.NewProject(r, "path")
+		newLock.Project = models.NewProject("github.com", r, "path")
 		_, _, err := b.TryLock(newLock)
 		Ok(t, err)
 	}
@@ -129,7 +129,7 @@ func TestLockingExistingLock(t *testing.T) {
 	t.Log("...succeed if the new project has a different path")
 	{
 		newLock := lock
-		newLock.Project = models.NewProject(project.RepoFullName, "different/path")
+		newLock.Project = models.NewProject("github.com", project.RepoFullName, "different/path")
 		acquired, currLock, err := b.TryLock(newLock)
 		Ok(t, err)
 		Equals(t, true, acquired)
@@ -149,7 +149,17 @@ func TestLockingExistingLock(t *testing.T) {
 	t.Log("...succeed if the new project has a different repoName")
 	{
 		newLock := lock
-		newLock.Project = models.NewProject("different/repo", project.Path)
+		newLock.Project = models.NewProject("github.com", "different/repo", project.Path)
+		acquired, currLock, err := b.TryLock(newLock)
+		Ok(t, err)
+		Equals(t, true, acquired)
+		Equals(t, newLock, currLock)
+	}
+
+	t.Log("...succeed if the new project is on a different host")
+	{
+		newLock := lock
+		newLock.Project = models.NewProject("gitlab.com", project.RepoFullName, project.Path)
 		acquired, currLock, err := b.TryLock(newLock)
 		Ok(t, err)
 		Equals(t, true, acquired)
@@ -167,6 +177,33 @@ func TestLockingExistingLock(t *testing.T) {
 	}
 }
 
+func TestLockingNestedRepos(t *testing.T) {
+	t.Log("projects in nested GitLab repos whose repo and path join to the same string shouldn't share a lock")
+	db, b := newTestDB()
+	defer cleanupDB(db)
+
+	outer := lock
+	outer.Project = models.NewProject("gitlab.com", "group/repo", "sub/x")
+	inner := lock
+	inner.Project = models.NewProject("gitlab.com", "group/repo/sub", "x")
+
+	acquired, _, err := b.TryLock(outer)
+	Ok(t, err)
+	Equals(t, true, acquired)
+	acquired, curr, err := b.TryLock(inner)
+	Ok(t, err)
+	Equals(t, true, acquired)
+	Equals(t, inner.Project, curr.Project)
+
+	locks, err := b.List()
+	Ok(t, err)
+	Equals(t, 2, len(locks))
+	unlocked, err := b.UnlockByPull("gitlab.com", "group/repo", pullNum)
+	Ok(t, err)
+	Equals(t, 1, len(unlocked))
+	Equals(t, outer.Project, unlocked[0].Project)
+}
+
 func TestUnlockingNoLocks(t *testing.T) {
 	t.Log("unlocking with no locks should succeed")
 	db, b := newTestDB()
@@ -244,7 +281,7 @@ func TestUnlockByPullNone(t *testing.T) {
 	db, b := newTestDB()
 	defer cleanupDB(db)
 
-	_, err := b.UnlockByPull("any/repo", 1)
+	_, err := b.UnlockByPull("github.com", "any/repo", 1)
 	Ok(t, err)
 }
 
@@ -257,7 +294,7 @@ func TestUnlockByPullOne(t *testing.T) {
 
 	t.Log("...delete nothing when its the same repo but a different pull")
 	{
-		_, err := b.UnlockByPull(project.RepoFullName, pullNum+1)
+		_, err := b.UnlockByPull("github.com", project.RepoFullName, pullNum+1)
 		Ok(t, err)
 		ls, err := b.List()
 		Ok(t, err)
@@ -265,7 +302,7 @@ func TestUnlockByPullOne(t *testing.T) {
 	}
 	t.Log("...delete nothing when its the same pull but a different repo")
 	{
-		_, err := b.UnlockByPull("different/repo", pullNum)
+		_, err := b.UnlockByPull("github.com", "different/repo", pullNum)
 		Ok(t, err)
 		ls, err := b.List()
 		Ok(t, err)
@@ -273,7 +310,7 @@ func TestUnlockByPullOne(t *testing.T) {
 	}
 	t.Log("...delete the lock when its the same repo and pull")
 	{
-		_, err := b.UnlockByPull(project.RepoFullName, pullNum)
+		_, err := b.UnlockByPull("github.com", project.RepoFullName, pullNum)
 		Ok(t, err)
 		ls, err := b.List()
 		Ok(t, err)
@@ -290,13 +327,37 @@ func TestUnlockByPullAfterUnlock(t *testing.T) {
 	_, err = b.Unlock(project, workspace)
 	Ok(t, err)
 
-	_, err = b.UnlockByPull(project.RepoFullName, pullNum)
+	_, err = b.UnlockByPull("github.com", project.RepoFullName, pullNum)
 	Ok(t, err)
 	ls, err := b.List()
 	Ok(t, err)
 	Equals(t, 0, len(ls))
 }
 
+func TestUnlockByPullNestedRepos(t *testing.T) {
+	t.Log("UnlockByPull shouldn't delete locks for repos whose names start with the same prefix")
+	db, b := newTestDB()
+	defer cleanupDB(db)
+	_, _, err := b.TryLock(lock)
+	Ok(t, err)
+
+	// owner/repo/subrepo is a different GitLab repo nested under owner/repo.
+	nested := lock
+	nested.Project = models.NewProject("github.com", "owner/repo/subrepo", ".")
+	_, _, err = b.TryLock(nested)
+	Ok(t, err)
+	similar := lock
+	similar.Project = models.NewProject("github.com", "owner/repo2", ".")
+	_, _, err = b.TryLock(similar)
+	Ok(t, err)
+
+	_, err = b.UnlockByPull("github.com", project.RepoFullName, pullNum)
+	Ok(t, err)
+	ls, err := b.List()
+	Ok(t, err)
+	Equals(t, 2, len(ls))
+}
+
 func TestUnlockByPullMatching(t *testing.T) {
 	t.Log("UnlockByPull should delete all locks in that repo and pull num")
 	db, b := newTestDB()
@@ -320,7 +381,7 @@ func TestUnlockByPullMatching(t *testing.T) {
 	Equals(t, 3, len(ls))
 
 	// should all be unlocked
-	_, err = b.UnlockByPull(project.RepoFullName, pullNum)
+	_, err = b.UnlockByPull("github.com", project.RepoFullName, pullNum)
 	Ok(t, err)
 	ls, err = b.List()
 	Ok(t, err)
@@ -0,0 +1,72 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package boltdb
+
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// RecordDelivery records that the webhook delivery with id was received.
+// It returns true if id had already been recorded less than ttl ago, in which
+// case the delivery is a duplicate and shouldn't be processed again. Only id's
+// record is read so it doesn't slow down as the bucket grows. Expired records
+// are removed by PruneDeliveries.
+func (b *BoltLocker) RecordDelivery(id string, ttl time.Duration) (bool, error) {
+	var duplicate bool
+	now := time.Now()
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.deliveryBucket)
+		if v := bucket.Get([]byte(id)); v != nil {
+			// An expired record that hasn't been pruned yet doesn't count.
+			var received time.Time
+			if err := received.UnmarshalText(v); err == nil && now.Sub(received) < ttl {
+				duplicate = true
+				return nil
+			}
+		}
+		nowSerialized, _ := now.MarshalText()
+		return bucket.Put([]byte(id), nowSerialized)
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "DB transaction failed")
+	}
+	return duplicate, nil
+}
+
+// PruneDeliveries removes the records of deliveries received ttl or more ago
+// so the bucket doesn't grow forever.
+func (b *BoltLocker) PruneDeliveries(ttl time.Duration) error {
+	now := time.Now()
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.deliveryBucket)
+		var expired [][]byte
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var received time.Time
+			if err := received.UnmarshalText(v); err != nil || now.Sub(received) >= ttl {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return errors.Wrap(err, "DB transaction failed")
+}
@@ -0,0 +1,87 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package boltdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestRecordDelivery_New(t *testing.T) {
+	t.Log("recording a delivery we haven't seen should not be a duplicate")
+	db, b := newTestDB()
+	defer cleanupDB(db)
+	dup, err := b.RecordDelivery("Github/id", time.Hour)
+	Ok(t, err)
+	Equals(t, false, dup)
+}
+
+func TestRecordDelivery_Duplicate(t *testing.T) {
+	t.Log("recording the same delivery twice should be a duplicate")
+	db, b := newTestDB()
+	defer cleanupDB(db)
+	_, err := b.RecordDelivery("Github/id", time.Hour)
+	Ok(t, err)
+	dup, err := b.RecordDelivery("Github/id", time.Hour)
+	Ok(t, err)
+	Equals(t, true, dup)
+}
+
+func TestRecordDelivery_DifferentIDs(t *testing.T) {
+	t.Log("recording different deliveries should not be duplicates")
+	db, b := newTestDB()
+	defer cleanupDB(db)
+	_, err := b.RecordDelivery("Github/id", time.Hour)
+	Ok(t, err)
+	dup, err := b.RecordDelivery("Gitlab/id", time.Hour)
+	Ok(t, err)
+	Equals(t, false, dup)
+}
+
+func TestRecordDelivery_Expired(t *testing.T) {
+	t.Log("recording a delivery whose previous record expired should not be a duplicate")
+	db, b := newTestDB()
+	defer cleanupDB(db)
+	_, err := b.RecordDelivery("Github/id", time.Hour)
+	Ok(t, err)
+	dup, err := b.RecordDelivery("Github/id", 0)
+	Ok(t, err)
+	Equals(t, false, dup)
+}
+
+func TestPruneDeliveries(t *testing.T) {
+	t.Log("pruning should forget expired deliveries and keep the rest")
+	db, b := newTestDB()
+	defer cleanupDB(db)
+	old, _ := time.Now().Add(-2 * time.Hour).MarshalText()
+	Ok(t, db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte("deliveries")).Put([]byte("Github/old"), old)
+	}))
+	_, err := b.RecordDelivery("Github/new", time.Hour)
+	Ok(t, err)
+
+	Ok(t, b.PruneDeliveries(time.Hour))
+	Ok(t, db.View(func(tx *bolt.Tx) error {
+		var ids []string
+		c := tx.Bucket([]byte("deliveries")).Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			ids = append(ids, string(k))
+		}
+		Equals(t, []string{"Github/new"}, ids)
+		return nil
+	}))
+}
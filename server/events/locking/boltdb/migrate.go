@@ -0,0 +1,84 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package boltdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// migrateLockKeys moves locks stored at keys in an older format to their
+// current key. It's safe to run on every startup since locks that are already
+// at their current key are skipped. The older formats are:
+//   - {repoFullName}/{path}/{workspace}, from before keys included the VCS
+//     hostname. Those locks' projects have no hostname so we get it from the
+//     pull request URL, which is always on the same host as the repo.
+//   - {hostname}/{repoFullName}/{path}/{workspace}, from before keys separated
+//     the repo from the path, so repos in GitLab subgroups could collide.
+//
+// Locks whose pull request URL has no hostname are left at their old key,
+// which is where we look up locks without a hostname, and are described in
+// b.unmigrated so the server can warn about them.
+func (b *BoltLocker) migrateLockKeys() error {
+	b.unmigrated = nil
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(b.bucket)
+
+		// We can't modify the bucket while iterating over it so first find
+		// the locks that need to be migrated.
+		migrated := make(map[string]models.ProjectLock)
+		err := bucket.ForEach(func(k, v []byte) error {
+			var lock models.ProjectLock
+			if err := json.Unmarshal(v, &lock); err != nil {
+				return errors.Wrapf(err, "deserializing lock at key %q", string(k))
+			}
+			if lock.Project.Hostname == "" {
+				pullURL, err := url.Parse(lock.Pull.URL)
+				if err != nil || pullURL.Hostname() == "" {
+					b.unmigrated = append(b.unmigrated, fmt.Sprintf("not migrating lock at key %q: can't determine hostname from pull request url %q", string(k), lock.Pull.URL))
+					return nil
+				}
+				lock.Project.Hostname = pullURL.Hostname()
+			}
+			if b.key(lock.Project, lock.Workspace) != string(k) {
+				migrated[string(k)] = lock
+			}
+			return nil
+		})
+		if err != nil {
+			b.unmigrated = nil
+			return err
+		}
+
+		for oldKey, lock := range migrated {
+			serialized, err := json.Marshal(lock)
+			if err != nil {
+				return errors.Wrapf(err, "serializing lock at key %q", oldKey)
+			}
+			if err := bucket.Delete([]byte(oldKey)); err != nil {
+				return errors.Wrapf(err, "deleting lock at key %q", oldKey)
+			}
+			newKey := b.key(lock.Project, lock.Workspace)
+			if err := bucket.Put([]byte(newKey), serialized); err != nil {
+				return errors.Wrapf(err, "migrating lock at key %q to %q", oldKey, newKey)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,114 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package boltdb_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/runatlantis/atlantis/server/events/locking/boltdb"
+	"github.com/runatlantis/atlantis/server/events/models"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestMigrateLockKeys(t *testing.T) {
+	t.Log("locks stored without a hostname should be moved to host-qualified keys on startup")
+	db, _ := newTestDB()
+	defer cleanupDB(db)
+
+	oldLock := lock
+	oldLock.Project = models.Project{RepoFullName: "owner/repo", Path: "parent/child"}
+	oldLock.Pull.URL = "https://github.com/owner/repo/pull/1"
+	putRaw(t, db, "owner/repo/parent/child/default", oldLock)
+
+	b, err := boltdb.NewWithDB(db, lockBucket)
+	Ok(t, err)
+
+	Equals(t, []string{"github.com/owner/repo/-/parent/child/default"}, keys(t, db))
+	migrated, err := b.GetLock(models.NewProject("github.com", "owner/repo", "parent/child"), "default")
+	Ok(t, err)
+	Assert(t, migrated != nil, "exp lock to be migrated")
+	Equals(t, "github.com", migrated.Project.Hostname)
+	Equals(t, oldLock.Pull, migrated.Pull)
+
+	t.Log("running the migration again should be a no-op")
+	_, err = boltdb.NewWithDB(db, lockBucket)
+	Ok(t, err)
+	Equals(t, []string{"github.com/owner/repo/-/parent/child/default"}, keys(t, db))
+}
+
+func TestMigrateLockKeys_NoPullURL(t *testing.T) {
+	t.Log("locks we can't determine the hostname of should be left at their old key without failing startup")
+	db, _ := newTestDB()
+	defer cleanupDB(db)
+
+	oldLock := lock
+	oldLock.Project = models.Project{RepoFullName: "owner/repo", Path: "."}
+	putRaw(t, db, "owner/repo/./default", oldLock)
+	migratable := lock
+	migratable.Project = models.Project{RepoFullName: "owner/repo", Path: "child"}
+	migratable.Pull.URL = "https://github.com/owner/repo/pull/1"
+	putRaw(t, db, "owner/repo/child/default", migratable)
+
+	b, err := boltdb.NewWithDB(db, lockBucket)
+	Ok(t, err)
+	Equals(t, []string{`not migrating lock at key "owner/repo/./default": can't determine hostname from pull request url ""`}, b.UnmigratedLocks())
+	Equals(t, []string{"github.com/owner/repo/-/child/default", "owner/repo/./default"}, keys(t, db))
+	locks, err := b.List()
+	Ok(t, err)
+	Equals(t, 2, len(locks))
+
+	t.Log("the other locks should still be migrated and only the unmigrated lock reported on the next startup")
+	b, err = boltdb.NewWithDB(db, lockBucket)
+	Ok(t, err)
+	Equals(t, 1, len(b.UnmigratedLocks()))
+	Equals(t, []string{"github.com/owner/repo/-/child/default", "owner/repo/./default"}, keys(t, db))
+}
+
+func TestMigrateLockKeys_NoSeparator(t *testing.T) {
+	t.Log("locks stored before keys separated the repo from the path should be moved to their current key")
+	db, _ := newTestDB()
+	defer cleanupDB(db)
+
+	oldLock := lock
+	oldLock.Project = models.NewProject("gitlab.com", "group/repo", "sub/x")
+	putRaw(t, db, "gitlab.com/group/repo/sub/x/default", oldLock)
+
+	b, err := boltdb.NewWithDB(db, lockBucket)
+	Ok(t, err)
+	Equals(t, []string{"gitlab.com/group/repo/-/sub/x/default"}, keys(t, db))
+	migrated, err := b.GetLock(oldLock.Project, "default")
+	Ok(t, err)
+	Assert(t, migrated != nil, "exp lock to be migrated")
+}
+
+func putRaw(t *testing.T, db *bolt.DB, key string, l models.ProjectLock) {
+	serialized, err := json.Marshal(l)
+	Ok(t, err)
+	Ok(t, db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(lockBucket)).Put([]byte(key), serialized)
+	}))
+}
+
+func keys(t *testing.T, db *bolt.DB) []string {
+	var ks []string
+	Ok(t, db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(lockBucket)).ForEach(func(k, _ []byte) error {
+			ks = append(ks, string(k))
+			return nil
+		})
+	}))
+	return ks
+}
@@ -0,0 +1,52 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package boltdb
+
+import (
+	"encoding/json"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// RecordProject records that Atlantis has planned the project and workspace
+// in p. Recording the same project and workspace again overwrites it.
+func (b *BoltLocker) RecordProject(p models.KnownProject) error {
+	serialized, _ := json.Marshal(p)
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(b.projectBucket).Put([]byte(b.key(p.Project, p.Workspace)), serialized)
+	})
+	return errors.Wrap(err, "DB transaction failed")
+}
+
+// ListProjects lists all the projects and workspaces that have been recorded.
+func (b *BoltLocker) ListProjects() ([]models.KnownProject, error) {
+	var projects []models.KnownProject
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(b.projectBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var p models.KnownProject
+			if err := json.Unmarshal(v, &p); err != nil {
+				return errors.Wrapf(err, "deserializing project at key %q", string(k))
+			}
+			projects = append(projects, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "DB transaction failed")
+	}
+	return projects, nil
+}
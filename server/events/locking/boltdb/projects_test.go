@@ -0,0 +1,50 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package boltdb_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestListProjects_None(t *testing.T) {
+	t.Log("listing projects when none have been recorded should return none")
+	db, b := newTestDB()
+	defer cleanupDB(db)
+	projects, err := b.ListProjects()
+	Ok(t, err)
+	Equals(t, 0, len(projects))
+}
+
+func TestRecordProject(t *testing.T) {
+	t.Log("recorded projects should be listed once per project and workspace")
+	db, b := newTestDB()
+	defer cleanupDB(db)
+	p := models.KnownProject{
+		Project:           models.NewProject("github.com", "owner/repo", "."),
+		Workspace:         "default",
+		SanitizedCloneURL: "https://github.com/owner/repo.git",
+	}
+	staging := p
+	staging.Workspace = "staging"
+	Ok(t, b.RecordProject(p))
+	Ok(t, b.RecordProject(staging))
+	Ok(t, b.RecordProject(p))
+
+	projects, err := b.ListProjects()
+	Ok(t, err)
+	Equals(t, []models.KnownProject{p, staging}, projects)
+}
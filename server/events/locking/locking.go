@@ -31,7 +31,7 @@ type Backend interface {
 	Unlock(project models.Project, workspace string) (*models.ProjectLock, error)
 	List() ([]models.ProjectLock, error)
 	GetLock(project models.Project, workspace string) (*models.ProjectLock, error)
-	UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error)
+	UnlockByPull(hostname string, repoFullName string, pullNum int) ([]models.ProjectLock, error)
 }
 
 // TryLockResponse results from an attempted lock.
@@ -55,7 +55,7 @@ type Locker interface {
 	TryLock(p models.Project, workspace string, pull models.PullRequest, user models.User) (TryLockResponse, error)
 	Unlock(key string) (*models.ProjectLock, error)
 	List() (map[string]models.ProjectLock, error)
-	UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error)
+	UnlockByPull(hostname string, repoFullName string, pullNum int) ([]models.ProjectLock, error)
 	GetLock(key string) (*models.ProjectLock, error)
 }
 
@@ -66,8 +66,18 @@ func NewClient(backend Backend) *Client {
 	}
 }
 
-// keyRegex matches and captures {repoFullName}/{path}/{workspace} where path can have multiple /'s in it.
-var keyRegex = regexp.MustCompile(`^(.*?\/.*?)\/(.*)\/(.*)$`)
+// keySeparator separates the repo's full name from the rest of the lock key.
+// Both the repo name (for GitLab subgroups) and the path can contain /'s so
+// we need a separator that can't be part of a repo name. GitLab reserves "-"
+// as a group and project name and GitHub owners can't be named "-" so it
+// can't be the first segment.
+const keySeparator = "/-/"
+
+// keyRegex matches and captures {hostname}/{repoFullName}/-/{path}/{workspace}
+// where repoFullName and path can have multiple /'s in them. hostname is
+// empty for locks from before it was part of the key that couldn't be
+// migrated.
+var keyRegex = regexp.MustCompile(`^([^/]*)/([^/]+(?:/[^/]+)+?)/-/(.*)/([^/]*)$`)
 
 // TryLock attempts to acquire a lock to a project and workspace.
 func (c *Client) TryLock(p models.Project, workspace string, pull models.PullRequest, user models.User) (TryLockResponse, error) {
@@ -112,8 +122,8 @@ func (c *Client) List() (map[string]models.ProjectLock, error) {
 }
 
 // UnlockByPull deletes all locks associated with that pull request.
-func (c *Client) UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error) {
-	return c.backend.UnlockByPull(repoFullName, pullNum)
+func (c *Client) UnlockByPull(hostname string, repoFullName string, pullNum int) ([]models.ProjectLock, error) {
+	return c.backend.UnlockByPull(hostname, repoFullName, pullNum)
 }
 
 // GetLock attempts to get the lock stored at key. If successful,
@@ -135,14 +145,20 @@ func (c *Client) GetLock(key string) (*models.ProjectLock, error) {
 }
 
 func (c *Client) key(p models.Project, workspace string) string {
-	return fmt.Sprintf("%s/%s/%s", p.RepoFullName, p.Path, workspace)
+	return Key(p, workspace)
+}
+
+// Key returns the key of the lock on project p in workspace. It's the ID used
+// in the URLs of lock pages.
+func Key(p models.Project, workspace string) string {
+	return fmt.Sprintf("%s/%s%s%s/%s", p.Hostname, p.RepoFullName, keySeparator, p.Path, workspace)
 }
 
 func (c *Client) lockKeyToProjectWorkspace(key string) (models.Project, string, error) {
 	matches := keyRegex.FindStringSubmatch(key)
-	if len(matches) != 4 {
+	if len(matches) != 5 {
 		return models.Project{}, "", errors.New("invalid key format")
 	}
 
-	return models.Project{RepoFullName: matches[1], Path: matches[2]}, matches[3], nil
+	return models.Project{Hostname: matches[1], RepoFullName: matches[2], Path: matches[3]}, matches[4], nil
 }
@@ -14,21 +14,27 @@
 package locking_test
 
 import (
+	"encoding/json"
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"strings"
 
+	"github.com/boltdb/bolt"
 	. "github.com/petergtz/pegomock"
 	"github.com/runatlantis/atlantis/server/events/locking"
+	"github.com/runatlantis/atlantis/server/events/locking/boltdb"
 	"github.com/runatlantis/atlantis/server/events/locking/mocks"
 	"github.com/runatlantis/atlantis/server/events/locking/mocks/matchers"
 	"github.com/runatlantis/atlantis/server/events/models"
 	. "github.com/runatlantis/atlantis/testing"
 )
 
-var project = models.NewProject("owner/repo", "path")
+var project = models.NewProject("github.com", "owner/repo", "path")
 var workspace = "workspace"
 var pull = models.PullRequest{}
 var user = models.User{}
@@ -54,7 +60,7 @@ func TestTryLock_Success(t *testing.T) {
 	l := locking.NewClient(backend)
 	r, err := l.TryLock(project, workspace, pull, user)
 	Ok(t, err)
-	Equals(t, locking.TryLockResponse{LockAcquired: true, CurrLock: currLock, LockKey: "owner/repo/path/workspace"}, r)
+	Equals(t, locking.TryLockResponse{LockAcquired: true, CurrLock: currLock, LockKey: "github.com/owner/repo/-/path/workspace"}, r)
 }
 
 func TestUnlock_InvalidKey(t *testing.T) {
@@ -72,7 +78,7 @@ func TestUnlock_Err(t *testing.T) {
 	backend := mocks.NewMockBackend()
 	When(backend.Unlock(matchers.AnyModelsProject(), AnyString())).ThenReturn(nil, expectedErr)
 	l := locking.NewClient(backend)
-	_, err := l.Unlock("owner/repo/path/workspace")
+	_, err := l.Unlock("github.com/owner/repo/-/path/workspace")
 	Equals(t, err, err)
 	backend.VerifyWasCalledOnce().Unlock(project, "workspace")
 }
@@ -82,7 +88,7 @@ func TestUnlock(t *testing.T) {
 	backend := mocks.NewMockBackend()
 	When(backend.Unlock(matchers.AnyModelsProject(), AnyString())).ThenReturn(&pl, nil)
 	l := locking.NewClient(backend)
-	lock, err := l.Unlock("owner/repo/path/workspace")
+	lock, err := l.Unlock("github.com/owner/repo/-/path/workspace")
 	Ok(t, err)
 	Equals(t, &pl, lock)
 }
@@ -104,16 +110,16 @@ func TestList(t *testing.T) {
 	list, err := l.List()
 	Ok(t, err)
 	Equals(t, map[string]models.ProjectLock{
-		"owner/repo/path/workspace": pl,
+		"github.com/owner/repo/-/path/workspace": pl,
 	}, list)
 }
 
 func TestUnlockByPull(t *testing.T) {
 	RegisterMockTestingT(t)
 	backend := mocks.NewMockBackend()
-	When(backend.UnlockByPull("owner/repo", 1)).ThenReturn(nil, expectedErr)
+	When(backend.UnlockByPull("github.com", "owner/repo", 1)).ThenReturn(nil, expectedErr)
 	l := locking.NewClient(backend)
-	_, err := l.UnlockByPull("owner/repo", 1)
+	_, err := l.UnlockByPull("github.com", "owner/repo", 1)
 	Equals(t, expectedErr, err)
 }
 
@@ -131,7 +137,7 @@ func TestGetLock_Err(t *testing.T) {
 	backend := mocks.NewMockBackend()
 	When(backend.GetLock(project, workspace)).ThenReturn(nil, expectedErr)
 	l := locking.NewClient(backend)
-	_, err := l.GetLock("owner/repo/path/workspace")
+	_, err := l.GetLock("github.com/owner/repo/-/path/workspace")
 	Equals(t, expectedErr, err)
 }
 
@@ -140,7 +146,85 @@ func TestGetLock(t *testing.T) {
 	backend := mocks.NewMockBackend()
 	When(backend.GetLock(project, workspace)).ThenReturn(&pl, nil)
 	l := locking.NewClient(backend)
-	lock, err := l.GetLock("owner/repo/path/workspace")
+	lock, err := l.GetLock("github.com/owner/repo/-/path/workspace")
 	Ok(t, err)
 	Equals(t, &pl, lock)
 }
+
+func TestGetLock_NestedRepo(t *testing.T) {
+	t.Log("should parse keys for repos in GitLab subgroups")
+	RegisterMockTestingT(t)
+	backend := mocks.NewMockBackend()
+	nestedProject := models.NewProject("gitlab.com", "group/subgroup/repo", "path/to/project")
+	When(backend.GetLock(nestedProject, workspace)).ThenReturn(&pl, nil)
+	l := locking.NewClient(backend)
+	lock, err := l.GetLock("gitlab.com/group/subgroup/repo/-/path/to/project/workspace")
+	Ok(t, err)
+	Equals(t, &pl, lock)
+}
+
+func TestUnlock_UnmigratedLock(t *testing.T) {
+	t.Log("locks from before keys had a hostname that couldn't be migrated should be discardable")
+	dataDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dataDir) // nolint: errcheck
+	oldLock := pl
+	oldLock.Project = models.Project{RepoFullName: "owner/repo", Path: "."}
+	oldLock.Workspace = "default"
+	serialized, err := json.Marshal(oldLock)
+	Ok(t, err)
+	db, err := bolt.Open(filepath.Join(dataDir, "atlantis.db"), 0600, nil)
+	Ok(t, err)
+	Ok(t, db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte("runLocks"))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte("owner/repo/./default"), serialized)
+	}))
+	Ok(t, db.Close())
+
+	backend, err := boltdb.New(dataDir)
+	Ok(t, err)
+	Equals(t, 1, len(backend.UnmigratedLocks()))
+	l := locking.NewClient(backend)
+	locks, err := l.List()
+	Ok(t, err)
+	Equals(t, 1, len(locks))
+	var key string
+	for k := range locks {
+		key = k
+	}
+	Equals(t, "/owner/repo/-/./default", key)
+
+	lock, err := l.GetLock(key)
+	Ok(t, err)
+	Assert(t, lock != nil, "exp to get the lock")
+	unlocked, err := l.Unlock(key)
+	Ok(t, err)
+	Assert(t, unlocked != nil, "exp to unlock the lock")
+	locks, err = l.List()
+	Ok(t, err)
+	Equals(t, 0, len(locks))
+}
+
+func TestKey_RoundTrip(t *testing.T) {
+	t.Log("the key returned by TryLock should be usable with Unlock")
+	cases := []models.Project{
+		models.NewProject("github.com", "owner/repo", "."),
+		models.NewProject("github.com", "owner/-", "path"),
+		models.NewProject("github.com", "group/subgroup/repo", "."),
+		models.NewProject("github.com", "group/subgroup/repo", "path/to/project"),
+	}
+	for _, p := range cases {
+		RegisterMockTestingT(t)
+		backend := mocks.NewMockBackend()
+		When(backend.TryLock(matchers.AnyModelsProjectLock())).ThenReturn(true, models.ProjectLock{}, nil)
+		l := locking.NewClient(backend)
+		r, err := l.TryLock(p, workspace, pull, user)
+		Ok(t, err)
+		_, err = l.Unlock(r.LockKey)
+		Ok(t, err)
+		backend.VerifyWasCalledOnce().Unlock(p, workspace)
+	}
+}
@@ -67,8 +67,8 @@ func (mock *MockLocker) List() (map[string]models.ProjectLock, error) {
 	return ret0, ret1
 }
 
-func (mock *MockLocker) UnlockByPull(repoFullName string, pullNum int) ([]models.ProjectLock, error) {
-	params := []pegomock.Param{repoFullName, pullNum}
+func (mock *MockLocker) UnlockByPull(hostname string, repoFullName string, pullNum int) ([]models.ProjectLock, error) {
+	params := []pegomock.Param{hostname, repoFullName, pullNum}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("UnlockByPull", params, []reflect.Type{reflect.TypeOf((*[]models.ProjectLock)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 []models.ProjectLock
 	var ret1 error
@@ -200,8 +200,8 @@ func (c *Locker_List_OngoingVerification) GetCapturedArguments() {
 func (c *Locker_List_OngoingVerification) GetAllCapturedArguments() {
 }
 
-func (verifier *VerifierLocker) UnlockByPull(repoFullName string, pullNum int) *Locker_UnlockByPull_OngoingVerification {
-	params := []pegomock.Param{repoFullName, pullNum}
+func (verifier *VerifierLocker) UnlockByPull(hostname string, repoFullName string, pullNum int) *Locker_UnlockByPull_OngoingVerification {
+	params := []pegomock.Param{hostname, repoFullName, pullNum}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UnlockByPull", params)
 	return &Locker_UnlockByPull_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -211,21 +211,25 @@ type Locker_UnlockByPull_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *Locker_UnlockByPull_OngoingVerification) GetCapturedArguments() (string, int) {
-	repoFullName, pullNum := c.GetAllCapturedArguments()
-	return repoFullName[len(repoFullName)-1], pullNum[len(pullNum)-1]
+func (c *Locker_UnlockByPull_OngoingVerification) GetCapturedArguments() (string, string, int) {
+	hostname, repoFullName, pullNum := c.GetAllCapturedArguments()
+	return hostname[len(hostname)-1], repoFullName[len(repoFullName)-1], pullNum[len(pullNum)-1]
 }
 
-func (c *Locker_UnlockByPull_OngoingVerification) GetAllCapturedArguments() (_param0 []string, _param1 []int) {
+func (c *Locker_UnlockByPull_OngoingVerification) GetAllCapturedArguments() (_param0 []string, _param1 []string, _param2 []int) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
 		_param0 = make([]string, len(params[0]))
 		for u, param := range params[0] {
 			_param0[u] = param.(string)
 		}
-		_param1 = make([]int, len(params[1]))
+		_param1 = make([]string, len(params[1]))
 		for u, param := range params[1] {
-			_param1[u] = param.(int)
+			_param1[u] = param.(string)
+		}
+		_param2 = make([]int, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(int)
 		}
 	}
 	return
@@ -16,6 +16,7 @@ package events
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"strings"
 	"text/template"
 )
@@ -44,10 +45,19 @@ type FailureData struct {
 
 // ResultData is data about a successful response.
 type ResultData struct {
-	Results map[string]string
+	Results []RenderedResult
 	CommonData
 }
 
+// RenderedResult is the rendered output of a single project result.
+type RenderedResult struct {
+	Path string
+	// Workspace is the workspace the result is for. It's empty for the
+	// default workspace so comments for it don't mention workspaces.
+	Workspace string
+	Output    string
+}
+
 // Render formats the data into a markdown string.
 // nolint: interfacer
 func (m *MarkdownRenderer) Render(res CommandResponse, cmdName CommandName, log string, verbose bool) string {
@@ -63,10 +73,11 @@ func (m *MarkdownRenderer) Render(res CommandResponse, cmdName CommandName, log
 }
 
 func (m *MarkdownRenderer) renderProjectResults(pathResults []ProjectResult, common CommonData) string {
-	results := make(map[string]string)
+	var results []RenderedResult
 	for _, result := range pathResults {
+		var output string
 		if result.Error != nil {
-			results[result.Path] = m.renderTemplate(errTmpl, struct {
+			output = m.renderTemplate(errTmpl, struct {
 				Command string
 				Error   string
 			}{
@@ -74,21 +85,43 @@ func (m *MarkdownRenderer) renderProjectResults(pathResults []ProjectResult, com
 				Error:   result.Error.Error(),
 			})
 		} else if result.Failure != "" {
-			results[result.Path] = m.renderTemplate(failureTmpl, struct {
+			output = m.renderTemplate(failureTmpl, struct {
 				Command string
 				Failure string
 			}{
 				Command: common.Command,
 				Failure: result.Failure,
 			})
+		} else if result.Skipped != "" {
+			output = m.renderTemplate(skippedTmpl, struct {
+				Command string
+				Skipped string
+			}{
+				Command: common.Command,
+				Skipped: result.Skipped,
+			})
 		} else if result.PlanSuccess != nil {
-			results[result.Path] = m.renderTemplate(planSuccessTmpl, *result.PlanSuccess)
+			output = m.renderTemplate(planSuccessTmpl, *result.PlanSuccess)
 		} else if result.ApplySuccess != "" {
-			results[result.Path] = m.renderTemplate(applySuccessTmpl, struct{ Output string }{result.ApplySuccess})
+			output = m.renderTemplate(applySuccessTmpl, struct{ Output string }{result.ApplySuccess})
 		} else {
-			results[result.Path] = "Found no template. This is a bug!"
+			output = "Found no template. This is a bug!"
 		}
+		if len(result.HookOutputs) > 0 {
+			output += m.renderTemplate(hookOutputsTmpl, result.HookOutputs)
+		}
+		workspace := result.Workspace
+		if workspace == DefaultWorkspace {
+			workspace = ""
+		}
+		results = append(results, RenderedResult{Path: result.Path, Workspace: workspace, Output: output})
 	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Path != results[j].Path {
+			return results[i].Path < results[j].Path
+		}
+		return results[i].Workspace < results[j].Workspace
+	})
 
 	var tmpl *template.Template
 	if len(results) == 1 {
@@ -107,22 +140,38 @@ func (m *MarkdownRenderer) renderTemplate(tmpl *template.Template, data interfac
 	return buf.String()
 }
 
-var singleProjectTmpl = template.Must(template.New("").Parse("{{ range $result := .Results }}{{$result}}{{end}}\n" + logTmpl))
+var singleProjectTmpl = template.Must(template.New("").Parse("{{ range .Results }}{{.Output}}{{end}}\n" + logTmpl))
 var multiProjectTmpl = template.Must(template.New("").Parse(
 	"Ran {{.Command}} in {{ len .Results }} directories:\n" +
-		"{{ range $path, $result := .Results }}" +
-		" * `{{$path}}`\n" +
+		"{{ range .Results }}" +
+		" * `{{.Path}}`{{if .Workspace}} in workspace `{{.Workspace}}`{{end}}\n" +
 		"{{end}}\n" +
-		"{{ range $path, $result := .Results }}" +
-		"## {{$path}}/\n" +
-		"{{$result}}\n" +
+		"{{ range .Results }}" +
+		"## {{.Path}}/{{if .Workspace}} in workspace `{{.Workspace}}`{{end}}\n" +
+		"{{.Output}}\n" +
 		"---\n{{end}}" +
 		logTmpl))
 var planSuccessTmpl = template.Must(template.New("").Parse(
-	"```diff\n" +
+	"{{with .Destroys}}{{if .Destroys}}**Warning: this plan destroys {{.Destroys}} resource(s)" +
+		"{{if .Replacements}}, including {{.Replacements}} replacement(s){{end}}.**\n" +
+		"{{if .RequiresConfirmation}}To apply it, comment `atlantis apply --allow-destroy`.\n{{end}}\n{{end}}{{end}}" +
+		"```diff\n" +
 		"{{.TerraformOutput}}\n" +
 		"```\n\n" +
+		"{{with .PolicyCheck}}{{if .Passed}}**Policy Check Passed**\n\n{{else}}" +
+		"**Policy Check Failed**\n" +
+		"```\n" +
+		"{{.Summary}}" +
+		"```\n" +
+		"This plan can't be applied until the violations are fixed or a policy owner overrides them.\n\n" +
+		"{{end}}{{end}}" +
 		"* To **discard** this plan click [here]({{.LockURL}})."))
+var hookOutputsTmpl = template.Must(template.New("").Parse(
+	"{{ range . }}\n<details><summary>{{.Stage}} output{{if .Failed}} (failed){{end}}</summary>\n\n" +
+		"```\n" +
+		"{{.Output}}\n" +
+		"```\n" +
+		"</details>{{end}}"))
 var applySuccessTmpl = template.Must(template.New("").Parse(
 	"```diff\n" +
 		"{{.Output}}\n" +
@@ -135,5 +184,6 @@ var errTmpl = template.Must(template.New("").Parse(errTmplText))
 var errWithLogTmpl = template.Must(template.New("").Parse(errTmplText + logTmpl))
 var failureTmplText = "**{{.Command}} Failed**: {{.Failure}}\n"
 var failureTmpl = template.Must(template.New("").Parse(failureTmplText))
+var skippedTmpl = template.Must(template.New("").Parse("**{{.Command}} Skipped**: {{.Skipped}}\n"))
 var failureWithLogTmpl = template.Must(template.New("").Parse(failureTmplText + logTmpl))
 var logTmpl = "{{if .Verbose}}\n<details><summary>Log</summary>\n  <p>\n\n```\n{{.Log}}```\n</p></details>{{end}}\n"
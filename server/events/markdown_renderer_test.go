@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/policy"
 	. "github.com/runatlantis/atlantis/testing"
 )
 
@@ -129,6 +130,95 @@ func TestRenderProjectResults(t *testing.T) {
 			},
 			"```diff\nterraform-output\n```\n\n* To **discard** this plan click [here](lock-url).\n\n",
 		},
+		{
+			"single plan that passed its policy check",
+			events.Plan,
+			[]events.ProjectResult{
+				{
+					PlanSuccess: &events.PlanSuccess{
+						TerraformOutput: "terraform-output",
+						LockURL:         "lock-url",
+						PolicyCheck:     &events.PolicyCheckResult{},
+					},
+				},
+			},
+			"```diff\nterraform-output\n```\n\n**Policy Check Passed**\n\n* To **discard** this plan click [here](lock-url).\n\n",
+		},
+		{
+			"single plan that failed its policy check",
+			events.Plan,
+			[]events.ProjectResult{
+				{
+					PlanSuccess: &events.PlanSuccess{
+						TerraformOutput: "terraform-output",
+						LockURL:         "lock-url",
+						PolicyCheck: &events.PolicyCheckResult{
+							Violations: []policy.Violation{{Policy: "no-public-s3", Address: "aws_s3_bucket.b", Description: "S3 buckets must not be public"}},
+						},
+					},
+				},
+			},
+			"```diff\nterraform-output\n```\n\n**Policy Check Failed**\n```\naws_s3_bucket.b: S3 buckets must not be public (policy \"no-public-s3\")\n```\nThis plan can't be applied until the violations are fixed or a policy owner overrides them.\n\n* To **discard** this plan click [here](lock-url).\n\n",
+		},
+		{
+			"single plan that destroys resources",
+			events.Plan,
+			[]events.ProjectResult{
+				{
+					PlanSuccess: &events.PlanSuccess{
+						TerraformOutput: "terraform-output",
+						LockURL:         "lock-url",
+						Destroys:        events.DestroySummary{Destroys: 2, Replacements: 1},
+					},
+				},
+			},
+			"**Warning: this plan destroys 2 resource(s), including 1 replacement(s).**\n\n```diff\nterraform-output\n```\n\n* To **discard** this plan click [here](lock-url).\n\n",
+		},
+		{
+			"single plan that requires confirming destroys",
+			events.Plan,
+			[]events.ProjectResult{
+				{
+					PlanSuccess: &events.PlanSuccess{
+						TerraformOutput: "terraform-output",
+						LockURL:         "lock-url",
+						Destroys:        events.DestroySummary{Destroys: 1, RequiresConfirmation: true},
+					},
+				},
+			},
+			"**Warning: this plan destroys 1 resource(s).**\nTo apply it, comment `atlantis apply --allow-destroy`.\n\n```diff\nterraform-output\n```\n\n* To **discard** this plan click [here](lock-url).\n\n",
+		},
+		{
+			"single plan with hook outputs",
+			events.Plan,
+			[]events.ProjectResult{
+				{
+					PlanSuccess: &events.PlanSuccess{
+						TerraformOutput: "terraform-output",
+						LockURL:         "lock-url",
+					},
+					HookOutputs: []events.HookOutput{
+						{Stage: "pre_plan", Output: "checked"},
+						{Stage: "post_plan", Output: "notified"},
+					},
+				},
+			},
+			"```diff\nterraform-output\n```\n\n* To **discard** this plan click [here](lock-url).\n" +
+				"<details><summary>pre_plan output</summary>\n\n```\nchecked\n```\n</details>\n" +
+				"<details><summary>post_plan output</summary>\n\n```\nnotified\n```\n</details>\n\n",
+		},
+		{
+			"single error with a failed hook",
+			events.Plan,
+			[]events.ProjectResult{
+				{
+					Error:       errors.New("running pre_plan commands: exit status 1"),
+					HookOutputs: []events.HookOutput{{Stage: "pre_plan", Output: "lint failed", Failed: true}},
+				},
+			},
+			"**Plan Error**\n```\nrunning pre_plan commands: exit status 1\n```\n\n" +
+				"<details><summary>pre_plan output (failed)</summary>\n\n```\nlint failed\n```\n</details>\n\n",
+		},
 		{
 			"single successful apply",
 			events.Apply,
@@ -175,6 +265,38 @@ func TestRenderProjectResults(t *testing.T) {
 			},
 			"Ran Apply in 2 directories:\n * `path`\n * `path2`\n\n## path/\n```diff\nsuccess\n```\n---\n## path2/\n```diff\nsuccess2\n```\n---\n\n",
 		},
+		{
+			"apply skipped because of a dependency",
+			events.Apply,
+			[]events.ProjectResult{
+				{
+					Path:    "app",
+					Skipped: "this project depends on `network` which didn't apply successfully.",
+				},
+				{
+					Path:    "network",
+					Failure: "failure",
+				},
+			},
+			"Ran Apply in 2 directories:\n * `app`\n * `network`\n\n## app/\n**Apply Skipped**: this project depends on `network` which didn't apply successfully.\n\n---\n## network/\n**Apply Failed**: failure\n\n---\n\n",
+		},
+		{
+			"plans in multiple workspaces",
+			events.Plan,
+			[]events.ProjectResult{
+				{
+					Path:      "path",
+					Workspace: "staging",
+					Failure:   "failure",
+				},
+				{
+					Path:      "path",
+					Workspace: "default",
+					Failure:   "failure",
+				},
+			},
+			"Ran Plan in 2 directories:\n * `path`\n * `path` in workspace `staging`\n\n## path/\n**Plan Failed**: failure\n\n---\n## path/ in workspace `staging`\n**Plan Failed**: failure\n\n---\n\n",
+		},
 		{
 			"single errored plan",
 			events.Plan,
@@ -0,0 +1,20 @@
+package matchers
+
+import (
+	"reflect"
+
+	"github.com/petergtz/pegomock"
+	events "github.com/runatlantis/atlantis/server/events"
+)
+
+func AnyEventsTrace() events.Trace {
+	pegomock.RegisterMatcher(pegomock.NewAnyMatcher(reflect.TypeOf((*(events.Trace))(nil)).Elem()))
+	var nullValue events.Trace
+	return nullValue
+}
+
+func EqEventsTrace(value events.Trace) events.Trace {
+	pegomock.RegisterMatcher(&pegomock.EqMatcher{Value: value})
+	var nullValue events.Trace
+	return nullValue
+}
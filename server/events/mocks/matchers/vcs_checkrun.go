@@ -0,0 +1,20 @@
+package matchers
+
+import (
+	"reflect"
+
+	"github.com/petergtz/pegomock"
+	vcs "github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+func AnyVcsCheckRun() vcs.CheckRun {
+	pegomock.RegisterMatcher(pegomock.NewAnyMatcher(reflect.TypeOf((*(vcs.CheckRun))(nil)).Elem()))
+	var nullValue vcs.CheckRun
+	return nullValue
+}
+
+func EqVcsCheckRun(value vcs.CheckRun) vcs.CheckRun {
+	pegomock.RegisterMatcher(&pegomock.EqMatcher{Value: value})
+	var nullValue vcs.CheckRun
+	return nullValue
+}
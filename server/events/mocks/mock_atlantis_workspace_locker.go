@@ -17,8 +17,8 @@ func NewMockAtlantisWorkspaceLocker() *MockAtlantisWorkspaceLocker {
 	return &MockAtlantisWorkspaceLocker{fail: pegomock.GlobalFailHandler}
 }
 
-func (mock *MockAtlantisWorkspaceLocker) TryLock(repoFullName string, workspace string, pullNum int) bool {
-	params := []pegomock.Param{repoFullName, workspace, pullNum}
+func (mock *MockAtlantisWorkspaceLocker) TryLock(hostname string, repoFullName string, workspace string, pullNum int) bool {
+	params := []pegomock.Param{hostname, repoFullName, workspace, pullNum}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("TryLock", params, []reflect.Type{reflect.TypeOf((*bool)(nil)).Elem()})
 	var ret0 bool
 	if len(result) != 0 {
@@ -29,8 +29,8 @@ func (mock *MockAtlantisWorkspaceLocker) TryLock(repoFullName string, workspace
 	return ret0
 }
 
-func (mock *MockAtlantisWorkspaceLocker) Unlock(repoFullName string, workspace string, pullNum int) {
-	params := []pegomock.Param{repoFullName, workspace, pullNum}
+func (mock *MockAtlantisWorkspaceLocker) Unlock(hostname string, repoFullName string, workspace string, pullNum int) {
+	params := []pegomock.Param{hostname, repoFullName, workspace, pullNum}
 	pegomock.GetGenericMockFrom(mock).Invoke("Unlock", params, []reflect.Type{})
 }
 
@@ -52,8 +52,8 @@ type VerifierAtlantisWorkspaceLocker struct {
 	inOrderContext         *pegomock.InOrderContext
 }
 
-func (verifier *VerifierAtlantisWorkspaceLocker) TryLock(repoFullName string, workspace string, pullNum int) *AtlantisWorkspaceLocker_TryLock_OngoingVerification {
-	params := []pegomock.Param{repoFullName, workspace, pullNum}
+func (verifier *VerifierAtlantisWorkspaceLocker) TryLock(hostname string, repoFullName string, workspace string, pullNum int) *AtlantisWorkspaceLocker_TryLock_OngoingVerification {
+	params := []pegomock.Param{hostname, repoFullName, workspace, pullNum}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "TryLock", params)
 	return &AtlantisWorkspaceLocker_TryLock_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -63,12 +63,12 @@ type AtlantisWorkspaceLocker_TryLock_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *AtlantisWorkspaceLocker_TryLock_OngoingVerification) GetCapturedArguments() (string, string, int) {
-	repoFullName, workspace, pullNum := c.GetAllCapturedArguments()
-	return repoFullName[len(repoFullName)-1], workspace[len(workspace)-1], pullNum[len(pullNum)-1]
+func (c *AtlantisWorkspaceLocker_TryLock_OngoingVerification) GetCapturedArguments() (string, string, string, int) {
+	hostname, repoFullName, workspace, pullNum := c.GetAllCapturedArguments()
+	return hostname[len(hostname)-1], repoFullName[len(repoFullName)-1], workspace[len(workspace)-1], pullNum[len(pullNum)-1]
 }
 
-func (c *AtlantisWorkspaceLocker_TryLock_OngoingVerification) GetAllCapturedArguments() (_param0 []string, _param1 []string, _param2 []int) {
+func (c *AtlantisWorkspaceLocker_TryLock_OngoingVerification) GetAllCapturedArguments() (_param0 []string, _param1 []string, _param2 []string, _param3 []int) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
 		_param0 = make([]string, len(params[0]))
@@ -79,16 +79,20 @@ func (c *AtlantisWorkspaceLocker_TryLock_OngoingVerification) GetAllCapturedArgu
 		for u, param := range params[1] {
 			_param1[u] = param.(string)
 		}
-		_param2 = make([]int, len(params[2]))
+		_param2 = make([]string, len(params[2]))
 		for u, param := range params[2] {
-			_param2[u] = param.(int)
+			_param2[u] = param.(string)
+		}
+		_param3 = make([]int, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(int)
 		}
 	}
 	return
 }
 
-func (verifier *VerifierAtlantisWorkspaceLocker) Unlock(repoFullName string, workspace string, pullNum int) *AtlantisWorkspaceLocker_Unlock_OngoingVerification {
-	params := []pegomock.Param{repoFullName, workspace, pullNum}
+func (verifier *VerifierAtlantisWorkspaceLocker) Unlock(hostname string, repoFullName string, workspace string, pullNum int) *AtlantisWorkspaceLocker_Unlock_OngoingVerification {
+	params := []pegomock.Param{hostname, repoFullName, workspace, pullNum}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "Unlock", params)
 	return &AtlantisWorkspaceLocker_Unlock_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -98,12 +102,12 @@ type AtlantisWorkspaceLocker_Unlock_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *AtlantisWorkspaceLocker_Unlock_OngoingVerification) GetCapturedArguments() (string, string, int) {
-	repoFullName, workspace, pullNum := c.GetAllCapturedArguments()
-	return repoFullName[len(repoFullName)-1], workspace[len(workspace)-1], pullNum[len(pullNum)-1]
+func (c *AtlantisWorkspaceLocker_Unlock_OngoingVerification) GetCapturedArguments() (string, string, string, int) {
+	hostname, repoFullName, workspace, pullNum := c.GetAllCapturedArguments()
+	return hostname[len(hostname)-1], repoFullName[len(repoFullName)-1], workspace[len(workspace)-1], pullNum[len(pullNum)-1]
 }
 
-func (c *AtlantisWorkspaceLocker_Unlock_OngoingVerification) GetAllCapturedArguments() (_param0 []string, _param1 []string, _param2 []int) {
+func (c *AtlantisWorkspaceLocker_Unlock_OngoingVerification) GetAllCapturedArguments() (_param0 []string, _param1 []string, _param2 []string, _param3 []int) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
 		_param0 = make([]string, len(params[0]))
@@ -114,9 +118,13 @@ func (c *AtlantisWorkspaceLocker_Unlock_OngoingVerification) GetAllCapturedArgum
 		for u, param := range params[1] {
 			_param1[u] = param.(string)
 		}
-		_param2 = make([]int, len(params[2]))
+		_param2 = make([]string, len(params[2]))
 		for u, param := range params[2] {
-			_param2[u] = param.(int)
+			_param2[u] = param.(string)
+		}
+		_param3 = make([]int, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(int)
 		}
 	}
 	return
@@ -19,8 +19,8 @@ func NewMockCommentParsing() *MockCommentParsing {
 	return &MockCommentParsing{fail: pegomock.GlobalFailHandler}
 }
 
-func (mock *MockCommentParsing) Parse(comment string, vcsHost vcs.Host) events.CommentParseResult {
-	params := []pegomock.Param{comment, vcsHost}
+func (mock *MockCommentParsing) Parse(comment string, vcsHost vcs.Host, hostname string) events.CommentParseResult {
+	params := []pegomock.Param{comment, vcsHost, hostname}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("Parse", params, []reflect.Type{reflect.TypeOf((*events.CommentParseResult)(nil)).Elem()})
 	var ret0 events.CommentParseResult
 	if len(result) != 0 {
@@ -49,8 +49,8 @@ type VerifierCommentParsing struct {
 	inOrderContext         *pegomock.InOrderContext
 }
 
-func (verifier *VerifierCommentParsing) Parse(comment string, vcsHost vcs.Host) *CommentParsing_Parse_OngoingVerification {
-	params := []pegomock.Param{comment, vcsHost}
+func (verifier *VerifierCommentParsing) Parse(comment string, vcsHost vcs.Host, hostname string) *CommentParsing_Parse_OngoingVerification {
+	params := []pegomock.Param{comment, vcsHost, hostname}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "Parse", params)
 	return &CommentParsing_Parse_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -60,12 +60,12 @@ type CommentParsing_Parse_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *CommentParsing_Parse_OngoingVerification) GetCapturedArguments() (string, vcs.Host) {
-	comment, vcsHost := c.GetAllCapturedArguments()
-	return comment[len(comment)-1], vcsHost[len(vcsHost)-1]
+func (c *CommentParsing_Parse_OngoingVerification) GetCapturedArguments() (string, vcs.Host, string) {
+	comment, vcsHost, hostname := c.GetAllCapturedArguments()
+	return comment[len(comment)-1], vcsHost[len(vcsHost)-1], hostname[len(hostname)-1]
 }
 
-func (c *CommentParsing_Parse_OngoingVerification) GetAllCapturedArguments() (_param0 []string, _param1 []vcs.Host) {
+func (c *CommentParsing_Parse_OngoingVerification) GetAllCapturedArguments() (_param0 []string, _param1 []vcs.Host, _param2 []string) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
 		_param0 = make([]string, len(params[0]))
@@ -76,6 +76,10 @@ func (c *CommentParsing_Parse_OngoingVerification) GetAllCapturedArguments() (_p
 		for u, param := range params[1] {
 			_param1[u] = param.(vcs.Host)
 		}
+		_param2 = make([]string, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.(string)
+		}
 	}
 	return
 }
@@ -19,8 +19,8 @@ func NewMockProjectFinder() *MockProjectFinder {
 	return &MockProjectFinder{fail: pegomock.GlobalFailHandler}
 }
 
-func (mock *MockProjectFinder) DetermineProjects(log *logging.SimpleLogger, modifiedFiles []string, repoFullName string, repoDir string) []models.Project {
-	params := []pegomock.Param{log, modifiedFiles, repoFullName, repoDir}
+func (mock *MockProjectFinder) DetermineProjects(log *logging.SimpleLogger, modifiedFiles []string, repo models.Repo, repoDir string) []models.Project {
+	params := []pegomock.Param{log, modifiedFiles, repo, repoDir}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("DetermineProjects", params, []reflect.Type{reflect.TypeOf((*[]models.Project)(nil)).Elem()})
 	var ret0 []models.Project
 	if len(result) != 0 {
@@ -49,8 +49,8 @@ type VerifierProjectFinder struct {
 	inOrderContext         *pegomock.InOrderContext
 }
 
-func (verifier *VerifierProjectFinder) DetermineProjects(log *logging.SimpleLogger, modifiedFiles []string, repoFullName string, repoDir string) *ProjectFinder_DetermineProjects_OngoingVerification {
-	params := []pegomock.Param{log, modifiedFiles, repoFullName, repoDir}
+func (verifier *VerifierProjectFinder) DetermineProjects(log *logging.SimpleLogger, modifiedFiles []string, repo models.Repo, repoDir string) *ProjectFinder_DetermineProjects_OngoingVerification {
+	params := []pegomock.Param{log, modifiedFiles, repo, repoDir}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "DetermineProjects", params)
 	return &ProjectFinder_DetermineProjects_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -60,12 +60,12 @@ type ProjectFinder_DetermineProjects_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *ProjectFinder_DetermineProjects_OngoingVerification) GetCapturedArguments() (*logging.SimpleLogger, []string, string, string) {
-	log, modifiedFiles, repoFullName, repoDir := c.GetAllCapturedArguments()
-	return log[len(log)-1], modifiedFiles[len(modifiedFiles)-1], repoFullName[len(repoFullName)-1], repoDir[len(repoDir)-1]
+func (c *ProjectFinder_DetermineProjects_OngoingVerification) GetCapturedArguments() (*logging.SimpleLogger, []string, models.Repo, string) {
+	log, modifiedFiles, repo, repoDir := c.GetAllCapturedArguments()
+	return log[len(log)-1], modifiedFiles[len(modifiedFiles)-1], repo[len(repo)-1], repoDir[len(repoDir)-1]
 }
 
-func (c *ProjectFinder_DetermineProjects_OngoingVerification) GetAllCapturedArguments() (_param0 []*logging.SimpleLogger, _param1 [][]string, _param2 []string, _param3 []string) {
+func (c *ProjectFinder_DetermineProjects_OngoingVerification) GetAllCapturedArguments() (_param0 []*logging.SimpleLogger, _param1 [][]string, _param2 []models.Repo, _param3 []string) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
 		_param0 = make([]*logging.SimpleLogger, len(params[0]))
@@ -76,9 +76,9 @@ func (c *ProjectFinder_DetermineProjects_OngoingVerification) GetAllCapturedArgu
 		for u, param := range params[1] {
 			_param1[u] = param.([]string)
 		}
-		_param2 = make([]string, len(params[2]))
+		_param2 = make([]models.Repo, len(params[2]))
 		for u, param := range params[2] {
-			_param2[u] = param.(string)
+			_param2[u] = param.(models.Repo)
 		}
 		_param3 = make([]string, len(params[3]))
 		for u, param := range params[3] {
@@ -29,9 +29,12 @@ import (
 // Repo is a VCS repository.
 type Repo struct {
 	// FullName is the owner and repo name separated
-	// by a "/", ex. "runatlantis/atlantis".
+	// by a "/", ex. "runatlantis/atlantis". For GitLab repos in subgroups the
+	// owner contains "/"'s, ex. "group/subgroup/repo".
 	FullName string
-	// Owner is just the repo owner, ex. "runatlantis".
+	// Owner is the namespace the repo is in, ex. "runatlantis". For GitLab
+	// repos in subgroups this is the full path of the subgroup, ex.
+	// "group/subgroup".
 	Owner string
 	// Name is just the repo name, ex. "atlantis".
 	Name string
@@ -69,15 +72,20 @@ func NewRepo(repoFullName string, cloneURL string, vcsUser string, vcsToken stri
 	authedCloneURL := strings.Replace(cloneURL, "https://", "https://"+auth, -1)
 	authedCloneURL = strings.Replace(authedCloneURL, "http://", "http://"+auth, -1)
 
-	// Get the owner and repo names from the full name.
-	var owner string
-	var repo string
+	// Get the owner and repo names from the full name. GitLab supports
+	// nested groups so the owner can be multiple levels deep, ex.
+	// group/subgroup/repo, but none of the levels can be empty.
 	pathSplit := strings.Split(repoFullName, "/")
-	if len(pathSplit) != 2 || pathSplit[0] == "" || pathSplit[1] == "" {
+	if len(pathSplit) < 2 {
 		return Repo{}, fmt.Errorf("invalid repo format %q", repoFullName)
 	}
-	owner = pathSplit[0]
-	repo = pathSplit[1]
+	for _, part := range pathSplit {
+		if part == "" {
+			return Repo{}, fmt.Errorf("invalid repo format %q", repoFullName)
+		}
+	}
+	owner := strings.Join(pathSplit[:len(pathSplit)-1], "/")
+	repo := pathSplit[len(pathSplit)-1]
 
 	return Repo{
 		FullName:          repoFullName,
@@ -143,6 +151,10 @@ type ProjectLock struct {
 // Terraform projects in a single repo we also include Path to the project
 // root relative to the repo root.
 type Project struct {
+	// Hostname is the hostname of the VCS provider the repo is hosted on,
+	// ex. "github.com". It's needed because repos with the same name can
+	// exist on different hosts.
+	Hostname string
 	// RepoFullName is the owner and repo name, ex. "runatlantis/atlantis"
 	RepoFullName string
 	// Path to project root in the repo.
@@ -151,6 +163,16 @@ type Project struct {
 	Path string
 }
 
+// KnownProject is a project and workspace that Atlantis has planned. Drift
+// detection plans each of them against the repo's default branch.
+type KnownProject struct {
+	Project   Project
+	Workspace string
+	// SanitizedCloneURL is the repo's clone url without credentials, ex.
+	// "https://github.com/runatlantis/atlantis.git".
+	SanitizedCloneURL string
+}
+
 // Plan is the result of running an Atlantis plan command.
 // This model is used to represent a plan on disk.
 type Plan struct {
@@ -163,12 +185,13 @@ type Plan struct {
 
 // NewProject constructs a Project. Use this constructor because it
 // sets Path correctly.
-func NewProject(repoFullName string, path string) Project {
+func NewProject(hostname string, repoFullName string, path string) Project {
 	path = paths.Clean(path)
 	if path == "/" {
 		path = "."
 	}
 	return Project{
+		Hostname:     hostname,
 		RepoFullName: repoFullName,
 		Path:         path,
 	}
@@ -43,7 +43,9 @@ func TestNewRepo_CloneURLWrongRepo(t *testing.T) {
 
 func TestNewRepo_FullNameWrongFormat(t *testing.T) {
 	cases := []string{
-		"owner/repo/extra",
+		"owner",
+		"owner/repo/",
+		"owner//repo",
 		"/",
 		"//",
 		"///",
@@ -59,6 +61,20 @@ func TestNewRepo_FullNameWrongFormat(t *testing.T) {
 	}
 }
 
+func TestNewRepo_NestedGroups(t *testing.T) {
+	// GitLab repos can be in subgroups.
+	repo, err := models.NewRepo("group/subgroup/repo", "https://gitlab.com/group/subgroup/repo.git", "u", "p")
+	Ok(t, err)
+	Equals(t, models.Repo{
+		Hostname:          "gitlab.com",
+		SanitizedCloneURL: "https://gitlab.com/group/subgroup/repo.git",
+		CloneURL:          "https://u:p@gitlab.com/group/subgroup/repo.git",
+		FullName:          "group/subgroup/repo",
+		Owner:             "group/subgroup",
+		Name:              "repo",
+	}, repo)
+}
+
 func TestNewRepo_HTTPAuth(t *testing.T) {
 	// When the url has http the auth should be added.
 	repo, err := models.NewRepo("owner/repo", "http://github.com/owner/repo.git", "u", "p")
@@ -0,0 +1,137 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// moduleGraph maps the dirs of modules in a repo to the dirs that use them
+// via module blocks with local sources. All dirs are relative to the repo
+// root.
+type moduleGraph map[string][]string
+
+// buildModuleGraph parses the .tf files in repoDir to find which dirs use
+// which local modules. Files that can't be scanned are skipped with a
+// warning since the projects that use modules in them won't be planned.
+func buildModuleGraph(log *logging.SimpleLogger, repoDir string) (moduleGraph, error) {
+	graph := make(moduleGraph)
+	err := filepath.Walk(repoDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			switch info.Name() {
+			case ".git", ".terraform", ".terragrunt-cache":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(p) != ".tf" {
+			return nil
+		}
+		rel, err := filepath.Rel(repoDir, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		dir := filepath.ToSlash(rel)
+		sources, err := localModuleSources(p)
+		if err != nil {
+			log.Warn("skipping %q when finding modules: %s", p, err)
+			return nil
+		}
+		for _, source := range sources {
+			if module := repoRelative(dir, source); module != "" && module != dir {
+				graph[module] = appendUnique(graph[module], dir)
+			}
+		}
+		return nil
+	})
+	return graph, err
+}
+
+// localModuleSources returns the sources of the module blocks in the .tf
+// file at filename that are local paths.
+func localModuleSources(filename string) ([]string, error) {
+	body, err := scanHCLFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var sources []string
+	for _, module := range body.Blocks {
+		if module.Type != "module" {
+			continue
+		}
+		source, ok := hclString(module.Body.Attributes["source"])
+		if !ok {
+			continue
+		}
+		// Local paths are used as is, even if they contain a //. In other
+		// sources a // separates what's downloaded from the subdir to use
+		// but they aren't in the repo so they're skipped.
+		if strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../") {
+			sources = append(sources, source)
+		}
+	}
+	return sources, nil
+}
+
+// isModule returns true if dir is used as a module.
+func (g moduleGraph) isModule(dir string) bool {
+	return len(g[dir]) > 0
+}
+
+// projectsUsing returns the dirs of the projects that use, directly or
+// through other modules, the modules that file is in, sorted. Projects are
+// dirs that aren't themselves used as modules.
+func (g moduleGraph) projectsUsing(file string) []string {
+	var queue []string
+	for module := range g {
+		if path.Dir(file) == module || isInDir(file, module) {
+			queue = append(queue, module)
+		}
+	}
+	seen := make(map[string]bool)
+	var projects []string
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		if !g.isModule(dir) {
+			projects = append(projects, dir)
+			continue
+		}
+		queue = append(queue, g[dir]...)
+	}
+	sort.Strings(projects)
+	return projects
+}
+
+func appendUnique(strs []string, s string) []string {
+	for _, existing := range strs {
+		if existing == s {
+			return strs
+		}
+	}
+	return append(strs, s)
+}
@@ -0,0 +1,127 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestDetermineProjects_ModuleSyntax(t *testing.T) {
+	// modules/
+	//   network/main.tf  # 0.12 syntax
+	//   vpc/main.tf      # 0.12 syntax, uses ../network
+	//   legacy/main.tf   # 0.11 syntax
+	//   broken/main.tf   # can't be scanned, uses ../network
+	// project011/main.tf # 0.11 syntax, uses ../modules/legacy
+	// project012/main.tf # 0.12 syntax, uses ../modules/vpc
+	repoDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(repoDir) // nolint: errcheck
+	files := map[string]string{
+		"modules/network/main.tf": `variable "cidr" {
+  type = string
+}`,
+		"modules/vpc/main.tf": `variable "cidr" {
+  type    = string
+  default = "10.0.0.0/16"
+}
+
+locals {
+  # A comment with a } and a "quote
+  tags  = { for k, v in var.tags : k => upper(v) if v != "" }
+  names = [for s in var.subnets : "${s.name}-${lookup(s, "suffix", "x")}"]
+}
+
+/* module "commented" { source = "../commented" } */
+
+module "network" {
+  count  = var.enabled ? 1 : 0
+  cidr   = var.cidr
+  policy = <<-EOF
+    { "module": "not-a-block" }
+  EOF
+  source = "../network"
+}
+
+resource "aws_subnet" "this" {
+  dynamic "tag" {
+    for_each = local.tags
+    content {
+      key = tag.key
+    }
+  }
+}`,
+		"modules/legacy/main.tf": `variable "cidr" {
+  type = "string"
+}`,
+		"modules/broken/main.tf": `module "network" {
+  source = "../network
+}`,
+		"project011/main.tf": `variable "cidr" {}
+
+module "legacy" {
+  source = "../modules/legacy"
+  cidr   = "${var.cidr}"
+  tags   = "${map("Name", "legacy")}"
+}`,
+		"project012/main.tf": `module "vpc" {
+  source  = "../modules/vpc"
+  cidr    = var.cidr
+  subnets = [{ name = "a" }, { name = "b" }]
+  providers = {
+    aws = aws.west
+  }
+}`,
+	}
+	for name, contents := range files {
+		Ok(t, os.MkdirAll(filepath.Join(repoDir, filepath.Dir(name)), 0700))
+		Ok(t, ioutil.WriteFile(filepath.Join(repoDir, name), []byte(contents), 0600))
+	}
+
+	cases := []struct {
+		description     string
+		files           []string
+		expProjectPaths []string
+	}{
+		{
+			"A change to a module used with the 0.11 syntax should plan the project that uses it",
+			[]string{"modules/legacy/main.tf"},
+			[]string{"project011"},
+		},
+		{
+			"A change to a module used with the 0.12 syntax should plan the project that uses it",
+			[]string{"modules/vpc/variables.tf"},
+			[]string{"project012"},
+		},
+		{
+			"A change to a module should plan the projects that use it transitively",
+			[]string{"modules/network/main.tf"},
+			[]string{"project012"},
+		},
+	}
+	for _, c := range cases {
+		t.Log(c.description)
+		projects := m.DetermineProjects(noopLogger, c.files, modifiedRepo, repoDir)
+		var paths []string
+		for _, project := range projects {
+			paths = append(paths, project.Path)
+		}
+		Equals(t, c.expProjectPaths, paths)
+	}
+}
@@ -15,15 +15,25 @@ package events
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/go-version"
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/locking"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/policy"
 	"github.com/runatlantis/atlantis/server/events/run"
 	"github.com/runatlantis/atlantis/server/events/terraform"
 	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/metrics"
 )
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_lock_url_generator.go LockURLGenerator
@@ -49,12 +59,34 @@ type PlanExecutor struct {
 	Workspace         AtlantisWorkspace
 	ProjectPreExecute ProjectPreExecutor
 	ProjectFinder     ProjectFinder
+	// AtlantisWorkspaceLocker locks the workspaces we plan in other than the
+	// command's workspace, which is locked before the command is executed.
+	AtlantisWorkspaceLocker AtlantisWorkspaceLocker
+	// PolicyChecker checks plans against the server's policies. If nil,
+	// policy checks are disabled.
+	PolicyChecker *policy.Checker
+	// KnownProjects records each project and workspace that's planned so
+	// drift detection can check them. If nil, they aren't recorded.
+	KnownProjects KnownProjectStore
+	// Metrics records how long plans take. If nil, they aren't recorded.
+	Metrics *metrics.Metrics
+	// Webhooks are sent after each project is planned. If nil, they aren't
+	// sent.
+	Webhooks webhooks.PlanSender
+	// LockWebhooks are sent when a project's lock is released because its
+	// plan errored. If nil, they aren't sent.
+	LockWebhooks webhooks.LockSender
 }
 
 // PlanSuccess is the result of a successful plan.
 type PlanSuccess struct {
 	TerraformOutput string
 	LockURL         string
+	// PolicyCheck is the result of checking the plan against the server's
+	// policies. It's nil if policy checks are disabled.
+	PolicyCheck *PolicyCheckResult
+	// Destroys describes the resources the plan destroys.
+	Destroys DestroySummary
 }
 
 // SetLockURL takes a function that given a lock id, will return a url
@@ -71,71 +103,274 @@ func (p *PlanExecutor) Execute(ctx *CommandContext) CommandResponse {
 	}
 
 	var projects []models.Project
-	if ctx.Command.Dir == "" {
+	var modifiedFiles []string
+	if ctx.Command.selectsProjects() {
+		candidates := func() ([]models.Project, error) {
+			return allProjects(ctx.Log, p.ProjectFinder, ctx.BaseRepo, cloneDir)
+		}
+		projects, err = selectProjects(ctx.Command, ctx.BaseRepo, cloneDir, candidates)
+		if err != nil {
+			return CommandResponse{Failure: err.Error()}
+		}
+		if len(projects) == 0 {
+			return CommandResponse{Failure: "No Terraform projects were found."}
+		}
+	} else {
 		// If they didn't specify a directory to plan in, figure out what
 		// projects have been modified so we know where to run plan.
-		modifiedFiles, err := p.VCSClient.GetModifiedFiles(ctx.BaseRepo, ctx.Pull, ctx.VCSHost)
+		modifiedFiles, err = p.VCSClient.GetModifiedFiles(ctx.BaseRepo, ctx.Pull, ctx.VCSHost)
 		if err != nil {
 			return CommandResponse{Error: errors.Wrap(err, "getting modified files")}
 		}
 		ctx.Log.Info("found %d files modified in this pull request", len(modifiedFiles))
-		projects = p.ProjectFinder.DetermineProjects(ctx.Log, modifiedFiles, ctx.BaseRepo.FullName, cloneDir)
+		projects = p.ProjectFinder.DetermineProjects(ctx.Log, modifiedFiles, ctx.BaseRepo, cloneDir)
 		if len(projects) == 0 {
 			return CommandResponse{Failure: "No Terraform files were modified."}
 		}
-	} else {
-		projects = []models.Project{{
-			Path:         ctx.Command.Dir,
-			RepoFullName: ctx.BaseRepo.FullName,
-		}}
 	}
 
+	// Each workspace other than the command's is planned in its own clone.
+	cloneDirs := map[string]string{ctx.Command.Workspace: cloneDir}
+	defer func() {
+		for workspace := range cloneDirs {
+			if workspace != ctx.Command.Workspace && p.AtlantisWorkspaceLocker != nil {
+				p.AtlantisWorkspaceLocker.Unlock(ctx.BaseRepo.Hostname, ctx.BaseRepo.FullName, workspace, ctx.Pull.Num)
+			}
+		}
+	}()
+
 	var results []ProjectResult
 	for _, project := range projects {
-		ctx.Log.Info("running plan for project at path %q", project.Path)
-		result := p.plan(ctx, cloneDir, project)
-		result.Path = project.Path
-		results = append(results, result)
+		for _, workspace := range p.workspaces(ctx, cloneDir, project, modifiedFiles) {
+			done := logProject(ctx, project.Path, workspace)
+			ctx.Log.Info("running plan for project at path %q in workspace %q", project.Path, workspace)
+			result := p.planInWorkspace(ctx, cloneDirs, project, workspace)
+			done()
+			result.Path = project.Path
+			result.Workspace = workspace
+			p.sendWebhook(ctx, result)
+			results = append(results, result)
+		}
 	}
 	return CommandResponse{ProjectResults: results}
 }
 
+// sendWebhook sends the plan webhook for result.
+func (p *PlanExecutor) sendWebhook(ctx *CommandContext, result ProjectResult) {
+	if p.Webhooks == nil {
+		return
+	}
+	planResult := webhooks.PlanResult{
+		Repo:      ctx.BaseRepo,
+		Pull:      ctx.Pull,
+		User:      ctx.User,
+		Path:      result.Path,
+		Workspace: result.Workspace,
+		Success:   result.PlanSuccess != nil,
+	}
+	if result.PlanSuccess != nil {
+		planResult.Summary = ctx.Redactor.Redact(outputSummary(result.PlanSuccess.TerraformOutput, ""))
+	}
+	p.Webhooks.SendPlan(ctx.Log, planResult) // nolint: errcheck
+}
+
+// workspaces returns the workspaces to plan project in. If the workspace
+// was set with -w we only plan in that workspace. If --all-workspaces was
+// used we plan in every workspace with an env/{workspace}.tfvars file.
+// Otherwise we plan in the workspaces whose env/{workspace}.tfvars files
+// were modified and, if anything else in the project was modified, in the
+// command's workspace.
+func (p *PlanExecutor) workspaces(ctx *CommandContext, repoDir string, project models.Project, modifiedFiles []string) []string {
+	if ctx.Command.AllWorkspaces {
+		envFiles, _ := filepath.Glob(filepath.Join(repoDir, project.Path, "env", "*.tfvars"))
+		var workspaces []string
+		for _, f := range envFiles {
+			if w, ok := envFileWorkspace(ctx, f); ok {
+				workspaces = append(workspaces, w)
+			}
+		}
+		if len(workspaces) == 0 {
+			return []string{ctx.Command.Workspace}
+		}
+		sort.Strings(workspaces)
+		return workspaces
+	}
+	if ctx.Command.WorkspaceSet {
+		return []string{ctx.Command.Workspace}
+	}
+
+	var envWorkspaces []string
+	planCommandWorkspace := false
+	for _, f := range modifiedFiles {
+		if !isInDir(f, project.Path) {
+			continue
+		}
+		if path.Dir(f) == path.Join(project.Path, "env") && path.Ext(f) == ".tfvars" {
+			if w, ok := envFileWorkspace(ctx, f); ok {
+				envWorkspaces = appendUnique(envWorkspaces, w)
+			}
+		} else {
+			planCommandWorkspace = true
+		}
+	}
+	// If no files in the project were modified then it's being planned
+	// because of a module or dependency so we plan the command's workspace.
+	if len(envWorkspaces) == 0 {
+		return []string{ctx.Command.Workspace}
+	}
+	sort.Strings(envWorkspaces)
+	if planCommandWorkspace {
+		workspaces := []string{ctx.Command.Workspace}
+		for _, w := range envWorkspaces {
+			workspaces = appendUnique(workspaces, w)
+		}
+		return workspaces
+	}
+	return envWorkspaces
+}
+
+// envFileWorkspace returns the workspace for the env/{workspace}.tfvars file
+// f. ok is false if the file's name isn't a valid workspace, ex. "...tfvars",
+// since it's used as the name of a directory.
+func envFileWorkspace(ctx *CommandContext, f string) (workspace string, ok bool) {
+	workspace = strings.TrimSuffix(filepath.Base(f), ".tfvars")
+	if !validWorkspace(workspace) {
+		ctx.Log.Warn("not planning workspace for %q: %q isn't a valid workspace name", f, workspace)
+		return "", false
+	}
+	return workspace, true
+}
+
+// planArgs returns the args that plan is run with in projectDir after the
+// ones Atlantis always adds: extraArgs, the flags from the comment and the
+// env/{workspace}.tfvars file if it exists.
+func planArgs(ctx *CommandContext, projectDir string, extraArgs []string) []string {
+	args := append(append([]string{}, extraArgs...), ctx.Command.Flags...)
+	envFileName := filepath.Join("env", ctx.Command.Workspace+".tfvars")
+	if _, err := os.Stat(filepath.Join(projectDir, envFileName)); err == nil {
+		args = append(args, "-var-file", envFileName)
+	}
+	return args
+}
+
+// planInWorkspace plans project in workspace, cloning and locking the
+// workspace first if it isn't the command's workspace.
+func (p *PlanExecutor) planInWorkspace(ctx *CommandContext, cloneDirs map[string]string, project models.Project, workspace string) ProjectResult {
+	if workspace == ctx.Command.Workspace {
+		return p.plan(ctx, cloneDirs[workspace], project)
+	}
+
+	cloneDir, ok := cloneDirs[workspace]
+	if !ok {
+		if p.AtlantisWorkspaceLocker != nil && !p.AtlantisWorkspaceLocker.TryLock(ctx.BaseRepo.Hostname, ctx.BaseRepo.FullName, workspace, ctx.Pull.Num) {
+			return ProjectResult{Failure: fmt.Sprintf("The %s workspace is currently locked by another command that is running for this pull request. Wait until the previous command is complete and try again.", workspace)}
+		}
+		var err error
+		cloneDir, err = p.Workspace.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, workspace)
+		if err != nil {
+			if p.AtlantisWorkspaceLocker != nil {
+				p.AtlantisWorkspaceLocker.Unlock(ctx.BaseRepo.Hostname, ctx.BaseRepo.FullName, workspace, ctx.Pull.Num)
+			}
+			return ProjectResult{Error: err}
+		}
+		cloneDirs[workspace] = cloneDir
+	}
+
+	workspaceCtx := *ctx
+	command := *ctx.Command
+	command.Workspace = workspace
+	workspaceCtx.Command = &command
+	return p.plan(&workspaceCtx, cloneDir, project)
+}
+
 func (p *PlanExecutor) plan(ctx *CommandContext, repoDir string, project models.Project) ProjectResult {
 	preExecute := p.ProjectPreExecute.Execute(ctx, repoDir, project)
-	if preExecute.ProjectResult != (ProjectResult{}) {
+	if preExecute.ProjectResult.Error != nil || preExecute.ProjectResult.Failure != "" {
 		return preExecute.ProjectResult
 	}
 	config := preExecute.ProjectConfig
 	terraformVersion := preExecute.TerraformVersion
+	hookOutputs := preExecute.HookOutputs
 	workspace := ctx.Command.Workspace
 
-	// Run terraform plan.
+	// Run terraform plan, or the workflow's plan steps if it has one.
 	planFile := filepath.Join(repoDir, project.Path, fmt.Sprintf("%s.tfplan", workspace))
-	userVar := fmt.Sprintf("%s=%s", atlantisUserTFVar, ctx.User.Username)
-	planExtraArgs := config.GetExtraArguments(ctx.Command.Name.String())
-	tfPlanCmd := append(append([]string{"plan", "-refresh", "-no-color", "-out", planFile, "-var", userVar}, planExtraArgs...), ctx.Command.Flags...)
-
-	// Check if env/{workspace}.tfvars exist.
-	envFileName := filepath.Join("env", workspace+".tfvars")
-	if _, err := os.Stat(filepath.Join(repoDir, project.Path, envFileName)); err == nil {
-		tfPlanCmd = append(tfPlanCmd, "-var-file", envFileName)
+	var output string
+	var err error
+	start := time.Now()
+	if config.Workflow != nil {
+		w := &workflowRun{
+			ctx:       ctx,
+			terraform: p.Terraform,
+			run:       p.Run,
+			config:    config,
+			env:       runEnv(ctx, repoDir, project.Path, terraformVersion),
+		}
+		output, err = w.runSteps(config.Workflow.PlanSteps())
+	} else {
+		userVar := fmt.Sprintf("%s=%s", atlantisUserTFVar, ctx.User.Username)
+		planArgs := planArgs(ctx, filepath.Join(repoDir, project.Path), config.GetExtraArguments(ctx.Command.Name.String()))
+		tfPlanCmd := append([]string{"plan", "-refresh", "-no-color", "-out", planFile, "-var", userVar}, planArgs...)
+		output, err = p.Terraform.RunCommandWithVersion(ctx.Log, filepath.Join(repoDir, project.Path), tfPlanCmd, terraformVersion, workspace)
+		if err != nil {
+			err = fmt.Errorf("%s\n%s", err.Error(), output)
+		}
 	}
-	output, err := p.Terraform.RunCommandWithVersion(ctx.Log, filepath.Join(repoDir, project.Path), tfPlanCmd, terraformVersion, workspace)
+	p.Metrics.TerraformRan("plan", ctx.BaseRepo.FullName, time.Since(start))
 	if err != nil {
 		// Plan failed so unlock the state.
-		if _, unlockErr := p.Locker.Unlock(preExecute.LockResponse.LockKey); unlockErr != nil {
+		lock, unlockErr := p.Locker.Unlock(preExecute.LockResponse.LockKey)
+		if unlockErr != nil {
 			ctx.Log.Err("error unlocking state after plan error: %v", unlockErr)
 		}
-		return ProjectResult{Error: fmt.Errorf("%s\n%s", err.Error(), output)}
+		if lock != nil && p.LockWebhooks != nil {
+			p.LockWebhooks.SendUnlock(ctx.Log, webhooks.LockResult{Lock: *lock, Reason: webhooks.UnlockErrorReason}) // nolint: errcheck
+		}
+		return ProjectResult{Error: err, HookOutputs: hookOutputs}
 	}
 	ctx.Log.Info("plan succeeded")
 
 	// If there are post plan commands then run them.
 	if len(config.PostPlan) > 0 {
-		absolutePath := filepath.Join(repoDir, project.Path)
-		_, err := p.Run.Execute(ctx.Log, config.PostPlan, absolutePath, workspace, terraformVersion, "post_plan")
+		hookOutputs, err = runHook(p.Run, ctx, config, config.PostPlan, runEnv(ctx, repoDir, project.Path, terraformVersion), "post_plan", hookOutputs)
 		if err != nil {
-			return ProjectResult{Error: errors.Wrap(err, "running post plan commands")}
+			return ProjectResult{Error: errors.Wrap(err, "running post plan commands"), HookOutputs: hookOutputs}
+		}
+	}
+
+	// Any failures from a previous plan no longer apply.
+	if err := os.Remove(policyFailuresFile(planFile)); err != nil && !os.IsNotExist(err) {
+		return ProjectResult{Error: errors.Wrap(err, "removing previous policy check failures")}
+	}
+	if err := os.Remove(destroyConfirmationFile(planFile)); err != nil && !os.IsNotExist(err) {
+		return ProjectResult{Error: errors.Wrap(err, "removing previous destroy confirmation")}
+	}
+	destroys := summarizeDestroys(output, config.DestroyThreshold)
+	if destroys.RequiresConfirmation {
+		ctx.Log.Info("plan destroys %d resource(s) so applying requires confirmation", destroys.Destroys)
+		if err := ioutil.WriteFile(destroyConfirmationFile(planFile), []byte(strconv.Itoa(destroys.Destroys)), 0600); err != nil {
+			return ProjectResult{Error: errors.Wrap(err, "saving destroy confirmation")}
+		}
+	}
+
+	var policyCheck *PolicyCheckResult
+	if p.PolicyChecker != nil {
+		policyCheck = p.checkPolicies(ctx, filepath.Join(repoDir, project.Path), planFile, terraformVersion, workspace)
+		if !policyCheck.Passed() {
+			if err := ioutil.WriteFile(policyFailuresFile(planFile), []byte(policyCheck.Summary()), 0600); err != nil {
+				return ProjectResult{Error: errors.Wrap(err, "saving policy check failures")}
+			}
+		}
+	}
+
+	if p.KnownProjects != nil {
+		err := p.KnownProjects.RecordProject(models.KnownProject{
+			Project:           project,
+			Workspace:         workspace,
+			SanitizedCloneURL: ctx.BaseRepo.SanitizedCloneURL,
+		})
+		if err != nil {
+			ctx.Log.Warn("recording project for drift detection: %s", err)
 		}
 	}
 
@@ -143,6 +378,27 @@ func (p *PlanExecutor) plan(ctx *CommandContext, repoDir string, project models.
 		PlanSuccess: &PlanSuccess{
 			TerraformOutput: output,
 			LockURL:         p.LockURL(preExecute.LockResponse.LockKey),
+			PolicyCheck:     policyCheck,
+			Destroys:        destroys,
 		},
+		HookOutputs: hookOutputs,
+	}
+}
+
+// checkPolicies converts the plan at planFile to JSON and checks it against
+// our policies. workspace is the workspace the project was planned in.
+func (p *PlanExecutor) checkPolicies(ctx *CommandContext, projectDir string, planFile string, terraformVersion *version.Version, workspace string) *PolicyCheckResult {
+	// "show -json" was added in Terraform 0.12 so this errors for older
+	// versions which fails the check. We only read stdout since warnings and
+	// terragrunt's logging go to stderr and would break the JSON.
+	planJSON, err := p.Terraform.RunCommandWithVersionStdout(ctx.Log, projectDir, []string{"show", "-json", planFile}, terraformVersion, workspace)
+	if err != nil {
+		return &PolicyCheckResult{Error: errors.Wrap(err, "converting plan to json")}
+	}
+	violations, err := p.PolicyChecker.Check([]byte(planJSON))
+	if err != nil {
+		return &PolicyCheckResult{Error: err}
 	}
+	ctx.Log.Info("policy check found %d violation(s)", len(violations))
+	return &PolicyCheckResult{Violations: violations}
 }
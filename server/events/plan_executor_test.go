@@ -15,6 +15,9 @@ package events_test
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/mohae/deepcopy"
@@ -24,10 +27,14 @@ import (
 	lmocks "github.com/runatlantis/atlantis/server/events/locking/mocks"
 	"github.com/runatlantis/atlantis/server/events/mocks"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/policy"
+	"github.com/runatlantis/atlantis/server/events/run"
 	rmocks "github.com/runatlantis/atlantis/server/events/run/mocks"
 	tmocks "github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	tmatchers "github.com/runatlantis/atlantis/server/events/terraform/mocks/matchers"
 	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
 	"github.com/runatlantis/atlantis/server/events/vcs/mocks/matchers"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/logging"
 	. "github.com/runatlantis/atlantis/testing"
 )
@@ -36,7 +43,6 @@ var planCtx = events.CommandContext{
 	Command: &events.Command{
 		Name:      events.Plan,
 		Workspace: "workspace",
-		Dir:       "",
 	},
 	Log:      logging.NewNoopLogger(),
 	BaseRepo: models.Repo{},
@@ -83,7 +89,7 @@ func TestExecute_DirectoryAndWorkspaceSet(t *testing.T) {
 	p, runner, _ := setupPlanExecutorTest(t)
 	ctx := deepcopy.Copy(planCtx).(events.CommandContext)
 	ctx.Log = logging.NewNoopLogger()
-	ctx.Command.Dir = "dir1/dir2"
+	ctx.Command.Dirs = []string{"dir1/dir2"}
 	ctx.Command.Workspace = "workspace-flag"
 
 	When(p.Workspace.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, "workspace-flag")).
@@ -187,6 +193,48 @@ func TestExecute_Success(t *testing.T) {
 	Equals(t, "lockurl-key", result.PlanSuccess.LockURL)
 }
 
+// fakePlanSender records the plan webhooks it's sent.
+type fakePlanSender struct {
+	results []webhooks.PlanResult
+}
+
+func (f *fakePlanSender) SendPlan(_ *logging.SimpleLogger, result webhooks.PlanResult) error {
+	f.results = append(f.results, result)
+	return nil
+}
+
+func TestExecute_PlanWebhooks(t *testing.T) {
+	t.Log("should send a plan webhook for each project that's planned")
+	p, runner, _ := setupPlanExecutorTest(t)
+	sender := &fakePlanSender{}
+	p.Webhooks = sender
+	When(runner.RunCommandWithVersion(tmatchers.AnyPtrToLoggingSimpleLogger(), AnyString(), tmatchers.AnySliceOfString(), tmatchers.AnyPtrToGoVersionVersion(), AnyString())).
+		ThenReturn("Plan: 1 to add, 0 to change, 0 to destroy.", nil)
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "workspace")).
+		ThenReturn("/tmp/clone-repo", nil)
+	When(p.ProjectPreExecute.Execute(&planCtx, "/tmp/clone-repo", models.Project{RepoFullName: "", Path: "."})).
+		ThenReturn(events.PreExecuteResult{
+			LockResponse: locking.TryLockResponse{
+				LockKey: "key",
+			},
+		})
+
+	p.Execute(&planCtx)
+
+	Equals(t, []webhooks.PlanResult{
+		{
+			Repo:      planCtx.BaseRepo,
+			Pull:      planCtx.Pull,
+			User:      planCtx.User,
+			Path:      ".",
+			Workspace: "workspace",
+			Success:   true,
+			Summary:   "Plan: 1 to add, 0 to change, 0 to destroy.",
+		},
+	}, sender.results)
+}
+
 func TestExecute_PreExecuteResult(t *testing.T) {
 	t.Log("If DefaultProjectPreExecutor.Execute returns a ProjectResult we should return it")
 	p, _, _ := setupPlanExecutorTest(t)
@@ -256,7 +304,14 @@ func TestExecute_PostPlanCommands(t *testing.T) {
 		ThenReturn(events.PreExecuteResult{
 			ProjectConfig: events.ProjectConfig{PostPlan: []string{"post-plan"}},
 		})
-	When(p.Run.Execute(planCtx.Log, []string{"post-plan"}, "/tmp/clone-repo", "workspace", nil, "post_plan")).
+	env := run.Env{
+		Dir:         "/tmp/clone-repo",
+		Workspace:   "workspace",
+		UserName:    "anubhavmishra",
+		ProjectPath: ".",
+		PlanFile:    "/tmp/clone-repo/workspace.tfplan",
+	}
+	When(p.Run.Execute(planCtx.Log, []string{"post-plan"}, env, "post_plan")).
 		ThenReturn("", errors.New("err"))
 
 	r := p.Execute(&planCtx)
@@ -267,6 +322,325 @@ func TestExecute_PostPlanCommands(t *testing.T) {
 	Equals(t, "running post plan commands: err", result.Error.Error())
 }
 
+func TestExecute_PolicyCheck(t *testing.T) {
+	t.Log("If policy checks are enabled, failures should be returned and saved next to the plan")
+	p, runner, _ := setupPlanExecutorTest(t)
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	p.PolicyChecker = &policy.Checker{Policies: []policy.Policy{{
+		Name:  "no-deletes",
+		Rules: []policy.Rule{{Description: "no deletes", Actions: []string{"delete"}}},
+	}}}
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "workspace")).ThenReturn(tmp, nil)
+	When(p.ProjectPreExecute.Execute(&planCtx, tmp, models.Project{RepoFullName: "", Path: "."})).
+		ThenReturn(events.PreExecuteResult{LockResponse: locking.TryLockResponse{LockKey: "key"}})
+	planFile := filepath.Join(tmp, "workspace.tfplan")
+	showCmd := []string{"show", "-json", planFile}
+	When(runner.RunCommandWithVersionStdout(planCtx.Log, tmp, showCmd, nil, "workspace")).
+		ThenReturn(`{"resource_changes": [{"address": "null_resource.a", "type": "null_resource", "change": {"actions": ["delete"]}}]}`, nil)
+
+	r := p.Execute(&planCtx)
+
+	Equals(t, 1, len(r.ProjectResults))
+	check := r.ProjectResults[0].PlanSuccess.PolicyCheck
+	Equals(t, []policy.Violation{{Policy: "no-deletes", Address: "null_resource.a", Description: "no deletes"}}, check.Violations)
+	failures, err := ioutil.ReadFile(planFile + ".policy-failures")
+	Ok(t, err)
+	Equals(t, check.Summary(), string(failures))
+
+	t.Log("If the next plan passes, the saved failures should be removed")
+	When(runner.RunCommandWithVersionStdout(planCtx.Log, tmp, showCmd, nil, "workspace")).
+		ThenReturn(`{"resource_changes": []}`, nil)
+	r = p.Execute(&planCtx)
+	Assert(t, r.ProjectResults[0].PlanSuccess.PolicyCheck.Passed(), "exp policy check to pass")
+	_, err = os.Stat(planFile + ".policy-failures")
+	Assert(t, os.IsNotExist(err), "exp failures file to be removed")
+}
+
+func TestExecute_PolicyCheckEnvWorkspace(t *testing.T) {
+	t.Log("The plan of a workspace inferred from an env file should be shown in that workspace")
+	p, runner, _ := setupPlanExecutorTest(t)
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	p.PolicyChecker = &policy.Checker{}
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).
+		ThenReturn([]string{"env/staging.tfvars"}, nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "staging")).ThenReturn(tmp, nil)
+	planFile := filepath.Join(tmp, "staging.tfplan")
+	showCmd := []string{"show", "-json", planFile}
+	When(runner.RunCommandWithVersionStdout(planCtx.Log, tmp, showCmd, nil, "staging")).
+		ThenReturn(`{"resource_changes": []}`, nil)
+
+	r := p.Execute(&planCtx)
+
+	Equals(t, 1, len(r.ProjectResults))
+	Assert(t, r.ProjectResults[0].PlanSuccess.PolicyCheck.Passed(), "exp policy check to pass")
+	runner.VerifyWasCalledOnce().RunCommandWithVersionStdout(planCtx.Log, tmp, showCmd, nil, "staging")
+}
+
+func TestExecute_Destroys(t *testing.T) {
+	t.Log("If a plan destroys more resources than the threshold, it should require confirmation")
+	p, runner, _ := setupPlanExecutorTest(t)
+	tmp, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmp) // nolint: errcheck
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "workspace")).ThenReturn(tmp, nil)
+	When(p.ProjectPreExecute.Execute(&planCtx, tmp, models.Project{RepoFullName: "", Path: "."})).
+		ThenReturn(events.PreExecuteResult{
+			LockResponse:  locking.TryLockResponse{LockKey: "key"},
+			ProjectConfig: events.ProjectConfig{DestroyThreshold: 1},
+		})
+	planFile := filepath.Join(tmp, "workspace.tfplan")
+	planCmd := []string{"plan", "-refresh", "-no-color", "-out", planFile, "-var", "atlantis_user=anubhavmishra"}
+	When(runner.RunCommandWithVersion(planCtx.Log, tmp, planCmd, nil, "workspace")).
+		ThenReturn("-/+ null_resource.a (new resource required)\n- null_resource.b\n\nPlan: 1 to add, 0 to change, 2 to destroy.\n", nil)
+
+	r := p.Execute(&planCtx)
+
+	Equals(t, events.DestroySummary{Destroys: 2, Replacements: 1, RequiresConfirmation: true}, r.ProjectResults[0].PlanSuccess.Destroys)
+	destroys, err := ioutil.ReadFile(planFile + ".destroys")
+	Ok(t, err)
+	Equals(t, "2", string(destroys))
+
+	t.Log("If the next plan is under the threshold, the confirmation should be removed")
+	When(runner.RunCommandWithVersion(planCtx.Log, tmp, planCmd, nil, "workspace")).
+		ThenReturn("- null_resource.b\n\nPlan: 0 to add, 0 to change, 1 to destroy.\n", nil)
+	r = p.Execute(&planCtx)
+	Equals(t, events.DestroySummary{Destroys: 1}, r.ProjectResults[0].PlanSuccess.Destroys)
+	_, err = os.Stat(planFile + ".destroys")
+	Assert(t, os.IsNotExist(err), "exp destroy confirmation to be removed")
+}
+
+func TestExecute_Workflow(t *testing.T) {
+	t.Log("If the project uses a workflow, its plan steps should be run instead of terraform plan")
+	p, runner, _ := setupPlanExecutorTest(t)
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).ThenReturn([]string{"file.tf"}, nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "workspace")).
+		ThenReturn("/tmp/clone-repo", nil)
+	workflow := events.Workflow{Plan: &events.Stage{Steps: []events.Step{
+		{Name: "env", EnvName: "STAGE", EnvValue: "prod"},
+		{Name: "run", RunCommand: "tflint"},
+		{Name: "plan", ExtraArgs: []string{"-lock=false"}},
+	}}}
+	When(p.ProjectPreExecute.Execute(&planCtx, "/tmp/clone-repo", models.Project{RepoFullName: "", Path: "."})).
+		ThenReturn(events.PreExecuteResult{
+			ProjectConfig: events.ProjectConfig{Workflow: &workflow},
+		})
+	planFile := "/tmp/clone-repo/workspace.tfplan"
+	env := run.Env{
+		Dir:         "/tmp/clone-repo",
+		Workspace:   "workspace",
+		UserName:    "anubhavmishra",
+		ProjectPath: ".",
+		PlanFile:    planFile,
+		Vars:        map[string]string{"STAGE": "prod"},
+	}
+	When(p.Run.Execute(planCtx.Log, []string{"tflint"}, env, "run")).
+		ThenReturn("lint passed", nil)
+	planCmd := []string{"plan", "-refresh", "-no-color", "-out", planFile, "-var", "atlantis_user=anubhavmishra", "-lock=false"}
+	When(runner.RunCommandWithVersion(planCtx.Log, "/tmp/clone-repo", planCmd, nil, "workspace")).
+		ThenReturn("plan output", nil)
+
+	r := p.Execute(&planCtx)
+
+	Equals(t, 1, len(r.ProjectResults))
+	Equals(t, "lint passed\nplan output", r.ProjectResults[0].PlanSuccess.TerraformOutput)
+}
+
+func TestExecute_EnvWorkspaces(t *testing.T) {
+	t.Log("If env/{workspace}.tfvars files are modified, plan should run in those workspaces too")
+	p, runner, _ := setupPlanExecutorTest(t)
+	p.AtlantisWorkspaceLocker = events.NewDefaultAtlantisWorkspaceLocker()
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).
+		ThenReturn([]string{"main.tf", "env/staging.tfvars", "env/production.tfvars"}, nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "workspace")).
+		ThenReturn("/tmp/clone-repo", nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "production")).
+		ThenReturn("/tmp/clone-production", nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "staging")).
+		ThenReturn("/tmp/clone-staging", nil)
+
+	r := p.Execute(&planCtx)
+
+	Equals(t, 3, len(r.ProjectResults))
+	for i, workspace := range []string{"workspace", "production", "staging"} {
+		Equals(t, workspace, r.ProjectResults[i].Workspace)
+		Equals(t, ".", r.ProjectResults[i].Path)
+	}
+	runner.VerifyWasCalledOnce().RunCommandWithVersion(
+		planCtx.Log,
+		"/tmp/clone-staging",
+		[]string{"plan", "-refresh", "-no-color", "-out", "/tmp/clone-staging/staging.tfplan", "-var", "atlantis_user=anubhavmishra"},
+		nil,
+		"staging",
+	)
+	// The workspaces we locked should be unlocked once we're done.
+	Assert(t, p.AtlantisWorkspaceLocker.TryLock(planCtx.BaseRepo.Hostname, planCtx.BaseRepo.FullName, "staging", planCtx.Pull.Num), "exp staging to be unlocked")
+}
+
+func TestExecute_EnvWorkspacesOnly(t *testing.T) {
+	t.Log("If only env/{workspace}.tfvars files are modified, plan shouldn't run in the command's workspace")
+	p, _, _ := setupPlanExecutorTest(t)
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).
+		ThenReturn([]string{"env/staging.tfvars"}, nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "workspace")).
+		ThenReturn("/tmp/clone-repo", nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "staging")).
+		ThenReturn("/tmp/clone-staging", nil)
+
+	r := p.Execute(&planCtx)
+
+	Equals(t, 1, len(r.ProjectResults))
+	Equals(t, "staging", r.ProjectResults[0].Workspace)
+}
+
+func TestExecute_EnvWorkspacesInvalid(t *testing.T) {
+	t.Log("env files whose names aren't valid workspaces shouldn't be planned since the workspace is used as a directory name")
+	p, _, _ := setupPlanExecutorTest(t)
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).
+		ThenReturn([]string{"env/...tfvars", "env/..tfvars", "env/.tfvars", "env/staging.tfvars"}, nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "workspace")).
+		ThenReturn("/tmp/clone-repo", nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "staging")).
+		ThenReturn("/tmp/clone-staging", nil)
+
+	r := p.Execute(&planCtx)
+
+	Equals(t, 1, len(r.ProjectResults))
+	Equals(t, "staging", r.ProjectResults[0].Workspace)
+	for _, workspace := range []string{"..", ".", ""} {
+		p.Workspace.(*mocks.MockAtlantisWorkspace).VerifyWasCalled(Never()).Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, workspace)
+	}
+}
+
+func TestExecute_EnvWorkspaceLocked(t *testing.T) {
+	t.Log("If another command is running in an env workspace, its plan should fail")
+	p, _, _ := setupPlanExecutorTest(t)
+	p.AtlantisWorkspaceLocker = events.NewDefaultAtlantisWorkspaceLocker()
+	p.AtlantisWorkspaceLocker.TryLock(planCtx.BaseRepo.Hostname, planCtx.BaseRepo.FullName, "staging", planCtx.Pull.Num)
+	When(p.VCSClient.GetModifiedFiles(matchers.AnyModelsRepo(), matchers.AnyModelsPullRequest(), matchers.AnyVcsHost())).
+		ThenReturn([]string{"env/staging.tfvars"}, nil)
+	When(p.Workspace.Clone(planCtx.Log, planCtx.BaseRepo, planCtx.HeadRepo, planCtx.Pull, "workspace")).
+		ThenReturn("/tmp/clone-repo", nil)
+
+	r := p.Execute(&planCtx)
+
+	Equals(t, 1, len(r.ProjectResults))
+	Equals(t, "The staging workspace is currently locked by another command that is running for this pull request. Wait until the previous command is complete and try again.", r.ProjectResults[0].Failure)
+}
+
+func TestExecute_AllWorkspaces(t *testing.T) {
+	t.Log("With --all-workspaces, plan should run in every workspace with an env file")
+	p, _, _ := setupPlanExecutorTest(t)
+	repoDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(repoDir) // nolint: errcheck
+	Ok(t, os.MkdirAll(filepath.Join(repoDir, "project", "env"), 0700))
+	for _, f := range []string{"dev.tfvars", "prod.tfvars", "README.md", "...tfvars"} {
+		Ok(t, ioutil.WriteFile(filepath.Join(repoDir, "project", "env", f), nil, 0600))
+	}
+	ctx := deepcopy.Copy(planCtx).(events.CommandContext)
+	ctx.Log = logging.NewNoopLogger()
+	ctx.Command.Dirs = []string{"project"}
+	ctx.Command.AllWorkspaces = true
+	When(p.Workspace.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, "workspace")).
+		ThenReturn(repoDir, nil)
+	When(p.Workspace.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, "dev")).
+		ThenReturn(repoDir, nil)
+	When(p.Workspace.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, "prod")).
+		ThenReturn(repoDir, nil)
+
+	r := p.Execute(&ctx)
+
+	Equals(t, 2, len(r.ProjectResults))
+	Equals(t, "dev", r.ProjectResults[0].Workspace)
+	Equals(t, "prod", r.ProjectResults[1].Workspace)
+}
+
+func TestExecute_SelectProjects(t *testing.T) {
+	t.Log("Projects selected with -d globs, -p and --all should be planned")
+	repoDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(repoDir) // nolint: errcheck
+	for _, f := range []string{
+		"envs/dev/main.tf",
+		"envs/prod/main.tf",
+		"modules/vpc/main.tf",
+		"app/main.tf",
+		"app/atlantis.yaml",
+	} {
+		Ok(t, os.MkdirAll(filepath.Join(repoDir, filepath.Dir(f)), 0700))
+		Ok(t, ioutil.WriteFile(filepath.Join(repoDir, f), nil, 0600))
+	}
+	Ok(t, ioutil.WriteFile(filepath.Join(repoDir, "envs/dev/main.tf"), []byte(`module "vpc" { source = "../../modules/vpc" }`), 0600))
+	Ok(t, ioutil.WriteFile(filepath.Join(repoDir, "app/atlantis.yaml"), []byte("name: app"), 0600))
+
+	cases := []struct {
+		description  string
+		dirs         []string
+		projectNames []string
+		all          bool
+		expPaths     []string
+		expFailure   string
+	}{
+		{
+			description: "glob",
+			dirs:        []string{"envs/*"},
+			expPaths:    []string{"envs/dev", "envs/prod"},
+		},
+		{
+			description: "repeated dirs",
+			dirs:        []string{"app", "envs/prod", "app"},
+			expPaths:    []string{"app", "envs/prod"},
+		},
+		{
+			description:  "project name",
+			projectNames: []string{"app"},
+			expPaths:     []string{"app"},
+		},
+		{
+			description: "all",
+			all:         true,
+			expPaths:    []string{"app", "envs/dev", "envs/prod"},
+		},
+		{
+			description: "glob that doesn't match",
+			dirs:        []string{"nope/*"},
+			expFailure:  `no projects match "nope/*"`,
+		},
+		{
+			description:  "unknown project name",
+			projectNames: []string{"nope"},
+			expFailure:   `no project is named "nope" in an atlantis.yaml file`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			p, _, _ := setupPlanExecutorTest(t)
+			ctx := deepcopy.Copy(planCtx).(events.CommandContext)
+			ctx.Log = logging.NewNoopLogger()
+			ctx.Command.Dirs = c.dirs
+			ctx.Command.ProjectNames = c.projectNames
+			ctx.Command.All = c.all
+			When(p.Workspace.Clone(ctx.Log, ctx.BaseRepo, ctx.HeadRepo, ctx.Pull, "workspace")).
+				ThenReturn(repoDir, nil)
+
+			r := p.Execute(&ctx)
+
+			Equals(t, c.expFailure, r.Failure)
+			var paths []string
+			for _, result := range r.ProjectResults {
+				paths = append(paths, result.Path)
+			}
+			Equals(t, c.expPaths, paths)
+		})
+	}
+}
+
 func setupPlanExecutorTest(t *testing.T) (*events.PlanExecutor, *tmocks.MockClient, *lmocks.MockLocker) {
 	RegisterMockTestingT(t)
 	vcsProxy := vcsmocks.NewMockClientProxy()
@@ -0,0 +1,226 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+// Package policy checks Terraform plans against policies configured for the
+// Atlantis server, ex. "no public S3 buckets".
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// validActions are the Terraform change actions rules can match.
+var validActions = []string{"create", "update", "delete", "read"}
+
+// Policy is a set of rules loaded from a policy file.
+type Policy struct {
+	// Name identifies the policy in comments. Defaults to the name of the
+	// policy file without its extension.
+	Name  string `yaml:"name"`
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule denies any resource change that matches it.
+type Rule struct {
+	// Description is shown to users when a change violates the rule.
+	Description string `yaml:"description"`
+	// ResourceTypes are the resource types the rule applies to, ex.
+	// aws_s3_bucket. Glob patterns are supported, ex. aws_db_*. If empty, the
+	// rule applies to all resource types.
+	ResourceTypes []string `yaml:"resource-types"`
+	// Actions are the change actions the rule applies to, any of create,
+	// update, delete and read. A replace is both a delete and a create. If
+	// empty, the rule applies to every change that isn't a no-op.
+	Actions []string `yaml:"actions"`
+	// Attributes limits the rule to changes where each top-level attribute
+	// of the resource is planned to be one of the listed values. Values are
+	// compared as strings. For deletes, the current values are used.
+	Attributes map[string][]string `yaml:"attributes"`
+}
+
+// Violation is a resource change that violates a rule.
+type Violation struct {
+	// Policy is the name of the policy the rule is in.
+	Policy string
+	// Address is the address of the resource, ex. aws_s3_bucket.logs.
+	Address string
+	// Description is the description of the rule.
+	Description string
+}
+
+// String returns a description of the violation for humans.
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s (policy %q)", v.Address, v.Description, v.Policy)
+}
+
+// Checker checks plans against policies.
+type Checker struct {
+	Policies []Policy
+}
+
+// NewChecker returns a checker for the policies in the .yaml and .yml files
+// in dir.
+func NewChecker(dir string) (*Checker, error) {
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no policy files found in %q", dir)
+	}
+	sort.Strings(files)
+
+	var policies []Policy
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(file) // nolint: gosec
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading policy file %q", file)
+		}
+		policy, err := ParsePolicy(contents)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing policy file %q", file)
+		}
+		if policy.Name == "" {
+			policy.Name = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		}
+		policies = append(policies, policy)
+	}
+	return &Checker{Policies: policies}, nil
+}
+
+// ParsePolicy parses and validates the YAML policy in contents.
+func ParsePolicy(contents []byte) (Policy, error) {
+	var policy Policy
+	if err := yaml.UnmarshalStrict(contents, &policy); err != nil {
+		return policy, err
+	}
+	if len(policy.Rules) == 0 {
+		return policy, errors.New("no rules defined")
+	}
+	for i, rule := range policy.Rules {
+		if rule.Description == "" {
+			return policy, fmt.Errorf("rule %d: description must be set", i+1)
+		}
+		for _, action := range rule.Actions {
+			if !inSlice(action, validActions) {
+				return policy, fmt.Errorf("rule %d: invalid action %q, must be one of %s", i+1, action, strings.Join(validActions, ", "))
+			}
+		}
+		for _, resourceType := range rule.ResourceTypes {
+			if _, err := filepath.Match(resourceType, ""); err != nil {
+				return policy, fmt.Errorf("rule %d: invalid resource type pattern %q", i+1, resourceType)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// plan is the subset of the output of "terraform show -json" that we check.
+type plan struct {
+	ResourceChanges []resourceChange `json:"resource_changes"`
+}
+
+type resourceChange struct {
+	Address string `json:"address"`
+	Type    string `json:"type"`
+	Change  struct {
+		Actions []string               `json:"actions"`
+		Before  map[string]interface{} `json:"before"`
+		After   map[string]interface{} `json:"after"`
+	} `json:"change"`
+}
+
+// Check returns the changes in planJSON, the output of
+// "terraform show -json <planfile>", that violate our policies.
+func (c *Checker) Check(planJSON []byte) ([]Violation, error) {
+	var p plan
+	if err := json.Unmarshal(planJSON, &p); err != nil {
+		return nil, errors.Wrap(err, "parsing plan json")
+	}
+	var violations []Violation
+	for _, change := range p.ResourceChanges {
+		for _, policy := range c.Policies {
+			for _, rule := range policy.Rules {
+				if rule.matches(change) {
+					violations = append(violations, Violation{
+						Policy:      policy.Name,
+						Address:     change.Address,
+						Description: rule.Description,
+					})
+				}
+			}
+		}
+	}
+	return violations, nil
+}
+
+func (r Rule) matches(change resourceChange) bool {
+	if len(r.ResourceTypes) > 0 {
+		typeMatches := false
+		for _, pattern := range r.ResourceTypes {
+			if ok, _ := filepath.Match(pattern, change.Type); ok {
+				typeMatches = true
+				break
+			}
+		}
+		if !typeMatches {
+			return false
+		}
+	}
+
+	actionMatches := false
+	for _, action := range change.Change.Actions {
+		if len(r.Actions) == 0 && action != "no-op" && action != "read" {
+			actionMatches = true
+		}
+		if inSlice(action, r.Actions) {
+			actionMatches = true
+		}
+	}
+	if !actionMatches {
+		return false
+	}
+
+	values := change.Change.After
+	if values == nil {
+		values = change.Change.Before
+	}
+	for attr, denied := range r.Attributes {
+		v, ok := values[attr]
+		if !ok || v == nil || !inSlice(fmt.Sprint(v), denied) {
+			return false
+		}
+	}
+	return true
+}
+
+func inSlice(s string, list []string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package policy_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/policy"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+var planJSON = []byte(`{
+  "resource_changes": [
+    {
+      "address": "aws_s3_bucket.public",
+      "type": "aws_s3_bucket",
+      "change": {"actions": ["create"], "before": null, "after": {"acl": "public-read"}}
+    },
+    {
+      "address": "aws_s3_bucket.private",
+      "type": "aws_s3_bucket",
+      "change": {"actions": ["update"], "before": {"acl": "private"}, "after": {"acl": "private"}}
+    },
+    {
+      "address": "aws_db_instance.main",
+      "type": "aws_db_instance",
+      "change": {"actions": ["delete", "create"], "before": {"engine": "postgres"}, "after": {"engine": "postgres"}}
+    },
+    {
+      "address": "aws_db_instance.unchanged",
+      "type": "aws_db_instance",
+      "change": {"actions": ["no-op"], "before": {"engine": "postgres"}, "after": {"engine": "postgres"}}
+    }
+  ]
+}`)
+
+func TestCheck(t *testing.T) {
+	noPublicS3, err := policy.ParsePolicy([]byte(`
+name: no-public-s3
+rules:
+- description: S3 buckets must not be public
+  resource-types: [aws_s3_bucket]
+  attributes:
+    acl: [public-read, public-read-write]
+`))
+	Ok(t, err)
+	noRDSDeletes, err := policy.ParsePolicy([]byte(`
+name: no-rds-deletes
+rules:
+- description: RDS instances must not be deleted
+  resource-types: [aws_db_*]
+  actions: [delete]
+`))
+	Ok(t, err)
+	c := policy.Checker{Policies: []policy.Policy{noPublicS3, noRDSDeletes}}
+
+	violations, err := c.Check(planJSON)
+	Ok(t, err)
+	Equals(t, []policy.Violation{
+		{Policy: "no-public-s3", Address: "aws_s3_bucket.public", Description: "S3 buckets must not be public"},
+		{Policy: "no-rds-deletes", Address: "aws_db_instance.main", Description: "RDS instances must not be deleted"},
+	}, violations)
+	Equals(t, `aws_s3_bucket.public: S3 buckets must not be public (policy "no-public-s3")`, violations[0].String())
+}
+
+func TestCheck_NoActionsIgnoresNoOps(t *testing.T) {
+	t.Log("rules without actions should match every change except no-ops")
+	p, err := policy.ParsePolicy([]byte(`
+rules:
+- description: no databases
+  resource-types: [aws_db_instance]
+`))
+	Ok(t, err)
+	c := policy.Checker{Policies: []policy.Policy{p}}
+	violations, err := c.Check(planJSON)
+	Ok(t, err)
+	Equals(t, 1, len(violations))
+	Equals(t, "aws_db_instance.main", violations[0].Address)
+}
+
+func TestCheck_InvalidJSON(t *testing.T) {
+	c := policy.Checker{}
+	_, err := c.Check([]byte("not json"))
+	Assert(t, err != nil, "exp error")
+}
+
+func TestParsePolicy_Errors(t *testing.T) {
+	cases := []struct {
+		yaml   string
+		expErr string
+	}{
+		{
+			"name: empty",
+			"no rules defined",
+		},
+		{
+			"rules:\n- resource-types: [aws_s3_bucket]",
+			"rule 1: description must be set",
+		},
+		{
+			"rules:\n- description: d\n  actions: [destroy]",
+			`rule 1: invalid action "destroy", must be one of create, update, delete, read`,
+		},
+		{
+			"rules:\n- description: d\n  resource-types: ['aws_[']",
+			`rule 1: invalid resource type pattern "aws_["`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.yaml, func(t *testing.T) {
+			_, err := policy.ParsePolicy([]byte(c.yaml))
+			ErrEquals(t, c.expErr, err)
+		})
+	}
+}
+
+func TestNewChecker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	t.Log("should error if there are no policy files")
+	_, err = policy.NewChecker(dir)
+	ErrEquals(t, `no policy files found in "`+dir+`"`, err)
+
+	t.Log("should default the policy name to the file name")
+	Ok(t, ioutil.WriteFile(filepath.Join(dir, "no-public-s3.yaml"), []byte("rules:\n- description: d\n"), 0600))
+	Ok(t, ioutil.WriteFile(filepath.Join(dir, "named.yml"), []byte("name: custom\nrules:\n- description: d\n"), 0600))
+	c, err := policy.NewChecker(dir)
+	Ok(t, err)
+	Equals(t, 2, len(c.Policies))
+	Equals(t, "custom", c.Policies[0].Name)
+	Equals(t, "no-public-s3", c.Policies[1].Name)
+}
@@ -0,0 +1,55 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/runatlantis/atlantis/server/events/policy"
+)
+
+// PolicyCheckResult is the result of checking a plan against the server's
+// policies.
+type PolicyCheckResult struct {
+	// Violations are the changes in the plan that violate a policy.
+	Violations []policy.Violation
+	// Error is set if we couldn't check the plan. The check counts as failed.
+	Error error
+}
+
+// Passed returns true if the plan can be applied without an override.
+func (p PolicyCheckResult) Passed() bool {
+	return p.Error == nil && len(p.Violations) == 0
+}
+
+// Summary describes why the check failed, one line per violation. It's empty
+// if the check passed.
+func (p PolicyCheckResult) Summary() string {
+	buf := &bytes.Buffer{}
+	if p.Error != nil {
+		fmt.Fprintf(buf, "error checking policies: %s\n", p.Error)
+	}
+	for _, v := range p.Violations {
+		fmt.Fprintf(buf, "%s\n", v)
+	}
+	return buf.String()
+}
+
+// policyFailuresFile returns the path to the file next to planFile that holds
+// the summary of its failed policy check. The file only exists if the check
+// failed. ApplyExecutor uses it to refuse to apply the plan.
+func policyFailuresFile(planFile string) string {
+	return planFile + ".policy-failures"
+}
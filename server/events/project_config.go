@@ -14,9 +14,12 @@
 package events
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/go-version"
 	"github.com/pkg/errors"
@@ -42,10 +45,15 @@ type ProjectConfigReader interface {
 // Hook represents the commands that can be run at a certain stage.
 type Hook struct {
 	Commands []string `yaml:"commands"`
+	// Show is when the output of the commands is shown in the pull request
+	// comment. It's one of always, on_failure or never and defaults to
+	// always.
+	Show string `yaml:"show"`
 }
 
 // projectConfigYAML is used to parse the YAML.
 type projectConfigYAML struct {
+	Name             string                  `yaml:"name"`
 	PreInit          Hook                    `yaml:"pre_init"`
 	PreGet           Hook                    `yaml:"pre_get"`
 	PrePlan          Hook                    `yaml:"pre_plan"`
@@ -54,11 +62,20 @@ type projectConfigYAML struct {
 	PostApply        Hook                    `yaml:"post_apply"`
 	TerraformVersion string                  `yaml:"terraform_version"`
 	ExtraArguments   []commandExtraArguments `yaml:"extra_arguments"`
+	DestroyThreshold int                     `yaml:"destroy_threshold"`
+	Workflow         string                  `yaml:"workflow"`
+	Workflows        map[string]Workflow     `yaml:"workflows"`
+	DependsOn        []string                `yaml:"depends_on"`
 }
 
 // ProjectConfig is a more usable version of projectConfigYAML that we can
 // return to our callers. It holds the config for a project.
 type ProjectConfig struct {
+	// Name is the name used to select the project with -p.
+	Name string
+	// DependsOn are the dirs of the projects, relative to the repo root,
+	// that must be applied before this project.
+	DependsOn []string
 	// PreInit is a slice of command strings to run prior to terraform init.
 	PreInit []string
 	// PreGet is a slice of command strings to run prior to terraform get.
@@ -74,6 +91,24 @@ type ProjectConfig struct {
 	// TerraformVersion is the version specified in the config file or nil
 	// if version wasn't specified.
 	TerraformVersion *version.Version
+	// DestroyThreshold is how many resources a plan can destroy (including
+	// replacements) before applying it requires apply --allow-destroy. It
+	// defaults to 0 so that any destroy requires confirmation. If negative,
+	// confirmation is never required.
+	DestroyThreshold int
+	// WorkflowName is the name of the workflow the project uses or empty if
+	// it didn't specify one.
+	WorkflowName string
+	// Workflows are the workflows defined in the config file, by name.
+	Workflows map[string]Workflow
+	// HookOutput is when the output of each hook is shown in the pull
+	// request comment, by stage, ex. pre_plan. Hooks that aren't set use
+	// ShowHookOutputAlways.
+	HookOutput map[string]string
+	// Workflow is the workflow whose steps are run instead of the default
+	// init, plan and apply. It's resolved when the project is pre-executed
+	// and is nil if the project doesn't use a workflow.
+	Workflow *Workflow
 	// extraArguments is the extra args that we should tack on to certain
 	// terraform commands. It shouldn't be used directly and instead callers
 	// should use the GetExtraArguments method on ProjectConfig.
@@ -115,6 +150,34 @@ func (c *ProjectConfigManager) Read(execPath string) (ProjectConfig, error) {
 		return pc, errors.Wrapf(err, "parsing %s", ProjectConfigFile)
 	}
 
+	hookOutput := make(map[string]string)
+	for stage, hook := range map[string]Hook{
+		"pre_init":   pcYaml.PreInit,
+		"pre_get":    pcYaml.PreGet,
+		"pre_plan":   pcYaml.PrePlan,
+		"post_plan":  pcYaml.PostPlan,
+		"pre_apply":  pcYaml.PreApply,
+		"post_apply": pcYaml.PostApply,
+	} {
+		switch hook.Show {
+		case "":
+		case ShowHookOutputAlways, ShowHookOutputOnFailure, ShowHookOutputNever:
+			hookOutput[stage] = hook.Show
+		default:
+			return pc, fmt.Errorf("parsing %s: %s.show must be one of %s, %s or %s but was %q",
+				ProjectConfigFile, stage, ShowHookOutputAlways, ShowHookOutputOnFailure, ShowHookOutputNever, hook.Show)
+		}
+	}
+
+	var dependsOn []string
+	for _, dep := range pcYaml.DependsOn {
+		dep = path.Clean(dep)
+		if path.IsAbs(dep) || dep == ".." || strings.HasPrefix(dep, "../") {
+			return pc, fmt.Errorf("parsing %s: depends_on must be relative to the repo root but was %q", ProjectConfigFile, dep)
+		}
+		dependsOn = append(dependsOn, dep)
+	}
+
 	var v *version.Version
 	if pcYaml.TerraformVersion != "" {
 		v, err = version.NewVersion(pcYaml.TerraformVersion)
@@ -123,7 +186,13 @@ func (c *ProjectConfigManager) Read(execPath string) (ProjectConfig, error) {
 		}
 	}
 	return ProjectConfig{
+		Name:             pcYaml.Name,
+		DependsOn:        dependsOn,
 		TerraformVersion: v,
+		DestroyThreshold: pcYaml.DestroyThreshold,
+		HookOutput:       hookOutput,
+		WorkflowName:     pcYaml.Workflow,
+		Workflows:        pcYaml.Workflows,
 		extraArguments:   pcYaml.ExtraArguments,
 		PreInit:          pcYaml.PreInit.Commands,
 		PreGet:           pcYaml.PreGet.Commands,
@@ -144,3 +213,16 @@ func (c *ProjectConfig) GetExtraArguments(command string) []string {
 	}
 	return nil
 }
+
+// showHookOutput returns true if the output of the hook for stage should be
+// shown in the pull request comment given whether it failed.
+func (c *ProjectConfig) showHookOutput(stage string, failed bool) bool {
+	switch c.HookOutput[stage] {
+	case ShowHookOutputNever:
+		return false
+	case ShowHookOutputOnFailure:
+		return failed
+	default:
+		return true
+	}
+}
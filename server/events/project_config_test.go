@@ -26,6 +26,7 @@ var tempConfigFile = "/tmp/" + events.ProjectConfigFile
 var projectConfigFileStr = `
 ---
 terraform_version: "0.0.1"
+destroy_threshold: 5
 pre_init:
   commands:
   - "echo"
@@ -38,6 +39,7 @@ pre_plan:
   commands:
   - "echo"
   - "pre_plan"
+  show: on_failure
 post_plan:
   commands:
   - "echo"
@@ -50,6 +52,7 @@ post_apply:
   commands:
   - "echo"
   - "post_apply"
+  show: never
 extra_arguments:
 - command_name: "init"
   arguments: ["arg", "init"]
@@ -59,6 +62,14 @@ extra_arguments:
   arguments: ["arg", "plan"]
 - command_name: "apply"
   arguments: ["arg", "apply"]
+workflow: "custom"
+workflows:
+  custom:
+    plan:
+      steps:
+      - init
+      - run: "tflint"
+      - plan: {extra_args: ["-lock=false"]}
 `
 
 var c events.ProjectConfigManager
@@ -96,11 +107,46 @@ func TestRead_ValidConfig(t *testing.T) {
 	Equals(t, []string{"echo", "post_plan"}, config.PostPlan)
 	Equals(t, []string{"echo", "pre_apply"}, config.PreApply)
 	Equals(t, []string{"echo", "post_apply"}, config.PostApply)
+	Equals(t, 5, config.DestroyThreshold)
 	Equals(t, []string{"arg", "init"}, config.GetExtraArguments("init"))
 	Equals(t, []string{"arg", "get"}, config.GetExtraArguments("get"))
 	Equals(t, []string{"arg", "plan"}, config.GetExtraArguments("plan"))
 	Equals(t, []string{"arg", "apply"}, config.GetExtraArguments("apply"))
 	Equals(t, 0, len(config.GetExtraArguments("not-specified")))
+	Equals(t, map[string]string{"pre_plan": "on_failure", "post_apply": "never"}, config.HookOutput)
+	Equals(t, "custom", config.WorkflowName)
+	Equals(t, map[string]events.Workflow{
+		"custom": {
+			Plan: &events.Stage{
+				Steps: []events.Step{
+					{Name: "init"},
+					{Name: "run", RunCommand: "tflint"},
+					{Name: "plan", ExtraArgs: []string{"-lock=false"}},
+				},
+			},
+		},
+	}, config.Workflows)
+}
+
+func TestRead_InvalidHookShow(t *testing.T) {
+	t.Log("when a hook's show setting isn't valid, we expect an error")
+	writeAtlantisConfigFile(t, []byte("pre_plan:\n  commands: [echo]\n  show: sometimes\n"))
+	defer os.Remove(tempConfigFile) // nolint: errcheck
+	_, err := c.Read("/tmp")
+	ErrEquals(t, `parsing atlantis.yaml: pre_plan.show must be one of always, on_failure or never but was "sometimes"`, err)
+}
+
+func TestRead_DependsOn(t *testing.T) {
+	t.Log("depends_on should be cleaned and must be relative to the repo root")
+	writeAtlantisConfigFile(t, []byte("depends_on: [network/, ./shared/db]\n"))
+	defer os.Remove(tempConfigFile) // nolint: errcheck
+	config, err := c.Read("/tmp")
+	Ok(t, err)
+	Equals(t, []string{"network", "shared/db"}, config.DependsOn)
+
+	writeAtlantisConfigFile(t, []byte("depends_on: [../network]\n"))
+	_, err = c.Read("/tmp")
+	ErrEquals(t, `parsing atlantis.yaml: depends_on must be relative to the repo root but was "../network"`, err)
 }
 
 func writeAtlantisConfigFile(t *testing.T, s []byte) {
@@ -29,7 +29,7 @@ import (
 type ProjectFinder interface {
 	// DetermineProjects returns the list of projects that were modified based on
 	// the modifiedFiles. The list will be de-duplicated.
-	DetermineProjects(log *logging.SimpleLogger, modifiedFiles []string, repoFullName string, repoDir string) []models.Project
+	DetermineProjects(log *logging.SimpleLogger, modifiedFiles []string, repo models.Repo, repoDir string) []models.Project
 }
 
 // DefaultProjectFinder implements ProjectFinder.
@@ -39,26 +39,54 @@ var excludeList = []string{"terraform.tfstate", "terraform.tfstate.backup"}
 
 // DetermineProjects returns the list of projects that were modified based on
 // the modifiedFiles. The list will be de-duplicated.
-func (p *DefaultProjectFinder) DetermineProjects(log *logging.SimpleLogger, modifiedFiles []string, repoFullName string, repoDir string) []models.Project {
+func (p *DefaultProjectFinder) DetermineProjects(log *logging.SimpleLogger, modifiedFiles []string, repo models.Repo, repoDir string) []models.Project {
 	var projects []models.Project
-
-	modifiedTerraformFiles := p.filterToTerraform(modifiedFiles)
-	if len(modifiedTerraformFiles) == 0 {
+	if len(modifiedFiles) == 0 {
 		return projects
 	}
-	log.Info("filtered modified files to %d .tf files: %v",
-		len(modifiedTerraformFiles), modifiedTerraformFiles)
 
-	var paths []string
+	var terragruntConfigs map[string]terragruntConfig
+	var modules moduleGraph
+	if repoDir != "" {
+		var err error
+		terragruntConfigs, err = findTerragruntConfigs(repoDir)
+		if err != nil {
+			log.Warn("finding terragrunt projects: %s", err)
+		}
+		modules, err = buildModuleGraph(log, repoDir)
+		if err != nil {
+			log.Warn("finding module dependencies: %s", err)
+		}
+	}
+	paths := affectedTerragruntProjects(terragruntConfigs, modifiedFiles)
+	if len(paths) > 0 {
+		log.Info("found %d modified terragrunt project(s): %v", len(paths), paths)
+	}
+
+	modifiedTerraformFiles := p.filterToTerraform(modifiedFiles)
+	if len(modifiedTerraformFiles) > 0 {
+		log.Info("filtered modified files to %d .tf files: %v",
+			len(modifiedTerraformFiles), modifiedTerraformFiles)
+	}
 	for _, modifiedFile := range modifiedTerraformFiles {
 		projectPath := p.getProjectPath(modifiedFile, repoDir)
-		if projectPath != "" {
+		// Modules are planned through the projects that use them.
+		if projectPath != "" && !modules.isModule(projectPath) && !p.isTerragruntSource(projectPath, terragruntConfigs) {
 			paths = append(paths, projectPath)
 		}
 	}
+	for _, modifiedFile := range modifiedFiles {
+		if users := modules.projectsUsing(modifiedFile); len(users) > 0 {
+			log.Info("found %d project(s) using the module modified by %q: %v", len(users), modifiedFile, users)
+			paths = append(paths, users...)
+		}
+	}
+	if len(paths) == 0 {
+		return projects
+	}
 	uniquePaths := p.unique(paths)
 	for _, uniquePath := range uniquePaths {
-		projects = append(projects, models.NewProject(repoFullName, uniquePath))
+		projects = append(projects, models.NewProject(repo.Hostname, repo.FullName, uniquePath))
 	}
 	log.Info("there are %d modified project(s) at path(s): %v",
 		len(projects), strings.Join(uniquePaths, ", "))
@@ -148,6 +176,18 @@ func (p *DefaultProjectFinder) getProjectPath(modifiedFilePath string, repoDir s
 	return dir
 }
 
+// isTerragruntSource returns true if dir is the terraform source of one of
+// the terragrunt configs, in which case it's planned through them rather than
+// on its own.
+func (p *DefaultProjectFinder) isTerragruntSource(dir string, configs map[string]terragruntConfig) bool {
+	for _, config := range configs {
+		if config.Source != "" && (dir == config.Source || isInDir(dir, config.Source)) {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *DefaultProjectFinder) unique(strs []string) []string {
 	hash := make(map[string]bool)
 	var unique []string
@@ -20,12 +20,13 @@ import (
 	"testing"
 
 	"github.com/runatlantis/atlantis/server/events"
+	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/logging"
 	. "github.com/runatlantis/atlantis/testing"
 )
 
 var noopLogger = logging.NewNoopLogger()
-var modifiedRepo = "owner/repo"
+var modifiedRepo = models.Repo{FullName: "owner/repo", Hostname: "github.com"}
 var m = events.DefaultProjectFinder{}
 var nestedModules1 string
 var nestedModules2 string
@@ -171,7 +172,8 @@ func TestDetermineProjects(t *testing.T) {
 		for _, project := range projects {
 			paths = append(paths, project.Path)
 			// Check that the project object has the repo set properly.
-			Equals(t, modifiedRepo, project.RepoFullName)
+			Equals(t, modifiedRepo.FullName, project.RepoFullName)
+			Equals(t, modifiedRepo.Hostname, project.Hostname)
 		}
 		Assert(t, len(c.expProjectPaths) == len(paths),
 			"exp %d paths but found %d. They were %v", len(c.expProjectPaths), len(paths), paths)
@@ -190,3 +192,167 @@ func TestDetermineProjects(t *testing.T) {
 		}
 	}
 }
+
+func TestDetermineProjects_Terragrunt(t *testing.T) {
+	// terragrunt.hcl
+	// infra/
+	//   vpc/main.tf
+	// live/
+	//   vpc/terragrunt.hcl # includes the root config, source is infra//vpc
+	//   app/terragrunt.hcl # includes the root config, depends on vpc
+	//   db/terragrunt.hcl  # includes the root config, depends on vpc
+	//   tools/terragrunt.hcl # standalone with a remote source
+	repoDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(repoDir) // nolint: errcheck
+	files := map[string]string{
+		"terragrunt.hcl":    `remote_state { backend = "s3" }`,
+		"infra/vpc/main.tf": "",
+		"live/vpc/terragrunt.hcl": `include {
+  path = find_in_parent_folders()
+}
+terraform {
+  source = "../../infra//vpc"
+}`,
+		"live/app/terragrunt.hcl": `include {
+  path = "${find_in_parent_folders()}"
+}
+# dependency "ignored" { config_path = "../tools" }
+dependency "vpc" {
+  config_path = "../vpc"
+}`,
+		"live/db/terragrunt.hcl": `include "root" {
+  path = find_in_parent_folders("terragrunt.hcl")
+}
+dependencies {
+  paths = ["../vpc"]
+}`,
+		"live/tools/terragrunt.hcl": `terraform {
+  source = "git::https://github.com/owner/modules.git//tools?ref=v1.0.0"
+}`,
+	}
+	for name, contents := range files {
+		Ok(t, os.MkdirAll(filepath.Join(repoDir, filepath.Dir(name)), 0700))
+		Ok(t, ioutil.WriteFile(filepath.Join(repoDir, name), []byte(contents), 0600))
+	}
+
+	cases := []struct {
+		description     string
+		files           []string
+		expProjectPaths []string
+	}{
+		{
+			"A change to a project's config should plan it",
+			[]string{"live/app/terragrunt.hcl"},
+			[]string{"live/app"},
+		},
+		{
+			"A change to a non-.tf file in a project should plan it",
+			[]string{"live/tools/inputs.yaml"},
+			[]string{"live/tools"},
+		},
+		{
+			"A change to an included config should plan the projects that include it",
+			[]string{"terragrunt.hcl"},
+			[]string{"live/app", "live/db", "live/vpc"},
+		},
+		{
+			"A change to a project's source should plan it and its dependents but not the source",
+			[]string{"infra/vpc/main.tf"},
+			[]string{"live/app", "live/db", "live/vpc"},
+		},
+		{
+			"Terraform projects should still be found",
+			[]string{"other/main.tf", "live/db/terragrunt.hcl"},
+			[]string{"live/db", "other"},
+		},
+	}
+	for _, c := range cases {
+		t.Log(c.description)
+		projects := m.DetermineProjects(noopLogger, c.files, modifiedRepo, repoDir)
+		var paths []string
+		for _, project := range projects {
+			paths = append(paths, project.Path)
+		}
+		Equals(t, c.expProjectPaths, paths)
+	}
+}
+
+func TestDetermineProjects_ModuleDependencies(t *testing.T) {
+	// modules/
+	//   vpc/main.tf
+	//   app/main.tf      # uses ../vpc
+	// shared/
+	//   db/main.tf
+	//   cache/main.tf
+	// project1/main.tf   # uses ../modules/app
+	// project2/main.tf   # uses ../modules//vpc
+	// project3/main.tf   # uses ../shared/db and a remote module
+	// project4/main.tf   # uses ../shared//db
+	repoDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(repoDir) // nolint: errcheck
+	files := map[string]string{
+		"modules/vpc/main.tf": "",
+		"modules/app/main.tf": `module "vpc" {
+  source = "../vpc"
+}`,
+		"shared/db/main.tf":    "",
+		"shared/cache/main.tf": "",
+		"project1/main.tf": `module "app" {
+  source = "../modules/app"
+}`,
+		"project2/main.tf": `module "vpc" {
+  source = "../modules//vpc"
+}`,
+		"project3/main.tf": `module "db" {
+  source = "../shared/db"
+}
+module "remote" {
+  source = "git::https://github.com/owner/modules.git//db"
+}`,
+		"project4/main.tf": `module "db" {
+  source = "../shared//db"
+}`,
+	}
+	for name, contents := range files {
+		Ok(t, os.MkdirAll(filepath.Join(repoDir, filepath.Dir(name)), 0700))
+		Ok(t, ioutil.WriteFile(filepath.Join(repoDir, name), []byte(contents), 0600))
+	}
+
+	cases := []struct {
+		description     string
+		files           []string
+		expProjectPaths []string
+	}{
+		{
+			"A change to a module should plan the projects that use it transitively",
+			[]string{"modules/vpc/main.tf"},
+			[]string{"project1", "project2"},
+		},
+		{
+			"A change to a module outside a modules dir should plan its users but not the module",
+			[]string{"shared/db/variables.tf"},
+			[]string{"project3", "project4"},
+		},
+		{
+			"A // in a local source shouldn't make its users depend on the dir before it",
+			[]string{"shared/cache/main.tf"},
+			[]string{"shared/cache"},
+		},
+		{
+			"A change to a project should still plan it",
+			[]string{"project1/main.tf", "modules/app/main.tf"},
+			[]string{"project1"},
+		},
+	}
+	for _, c := range cases {
+		t.Log(c.description)
+		projects := m.DetermineProjects(noopLogger, c.files, modifiedRepo, repoDir)
+		var paths []string
+		for _, project := range projects {
+			paths = append(paths, project.Path)
+		}
+		Equals(t, c.expProjectPaths, paths)
+	}
+}
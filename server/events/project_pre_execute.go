@@ -15,8 +15,10 @@ package events
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-version"
 	"github.com/pkg/errors"
@@ -24,6 +26,8 @@ import (
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/run"
 	"github.com/runatlantis/atlantis/server/events/terraform"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/metrics"
 )
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_project_pre_executor.go ProjectPreExecutor
@@ -41,6 +45,16 @@ type DefaultProjectPreExecutor struct {
 	ConfigReader ProjectConfigReader
 	Terraform    terraform.Client
 	Run          run.Runner
+	// Workflows are the server-side workflows, by name. Projects can use
+	// them by name and the one named "default" is used by projects that
+	// don't name a workflow.
+	Workflows map[string]Workflow
+	// Metrics records how long terraform init takes. If nil, it isn't
+	// recorded.
+	Metrics *metrics.Metrics
+	// Webhooks are sent when a lock is acquired and when it's released
+	// because pre-execution failed. If nil, they aren't sent.
+	Webhooks webhooks.LockSender
 }
 
 // PreExecuteResult is the result of running the pre execute.
@@ -49,6 +63,9 @@ type PreExecuteResult struct {
 	ProjectConfig    ProjectConfig
 	TerraformVersion *version.Version
 	LockResponse     locking.TryLockResponse
+	// HookOutputs are the outputs of the hooks that ran to show in the
+	// comment.
+	HookOutputs []HookOutput
 }
 
 // Execute executes the pre plan/apply tasks.
@@ -64,20 +81,25 @@ func (p *DefaultProjectPreExecutor) Execute(ctx *CommandContext, repoDir string,
 			lockAttempt.CurrLock.Pull.Num)}}
 	}
 	ctx.Log.Info("acquired lock with id %q", lockAttempt.LockKey)
-	config, tfVersion, err := p.executeWithLock(ctx, repoDir, project)
+	if lockAttempt.LockAcquired && p.Webhooks != nil {
+		p.Webhooks.SendLock(ctx.Log, webhooks.LockResult{Lock: lockAttempt.CurrLock}) // nolint: errcheck
+	}
+	config, tfVersion, hookOutputs, err := p.executeWithLock(ctx, repoDir, project)
 	if err != nil {
-		p.Locker.Unlock(lockAttempt.LockKey) // nolint: errcheck
-		return PreExecuteResult{ProjectResult: ProjectResult{Error: err}}
+		lock, _ := p.Locker.Unlock(lockAttempt.LockKey)
+		if lock != nil && p.Webhooks != nil {
+			p.Webhooks.SendUnlock(ctx.Log, webhooks.LockResult{Lock: *lock, Reason: webhooks.UnlockErrorReason}) // nolint: errcheck
+		}
+		return PreExecuteResult{ProjectResult: ProjectResult{Error: err, HookOutputs: hookOutputs}}
 	}
-	return PreExecuteResult{ProjectConfig: config, TerraformVersion: tfVersion, LockResponse: lockAttempt}
+	return PreExecuteResult{ProjectConfig: config, TerraformVersion: tfVersion, LockResponse: lockAttempt, HookOutputs: hookOutputs}
 }
 
 // executeWithLock executes the pre plan/apply tasks after the lock has been
 // acquired. This helper func makes revoking the lock on error easier.
-// Returns the project config, terraform version, or an error.
-func (p *DefaultProjectPreExecutor) executeWithLock(ctx *CommandContext, repoDir string, project models.Project) (ProjectConfig, *version.Version, error) {
-	workspace := ctx.Command.Workspace
-
+// Returns the project config, terraform version and the outputs of the hooks
+// that ran, or an error.
+func (p *DefaultProjectPreExecutor) executeWithLock(ctx *CommandContext, repoDir string, project models.Project) (ProjectConfig, *version.Version, []HookOutput, error) {
 	// Check if config file is found, if not we continue the run.
 	var config ProjectConfig
 	absolutePath := filepath.Join(repoDir, project.Path)
@@ -85,56 +107,132 @@ func (p *DefaultProjectPreExecutor) executeWithLock(ctx *CommandContext, repoDir
 		var err error
 		config, err = p.ConfigReader.Read(absolutePath)
 		if err != nil {
-			return config, nil, err
+			return config, nil, nil, err
 		}
 		ctx.Log.Info("parsed atlantis config file in %q", absolutePath)
 	}
+	workflow, err := resolveWorkflow(config, p.Workflows)
+	if err != nil {
+		return config, nil, nil, err
+	}
+	config.Workflow = workflow
+	// The values of sensitive variables are only masked in this command's
+	// output since they come from the pull request.
+	if ctx.Redactor != nil {
+		for _, value := range sensitiveVariableValues(ctx.Log, absolutePath, variableArgs(ctx, config, absolutePath), os.Environ()) {
+			ctx.Redactor.AddSecret(value)
+		}
+	}
 
 	// Check if terraform version is >= 0.9.0.
 	terraformVersion := p.Terraform.Version()
 	if config.TerraformVersion != nil {
 		terraformVersion = config.TerraformVersion
 	}
+	var hookOutputs []HookOutput
+	if !config.Workflow.runsInit(ctx.Command.Name) {
+		hookOutputs, err = p.init(ctx, repoDir, project, config, terraformVersion)
+		if err != nil {
+			return config, nil, hookOutputs, err
+		}
+	}
+
+	stage := fmt.Sprintf("pre_%s", strings.ToLower(ctx.Command.Name.String()))
+	var commands []string
+	if ctx.Command.Name == Plan {
+		commands = config.PrePlan
+	} else {
+		commands = config.PreApply
+	}
+	if len(commands) > 0 {
+		hookOutputs, err = runHook(p.Run, ctx, config, commands, runEnv(ctx, repoDir, project.Path, terraformVersion), stage, hookOutputs)
+		if err != nil {
+			return config, nil, hookOutputs, errors.Wrapf(err, "running %s commands", stage)
+		}
+	}
+	return config, terraformVersion, hookOutputs, nil
+}
+
+// init runs terraform init, or get for versions < 0.9, and the hooks that
+// run before them. It returns the outputs of the hooks to show.
+func (p *DefaultProjectPreExecutor) init(ctx *CommandContext, repoDir string, project models.Project, config ProjectConfig, terraformVersion *version.Version) ([]HookOutput, error) {
+	workspace := ctx.Command.Workspace
+	absolutePath := filepath.Join(repoDir, project.Path)
+	env := runEnv(ctx, repoDir, project.Path, terraformVersion)
+	var hookOutputs []HookOutput
+	var err error
 	constraints, _ := version.NewConstraint(">= 0.9.0")
 	if constraints.Check(terraformVersion) {
 		ctx.Log.Info("determined that we are running terraform with version >= 0.9.0. Running version %s", terraformVersion)
 		if len(config.PreInit) > 0 {
-			_, err := p.Run.Execute(ctx.Log, config.PreInit, absolutePath, workspace, terraformVersion, "pre_init")
+			hookOutputs, err = runHook(p.Run, ctx, config, config.PreInit, env, "pre_init", hookOutputs)
 			if err != nil {
-				return config, nil, errors.Wrapf(err, "running %s commands", "pre_init")
+				return hookOutputs, errors.Wrapf(err, "running %s commands", "pre_init")
 			}
 		}
-		_, err := p.Terraform.Init(ctx.Log, absolutePath, workspace, config.GetExtraArguments("init"), terraformVersion)
+		start := time.Now()
+		_, err = p.Terraform.Init(ctx.Log, absolutePath, workspace, config.GetExtraArguments("init"), terraformVersion)
+		p.Metrics.TerraformRan("init", ctx.BaseRepo.FullName, time.Since(start))
 		if err != nil {
-			return config, nil, err
+			return hookOutputs, err
 		}
 	} else {
 		ctx.Log.Info("determined that we are running terraform with version < 0.9.0. Running version %s", terraformVersion)
 		if len(config.PreGet) > 0 {
-			_, err := p.Run.Execute(ctx.Log, config.PreGet, absolutePath, workspace, terraformVersion, "pre_get")
+			hookOutputs, err = runHook(p.Run, ctx, config, config.PreGet, env, "pre_get", hookOutputs)
 			if err != nil {
-				return config, nil, errors.Wrapf(err, "running %s commands", "pre_get")
+				return hookOutputs, errors.Wrapf(err, "running %s commands", "pre_get")
 			}
 		}
 		terraformGetCmd := append([]string{"get", "-no-color"}, config.GetExtraArguments("get")...)
-		_, err := p.Terraform.RunCommandWithVersion(ctx.Log, absolutePath, terraformGetCmd, terraformVersion, workspace)
+		start := time.Now()
+		_, err = p.Terraform.RunCommandWithVersion(ctx.Log, absolutePath, terraformGetCmd, terraformVersion, workspace)
+		p.Metrics.TerraformRan("init", ctx.BaseRepo.FullName, time.Since(start))
 		if err != nil {
-			return config, nil, err
+			return hookOutputs, err
 		}
 	}
+	return hookOutputs, nil
+}
 
-	stage := fmt.Sprintf("pre_%s", strings.ToLower(ctx.Command.Name.String()))
-	var commands []string
-	if ctx.Command.Name == Plan {
-		commands = config.PrePlan
-	} else {
-		commands = config.PreApply
+// runEnv returns the environment that commands for the project at projectPath
+// in repoDir are run in.
+func runEnv(ctx *CommandContext, repoDir string, projectPath string, terraformVersion *version.Version) run.Env {
+	workspace := ctx.Command.Workspace
+	dir := filepath.Join(repoDir, projectPath)
+	return run.Env{
+		Dir:              dir,
+		Workspace:        workspace,
+		TerraformVersion: terraformVersion,
+		PullNum:          ctx.Pull.Num,
+		HeadCommit:       ctx.Pull.HeadCommit,
+		BaseRepo:         ctx.BaseRepo.FullName,
+		HeadBranch:       ctx.Pull.Branch,
+		UserName:         ctx.User.Username,
+		ProjectPath:      projectPath,
+		PlanFile:         filepath.Join(dir, workspace+".tfplan"),
 	}
-	if len(commands) > 0 {
-		_, err := p.Run.Execute(ctx.Log, commands, absolutePath, workspace, terraformVersion, stage)
-		if err != nil {
-			return config, nil, errors.Wrapf(err, "running %s commands", stage)
+}
+
+// variableArgs returns the args that plan and apply are run with in
+// projectDir, which can set variables. They're built the same way as the
+// commands' own.
+func variableArgs(ctx *CommandContext, config ProjectConfig, projectDir string) []string {
+	if config.Workflow == nil {
+		args := planArgs(ctx, projectDir, config.GetExtraArguments(Plan.String()))
+		return append(append(args, config.GetExtraArguments(Apply.String())...), ctx.Command.Flags...)
+	}
+	var args []string
+	for _, step := range config.Workflow.PlanSteps() {
+		if step.Name == PlanStepName {
+			extraArgs := append(append([]string{}, config.GetExtraArguments(PlanStepName)...), step.ExtraArgs...)
+			args = append(args, planArgs(ctx, projectDir, extraArgs)...)
+		}
+	}
+	for _, step := range config.Workflow.ApplySteps() {
+		if step.Name == ApplyStepName {
+			args = append(append(append(args, config.GetExtraArguments(ApplyStepName)...), step.ExtraArgs...), ctx.Command.Flags...)
 		}
 	}
-	return config, terraformVersion, nil
+	return args
 }
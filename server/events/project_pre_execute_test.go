@@ -15,6 +15,9 @@ package events_test
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/go-version"
@@ -25,9 +28,12 @@ import (
 	lmocks "github.com/runatlantis/atlantis/server/events/locking/mocks"
 	"github.com/runatlantis/atlantis/server/events/mocks"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/run"
 	rmocks "github.com/runatlantis/atlantis/server/events/run/mocks"
 	tmocks "github.com/runatlantis/atlantis/server/events/terraform/mocks"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/redact"
 	. "github.com/runatlantis/atlantis/testing"
 )
 
@@ -87,7 +93,7 @@ func TestExecute_PreInitErr(t *testing.T) {
 	}, nil)
 	tfVersion, _ := version.NewVersion("0.9.0")
 	When(tm.Version()).ThenReturn(tfVersion)
-	When(r.Execute(ctx.Log, []string{"pre-init"}, "", "", tfVersion, "pre_init")).ThenReturn("", errors.New("err"))
+	When(r.Execute(ctx.Log, []string{"pre-init"}, preExecuteEnv(tfVersion), "pre_init")).ThenReturn("", errors.New("err"))
 
 	res := p.Execute(&ctx, "", project)
 	Equals(t, "running pre_init commands: err", res.ProjectResult.Error.Error())
@@ -121,7 +127,7 @@ func TestExecute_PreGetErr(t *testing.T) {
 	}, nil)
 	tfVersion, _ := version.NewVersion("0.8")
 	When(tm.Version()).ThenReturn(tfVersion)
-	When(r.Execute(ctx.Log, []string{"pre-get"}, "", "", tfVersion, "pre_get")).ThenReturn("", errors.New("err"))
+	When(r.Execute(ctx.Log, []string{"pre-get"}, preExecuteEnv(tfVersion), "pre_get")).ThenReturn("", errors.New("err"))
 
 	res := p.Execute(&ctx, "", project)
 	Equals(t, "running pre_get commands: err", res.ProjectResult.Error.Error())
@@ -156,7 +162,7 @@ func TestExecute_PreCommandErr(t *testing.T) {
 	tfVersion, _ := version.NewVersion("0.9")
 	When(tm.Version()).ThenReturn(tfVersion)
 	When(tm.Init(ctx.Log, "", "", nil, tfVersion)).ThenReturn(nil, nil)
-	When(r.Execute(ctx.Log, []string{"command"}, "", "", tfVersion, "pre_plan")).ThenReturn("", errors.New("err"))
+	When(r.Execute(ctx.Log, []string{"command"}, preExecuteEnv(tfVersion), "pre_plan")).ThenReturn("", errors.New("err"))
 
 	res := p.Execute(&ctx, "", project)
 	Equals(t, "running pre_plan commands: err", res.ProjectResult.Error.Error())
@@ -185,7 +191,7 @@ func TestExecute_SuccessTF9(t *testing.T) {
 		LockResponse:     lockResponse,
 	}, res)
 	tm.VerifyWasCalledOnce().Init(ctx.Log, "", "", nil, tfVersion)
-	r.VerifyWasCalledOnce().Execute(ctx.Log, []string{"pre-init"}, "", "", tfVersion, "pre_init")
+	r.VerifyWasCalledOnce().Execute(ctx.Log, []string{"pre-init"}, preExecuteEnv(tfVersion), "pre_init")
 }
 
 func TestExecute_SuccessTF8(t *testing.T) {
@@ -210,7 +216,7 @@ func TestExecute_SuccessTF8(t *testing.T) {
 		LockResponse:     lockResponse,
 	}, res)
 	tm.VerifyWasCalledOnce().RunCommandWithVersion(ctx.Log, "", []string{"get", "-no-color"}, tfVersion, "")
-	r.VerifyWasCalledOnce().Execute(ctx.Log, []string{"pre-get"}, "", "", tfVersion, "pre_get")
+	r.VerifyWasCalledOnce().Execute(ctx.Log, []string{"pre-get"}, preExecuteEnv(tfVersion), "pre_get")
 }
 
 func TestExecute_SuccessPrePlan(t *testing.T) {
@@ -234,7 +240,7 @@ func TestExecute_SuccessPrePlan(t *testing.T) {
 		TerraformVersion: tfVersion,
 		LockResponse:     lockResponse,
 	}, res)
-	r.VerifyWasCalledOnce().Execute(ctx.Log, []string{"command"}, "", "", tfVersion, "pre_plan")
+	r.VerifyWasCalledOnce().Execute(ctx.Log, []string{"command"}, preExecuteEnv(tfVersion), "pre_plan")
 }
 
 func TestExecute_SuccessPreApply(t *testing.T) {
@@ -264,7 +270,210 @@ func TestExecute_SuccessPreApply(t *testing.T) {
 		TerraformVersion: tfVersion,
 		LockResponse:     lockResponse,
 	}, res)
-	r.VerifyWasCalledOnce().Execute(cpCtx.Log, []string{"command"}, "", "", tfVersion, "pre_apply")
+	r.VerifyWasCalledOnce().Execute(cpCtx.Log, []string{"command"}, preExecuteEnv(tfVersion), "pre_apply")
+}
+
+func TestExecute_HookOutputs(t *testing.T) {
+	t.Log("hook outputs should be returned depending on their show setting")
+	p, l, tm, r := setupPreExecuteTest(t)
+	When(l.TryLock(project, "", ctx.Pull, ctx.User)).ThenReturn(locking.TryLockResponse{
+		LockAcquired: true,
+	}, nil)
+	When(p.ConfigReader.Exists("")).ThenReturn(true)
+	When(p.ConfigReader.Read("")).ThenReturn(events.ProjectConfig{
+		PreInit:    []string{"pre-init"},
+		PrePlan:    []string{"pre-plan"},
+		HookOutput: map[string]string{"pre_plan": events.ShowHookOutputOnFailure},
+	}, nil)
+	tfVersion, _ := version.NewVersion("0.9")
+	When(tm.Version()).ThenReturn(tfVersion)
+	When(r.Execute(ctx.Log, []string{"pre-init"}, preExecuteEnv(tfVersion), "pre_init")).ThenReturn("initialized\n", nil)
+	When(r.Execute(ctx.Log, []string{"pre-plan"}, preExecuteEnv(tfVersion), "pre_plan")).ThenReturn("checked\n", nil)
+
+	res := p.Execute(&ctx, "", project)
+	Equals(t, []events.HookOutput{{Stage: "pre_init", Output: "initialized"}}, res.HookOutputs)
+
+	t.Log("hooks shown on failure should be returned when they fail")
+	When(r.Execute(ctx.Log, []string{"pre-plan"}, preExecuteEnv(tfVersion), "pre_plan")).ThenReturn("failed\n", errors.New("err"))
+	res = p.Execute(&ctx, "", project)
+	Equals(t, "running pre_plan commands: err", res.ProjectResult.Error.Error())
+	Equals(t, []events.HookOutput{
+		{Stage: "pre_init", Output: "initialized"},
+		{Stage: "pre_plan", Output: "failed", Failed: true},
+	}, res.ProjectResult.HookOutputs)
+}
+
+func TestExecute_UnknownWorkflow(t *testing.T) {
+	t.Log("when the project names a workflow that isn't defined we return an error")
+	p, l, _, _ := setupPreExecuteTest(t)
+	When(l.TryLock(project, "", ctx.Pull, ctx.User)).ThenReturn(locking.TryLockResponse{
+		LockAcquired: true,
+	}, nil)
+	When(p.ConfigReader.Exists("")).ThenReturn(true)
+	When(p.ConfigReader.Read("")).ThenReturn(events.ProjectConfig{WorkflowName: "missing"}, nil)
+
+	res := p.Execute(&ctx, "", project)
+	Equals(t, `workflow "missing" isn't defined in atlantis.yaml or on the server`, res.ProjectResult.Error.Error())
+}
+
+func TestExecute_WorkflowSkipsInit(t *testing.T) {
+	t.Log("when the project uses a workflow, init is left to its steps but pre_plan commands still run")
+	p, l, tm, r := setupPreExecuteTest(t)
+	lockResponse := locking.TryLockResponse{
+		LockAcquired: true,
+	}
+	When(l.TryLock(project, "", ctx.Pull, ctx.User)).ThenReturn(lockResponse, nil)
+	When(p.ConfigReader.Exists("")).ThenReturn(true)
+	When(p.ConfigReader.Read("")).ThenReturn(events.ProjectConfig{
+		PreInit: []string{"pre-init"},
+		PrePlan: []string{"pre-plan"},
+	}, nil)
+	workflow := events.Workflow{Plan: &events.Stage{Steps: []events.Step{{Name: "plan"}}}}
+	p.Workflows = map[string]events.Workflow{"default": workflow}
+	tfVersion, _ := version.NewVersion("0.9")
+	When(tm.Version()).ThenReturn(tfVersion)
+
+	res := p.Execute(&ctx, "", project)
+	Equals(t, &workflow, res.ProjectConfig.Workflow)
+	tm.VerifyWasCalled(Never()).Init(ctx.Log, "", "", nil, tfVersion)
+	r.VerifyWasCalled(Never()).Execute(ctx.Log, []string{"pre-init"}, preExecuteEnv(tfVersion), "pre_init")
+	r.VerifyWasCalledOnce().Execute(ctx.Log, []string{"pre-plan"}, preExecuteEnv(tfVersion), "pre_plan")
+}
+
+func TestExecute_SensitiveVariables(t *testing.T) {
+	t.Log("the values of variables marked sensitive should be added to the command's redactor")
+	p, l, tm, _ := setupPreExecuteTest(t)
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmpDir) // nolint: errcheck
+	files := map[string]string{
+		"main.tf": `
+variable "db_password" {
+  type      = string
+  sensitive = true
+  validation {
+    condition     = length(var.db_password) >= 8
+    error_message = "The password must be at least 8 characters."
+  }
+}
+variable "api_key" {
+  type      = string
+  sensitive = true
+}
+variable "env_secret" {
+  sensitive = true
+}
+variable "file_secret" {
+  type      = string
+  sensitive = true
+}
+variable "auto_secret" {
+  type      = string
+  sensitive = true
+}
+variable "env_file_secret" {
+  sensitive = true
+}
+variable "flag_secret" {
+  sensitive = true
+}
+variable "region" {
+  type = string
+}
+variable "tags" {
+  type    = map(string)
+  default = {}
+}
+resource "aws_db_instance" "db" {
+  password = var.db_password
+  tags     = { for k, v in var.tags : k => v }
+}
+`,
+		"terraform.tfvars": `db_password = "tfvars-password"` + "\nregion = \"us-east-1\"\n",
+		"secrets.auto.tfvars": `tags = {
+  team = "infra"
+}
+auto_secret = "auto-tfvars-secret"
+`,
+		"secrets.tfvars":     `file_secret = "var-file-secret"`,
+		"env/staging.tfvars": `env_file_secret = "env-file-secret"`,
+		"flag.tfvars":        `flag_secret = "flag-file-secret"`,
+		events.ProjectConfigFile: `
+extra_arguments:
+- command_name: plan
+  arguments: ["-var", "api_key=var-arg-key", "-var-file=secrets.tfvars", "-var=region=us-west-2"]
+`,
+	}
+	Ok(t, os.Mkdir(filepath.Join(tmpDir, "env"), 0700))
+	for name, contents := range files {
+		Ok(t, ioutil.WriteFile(filepath.Join(tmpDir, name), []byte(contents), 0600))
+	}
+	Ok(t, os.Setenv("TF_VAR_env_secret", "env-var-secret"))
+	defer os.Unsetenv("TF_VAR_env_secret") // nolint: errcheck
+
+	p.ConfigReader = &events.ProjectConfigManager{}
+	serverRedactor, err := redact.New(nil)
+	Ok(t, err)
+	stagingCtx := ctx
+	stagingCtx.Command = &events.Command{Name: events.Plan, Workspace: "staging", Flags: []string{"-var-file", "flag.tfvars"}}
+	stagingCtx.Redactor = serverRedactor.Child()
+	When(l.TryLock(project, "staging", ctx.Pull, ctx.User)).ThenReturn(locking.TryLockResponse{LockAcquired: true}, nil)
+	tfVersion, _ := version.NewVersion("0.9")
+	When(tm.Version()).ThenReturn(tfVersion)
+
+	res := p.Execute(&stagingCtx, tmpDir, project)
+	Assert(t, res.ProjectResult.Error == nil, "exp no error, got %v", res.ProjectResult.Error)
+	secrets := "tfvars-password var-arg-key env-var-secret var-file-secret auto-tfvars-secret env-file-secret flag-file-secret us-east-1 us-west-2"
+	Equals(t,
+		"[REDACTED] [REDACTED] [REDACTED] [REDACTED] [REDACTED] [REDACTED] [REDACTED] us-east-1 us-west-2",
+		stagingCtx.Redactor.Redact(secrets))
+	t.Log("they shouldn't be added to the server's redactor")
+	Equals(t, secrets, serverRedactor.Redact(secrets))
+}
+
+func TestExecute_LockWebhooks(t *testing.T) {
+	t.Log("should send a lock webhook when the lock is acquired and an unlock webhook if pre-execution fails")
+	p, l, _, _ := setupPreExecuteTest(t)
+	sender := &fakeLockSender{}
+	p.Webhooks = sender
+	lock := models.ProjectLock{Project: project, Workspace: "default", Pull: ctx.Pull, User: ctx.User}
+	When(l.TryLock(project, "", ctx.Pull, ctx.User)).ThenReturn(locking.TryLockResponse{
+		LockAcquired: true,
+		CurrLock:     lock,
+		LockKey:      "key",
+	}, nil)
+	When(l.Unlock("key")).ThenReturn(&lock, nil)
+	When(p.ConfigReader.Exists("")).ThenReturn(true)
+	When(p.ConfigReader.Read("")).ThenReturn(events.ProjectConfig{}, errors.New("err"))
+
+	res := p.Execute(&ctx, "", project)
+	Equals(t, "err", res.ProjectResult.Error.Error())
+	Equals(t, []webhooks.LockResult{{Lock: lock}}, sender.locked)
+	Equals(t, []webhooks.LockResult{{Lock: lock, Reason: webhooks.UnlockErrorReason}}, sender.unlocked)
+}
+
+func TestExecute_LockWebhooksAlreadyLocked(t *testing.T) {
+	t.Log("shouldn't send a lock webhook if the pull request already held the lock")
+	p, l, tm, _ := setupPreExecuteTest(t)
+	sender := &fakeLockSender{}
+	p.Webhooks = sender
+	When(l.TryLock(project, "", ctx.Pull, ctx.User)).ThenReturn(locking.TryLockResponse{
+		LockAcquired: false,
+		CurrLock:     models.ProjectLock{Pull: ctx.Pull},
+	}, nil)
+	When(p.ConfigReader.Exists("")).ThenReturn(false)
+	tfVersion, _ := version.NewVersion("0.9")
+	When(tm.Version()).ThenReturn(tfVersion)
+
+	res := p.Execute(&ctx, "", project)
+	Assert(t, res.ProjectResult.Error == nil, "exp no error, got %v", res.ProjectResult.Error)
+	Equals(t, 0, len(sender.locked))
+	Equals(t, 0, len(sender.unlocked))
+}
+
+// preExecuteEnv returns the env commands are run with for project and ctx.
+func preExecuteEnv(tfVersion *version.Version) run.Env {
+	return run.Env{TerraformVersion: tfVersion, PlanFile: ".tfplan"}
 }
 
 func setupPreExecuteTest(t *testing.T) (*events.DefaultProjectPreExecutor, *lmocks.MockLocker, *tmocks.MockClient, *rmocks.MockRunner) {
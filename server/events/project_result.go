@@ -17,11 +17,19 @@ import "github.com/runatlantis/atlantis/server/events/vcs"
 
 // ProjectResult is the result of executing a plan/apply for a project.
 type ProjectResult struct {
-	Path         string
+	Path string
+	// Workspace is the Terraform workspace the command ran in.
+	Workspace    string
 	Error        error
 	Failure      string
 	PlanSuccess  *PlanSuccess
 	ApplySuccess string
+	// Skipped is why the command wasn't run for the project, ex. because a
+	// project it depends on failed to apply.
+	Skipped string
+	// HookOutputs are the outputs of the project's hooks to show in the
+	// comment, in the order they ran.
+	HookOutputs []HookOutput
 }
 
 // Status returns the vcs commit status of this project result.
@@ -29,7 +37,7 @@ func (p ProjectResult) Status() vcs.CommitStatus {
 	if p.Error != nil {
 		return vcs.Failed
 	}
-	if p.Failure != "" {
+	if p.Failure != "" || p.Skipped != "" {
 		return vcs.Failed
 	}
 	return vcs.Success
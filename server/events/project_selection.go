@@ -0,0 +1,170 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+	"gopkg.in/yaml.v2"
+)
+
+// selectsProjects returns true if the command selects the projects to run in
+// with -d, -p or --all rather than using the modified projects.
+func (c *Command) selectsProjects() bool {
+	return len(c.Dirs) > 0 || len(c.ProjectNames) > 0 || c.All
+}
+
+// selectProjects returns the projects in repoDir that the command selected
+// with -d, -p or --all. Globs and --all select from the projects returned by
+// candidates, which is only called if they're used. Dirs that aren't globs
+// are selected even if they aren't candidates.
+func selectProjects(cmd *Command, repo models.Repo, repoDir string, candidates func() ([]models.Project, error)) ([]models.Project, error) {
+	if cmd.All {
+		return candidates()
+	}
+
+	var paths []string
+	var candidateProjects []models.Project
+	for _, dir := range cmd.Dirs {
+		if !isGlob(dir) {
+			paths = appendUnique(paths, dir)
+			continue
+		}
+		if candidateProjects == nil {
+			var err error
+			if candidateProjects, err = candidates(); err != nil {
+				return nil, err
+			}
+		}
+		matched := false
+		for _, candidate := range candidateProjects {
+			if ok, _ := path.Match(dir, candidate.Path); ok {
+				paths = appendUnique(paths, candidate.Path)
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no projects match %q", dir)
+		}
+	}
+	if len(cmd.ProjectNames) > 0 {
+		names, err := findProjectNames(repoDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range cmd.ProjectNames {
+			dir, ok := names[name]
+			if !ok {
+				return nil, fmt.Errorf("no project is named %q in an %s file", name, ProjectConfigFile)
+			}
+			paths = appendUnique(paths, dir)
+		}
+	}
+
+	var projects []models.Project
+	for _, p := range paths {
+		projects = append(projects, models.NewProject(repo.Hostname, repo.FullName, p))
+	}
+	return projects, nil
+}
+
+// allProjects returns every project in repoDir, sorted by path. These are the
+// projects that would be planned if every file in the repo was modified.
+func allProjects(log *logging.SimpleLogger, finder ProjectFinder, repo models.Repo, repoDir string) ([]models.Project, error) {
+	var files []string
+	err := filepath.Walk(repoDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			switch info.Name() {
+			case ".git", ".terraform", ".terragrunt-cache":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(repoDir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "finding projects")
+	}
+	projects := finder.DetermineProjects(log, files, repo, repoDir)
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Path < projects[j].Path })
+	return projects, nil
+}
+
+// findProjectNames returns the dirs of the projects in repoDir by the names
+// set in their atlantis.yaml files.
+func findProjectNames(repoDir string) (map[string]string, error) {
+	names := make(map[string]string)
+	err := filepath.Walk(repoDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			switch info.Name() {
+			case ".git", ".terraform", ".terragrunt-cache":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() != ProjectConfigFile {
+			return nil
+		}
+		raw, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		var config struct {
+			Name string `yaml:"name"`
+		}
+		if err := yaml.Unmarshal(raw, &config); err != nil {
+			return errors.Wrapf(err, "parsing %s", p)
+		}
+		if config.Name == "" {
+			return nil
+		}
+		rel, err := filepath.Rel(repoDir, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		dir := filepath.ToSlash(rel)
+		if existing, ok := names[config.Name]; ok {
+			return fmt.Errorf("project name %q is used in both %s and %s", config.Name, existing, dir)
+		}
+		names[config.Name] = dir
+		return nil
+	})
+	return names, errors.Wrap(err, "finding project names")
+}
+
+// isGlob returns true if dir contains any of the special characters used
+// in globs.
+func isGlob(dir string) bool {
+	return strings.ContainsAny(dir, "*?[")
+}
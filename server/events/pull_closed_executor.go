@@ -24,6 +24,8 @@ import (
 	"github.com/runatlantis/atlantis/server/events/locking"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/logging"
 )
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_pull_cleaner.go PullCleaner
@@ -41,6 +43,11 @@ type PullClosedExecutor struct {
 	Locker    locking.Locker
 	VCSClient vcs.ClientProxy
 	Workspace AtlantisWorkspace
+	// Webhooks are sent for each lock that's released. If nil, they aren't
+	// sent.
+	Webhooks webhooks.LockSender
+	// Logger is used when sending webhooks.
+	Logger *logging.SimpleLogger
 }
 
 type templatedProject struct {
@@ -62,10 +69,15 @@ func (p *PullClosedExecutor) CleanUpPull(repo models.Repo, pull models.PullReque
 	// Finally, delete locks. We do this last because when someone
 	// unlocks a project, right now we don't actually delete the plan
 	// so we might have plans laying around but no locks.
-	locks, err := p.Locker.UnlockByPull(repo.FullName, pull.Num)
+	locks, err := p.Locker.UnlockByPull(repo.Hostname, repo.FullName, pull.Num)
 	if err != nil {
 		return errors.Wrap(err, "cleaning up locks")
 	}
+	if p.Webhooks != nil {
+		for _, lock := range locks {
+			p.Webhooks.SendUnlock(p.Logger, webhooks.LockResult{Lock: lock, Reason: webhooks.UnlockPullClosedReason}) // nolint: errcheck
+		}
+	}
 
 	// If there are no locks then there's no need to comment.
 	if len(locks) == 0 {
@@ -26,6 +26,8 @@ import (
 	"github.com/runatlantis/atlantis/server/events/models/fixtures"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/logging"
 	. "github.com/runatlantis/atlantis/testing"
 )
 
@@ -52,7 +54,7 @@ func TestCleanUpPullUnlockErr(t *testing.T) {
 		Workspace: w,
 	}
 	err := errors.New("err")
-	When(l.UnlockByPull(fixtures.Repo.FullName, fixtures.Pull.Num)).ThenReturn(nil, err)
+	When(l.UnlockByPull(fixtures.Repo.Hostname, fixtures.Repo.FullName, fixtures.Pull.Num)).ThenReturn(nil, err)
 	actualErr := pce.CleanUpPull(fixtures.Repo, fixtures.Pull, vcs.Github)
 	Equals(t, "cleaning up locks: err", actualErr.Error())
 }
@@ -68,7 +70,7 @@ func TestCleanUpPullNoLocks(t *testing.T) {
 		VCSClient: cp,
 		Workspace: w,
 	}
-	When(l.UnlockByPull(fixtures.Repo.FullName, fixtures.Pull.Num)).ThenReturn(nil, nil)
+	When(l.UnlockByPull(fixtures.Repo.Hostname, fixtures.Repo.FullName, fixtures.Pull.Num)).ThenReturn(nil, nil)
 	err := pce.CleanUpPull(fixtures.Repo, fixtures.Pull, vcs.Github)
 	Ok(t, err)
 	cp.VerifyWasCalled(Never()).CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), matchers.AnyVcsHost())
@@ -86,7 +88,7 @@ func TestCleanUpPullComments(t *testing.T) {
 			"single lock, empty path",
 			[]models.ProjectLock{
 				{
-					Project:   models.NewProject("owner/repo", ""),
+					Project:   models.NewProject("github.com", "owner/repo", ""),
 					Workspace: "default",
 				},
 			},
@@ -96,7 +98,7 @@ func TestCleanUpPullComments(t *testing.T) {
 			"single lock, non-empty path",
 			[]models.ProjectLock{
 				{
-					Project:   models.NewProject("owner/repo", "path"),
+					Project:   models.NewProject("github.com", "owner/repo", "path"),
 					Workspace: "default",
 				},
 			},
@@ -106,11 +108,11 @@ func TestCleanUpPullComments(t *testing.T) {
 			"single path, multiple workspaces",
 			[]models.ProjectLock{
 				{
-					Project:   models.NewProject("owner/repo", "path"),
+					Project:   models.NewProject("github.com", "owner/repo", "path"),
 					Workspace: "workspace1",
 				},
 				{
-					Project:   models.NewProject("owner/repo", "path"),
+					Project:   models.NewProject("github.com", "owner/repo", "path"),
 					Workspace: "workspace2",
 				},
 			},
@@ -120,19 +122,19 @@ func TestCleanUpPullComments(t *testing.T) {
 			"multiple paths, multiple workspaces",
 			[]models.ProjectLock{
 				{
-					Project:   models.NewProject("owner/repo", "path"),
+					Project:   models.NewProject("github.com", "owner/repo", "path"),
 					Workspace: "workspace1",
 				},
 				{
-					Project:   models.NewProject("owner/repo", "path"),
+					Project:   models.NewProject("github.com", "owner/repo", "path"),
 					Workspace: "workspace2",
 				},
 				{
-					Project:   models.NewProject("owner/repo", "path2"),
+					Project:   models.NewProject("github.com", "owner/repo", "path2"),
 					Workspace: "workspace1",
 				},
 				{
-					Project:   models.NewProject("owner/repo", "path2"),
+					Project:   models.NewProject("github.com", "owner/repo", "path2"),
 					Workspace: "workspace2",
 				},
 			},
@@ -149,7 +151,7 @@ func TestCleanUpPullComments(t *testing.T) {
 			Workspace: w,
 		}
 		t.Log("testing: " + c.Description)
-		When(l.UnlockByPull(fixtures.Repo.FullName, fixtures.Pull.Num)).ThenReturn(c.Locks, nil)
+		When(l.UnlockByPull(fixtures.Repo.Hostname, fixtures.Repo.FullName, fixtures.Pull.Num)).ThenReturn(c.Locks, nil)
 		err := pce.CleanUpPull(fixtures.Repo, fixtures.Pull, vcs.Github)
 		Ok(t, err)
 		_, _, comment, _ := cp.VerifyWasCalledOnce().CreateComment(matchers.AnyModelsRepo(), AnyInt(), AnyString(), matchers.AnyVcsHost()).GetCapturedArguments()
@@ -158,3 +160,47 @@ func TestCleanUpPullComments(t *testing.T) {
 		Equals(t, expected, comment)
 	}
 }
+
+// fakeLockSender records the lock webhooks it's sent.
+type fakeLockSender struct {
+	locked   []webhooks.LockResult
+	unlocked []webhooks.LockResult
+}
+
+func (f *fakeLockSender) SendLock(_ *logging.SimpleLogger, result webhooks.LockResult) error {
+	f.locked = append(f.locked, result)
+	return nil
+}
+
+func (f *fakeLockSender) SendUnlock(_ *logging.SimpleLogger, result webhooks.LockResult) error {
+	f.unlocked = append(f.unlocked, result)
+	return nil
+}
+
+func TestCleanUpPullUnlockWebhooks(t *testing.T) {
+	t.Log("should send an unlock webhook for each lock that's released")
+	RegisterMockTestingT(t)
+	w := mocks.NewMockAtlantisWorkspace()
+	l := lockmocks.NewMockLocker()
+	cp := vcsmocks.NewMockClientProxy()
+	sender := &fakeLockSender{}
+	pce := events.PullClosedExecutor{
+		Locker:    l,
+		VCSClient: cp,
+		Workspace: w,
+		Webhooks:  sender,
+		Logger:    logging.NewNoopLogger(),
+	}
+	locks := []models.ProjectLock{
+		{Project: models.NewProject(fixtures.Repo.Hostname, fixtures.Repo.FullName, "vpc"), Workspace: "default"},
+		{Project: models.NewProject(fixtures.Repo.Hostname, fixtures.Repo.FullName, "app"), Workspace: "staging"},
+	}
+	When(l.UnlockByPull(fixtures.Repo.Hostname, fixtures.Repo.FullName, fixtures.Pull.Num)).ThenReturn(locks, nil)
+	err := pce.CleanUpPull(fixtures.Repo, fixtures.Pull, vcs.Github)
+	Ok(t, err)
+	Equals(t, []webhooks.LockResult{
+		{Lock: locks[0], Reason: webhooks.UnlockPullClosedReason},
+		{Lock: locks[1], Reason: webhooks.UnlockPullClosedReason},
+	}, sender.unlocked)
+	Equals(t, 0, len(sender.locked))
+}
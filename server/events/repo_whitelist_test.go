@@ -35,6 +35,27 @@ func TestIsWhitelisted(t *testing.T) {
 			"github.com",
 			true,
 		},
+		{
+			"exact match for a repo in a subgroup",
+			"gitlab.com/group/subgroup/repo",
+			"group/subgroup/repo",
+			"gitlab.com",
+			true,
+		},
+		{
+			"group wildcard should match repos in subgroups",
+			"gitlab.com/group/*",
+			"group/subgroup/repo",
+			"gitlab.com",
+			true,
+		},
+		{
+			"subgroup wildcard shouldn't match the parent group's repos",
+			"gitlab.com/group/subgroup/*",
+			"group/repo",
+			"gitlab.com",
+			false,
+		},
 		{
 			"exact match shouldn't match anything else",
 			"github.com/owner/repo",
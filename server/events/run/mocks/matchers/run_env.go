@@ -0,0 +1,20 @@
+package matchers
+
+import (
+	"reflect"
+
+	"github.com/petergtz/pegomock"
+	run "github.com/runatlantis/atlantis/server/events/run"
+)
+
+func AnyRunEnv() run.Env {
+	pegomock.RegisterMatcher(pegomock.NewAnyMatcher(reflect.TypeOf((*(run.Env))(nil)).Elem()))
+	var nullValue run.Env
+	return nullValue
+}
+
+func EqRunEnv(value run.Env) run.Env {
+	pegomock.RegisterMatcher(&pegomock.EqMatcher{Value: value})
+	var nullValue run.Env
+	return nullValue
+}
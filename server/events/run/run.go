@@ -16,11 +16,13 @@
 package run
 
 import (
-	"bufio"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/hashicorp/go-version"
@@ -33,74 +35,114 @@ const inlineShebang = "#!/bin/sh -e"
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_runner.go Runner
 
 type Runner interface {
-	Execute(log *logging.SimpleLogger, commands []string, path string, workspace string, terraformVersion *version.Version, stage string) (string, error)
+	Execute(log *logging.SimpleLogger, commands []string, env Env, stage string) (string, error)
+}
+
+// Env is the context commands are run in. It's passed to them as
+// environment variables, named in the comments, so they don't have to be set
+// on the Atlantis process where concurrent runs would overwrite each other.
+type Env struct {
+	// Dir is the absolute path to the project. Commands are run in it. DIR.
+	Dir string
+	// Workspace is the Terraform workspace. WORKSPACE.
+	Workspace string
+	// TerraformVersion is the version of Terraform used for the project.
+	// ATLANTIS_TERRAFORM_VERSION.
+	TerraformVersion *version.Version
+	// PullNum is the pull request number. PULL_NUM.
+	PullNum int
+	// HeadCommit is the commit being planned or applied. HEAD_COMMIT.
+	HeadCommit string
+	// BaseRepo is the full name of the repo, ex. runatlantis/atlantis.
+	// BASE_REPO.
+	BaseRepo string
+	// HeadBranch is the branch of the pull request. HEAD_BRANCH.
+	HeadBranch string
+	// UserName is the user that ran the command. USER_NAME.
+	UserName string
+	// ProjectPath is the path to the project relative to the repo root.
+	// PROJECT_PATH.
+	ProjectPath string
+	// PlanFile is the absolute path to the project's plan file. It might
+	// not exist yet. PLANFILE.
+	PlanFile string
+	// Vars are additional variables to set, by name.
+	Vars map[string]string
+}
+
+// Environ returns the variables of e in the "key=value" format of
+// os.Environ.
+func (e Env) Environ() []string {
+	var tfVersion string
+	if e.TerraformVersion != nil {
+		tfVersion = e.TerraformVersion.String()
+	}
+	environ := []string{
+		"WORKSPACE=" + e.Workspace,
+		"ATLANTIS_TERRAFORM_VERSION=" + tfVersion,
+		"DIR=" + e.Dir,
+		"PULL_NUM=" + strconv.Itoa(e.PullNum),
+		"HEAD_COMMIT=" + e.HeadCommit,
+		"BASE_REPO=" + e.BaseRepo,
+		"HEAD_BRANCH=" + e.HeadBranch,
+		"USER_NAME=" + e.UserName,
+		"PROJECT_PATH=" + e.ProjectPath,
+		"PLANFILE=" + e.PlanFile,
+	}
+	var names []string
+	for name := range e.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		environ = append(environ, name+"="+e.Vars[name])
+	}
+	return environ
 }
 
 type Run struct{}
 
 // Execute runs the commands by writing them as a script to disk
-// and then executing the script.
+// and then executing the script in env.Dir.
 func (p *Run) Execute(
 	log *logging.SimpleLogger,
 	commands []string,
-	path string,
-	workspace string,
-	terraformVersion *version.Version,
+	env Env,
 	stage string) (string, error) {
 	// we create a script from the commands provided
 	if len(commands) == 0 {
 		return "", errors.Errorf("%s commands cannot be empty", stage)
 	}
 
-	s, err := createScript(commands, stage)
+	// The script is written to its own directory that only we can read
+	// since the commands might contain secrets.
+	dir, err := ioutil.TempDir("", "atlantis-run")
+	if err != nil {
+		return "", errors.Wrapf(err, "preparing %s shell script", stage)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	s, err := createScript(dir, commands, stage)
 	if err != nil {
 		return "", err
 	}
-	defer os.Remove(s) // nolint: errcheck
 
 	log.Info("running %s commands: %v", stage, commands)
-
-	// set environment variable for the run.
-	// this is to support scripts to use the WORKSPACE, ATLANTIS_TERRAFORM_VERSION
-	// and DIR variables in their scripts
-	os.Setenv("WORKSPACE", workspace)                                  // nolint: errcheck
-	os.Setenv("ATLANTIS_TERRAFORM_VERSION", terraformVersion.String()) // nolint: errcheck
-	os.Setenv("DIR", path)                                             // nolint: errcheck
-	return execute(s)
+	return execute(s, env)
 }
 
-func createScript(cmds []string, stage string) (string, error) {
-	tmp, err := ioutil.TempFile("/tmp", "atlantis-temp-script")
-	if err != nil {
-		return "", errors.Wrapf(err, "preparing %s shell script", stage)
-	}
-
-	scriptName := tmp.Name()
-
-	// Write our contents to it
-	writer := bufio.NewWriter(tmp)
-	if _, err = writer.WriteString(fmt.Sprintf("%s\n", inlineShebang)); err != nil {
-		return "", errors.Wrapf(err, "writing to %q", tmp.Name())
-	}
-	cmdsJoined := strings.Join(cmds, "\n")
-	if _, err := writer.WriteString(cmdsJoined); err != nil {
-		return "", errors.Wrapf(err, "preparing %s", stage)
+func createScript(dir string, cmds []string, stage string) (string, error) {
+	scriptName := filepath.Join(dir, stage+".sh")
+	script := fmt.Sprintf("%s\n%s", inlineShebang, strings.Join(cmds, "\n"))
+	if err := ioutil.WriteFile(scriptName, []byte(script), 0700); err != nil { // nolint: gas
+		return "", errors.Wrapf(err, "writing %s shell script", stage)
 	}
-
-	if err := writer.Flush(); err != nil {
-		return "", errors.Wrap(err, "flushing contents to file")
-	}
-	tmp.Close() // nolint: errcheck
-
-	if err := os.Chmod(scriptName, 0700); err != nil { // nolint: gas
-		return "", errors.Wrapf(err, "making %s script executable", stage)
-	}
-
 	return scriptName, nil
 }
 
-func execute(script string) (string, error) {
+func execute(script string, env Env) (string, error) {
 	localCmd := exec.Command("sh", "-c", script) // #nosec
+	localCmd.Dir = env.Dir
+	localCmd.Env = append(os.Environ(), env.Environ()...)
 	out, err := localCmd.CombinedOutput()
 	output := string(out)
 	if err != nil {
@@ -14,6 +14,10 @@
 package run
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/hashicorp/go-version"
@@ -25,23 +29,35 @@ var logger = logging.NewNoopLogger()
 var run = &Run{}
 
 func TestRunCreateScript_valid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
 	cmds := []string{"echo", "date"}
-	scriptName, err := createScript(cmds, "post_apply")
+	scriptName, err := createScript(dir, cmds, "post_apply")
 	Assert(t, scriptName != "", "there should be a script name")
 	Assert(t, err == nil, "there should not be an error")
+	info, err := os.Stat(scriptName)
+	Ok(t, err)
+	Equals(t, os.FileMode(0700), info.Mode().Perm())
 }
 
 func TestRunExecuteScript_invalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
 	cmds := []string{"invalid", "command"}
-	scriptName, _ := createScript(cmds, "post_apply")
-	_, err := execute(scriptName)
+	scriptName, _ := createScript(dir, cmds, "post_apply")
+	_, err = execute(scriptName, Env{})
 	Assert(t, err != nil, "there should be an error")
 }
 
 func TestRunExecuteScript_valid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
 	cmds := []string{"echo", "date"}
-	scriptName, _ := createScript(cmds, "post_apply")
-	output, err := execute(scriptName)
+	scriptName, _ := createScript(dir, cmds, "post_apply")
+	output, err := execute(scriptName, Env{})
 	Assert(t, err == nil, "there should not be an error")
 	Assert(t, output != "", "there should be output")
 }
@@ -49,6 +65,37 @@ func TestRunExecuteScript_valid(t *testing.T) {
 func TestRun_valid(t *testing.T) {
 	cmds := []string{"echo", "date"}
 	v, _ := version.NewVersion("0.8.8")
-	_, err := run.Execute(logger, cmds, "/tmp/atlantis", "staging", v, "post_apply")
+	_, err := run.Execute(logger, cmds, Env{Workspace: "staging", TerraformVersion: v}, "post_apply")
 	Ok(t, err)
 }
+
+func TestRun_Env(t *testing.T) {
+	t.Log("commands should run in the project dir with the env as variables")
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	dir, err = filepath.EvalSymlinks(dir)
+	Ok(t, err)
+	v, _ := version.NewVersion("0.11.1")
+	env := Env{
+		Dir:              dir,
+		Workspace:        "staging",
+		TerraformVersion: v,
+		PullNum:          2,
+		HeadCommit:       "abc123",
+		BaseRepo:         "runatlantis/atlantis",
+		HeadBranch:       "branch",
+		UserName:         "lkysow",
+		ProjectPath:      "project",
+		PlanFile:         dir + "/staging.tfplan",
+		Vars:             map[string]string{"STAGE": "prod"},
+	}
+	cmds := []string{`echo "$(pwd) $DIR $WORKSPACE $ATLANTIS_TERRAFORM_VERSION $PULL_NUM $HEAD_COMMIT $BASE_REPO $HEAD_BRANCH $USER_NAME $PROJECT_PATH $PLANFILE $STAGE"`}
+
+	output, err := run.Execute(logger, cmds, env, "pre_plan")
+	Ok(t, err)
+	Equals(t, fmt.Sprintf("%s %s staging 0.11.1 2 abc123 runatlantis/atlantis branch lkysow project %s/staging.tfplan prod\n", dir, dir, dir), output)
+
+	t.Log("the variables shouldn't be set on the Atlantis process")
+	Equals(t, "", os.Getenv("PULL_NUM"))
+}
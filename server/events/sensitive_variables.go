@@ -0,0 +1,94 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+// sensitiveVariableValues returns the values of the variables declared with
+// "sensitive = true" in the .tf files in dir so they can be redacted. Values
+// are read from the -var and -var-file args in args, the
+// terraform.tfvars and *.auto.tfvars files in dir and the TF_VAR_ variables
+// in environ. Files that can't be scanned are skipped with a warning since
+// the values of the variables in them won't be redacted.
+func sensitiveVariableValues(log *logging.SimpleLogger, dir string, args []string, environ []string) []string {
+	tfFiles, _ := filepath.Glob(filepath.Join(dir, "*.tf"))
+	names := make(map[string]bool)
+	for _, f := range tfFiles {
+		body, err := scanHCLFile(f)
+		if err != nil {
+			log.Warn("skipping %q when finding sensitive variables: %s", f, err)
+			continue
+		}
+		for _, v := range body.Blocks {
+			if v.Type == "variable" && len(v.Labels) == 1 && v.Body.Attributes["sensitive"] == "true" {
+				names[v.Labels[0]] = true
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	var values []string
+	for _, kv := range environ {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 && strings.HasPrefix(parts[0], "TF_VAR_") && names[strings.TrimPrefix(parts[0], "TF_VAR_")] {
+			values = append(values, parts[1])
+		}
+	}
+	varFiles, _ := filepath.Glob(filepath.Join(dir, "*.auto.tfvars"))
+	varFiles = append([]string{filepath.Join(dir, "terraform.tfvars")}, varFiles...)
+	for i := 0; i < len(args); i++ {
+		var flag, arg string
+		if parts := strings.SplitN(args[i], "=", 2); len(parts) == 2 && (parts[0] == "-var" || parts[0] == "-var-file") {
+			flag, arg = parts[0], parts[1]
+		} else if (args[i] == "-var" || args[i] == "-var-file") && i+1 < len(args) {
+			flag, arg = args[i], args[i+1]
+			i++
+		}
+		switch flag {
+		case "-var":
+			if parts := strings.SplitN(arg, "=", 2); len(parts) == 2 && names[parts[0]] {
+				values = append(values, parts[1])
+			}
+		case "-var-file":
+			if !filepath.IsAbs(arg) {
+				arg = filepath.Join(dir, arg)
+			}
+			varFiles = append(varFiles, arg)
+		}
+	}
+	for _, f := range varFiles {
+		body, err := scanHCLFile(f)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			log.Warn("skipping %q when finding sensitive variable values: %s", f, err)
+			continue
+		}
+		for name := range names {
+			if value, ok := hclString(body.Attributes[name]); ok {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
@@ -47,6 +47,22 @@ func (mock *MockClient) RunCommandWithVersion(log *logging.SimpleLogger, path st
 	return ret0, ret1
 }
 
+func (mock *MockClient) RunCommandWithVersionStdout(log *logging.SimpleLogger, path string, args []string, v *go_version.Version, workspace string) (string, error) {
+	params := []pegomock.Param{log, path, args, v, workspace}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("RunCommandWithVersionStdout", params, []reflect.Type{reflect.TypeOf((*string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
+	var ret0 string
+	var ret1 error
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(string)
+		}
+		if result[1] != nil {
+			ret1 = result[1].(error)
+		}
+	}
+	return ret0, ret1
+}
+
 func (mock *MockClient) Init(log *logging.SimpleLogger, path string, workspace string, extraInitArgs []string, version *go_version.Version) ([]string, error) {
 	params := []pegomock.Param{log, path, workspace, extraInitArgs, version}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("Init", params, []reflect.Type{reflect.TypeOf((*[]string)(nil)).Elem(), reflect.TypeOf((*error)(nil)).Elem()})
@@ -141,6 +157,49 @@ func (c *Client_RunCommandWithVersion_OngoingVerification) GetAllCapturedArgumen
 	return
 }
 
+func (verifier *VerifierClient) RunCommandWithVersionStdout(log *logging.SimpleLogger, path string, args []string, v *go_version.Version, workspace string) *Client_RunCommandWithVersionStdout_OngoingVerification {
+	params := []pegomock.Param{log, path, args, v, workspace}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "RunCommandWithVersionStdout", params)
+	return &Client_RunCommandWithVersionStdout_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type Client_RunCommandWithVersionStdout_OngoingVerification struct {
+	mock              *MockClient
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *Client_RunCommandWithVersionStdout_OngoingVerification) GetCapturedArguments() (*logging.SimpleLogger, string, []string, *go_version.Version, string) {
+	log, path, args, v, workspace := c.GetAllCapturedArguments()
+	return log[len(log)-1], path[len(path)-1], args[len(args)-1], v[len(v)-1], workspace[len(workspace)-1]
+}
+
+func (c *Client_RunCommandWithVersionStdout_OngoingVerification) GetAllCapturedArguments() (_param0 []*logging.SimpleLogger, _param1 []string, _param2 [][]string, _param3 []*go_version.Version, _param4 []string) {
+	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
+	if len(params) > 0 {
+		_param0 = make([]*logging.SimpleLogger, len(params[0]))
+		for u, param := range params[0] {
+			_param0[u] = param.(*logging.SimpleLogger)
+		}
+		_param1 = make([]string, len(params[1]))
+		for u, param := range params[1] {
+			_param1[u] = param.(string)
+		}
+		_param2 = make([][]string, len(params[2]))
+		for u, param := range params[2] {
+			_param2[u] = param.([]string)
+		}
+		_param3 = make([]*go_version.Version, len(params[3]))
+		for u, param := range params[3] {
+			_param3[u] = param.(*go_version.Version)
+		}
+		_param4 = make([]string, len(params[4]))
+		for u, param := range params[4] {
+			_param4[u] = param.(string)
+		}
+	}
+	return
+}
+
 func (verifier *VerifierClient) Init(log *logging.SimpleLogger, path string, workspace string, extraInitArgs []string, version *go_version.Version) *Client_Init_OngoingVerification {
 	params := []pegomock.Param{log, path, workspace, extraInitArgs, version}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "Init", params)
@@ -15,6 +15,7 @@
 package terraform
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"os/exec"
@@ -32,16 +33,25 @@ import (
 type Client interface {
 	Version() *version.Version
 	RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error)
+	// RunCommandWithVersionStdout is like RunCommandWithVersion but only
+	// returns stdout. It's for commands whose output we parse.
+	RunCommandWithVersionStdout(log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error)
 	Init(log *logging.SimpleLogger, path string, workspace string, extraInitArgs []string, version *version.Version) ([]string, error)
 }
 
 type DefaultClient struct {
 	defaultVersion          *version.Version
 	terraformPluginCacheDir string
+	// terragruntInstalled is true if terragrunt is in our $PATH.
+	terragruntInstalled bool
 }
 
 const terraformPluginCacheDirName = "plugin-cache"
 
+// TerragruntConfigFile is the name of terragrunt's config file. Commands in
+// directories with one are run with terragrunt instead of terraform.
+const TerragruntConfigFile = "terragrunt.hcl"
+
 // zeroPointNine constrains the version to be 0.9.*
 var zeroPointNine = MustConstraint(">=0.9,<0.10")
 var versionRegex = regexp.MustCompile("Terraform v(.*)\n")
@@ -75,9 +85,11 @@ func NewClient(dataDir string) (*DefaultClient, error) {
 		return nil, errors.Wrapf(err, "unable to create terraform plugin cache directory at %q", terraformPluginCacheDirName)
 	}
 
+	_, err = exec.LookPath("terragrunt")
 	return &DefaultClient{
 		defaultVersion:          v,
 		terraformPluginCacheDir: cacheDir,
+		terragruntInstalled:     err == nil,
 	}, nil
 }
 
@@ -90,12 +102,68 @@ func (c *DefaultClient) Version() *version.Version {
 // the provided args in path. v is the version of terraform executable to use
 // and workspace is the workspace specified by the user commenting
 // "atlantis plan/apply {workspace}" which is set to "default" by default.
+// If path contains a terragrunt.hcl file, terragrunt is run instead and it
+// runs the terraform executable. The output is stdout and stderr combined.
 func (c *DefaultClient) RunCommandWithVersion(log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error) {
+	terraformCmd, err := c.command(path, args, v, workspace)
+	if err != nil {
+		return "", err
+	}
+	out, err := terraformCmd.CombinedOutput()
+	commandStr := strings.Join(terraformCmd.Args, " ")
+	if err != nil {
+		err = fmt.Errorf("%s: running %q in %q: \n%s", err, commandStr, path, out)
+		log.Debug("error: %s", err)
+		return string(out), err
+	}
+	log.Info("successfully ran %q in %q", commandStr, path)
+	return string(out), nil
+}
+
+// RunCommandWithVersionStdout is like RunCommandWithVersion but only returns
+// what the command writes to stdout so it can be parsed without warnings or
+// terragrunt's logging getting in the way. If the command fails, the error
+// includes its stderr.
+func (c *DefaultClient) RunCommandWithVersionStdout(log *logging.SimpleLogger, path string, args []string, v *version.Version, workspace string) (string, error) {
+	terraformCmd, err := c.command(path, args, v, workspace)
+	if err != nil {
+		return "", err
+	}
+	var stderr bytes.Buffer
+	terraformCmd.Stderr = &stderr
+	out, err := terraformCmd.Output()
+	commandStr := strings.Join(terraformCmd.Args, " ")
+	if err != nil {
+		err = fmt.Errorf("%s: running %q in %q: \n%s", err, commandStr, path, stderr.String())
+		log.Debug("error: %s", err)
+		return string(out), err
+	}
+	log.Info("successfully ran %q in %q", commandStr, path)
+	return string(out), nil
+}
+
+// command returns the command that runs the terraform executable for v with
+// args in path, or terragrunt if path contains a terragrunt.hcl file.
+func (c *DefaultClient) command(path string, args []string, v *version.Version, workspace string) (*exec.Cmd, error) {
 	tfExecutable := "terraform"
 	// if version is the same as the default, don't need to prepend the version name to the executable
 	if !v.Equal(c.defaultVersion) {
 		tfExecutable = fmt.Sprintf("%s%s", tfExecutable, v.String())
 	}
+	executable := tfExecutable
+	var terragruntEnvVars []string
+	if _, err := os.Stat(filepath.Join(path, TerragruntConfigFile)); err == nil {
+		if !c.terragruntInstalled {
+			return nil, fmt.Errorf("found %s in %q but terragrunt isn't in $PATH. Download it from https://github.com/gruntwork-io/terragrunt/releases", TerragruntConfigFile, path)
+		}
+		executable = "terragrunt"
+		terragruntEnvVars = []string{
+			// Run the version of terraform the project uses.
+			fmt.Sprintf("TERRAGRUNT_TFPATH=%s", tfExecutable),
+			// Don't prompt, ex. to create the state bucket.
+			"TERRAGRUNT_NON_INTERACTIVE=true",
+		}
+	}
 
 	// set environment variables
 	// this is to support scripts to use the WORKSPACE, ATLANTIS_TERRAFORM_VERSION
@@ -111,23 +179,18 @@ func (c *DefaultClient) RunCommandWithVersion(log *logging.SimpleLogger, path st
 		fmt.Sprintf("ATLANTIS_TERRAFORM_VERSION=%s", v.String()),
 		fmt.Sprintf("DIR=%s", path),
 	}
-	envVars = append(envVars, os.Environ()...)
+	// The terragrunt vars go last so they override any set in our
+	// environment, otherwise an ambient TERRAGRUNT_TFPATH would run the
+	// wrong version of terraform.
+	envVars = append(append(envVars, os.Environ()...), terragruntEnvVars...)
 
 	// append terraform executable name with args
-	tfCmd := fmt.Sprintf("%s %s", tfExecutable, strings.Join(args, " "))
+	tfCmd := fmt.Sprintf("%s %s", executable, strings.Join(args, " "))
 
 	terraformCmd := exec.Command("sh", "-c", tfCmd) // #nosec
 	terraformCmd.Dir = path
 	terraformCmd.Env = envVars
-	out, err := terraformCmd.CombinedOutput()
-	commandStr := strings.Join(terraformCmd.Args, " ")
-	if err != nil {
-		err = fmt.Errorf("%s: running %q in %q: \n%s", err, commandStr, path, out)
-		log.Debug("error: %s", err)
-		return string(out), err
-	}
-	log.Info("successfully ran %q in %q", commandStr, path)
-	return string(out), nil
+	return terraformCmd, nil
 }
 
 // Init executes "terraform init" and "terraform workspace select" in path.
@@ -0,0 +1,118 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// fakeTerragrunt puts a terragrunt executable that runs script at the front
+// of $PATH. It returns a func that undoes it.
+func fakeTerragrunt(t *testing.T, script string) func() {
+	binDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	Ok(t, ioutil.WriteFile(filepath.Join(binDir, "terragrunt"), []byte(script), 0700))
+	origPath := os.Getenv("PATH")
+	os.Setenv("PATH", binDir+":"+origPath) // nolint: errcheck
+	return func() {
+		os.Setenv("PATH", origPath) // nolint: errcheck
+		os.RemoveAll(binDir)        // nolint: errcheck
+	}
+}
+
+func TestRunCommandWithVersion_Terragrunt(t *testing.T) {
+	t.Log("in dirs with a terragrunt.hcl we should run terragrunt with the project's terraform version, even if TERRAGRUNT_TFPATH is already set")
+	// The fake prints its args and the TERRAGRUNT_* env vars.
+	defer fakeTerragrunt(t, "#!/bin/sh\necho \"terragrunt $@\"\nenv | grep '^TERRAGRUNT_' | sort\n")()
+	os.Setenv("TERRAGRUNT_TFPATH", "terraform0.8.0") // nolint: errcheck
+	defer os.Unsetenv("TERRAGRUNT_TFPATH")           // nolint: errcheck
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	Ok(t, ioutil.WriteFile(filepath.Join(dir, TerragruntConfigFile), nil, 0600))
+	c := &DefaultClient{
+		defaultVersion:          version.Must(version.NewVersion("0.11.7")),
+		terraformPluginCacheDir: dir,
+		terragruntInstalled:     true,
+	}
+
+	out, err := c.RunCommandWithVersion(logging.NewNoopLogger(), dir, []string{"plan", "-no-color"}, version.Must(version.NewVersion("0.12.0")), "default")
+	Ok(t, err)
+	Equals(t, []string{
+		"terragrunt plan -no-color",
+		"TERRAGRUNT_NON_INTERACTIVE=true",
+		"TERRAGRUNT_TFPATH=terraform0.12.0",
+	}, strings.Split(strings.TrimSpace(out), "\n"))
+
+	t.Log("with the default version, TERRAGRUNT_TFPATH should be the plain terraform executable")
+	out, err = c.RunCommandWithVersion(logging.NewNoopLogger(), dir, []string{"plan"}, c.defaultVersion, "default")
+	Ok(t, err)
+	Assert(t, strings.Contains(out, "TERRAGRUNT_TFPATH=terraform\n"), "exp TERRAGRUNT_TFPATH=terraform in %q", out)
+}
+
+func TestRunCommandWithVersion_TerragruntNotInstalled(t *testing.T) {
+	t.Log("in dirs with a terragrunt.hcl we should error if terragrunt isn't installed")
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	Ok(t, ioutil.WriteFile(filepath.Join(dir, TerragruntConfigFile), nil, 0600))
+	v := version.Must(version.NewVersion("0.11.7"))
+	c := &DefaultClient{defaultVersion: v, terraformPluginCacheDir: dir}
+
+	_, err = c.RunCommandWithVersion(logging.NewNoopLogger(), dir, []string{"plan"}, v, "default")
+	ErrEquals(t, "found terragrunt.hcl in \""+dir+"\" but terragrunt isn't in $PATH. Download it from https://github.com/gruntwork-io/terragrunt/releases", err)
+}
+
+func TestRunCommandWithVersionStdout(t *testing.T) {
+	t.Log("only stdout should be returned so terragrunt's logging and warnings don't end up in output we parse")
+	defer fakeTerragrunt(t, "#!/bin/sh\necho '[terragrunt] running terraform' >&2\necho '{\"format_version\": \"0.1\"}'\necho 'Warning: deprecated' >&2\n")()
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	Ok(t, ioutil.WriteFile(filepath.Join(dir, TerragruntConfigFile), nil, 0600))
+	v := version.Must(version.NewVersion("0.12.0"))
+	c := &DefaultClient{defaultVersion: v, terraformPluginCacheDir: dir, terragruntInstalled: true}
+
+	out, err := c.RunCommandWithVersionStdout(logging.NewNoopLogger(), dir, []string{"show", "-json", "plan.tfplan"}, v, "default")
+	Ok(t, err)
+	Equals(t, "{\"format_version\": \"0.1\"}\n", out)
+
+	t.Log("RunCommandWithVersion should still combine them")
+	out, err = c.RunCommandWithVersion(logging.NewNoopLogger(), dir, []string{"show", "-json", "plan.tfplan"}, v, "default")
+	Ok(t, err)
+	Assert(t, strings.Contains(out, "[terragrunt] running terraform"), "exp stderr in %q", out)
+}
+
+func TestRunCommandWithVersionStdout_Error(t *testing.T) {
+	t.Log("if the command fails its stderr should be in the error")
+	defer fakeTerragrunt(t, "#!/bin/sh\necho 'partial'\necho 'Error: no plan file' >&2\nexit 1\n")()
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	Ok(t, ioutil.WriteFile(filepath.Join(dir, TerragruntConfigFile), nil, 0600))
+	v := version.Must(version.NewVersion("0.12.0"))
+	c := &DefaultClient{defaultVersion: v, terraformPluginCacheDir: dir, terragruntInstalled: true}
+
+	_, err = c.RunCommandWithVersionStdout(logging.NewNoopLogger(), dir, []string{"show", "-json", "plan.tfplan"}, v, "default")
+	Assert(t, err != nil, "exp err")
+	Assert(t, strings.Contains(err.Error(), "Error: no plan file"), "exp stderr in %q", err)
+}
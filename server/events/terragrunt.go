@@ -0,0 +1,234 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/terraform"
+)
+
+// terragruntConfig is the parts of a terragrunt.hcl file that determine
+// which changes affect it. All paths are relative to the repo root.
+type terragruntConfig struct {
+	// Includes are the config files it includes.
+	Includes []string
+	// Dependencies are the dirs of the projects it depends on.
+	Dependencies []string
+	// Source is the dir of its terraform source if it's in the repo. If its
+	// source is remote, it's empty.
+	Source string
+}
+
+// We don't parse terragrunt.hcl files as HCL since they use functions which
+// our HCL parser doesn't support. Instead we look for the few attributes we
+// need.
+var (
+	includeBlockRegex      = regexp.MustCompile(`(?s)\binclude(?:\s+"[^"]*")?\s*\{[^}]*?\bpath\s*=\s*([^\n]+)`)
+	findInParentRegex      = regexp.MustCompile(`find_in_parent_folders\(\s*(?:"([^"]*)")?\s*\)`)
+	dependencyBlockRegex   = regexp.MustCompile(`(?s)\bdependency\s+"[^"]*"\s*\{[^}]*?\bconfig_path\s*=\s*"([^"]+)"`)
+	dependenciesBlockRegex = regexp.MustCompile(`(?s)\bdependencies\s*\{[^}]*?\bpaths\s*=\s*\[([^\]]*)\]`)
+	terraformSourceRegex   = regexp.MustCompile(`(?s)\bterraform\s*\{[^}]*?\bsource\s*=\s*"([^"]+)"`)
+	quotedStringRegex      = regexp.MustCompile(`"([^"]*)"`)
+	commentLineRegex       = regexp.MustCompile(`(?m)^\s*(#|//).*$`)
+)
+
+// readTerragruntConfig reads the terragrunt.hcl file of the project in dir.
+// repoDir is the absolute path to the repo and dir is relative to it.
+func readTerragruntConfig(repoDir string, dir string) (terragruntConfig, error) {
+	var config terragruntConfig
+	raw, err := ioutil.ReadFile(filepath.Join(repoDir, dir, terraform.TerragruntConfigFile))
+	if err != nil {
+		return config, errors.Wrapf(err, "reading %s", terraform.TerragruntConfigFile)
+	}
+	contents := commentLineRegex.ReplaceAllString(string(raw), "")
+
+	for _, match := range includeBlockRegex.FindAllStringSubmatch(contents, -1) {
+		value := match[1]
+		if parent := findInParentRegex.FindStringSubmatch(value); parent != nil {
+			name := parent[1]
+			if name == "" {
+				name = terraform.TerragruntConfigFile
+			}
+			if included := findInParentFolders(repoDir, dir, name); included != "" {
+				config.Includes = append(config.Includes, included)
+			}
+		} else if quoted := quotedStringRegex.FindStringSubmatch(value); quoted != nil {
+			if included := repoRelative(dir, quoted[1]); included != "" {
+				config.Includes = append(config.Includes, included)
+			}
+		}
+	}
+	for _, match := range dependencyBlockRegex.FindAllStringSubmatch(contents, -1) {
+		if dep := repoRelative(dir, match[1]); dep != "" {
+			config.Dependencies = append(config.Dependencies, dep)
+		}
+	}
+	for _, match := range dependenciesBlockRegex.FindAllStringSubmatch(contents, -1) {
+		for _, quoted := range quotedStringRegex.FindAllStringSubmatch(match[1], -1) {
+			if dep := repoRelative(dir, quoted[1]); dep != "" {
+				config.Dependencies = append(config.Dependencies, dep)
+			}
+		}
+	}
+	if match := terraformSourceRegex.FindStringSubmatch(contents); match != nil {
+		source := match[1]
+		// A // separates the dir to download from the subdir to use. We
+		// download the whole dir so changes anywhere in it count.
+		if i := strings.Index(source, "//"); i != -1 {
+			source = source[:i]
+		}
+		if strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../") {
+			config.Source = repoRelative(dir, source)
+		}
+	}
+	return config, nil
+}
+
+// findInParentFolders mimics terragrunt's find_in_parent_folders function. It
+// returns the path to the closest file named name in the parents of dir, or
+// an empty string if there's none in the repo.
+func findInParentFolders(repoDir string, dir string, name string) string {
+	for dir != "." {
+		dir = path.Dir(dir)
+		candidate := path.Join(dir, name)
+		if _, err := os.Stat(filepath.Join(repoDir, candidate)); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// repoRelative returns p, which is relative to dir, relative to the repo
+// root. It returns an empty string if p uses interpolation, is absolute or
+// is outside the repo since we can't tell if they were modified.
+func repoRelative(dir string, p string) string {
+	if strings.Contains(p, "${") || path.IsAbs(p) {
+		return ""
+	}
+	joined := path.Join(dir, p)
+	if joined == ".." || strings.HasPrefix(joined, "../") {
+		return ""
+	}
+	return joined
+}
+
+// findTerragruntConfigs returns the terragrunt configs in repoDir, by the
+// dir they're in relative to repoDir.
+func findTerragruntConfigs(repoDir string) (map[string]terragruntConfig, error) {
+	configs := make(map[string]terragruntConfig)
+	err := filepath.Walk(repoDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			switch info.Name() {
+			case ".git", ".terraform", ".terragrunt-cache":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() != terraform.TerragruntConfigFile {
+			return nil
+		}
+		rel, err := filepath.Rel(repoDir, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		dir := filepath.ToSlash(rel)
+		config, err := readTerragruntConfig(repoDir, dir)
+		if err != nil {
+			return err
+		}
+		configs[dir] = config
+		return nil
+	})
+	return configs, err
+}
+
+// affectedTerragruntProjects returns the dirs of the terragrunt projects in
+// configs that modifiedFiles affect, sorted. A project is affected if a file
+// in its dir, a config it includes or its local source is modified, or if a
+// project it depends on is affected. Configs that are only included by other
+// configs aren't projects.
+func affectedTerragruntProjects(configs map[string]terragruntConfig, modifiedFiles []string) []string {
+	included := make(map[string]bool)
+	for _, config := range configs {
+		for _, include := range config.Includes {
+			included[include] = true
+		}
+	}
+
+	affected := make(map[string]bool)
+	for dir, config := range configs {
+		if included[path.Join(dir, terraform.TerragruntConfigFile)] {
+			continue
+		}
+		for _, file := range modifiedFiles {
+			if terragruntFileAffects(dir, config, file) {
+				affected[dir] = true
+				break
+			}
+		}
+	}
+
+	// Projects whose dependencies are affected are also affected. Keep
+	// going until nothing changes to handle chains of dependencies.
+	for changed := true; changed; {
+		changed = false
+		for dir, config := range configs {
+			if affected[dir] || included[path.Join(dir, terraform.TerragruntConfigFile)] {
+				continue
+			}
+			for _, dep := range config.Dependencies {
+				if affected[dep] {
+					affected[dir] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	var dirs []string
+	for dir := range affected {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+func terragruntFileAffects(dir string, config terragruntConfig, file string) bool {
+	if path.Dir(file) == dir {
+		return true
+	}
+	for _, include := range config.Includes {
+		if file == include {
+			return true
+		}
+	}
+	return config.Source != "" && isInDir(file, config.Source)
+}
+
+// isInDir returns true if file is in dir or its subdirs.
+func isInDir(file string, dir string) bool {
+	return dir == "." || strings.HasPrefix(file, dir+"/")
+}
@@ -0,0 +1,139 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestReadTerragruntConfig(t *testing.T) {
+	cases := []struct {
+		description string
+		config      string
+		exp         terragruntConfig
+	}{
+		{
+			"include with find_in_parent_folders",
+			`include {
+  path = find_in_parent_folders()
+}`,
+			terragruntConfig{Includes: []string{"terragrunt.hcl"}},
+		},
+		{
+			"labelled include with find_in_parent_folders of another file",
+			`include "common" {
+  path   = find_in_parent_folders( "common.hcl" )
+  expose = true
+}`,
+			terragruntConfig{Includes: []string{"live/common.hcl"}},
+		},
+		{
+			"include with a relative path",
+			`include {
+  path = "../../terragrunt.hcl"
+}`,
+			terragruntConfig{Includes: []string{"terragrunt.hcl"}},
+		},
+		{
+			"dependency blocks",
+			`dependency "vpc" {
+  config_path = "../vpc"
+}
+
+dependency "db" {
+  config_path  = "../db"
+  mock_outputs = { id = "mock" }
+}`,
+			terragruntConfig{Dependencies: []string{"live/vpc", "live/db"}},
+		},
+		{
+			"dependencies block",
+			`dependencies {
+  paths = ["../vpc",
+           "../db"]
+}`,
+			terragruntConfig{Dependencies: []string{"live/vpc", "live/db"}},
+		},
+		{
+			"commented out blocks are ignored",
+			`# dependency "vpc" {
+#   config_path = "../vpc"
+# }
+// include {
+//   path = find_in_parent_folders()
+// }`,
+			terragruntConfig{},
+		},
+		{
+			"paths that are interpolated, absolute or outside the repo are ignored",
+			`dependencies {
+  paths = ["${get_terragrunt_dir()}/../vpc", "/abs/db", "../../../outside"]
+}`,
+			terragruntConfig{},
+		},
+		{
+			"local terraform source with a subdir",
+			`terraform {
+  source = "../../modules//vpc"
+}`,
+			terragruntConfig{Source: "modules"},
+		},
+		{
+			"remote terraform source",
+			`terraform {
+  source = "git::git@github.com:acme/modules.git//vpc?ref=v0.1.0"
+}`,
+			terragruntConfig{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			repoDir, err := ioutil.TempDir("", "")
+			Ok(t, err)
+			defer os.RemoveAll(repoDir) // nolint: errcheck
+			Ok(t, os.MkdirAll(filepath.Join(repoDir, "live", "app"), 0700))
+			Ok(t, ioutil.WriteFile(filepath.Join(repoDir, "terragrunt.hcl"), nil, 0600))
+			Ok(t, ioutil.WriteFile(filepath.Join(repoDir, "live", "common.hcl"), nil, 0600))
+			Ok(t, ioutil.WriteFile(filepath.Join(repoDir, "live", "app", "terragrunt.hcl"), []byte(c.config), 0600))
+
+			config, err := readTerragruntConfig(repoDir, "live/app")
+			Ok(t, err)
+			Equals(t, c.exp, config)
+		})
+	}
+}
+
+func TestFindInParentFolders(t *testing.T) {
+	repoDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(repoDir) // nolint: errcheck
+	Ok(t, os.MkdirAll(filepath.Join(repoDir, "live", "prod", "app"), 0700))
+	Ok(t, ioutil.WriteFile(filepath.Join(repoDir, "terragrunt.hcl"), nil, 0600))
+	Ok(t, ioutil.WriteFile(filepath.Join(repoDir, "live", "terragrunt.hcl"), nil, 0600))
+	Ok(t, ioutil.WriteFile(filepath.Join(repoDir, "live", "prod", "app", "terragrunt.hcl"), nil, 0600))
+
+	t.Log("should find the closest parent, not the dir itself")
+	Equals(t, "live/terragrunt.hcl", findInParentFolders(repoDir, "live/prod/app", "terragrunt.hcl"))
+	Equals(t, "terragrunt.hcl", findInParentFolders(repoDir, "live", "terragrunt.hcl"))
+
+	t.Log("should return an empty string if there's none in the repo")
+	Equals(t, "", findInParentFolders(repoDir, "live/prod/app", "common.hcl"))
+	Equals(t, "", findInParentFolders(repoDir, ".", "terragrunt.hcl"))
+}
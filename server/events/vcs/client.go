@@ -24,5 +24,8 @@ type Client interface {
 	GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error)
 	CreateComment(repo models.Repo, pullNum int, comment string) error
 	PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error)
-	UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, description string) error
+	// UpdateStatus sets the commit status of pull's head commit. statusContext
+	// identifies the status so that different statuses don't overwrite each
+	// other, ex. DefaultStatusContext.
+	UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, statusContext string, description string) error
 }
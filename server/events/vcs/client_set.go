@@ -0,0 +1,67 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package vcs
+
+import (
+	"fmt"
+
+	"github.com/google/go-github/github"
+	"github.com/lkysow/go-gitlab"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// GithubClientSet holds a client for each GitHub install we support, keyed by
+// hostname. Like DefaultClientProxy, if there's only one client it's used for
+// every repo.
+type GithubClientSet map[string]*GithubClient
+
+// GetPullRequest returns the pull request using the client for repo's host.
+func (s GithubClientSet) GetPullRequest(repo models.Repo, num int) (*github.PullRequest, error) {
+	client, err := s.client(repo)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetPullRequest(repo, num)
+}
+
+// CreateCheckRun creates the check run using the client for repo's host.
+func (s GithubClientSet) CreateCheckRun(repo models.Repo, pull models.PullRequest, run CheckRun) error {
+	client, err := s.client(repo)
+	if err != nil {
+		return err
+	}
+	return client.CreateCheckRun(repo, pull, run)
+}
+
+func (s GithubClientSet) client(repo models.Repo) (*GithubClient, error) {
+	hostname, ok := InstallHostname(s, repo.Hostname)
+	if !ok {
+		return nil, fmt.Errorf("Atlantis was not configured to support repos from Github at %s", repo.Hostname)
+	}
+	return s[hostname], nil
+}
+
+// GitlabClientSet holds a client for each GitLab install we support, keyed by
+// hostname. Like DefaultClientProxy, if there's only one client it's used for
+// every repo.
+type GitlabClientSet map[string]*GitlabClient
+
+// GetMergeRequest returns the merge request using the client for repo's host.
+func (s GitlabClientSet) GetMergeRequest(repo models.Repo, pullNum int) (*gitlab.MergeRequest, error) {
+	hostname, ok := InstallHostname(s, repo.Hostname)
+	if !ok {
+		return nil, fmt.Errorf("Atlantis was not configured to support repos from Gitlab at %s", repo.Hostname)
+	}
+	return s[hostname].GetMergeRequest(repo.FullName, pullNum)
+}
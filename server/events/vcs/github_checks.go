@@ -0,0 +1,79 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package vcs
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+)
+
+// checksMediaType is the media type needed to use the GitHub Checks API.
+const checksMediaType = "application/vnd.github.antiope-preview+json"
+
+// CheckRun is a GitHub check run. See
+// https://developer.github.com/v3/checks/runs/#create-a-check-run.
+type CheckRun struct {
+	Name    string `json:"name"`
+	HeadSHA string `json:"head_sha"`
+	// ExternalID is sent back to us when one of Actions is requested.
+	ExternalID string `json:"external_id,omitempty"`
+	Status     string `json:"status"`
+	// Conclusion is one of success, failure, neutral, cancelled, timed_out
+	// or action_required.
+	Conclusion string           `json:"conclusion"`
+	Output     CheckRunOutput   `json:"output"`
+	Actions    []CheckRunAction `json:"actions,omitempty"`
+}
+
+// CheckRunOutput is what's shown on the check run's page.
+type CheckRunOutput struct {
+	Title       string               `json:"title"`
+	Summary     string               `json:"summary"`
+	Text        string               `json:"text,omitempty"`
+	Annotations []CheckRunAnnotation `json:"annotations,omitempty"`
+}
+
+// CheckRunAnnotation points at a line in a file of the pull request.
+type CheckRunAnnotation struct {
+	// Path is relative to the repo root.
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	// Level is one of notice, warning or failure.
+	Level   string `json:"annotation_level"`
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message"`
+}
+
+// CheckRunAction is a button on the check run that sends us a check_run
+// event with its Identifier when clicked.
+type CheckRunAction struct {
+	Label       string `json:"label"`
+	Description string `json:"description"`
+	Identifier  string `json:"identifier"`
+}
+
+// CreateCheckRun creates the check run on the pull request's head commit.
+func (g *GithubClient) CreateCheckRun(repo models.Repo, pull models.PullRequest, run CheckRun) error {
+	run.HeadSHA = pull.HeadCommit
+	req, err := g.client.NewRequest("POST", fmt.Sprintf("repos/%s/%s/check-runs", repo.Owner, repo.Name), run)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	req.Header.Set("Accept", checksMediaType)
+	_, err = g.client.Do(g.ctx, req, nil)
+	return err
+}
@@ -15,10 +15,8 @@ package vcs
 
 import (
 	"context"
-	"fmt"
 	"math"
 	"net/url"
-	"strings"
 
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
@@ -31,18 +29,19 @@ type GithubClient struct {
 	ctx    context.Context
 }
 
-// NewGithubClient returns a valid GitHub client.
-func NewGithubClient(hostname string, user string, pass string) (*GithubClient, error) {
-	tp := github.BasicAuthTransport{
-		Username: strings.TrimSpace(user),
-		Password: strings.TrimSpace(pass),
+// NewGithubClient returns a valid GitHub client that authenticates using
+// credentials.
+func NewGithubClient(hostname string, credentials GithubCredentials) (*GithubClient, error) {
+	httpClient, err := credentials.Client()
+	if err != nil {
+		return nil, errors.Wrap(err, "creating http client")
 	}
-	client := github.NewClient(tp.Client())
+	client := github.NewClient(httpClient)
 	// If we're using github.com then we don't need to do any additional configuration
 	// for the client. It we're using Github Enterprise, then we need to manually
 	// set the base url for the API.
 	if hostname != "github.com" {
-		baseURL := fmt.Sprintf("https://%s/api/v3/", hostname)
+		baseURL := githubAPIURL(hostname)
 		base, err := url.Parse(baseURL)
 		if err != nil {
 			return nil, errors.Wrapf(err, "Invalid github hostname trying to parse %s", baseURL)
@@ -122,8 +121,7 @@ func (g *GithubClient) GetPullRequest(repo models.Repo, num int) (*github.PullRe
 
 // UpdateStatus updates the status badge on the pull request.
 // See https://github.com/blog/1227-commit-status-api.
-func (g *GithubClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, description string) error {
-	const statusContext = "Atlantis"
+func (g *GithubClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, statusContext string, description string) error {
 	ghState := "error"
 	switch state {
 	case Pending:
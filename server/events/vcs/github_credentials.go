@@ -0,0 +1,335 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package vcs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+)
+
+// githubAppAccept is the media type required by the GitHub Apps API.
+const githubAppAccept = "application/vnd.github.machine-man-preview+json"
+
+// githubAppTokenUser is the username GitHub expects when cloning with an
+// installation token.
+const githubAppTokenUser = "x-access-token"
+
+// tokenRefreshWindow is how long before an installation token expires that
+// we'll mint a new one. It needs to be long enough that a clone started with
+// the old token finishes.
+const tokenRefreshWindow = 5 * time.Minute
+
+// githubAppHTTPClient is used for requests authenticated as the app itself,
+// ex. to mint installation tokens. It has a timeout so a slow GitHub doesn't
+// block everyone waiting for a token forever.
+var githubAppHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// GithubCredentials handles creating http.Clients and git credentials that
+// authenticate to GitHub.
+type GithubCredentials interface {
+	// Client returns an http.Client that authenticates its requests.
+	Client() (*http.Client, error)
+	// GetUser returns the username to use in git clone urls.
+	GetUser() string
+	// GetToken returns the password to use in git clone urls. It may change
+	// between calls so it shouldn't be cached.
+	GetToken() (string, error)
+}
+
+// GithubUserCredentials authenticates as a user with a personal access token.
+type GithubUserCredentials struct {
+	User  string
+	Token string
+}
+
+// Client returns a client that uses basic auth with the user and token.
+func (c *GithubUserCredentials) Client() (*http.Client, error) {
+	tp := github.BasicAuthTransport{
+		Username: strings.TrimSpace(c.User),
+		Password: strings.TrimSpace(c.Token),
+	}
+	return tp.Client(), nil
+}
+
+// GetUser returns the user.
+func (c *GithubUserCredentials) GetUser() string {
+	return c.User
+}
+
+// GetToken returns the token.
+func (c *GithubUserCredentials) GetToken() (string, error) {
+	return c.Token, nil
+}
+
+// GithubAppCredentials authenticates as an installation of a GitHub App.
+// It mints installation tokens using the app's private key and refreshes them
+// before they expire.
+type GithubAppCredentials struct {
+	// AppID is the id of the GitHub App.
+	AppID int64
+	// InstallationID is the id of the app's installation we act as.
+	InstallationID int64
+
+	key    *rsa.PrivateKey
+	apiURL string
+	// mutex guards the fields below. It's never held while making requests.
+	mutex sync.Mutex
+	token string
+	// expiresAt is when token expires.
+	expiresAt time.Time
+	// mintErr is the error from the last attempt to mint a token, if it
+	// failed.
+	mintErr error
+	// minting is the token mint in progress, if any. Callers that need a new
+	// token while one is being minted wait for it instead of minting their
+	// own.
+	minting *tokenMint
+}
+
+// tokenMint is a request to mint an installation token. done is closed once
+// token and err are set.
+type tokenMint struct {
+	done      chan struct{}
+	token     string
+	expiresAt time.Time
+	err       error
+}
+
+// NewGithubAppCredentials returns credentials for the GitHub App with appID.
+// keyPEM is the app's PEM encoded private key. If installationID is 0, the
+// app must be installed exactly once and that installation is used.
+func NewGithubAppCredentials(hostname string, appID int64, keyPEM []byte, installationID int64) (*GithubAppCredentials, error) {
+	key, err := parseRSAPrivateKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	c := &GithubAppCredentials{
+		AppID:          appID,
+		InstallationID: installationID,
+		key:            key,
+		apiURL:         githubAPIURL(hostname),
+	}
+	if c.InstallationID == 0 {
+		if c.InstallationID, err = c.findInstallation(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Client returns a client that authenticates with an installation token.
+func (c *GithubAppCredentials) Client() (*http.Client, error) {
+	return &http.Client{Transport: &githubAppTransport{creds: c}}, nil
+}
+
+// GetUser returns the user GitHub expects for installation tokens.
+func (c *GithubAppCredentials) GetUser() string {
+	return githubAppTokenUser
+}
+
+// GetToken returns a valid installation token, minting a new one if the
+// current one is about to expire.
+func (c *GithubAppCredentials) GetToken() (string, error) {
+	c.mutex.Lock()
+	if c.token != "" && time.Until(c.expiresAt) > tokenRefreshWindow {
+		token := c.token
+		c.mutex.Unlock()
+		return token, nil
+	}
+	m := c.minting
+	if m != nil {
+		c.mutex.Unlock()
+		<-m.done
+		return m.token, m.err
+	}
+	m = &tokenMint{done: make(chan struct{})}
+	c.minting = m
+	c.mutex.Unlock()
+
+	m.token, m.expiresAt, m.err = c.mintToken()
+
+	c.mutex.Lock()
+	c.minting = nil
+	c.mintErr = m.err
+	if m.err == nil {
+		c.token = m.token
+		c.expiresAt = m.expiresAt
+	}
+	c.mutex.Unlock()
+	close(m.done)
+	return m.token, m.err
+}
+
+// CheckCredentials returns an error if the app's key can't sign a JWT, or if
+// we don't have an unexpired token because minting the last one failed. It
+// never makes requests so it's cheap enough to call on every readiness probe.
+func (c *GithubAppCredentials) CheckCredentials() error {
+	if _, err := c.jwt(time.Now()); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.mintErr != nil && time.Now().After(c.expiresAt) {
+		return c.mintErr
+	}
+	return nil
+}
+
+// mintToken creates a new installation token.
+func (c *GithubAppCredentials) mintToken() (string, time.Time, error) {
+	var resp struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	url := fmt.Sprintf("%sapp/installations/%d/access_tokens", c.apiURL, c.InstallationID)
+	if err := c.appRequest("POST", url, &resp); err != nil {
+		return "", time.Time{}, errors.Wrap(err, "creating installation token")
+	}
+	return resp.Token, resp.ExpiresAt, nil
+}
+
+// Slug returns the app's slug. The app comments as "{slug}[bot]" and can be
+// mentioned as "@{slug}".
+func (c *GithubAppCredentials) Slug() (string, error) {
+	var resp struct {
+		Slug string `json:"slug"`
+	}
+	if err := c.appRequest("GET", c.apiURL+"app", &resp); err != nil {
+		return "", errors.Wrap(err, "getting app")
+	}
+	return resp.Slug, nil
+}
+
+// findInstallation returns the id of the app's only installation.
+func (c *GithubAppCredentials) findInstallation() (int64, error) {
+	var installations []struct {
+		ID int64 `json:"id"`
+	}
+	if err := c.appRequest("GET", c.apiURL+"app/installations", &installations); err != nil {
+		return 0, errors.Wrap(err, "listing app installations")
+	}
+	if len(installations) != 1 {
+		return 0, fmt.Errorf("app %d has %d installations, must specify which one to use", c.AppID, len(installations))
+	}
+	return installations[0].ID, nil
+}
+
+// appRequest makes a request authenticated as the app itself (rather than as
+// an installation) and decodes the JSON response into v.
+func (c *GithubAppCredentials) appRequest(method string, url string, v interface{}) error {
+	jwt, err := c.jwt(time.Now())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", githubAppAccept)
+	resp, err := githubAppHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s: unexpected status %d", method, url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// jwt returns a JSON Web Token signed by the app's private key, used to
+// authenticate as the app. See
+// https://developer.github.com/apps/building-github-apps/authenticating-with-github-apps/.
+func (c *GithubAppCredentials) jwt(now time.Time) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]int64{
+		// Backdate to allow for clock drift between us and GitHub.
+		"iat": now.Add(-time.Minute).Unix(),
+		// GitHub rejects tokens that expire more than 10 minutes from now.
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": c.AppID,
+	})
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", errors.Wrap(err, "signing jwt")
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// githubAppTransport adds an installation token to each request.
+type githubAppTransport struct {
+	creds *GithubAppCredentials
+}
+
+func (t *githubAppTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.creds.GetToken()
+	if err != nil {
+		return nil, err
+	}
+	// RoundTrippers shouldn't modify the request so we make a copy.
+	req2 := new(http.Request)
+	*req2 = *req
+	req2.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		req2.Header[k] = v
+	}
+	req2.Header.Set("Authorization", "token "+token)
+	return http.DefaultTransport.RoundTrip(req2)
+}
+
+func parseRSAPrivateKey(keyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("parsing GitHub App private key: no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing GitHub App private key")
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("parsing GitHub App private key: not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// githubAPIURL returns the base url of the GitHub API for hostname. It always
+// ends in a "/".
+func githubAPIURL(hostname string) string {
+	if hostname == "github.com" {
+		return "https://api.github.com/"
+	}
+	return fmt.Sprintf("https://%s/api/v3/", hostname)
+}
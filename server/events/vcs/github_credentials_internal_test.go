@@ -0,0 +1,238 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package vcs
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestParseRSAPrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	Ok(t, err)
+
+	t.Log("should parse PKCS1 keys")
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	parsed, err := parseRSAPrivateKey(pkcs1)
+	Ok(t, err)
+	Equals(t, key.N, parsed.N)
+
+	t.Log("should parse PKCS8 keys")
+	pkcs8Bytes, err := x509.MarshalPKCS8PrivateKey(key)
+	Ok(t, err)
+	pkcs8 := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Bytes})
+	parsed, err = parseRSAPrivateKey(pkcs8)
+	Ok(t, err)
+	Equals(t, key.N, parsed.N)
+
+	t.Log("should error on non-PEM data")
+	_, err = parseRSAPrivateKey([]byte("not a key"))
+	ErrEquals(t, "parsing GitHub App private key: no PEM data found", err)
+}
+
+func TestGithubAppCredentials_JWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	Ok(t, err)
+	c := &GithubAppCredentials{AppID: 123, key: key}
+	now := time.Unix(1500000000, 0)
+
+	jwt, err := c.jwt(now)
+	Ok(t, err)
+	parts := strings.Split(jwt, ".")
+	Equals(t, 3, len(parts))
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	Ok(t, err)
+	var claims map[string]int64
+	Ok(t, json.Unmarshal(claimsJSON, &claims))
+	Equals(t, map[string]int64{
+		"iat": 1500000000 - 60,
+		"exp": 1500000000 + 9*60,
+		"iss": 123,
+	}, claims)
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	Ok(t, err)
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	Ok(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], sig))
+}
+
+func TestGithubAppCredentials_GetToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	Ok(t, err)
+
+	t.Log("should mint a new token only when the current one is about to expire")
+	minted := 0
+	expiresIn := time.Hour
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "POST", r.Method)
+		Equals(t, "/app/installations/456/access_tokens", r.URL.Path)
+		Equals(t, githubAppAccept, r.Header.Get("Accept"))
+		Assert(t, strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "), "should authenticate with a jwt")
+		minted++
+		fmt.Fprintf(w, `{"token": "token%d", "expires_at": %q}`, minted, time.Now().Add(expiresIn).Format(time.RFC3339))
+	}))
+	defer server.Close()
+	c := &GithubAppCredentials{AppID: 123, InstallationID: 456, key: key, apiURL: server.URL + "/"}
+
+	token, err := c.GetToken()
+	Ok(t, err)
+	Equals(t, "token1", token)
+	token, err = c.GetToken()
+	Ok(t, err)
+	Equals(t, "token1", token)
+
+	// Force the current token to be within the refresh window.
+	c.expiresAt = time.Now().Add(time.Minute)
+	token, err = c.GetToken()
+	Ok(t, err)
+	Equals(t, "token2", token)
+	Equals(t, 2, minted)
+}
+
+func TestGithubAppCredentials_GetTokenConcurrent(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	Ok(t, err)
+
+	t.Log("concurrent callers should share one mint and not hold the mutex while it's in progress")
+	var minted int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&minted, 1)
+		<-release
+		fmt.Fprintf(w, `{"token": "token", "expires_at": %q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+	}))
+	defer server.Close()
+	c := &GithubAppCredentials{AppID: 123, InstallationID: 456, key: key, apiURL: server.URL + "/"}
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 5)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token, err := c.GetToken()
+			Ok(t, err)
+			tokens[i] = token
+		}(i)
+	}
+	// Wait for the mint to start then check the mutex is free.
+	for atomic.LoadInt32(&minted) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	c.mutex.Lock()
+	c.mutex.Unlock() // nolint: staticcheck
+	close(release)
+	wg.Wait()
+
+	Equals(t, int32(1), atomic.LoadInt32(&minted))
+	for _, token := range tokens {
+		Equals(t, "token", token)
+	}
+}
+
+func TestGithubAppCredentials_GetTokenTimeout(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	Ok(t, err)
+
+	t.Log("minting should time out if GitHub doesn't respond")
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	defaultClient := githubAppHTTPClient
+	githubAppHTTPClient = &http.Client{Timeout: 50 * time.Millisecond}
+	defer func() { githubAppHTTPClient = defaultClient }()
+	c := &GithubAppCredentials{AppID: 123, InstallationID: 456, key: key, apiURL: server.URL + "/"}
+
+	_, err = c.GetToken()
+	Assert(t, err != nil, "exp err")
+	Assert(t, strings.Contains(err.Error(), "Client.Timeout exceeded"), "exp timeout err, got %s", err)
+}
+
+func TestGithubAppCredentials_CheckCredentials(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	Ok(t, err)
+	requests := 0
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"token": "token", "expires_at": %q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+	}))
+	defer server.Close()
+	c := &GithubAppCredentials{AppID: 123, InstallationID: 456, key: key, apiURL: server.URL + "/"}
+
+	t.Log("should pass without making a request if we haven't minted a token yet")
+	Ok(t, c.CheckCredentials())
+	Equals(t, 0, requests)
+
+	t.Log("should fail if the last mint failed")
+	_, err = c.GetToken()
+	Assert(t, err != nil, "exp err")
+	Equals(t, err, c.CheckCredentials())
+	Equals(t, 1, requests)
+
+	t.Log("should pass once a token has been minted")
+	status = http.StatusCreated
+	_, err = c.GetToken()
+	Ok(t, err)
+	Ok(t, c.CheckCredentials())
+	Equals(t, 2, requests)
+
+	t.Log("should fail if the key can't sign")
+	c.key = &rsa.PrivateKey{PublicKey: rsa.PublicKey{N: key.N, E: key.E}}
+	Assert(t, c.CheckCredentials() != nil, "exp err")
+	Equals(t, 2, requests)
+}
+
+func TestGithubAppCredentials_FindInstallation(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	Ok(t, err)
+	body := `[{"id": 1}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Equals(t, "/app/installations", r.URL.Path)
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+	c := &GithubAppCredentials{AppID: 123, key: key, apiURL: server.URL + "/"}
+
+	t.Log("should use the only installation")
+	id, err := c.findInstallation()
+	Ok(t, err)
+	Equals(t, int64(1), id)
+
+	t.Log("should error if there's more than one installation")
+	body = `[{"id": 1}, {"id": 2}]`
+	_, err = c.findInstallation()
+	ErrEquals(t, "app 123 has 2 installations, must specify which one to use", err)
+}
@@ -25,6 +25,12 @@ type GitlabClient struct {
 	Client *gitlab.Client
 }
 
+// GitlabCredentials are the credentials of the GitLab user Atlantis runs as.
+type GitlabCredentials struct {
+	User  string
+	Token string
+}
+
 // GetModifiedFiles returns the names of files that were modified in the merge request.
 // The names include the path to the file from the repo root, ex. parent/child/file.txt.
 func (g *GitlabClient) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
@@ -79,9 +85,7 @@ func (g *GitlabClient) PullIsApproved(repo models.Repo, pull models.PullRequest)
 }
 
 // UpdateStatus updates the build status of a commit.
-func (g *GitlabClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, description string) error {
-	const statusContext = "Atlantis"
-
+func (g *GitlabClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, statusContext string, description string) error {
 	gitlabState := gitlab.Failed
 	switch state {
 	case Pending:
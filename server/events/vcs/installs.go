@@ -0,0 +1,38 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package vcs
+
+import (
+	"reflect"
+)
+
+// InstallHostname returns the hostname of the install that repos on hostname
+// belong to. installs must be a map keyed by the hostname of each install of
+// a VCS host we support, ex. the clients or webhook secrets for each GitHub
+// install.
+//
+// If hostname isn't one of the installs but there's only one, that install
+// is used for every repo so single install setups keep working even if the
+// hostname doesn't match exactly, ex. if GitLab is configured with a
+// different external URL. ok is false if there's no install for hostname.
+func InstallHostname(installs interface{}, hostname string) (installHostname string, ok bool) {
+	m := reflect.ValueOf(installs)
+	if m.MapIndex(reflect.ValueOf(hostname)).IsValid() {
+		return hostname, true
+	}
+	if m.Len() == 1 {
+		return m.MapKeys()[0].String(), true
+	}
+	return hostname, false
+}
@@ -0,0 +1,45 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package vcs_test
+
+import (
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestInstallHostname(t *testing.T) {
+	t.Log("should use the install for the hostname if there is one")
+	multiple := map[string][]byte{"github.com": nil, "github.mycompany.com": nil}
+	hostname, ok := vcs.InstallHostname(multiple, "github.mycompany.com")
+	Equals(t, "github.mycompany.com", hostname)
+	Equals(t, true, ok)
+
+	t.Log("should not fall back if there's more than one install")
+	hostname, ok = vcs.InstallHostname(multiple, "github.othercompany.com")
+	Equals(t, "github.othercompany.com", hostname)
+	Equals(t, false, ok)
+
+	t.Log("should fall back to the only install")
+	single := map[string]vcs.GitlabCredentials{"gitlab.mycompany.com": {}}
+	hostname, ok = vcs.InstallHostname(single, "gitlab.internal")
+	Equals(t, "gitlab.mycompany.com", hostname)
+	Equals(t, true, ok)
+
+	t.Log("should not find an install if there are none")
+	hostname, ok = vcs.InstallHostname(map[string]vcs.Client(nil), "github.com")
+	Equals(t, "github.com", hostname)
+	Equals(t, false, ok)
+}
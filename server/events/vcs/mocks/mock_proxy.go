@@ -63,8 +63,8 @@ func (mock *MockClientProxy) PullIsApproved(repo models.Repo, pull models.PullRe
 	return ret0, ret1
 }
 
-func (mock *MockClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, description string, host vcs.Host) error {
-	params := []pegomock.Param{repo, pull, state, description, host}
+func (mock *MockClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, statusContext string, description string, host vcs.Host) error {
+	params := []pegomock.Param{repo, pull, state, statusContext, description, host}
 	result := pegomock.GetGenericMockFrom(mock).Invoke("UpdateStatus", params, []reflect.Type{reflect.TypeOf((*error)(nil)).Elem()})
 	var ret0 error
 	if len(result) != 0 {
@@ -202,8 +202,8 @@ func (c *ClientProxy_PullIsApproved_OngoingVerification) GetAllCapturedArguments
 	return
 }
 
-func (verifier *VerifierClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, description string, host vcs.Host) *ClientProxy_UpdateStatus_OngoingVerification {
-	params := []pegomock.Param{repo, pull, state, description, host}
+func (verifier *VerifierClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, statusContext string, description string, host vcs.Host) *ClientProxy_UpdateStatus_OngoingVerification {
+	params := []pegomock.Param{repo, pull, state, statusContext, description, host}
 	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "UpdateStatus", params)
 	return &ClientProxy_UpdateStatus_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
 }
@@ -213,12 +213,12 @@ type ClientProxy_UpdateStatus_OngoingVerification struct {
 	methodInvocations []pegomock.MethodInvocation
 }
 
-func (c *ClientProxy_UpdateStatus_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest, vcs.CommitStatus, string, vcs.Host) {
-	repo, pull, state, description, host := c.GetAllCapturedArguments()
-	return repo[len(repo)-1], pull[len(pull)-1], state[len(state)-1], description[len(description)-1], host[len(host)-1]
+func (c *ClientProxy_UpdateStatus_OngoingVerification) GetCapturedArguments() (models.Repo, models.PullRequest, vcs.CommitStatus, string, string, vcs.Host) {
+	repo, pull, state, statusContext, description, host := c.GetAllCapturedArguments()
+	return repo[len(repo)-1], pull[len(pull)-1], state[len(state)-1], statusContext[len(statusContext)-1], description[len(description)-1], host[len(host)-1]
 }
 
-func (c *ClientProxy_UpdateStatus_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest, _param2 []vcs.CommitStatus, _param3 []string, _param4 []vcs.Host) {
+func (c *ClientProxy_UpdateStatus_OngoingVerification) GetAllCapturedArguments() (_param0 []models.Repo, _param1 []models.PullRequest, _param2 []vcs.CommitStatus, _param3 []string, _param4 []string, _param5 []vcs.Host) {
 	params := pegomock.GetGenericMockFrom(c.mock).GetInvocationParams(c.methodInvocations)
 	if len(params) > 0 {
 		_param0 = make([]models.Repo, len(params[0]))
@@ -237,9 +237,13 @@ func (c *ClientProxy_UpdateStatus_OngoingVerification) GetAllCapturedArguments()
 		for u, param := range params[3] {
 			_param3[u] = param.(string)
 		}
-		_param4 = make([]vcs.Host, len(params[4]))
+		_param4 = make([]string, len(params[4]))
 		for u, param := range params[4] {
-			_param4[u] = param.(vcs.Host)
+			_param4[u] = param.(string)
+		}
+		_param5 = make([]vcs.Host, len(params[5]))
+		for u, param := range params[5] {
+			_param5[u] = param.(vcs.Host)
 		}
 	}
 	return
@@ -24,6 +24,9 @@ import (
 // config then this client will be used which will error if it's ever called.
 type NotConfiguredVCSClient struct {
 	Host Host
+	// Hostname is the hostname of the repo we were called for. It may be
+	// empty.
+	Hostname string
 }
 
 func (a *NotConfiguredVCSClient) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
@@ -35,10 +38,14 @@ func (a *NotConfiguredVCSClient) CreateComment(repo models.Repo, pullNum int, co
 func (a *NotConfiguredVCSClient) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error) {
 	return false, a.err()
 }
-func (a *NotConfiguredVCSClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, description string) error {
+func (a *NotConfiguredVCSClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, statusContext string, description string) error {
 	return a.err()
 }
 func (a *NotConfiguredVCSClient) err() error {
+	//noinspection GoErrorStringFormat
+	if a.Hostname != "" {
+		return fmt.Errorf("Atlantis was not configured to support repos from %s at %s", a.Host.String(), a.Hostname)
+	}
 	//noinspection GoErrorStringFormat
 	return fmt.Errorf("Atlantis was not configured to support repos from %s", a.Host.String())
 }
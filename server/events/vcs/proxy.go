@@ -14,8 +14,13 @@
 package vcs
 
 import (
+	"strings"
+	"time"
+
 	"github.com/pkg/errors"
 	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/metrics"
+	"github.com/runatlantis/atlantis/server/redact"
 )
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_proxy.go ClientProxy
@@ -26,67 +31,102 @@ type ClientProxy interface {
 	GetModifiedFiles(repo models.Repo, pull models.PullRequest, host Host) ([]string, error)
 	CreateComment(repo models.Repo, pullNum int, comment string, host Host) error
 	PullIsApproved(repo models.Repo, pull models.PullRequest, host Host) (bool, error)
-	UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, description string, host Host) error
+	UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, statusContext string, description string, host Host) error
 }
 
 // DefaultClientProxy proxies calls to the correct VCS client depending on which
-// VCS host is required.
+// VCS host is required and the hostname of the repo.
 type DefaultClientProxy struct {
-	GithubClient Client
-	GitlabClient Client
+	// GithubClients maps the hostname of each GitHub install we support,
+	// ex. github.com, to its client.
+	GithubClients map[string]Client
+	// GitlabClients maps the hostname of each GitLab install we support,
+	// ex. gitlab.com, to its client.
+	GitlabClients map[string]Client
+	// Metrics records how long API calls take and whether they errored. If
+	// nil, they aren't recorded.
+	Metrics *metrics.Metrics
+	// Redactor masks secrets in comments and commit status descriptions. If
+	// nil, they're sent as is.
+	Redactor *redact.Redactor
 }
 
-func NewDefaultClientProxy(githubClient Client, gitlabClient Client) *DefaultClientProxy {
-	if githubClient == nil {
-		githubClient = &NotConfiguredVCSClient{}
-	}
-	if gitlabClient == nil {
-		gitlabClient = &NotConfiguredVCSClient{}
-	}
+// NewDefaultClientProxy returns a proxy that uses githubClients and
+// gitlabClients, which are keyed by hostname. Either can be nil if that VCS
+// host isn't supported.
+func NewDefaultClientProxy(githubClients map[string]Client, gitlabClients map[string]Client) *DefaultClientProxy {
 	return &DefaultClientProxy{
-		GitlabClient: gitlabClient,
-		GithubClient: githubClient,
+		GitlabClients: gitlabClients,
+		GithubClients: githubClients,
 	}
 }
 
 var invalidVCSErr = errors.New("Invalid VCS Host. This is a bug!")
 
 func (d *DefaultClientProxy) GetModifiedFiles(repo models.Repo, pull models.PullRequest, host Host) ([]string, error) {
-	switch host {
-	case Github:
-		return d.GithubClient.GetModifiedFiles(repo, pull)
-	case Gitlab:
-		return d.GitlabClient.GetModifiedFiles(repo, pull)
+	client, err := d.client(repo, host)
+	if err != nil {
+		return nil, err
 	}
-	return nil, invalidVCSErr
+	start := time.Now()
+	files, err := client.GetModifiedFiles(repo, pull)
+	d.observe(repo, host, "GetModifiedFiles", start, err)
+	return files, err
 }
 
 func (d *DefaultClientProxy) CreateComment(repo models.Repo, pullNum int, comment string, host Host) error {
-	switch host {
-	case Github:
-		return d.GithubClient.CreateComment(repo, pullNum, comment)
-	case Gitlab:
-		return d.GitlabClient.CreateComment(repo, pullNum, comment)
+	client, err := d.client(repo, host)
+	if err != nil {
+		return err
 	}
-	return invalidVCSErr
+	start := time.Now()
+	err = client.CreateComment(repo, pullNum, d.Redactor.Redact(comment))
+	d.observe(repo, host, "CreateComment", start, err)
+	return err
 }
 
 func (d *DefaultClientProxy) PullIsApproved(repo models.Repo, pull models.PullRequest, host Host) (bool, error) {
-	switch host {
-	case Github:
-		return d.GithubClient.PullIsApproved(repo, pull)
-	case Gitlab:
-		return d.GitlabClient.PullIsApproved(repo, pull)
+	client, err := d.client(repo, host)
+	if err != nil {
+		return false, err
 	}
-	return false, invalidVCSErr
+	start := time.Now()
+	approved, err := client.PullIsApproved(repo, pull)
+	d.observe(repo, host, "PullIsApproved", start, err)
+	return approved, err
 }
 
-func (d *DefaultClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, description string, host Host) error {
+func (d *DefaultClientProxy) UpdateStatus(repo models.Repo, pull models.PullRequest, state CommitStatus, statusContext string, description string, host Host) error {
+	client, err := d.client(repo, host)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = client.UpdateStatus(repo, pull, state, statusContext, d.Redactor.Redact(description))
+	d.observe(repo, host, "UpdateStatus", start, err)
+	return err
+}
+
+// observe records how long an API call to method that began at start took
+// and whether it errored.
+func (d *DefaultClientProxy) observe(repo models.Repo, host Host, method string, start time.Time, err error) {
+	d.Metrics.VCSCalled(strings.ToLower(host.String()), repo.Hostname, method, time.Since(start), err)
+}
+
+// client returns the client for repo's hostname.
+func (d *DefaultClientProxy) client(repo models.Repo, host Host) (Client, error) {
+	var clients map[string]Client
 	switch host {
 	case Github:
-		return d.GithubClient.UpdateStatus(repo, pull, state, description)
+		clients = d.GithubClients
 	case Gitlab:
-		return d.GitlabClient.UpdateStatus(repo, pull, state, description)
+		clients = d.GitlabClients
+	default:
+		return nil, invalidVCSErr
+	}
+	hostname, ok := InstallHostname(clients, repo.Hostname)
+	if !ok {
+		return &NotConfiguredVCSClient{Host: host, Hostname: repo.Hostname}, nil
 	}
-	return invalidVCSErr
+	return clients[hostname], nil
 }
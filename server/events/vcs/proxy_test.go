@@ -0,0 +1,116 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package vcs_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	"github.com/runatlantis/atlantis/server/metrics"
+	"github.com/runatlantis/atlantis/server/redact"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// fakeClient records the repos it's called for and what it was sent.
+type fakeClient struct {
+	commentedOn  []string
+	comments     []string
+	descriptions []string
+}
+
+func (f *fakeClient) GetModifiedFiles(repo models.Repo, pull models.PullRequest) ([]string, error) {
+	return nil, nil
+}
+
+func (f *fakeClient) CreateComment(repo models.Repo, pullNum int, comment string) error {
+	f.commentedOn = append(f.commentedOn, repo.Hostname+"/"+repo.FullName)
+	f.comments = append(f.comments, comment)
+	return nil
+}
+
+func (f *fakeClient) PullIsApproved(repo models.Repo, pull models.PullRequest) (bool, error) {
+	return false, nil
+}
+
+func (f *fakeClient) UpdateStatus(repo models.Repo, pull models.PullRequest, state vcs.CommitStatus, statusContext string, description string) error {
+	f.descriptions = append(f.descriptions, description)
+	return nil
+}
+
+func TestDefaultClientProxy_MultipleHosts(t *testing.T) {
+	t.Log("should use the client for the repo's hostname")
+	githubCom := &fakeClient{}
+	ghe := &fakeClient{}
+	p := vcs.NewDefaultClientProxy(map[string]vcs.Client{
+		"github.com":           githubCom,
+		"github.mycompany.com": ghe,
+	}, nil)
+	repo := models.Repo{FullName: "owner/repo", Hostname: "github.mycompany.com"}
+
+	Ok(t, p.CreateComment(repo, 1, "comment", vcs.Github))
+	Equals(t, []string{"github.mycompany.com/owner/repo"}, ghe.commentedOn)
+	Equals(t, 0, len(githubCom.commentedOn))
+
+	t.Log("should error if the repo's hostname isn't configured")
+	repo.Hostname = "github.other.com"
+	err := p.CreateComment(repo, 1, "comment", vcs.Github)
+	ErrEquals(t, "Atlantis was not configured to support repos from Github at github.other.com", err)
+}
+
+func TestDefaultClientProxy_Metrics(t *testing.T) {
+	t.Log("should record each API call")
+	p := vcs.NewDefaultClientProxy(map[string]vcs.Client{"github.com": &fakeClient{}}, nil)
+	p.Metrics = metrics.New()
+	repo := models.Repo{FullName: "owner/repo", Hostname: "github.com"}
+	Ok(t, p.CreateComment(repo, 1, "comment", vcs.Github))
+
+	var buf bytes.Buffer
+	Ok(t, p.Metrics.Registry.Write(&buf))
+	exp := `atlantis_vcs_api_duration_seconds_count{vcs="github",hostname="github.com",method="CreateComment"} 1`
+	Assert(t, strings.Contains(buf.String(), exp), "exp %q in output:\n%s", exp, buf.String())
+	Assert(t, !strings.Contains(buf.String(), "atlantis_vcs_api_errors_total{"), "exp no errors recorded")
+}
+
+func TestDefaultClientProxy_Redactor(t *testing.T) {
+	t.Log("should mask secrets in comments and status descriptions")
+	client := &fakeClient{}
+	p := vcs.NewDefaultClientProxy(map[string]vcs.Client{"github.com": client}, nil)
+	var err error
+	p.Redactor, err = redact.New(nil)
+	Ok(t, err)
+	p.Redactor.AddSecret("hunter2")
+	repo := models.Repo{FullName: "owner/repo", Hostname: "github.com"}
+
+	Ok(t, p.CreateComment(repo, 1, "password=hunter2", vcs.Github))
+	Ok(t, p.UpdateStatus(repo, models.PullRequest{}, vcs.Failed, "atlantis/plan", "hunter2 failed", vcs.Github))
+	Equals(t, []string{"password=[REDACTED]"}, client.comments)
+	Equals(t, []string{"[REDACTED] failed"}, client.descriptions)
+}
+
+func TestDefaultClientProxy_SingleHost(t *testing.T) {
+	t.Log("should use the only client for a host no matter the repo's hostname")
+	gitlab := &fakeClient{}
+	p := vcs.NewDefaultClientProxy(nil, map[string]vcs.Client{"gitlab.mycompany.com": gitlab})
+	repo := models.Repo{FullName: "owner/repo", Hostname: "gitlab.internal"}
+
+	Ok(t, p.CreateComment(repo, 1, "comment", vcs.Gitlab))
+	Equals(t, []string{"gitlab.internal/owner/repo"}, gitlab.commentedOn)
+
+	t.Log("should error if the host isn't configured at all")
+	err := p.CreateComment(repo, 1, "comment", vcs.Github)
+	ErrEquals(t, "Atlantis was not configured to support repos from Github at gitlab.internal", err)
+}
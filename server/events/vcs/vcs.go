@@ -13,6 +13,8 @@
 //
 package vcs
 
+import "fmt"
+
 type Host int
 
 const (
@@ -30,6 +32,21 @@ func (h Host) String() string {
 	return "<missing String() implementation>"
 }
 
+// DefaultStatusContext is the context of the commit status we set for the
+// result of plans and applies.
+const DefaultStatusContext = "Atlantis"
+
+// PolicyCheckStatusContext is the context of the commit status we set for the
+// result of policy checks.
+const PolicyCheckStatusContext = "Atlantis/policy-check"
+
+// ProjectStatusContext returns the context of the commit status we set for
+// the result of running command for the project at path in workspace, ex.
+// "atlantis/plan: vpc/default".
+func ProjectStatusContext(command string, path string, workspace string) string {
+	return fmt.Sprintf("atlantis/%s: %s/%s", command, path, workspace)
+}
+
 // CommitStatus is the result of executing an Atlantis command for the commit.
 // In Github the options are: error, failure, pending, success.
 // In Gitlab the options are: failed, canceled, pending, running, success.
@@ -0,0 +1,254 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package webhooks
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/locking"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+const EmailKind = "email"
+
+// defaultEmailSubjects are the subject templates of each event's emails if
+// the webhook doesn't set its own.
+var defaultEmailSubjects = map[string]string{
+	ApplyEvent:      "Atlantis apply {{if .Success}}succeeded{{else}}failed{{end}}: {{.Repo.FullName}} {{.Path}} in {{.Workspace}}",
+	ApplyStartEvent: "Atlantis apply started: {{.Repo.FullName}} {{.Path}} in {{.Workspace}}",
+	DriftEvent:      "Atlantis detected drift: {{.Repo.FullName}} {{.Path}} in {{.Workspace}}",
+	LockEvent:       "Atlantis locked {{.Repo.FullName}} {{.Path}} in {{.Workspace}}",
+	PlanEvent:       "Atlantis plan {{if .Success}}succeeded{{else}}failed{{end}}: {{.Repo.FullName}} {{.Path}} in {{.Workspace}}",
+	UnlockEvent:     "Atlantis unlocked {{.Repo.FullName}} {{.Path}} in {{.Workspace}}",
+}
+
+// defaultEmailBody is the body template of emails if the webhook doesn't
+// set its own.
+const defaultEmailBody = `Repo:      {{.Repo.FullName}}
+Path:      {{.Path}}
+Workspace: {{.Workspace}}
+{{- if .Pull.Num}}
+Pull:      {{.Pull.URL}}
+{{- end}}
+{{- if .User}}
+User:      {{.User}}
+{{- end}}
+{{- if .Reason}}
+Reason:    {{.Reason}}
+{{- end}}
+{{- if .Summary}}
+
+{{.Summary}}
+{{- end}}
+{{- if .LockURL}}
+
+Lock: {{.LockURL}}
+{{- end}}
+`
+
+// EmailData is what email subject and body templates are executed with.
+type EmailData struct {
+	// Event is the event the email is for, ex. apply.
+	Event string
+	Repo  models.Repo
+	// Pull is the pull request the event happened on. It's empty for drift
+	// emails.
+	Pull models.PullRequest
+	// User is the username of the user that ran the command or created the
+	// lock.
+	User      string
+	Path      string
+	Workspace string
+	// Success is whether the plan or apply succeeded.
+	Success bool
+	// Summary is the summary line of the plan or apply, ex.
+	// "Plan: 1 to add, 0 to change, 0 to destroy."
+	Summary string
+	// Reason is why the lock was released in unlock emails.
+	Reason string
+	// LockURL is the URL of the lock's page in the Atlantis UI. It's empty
+	// if the project isn't locked.
+	LockURL string
+}
+
+// EmailWebhook sends webhooks by email.
+type EmailWebhook struct {
+	Client         EmailClient
+	WorkspaceRegex *regexp.Regexp
+	// RepoRegex is matched against the repo's full name. If nil, webhooks
+	// are sent for all repos.
+	RepoRegex *regexp.Regexp
+	// To are the addresses emails are sent to.
+	To      []string
+	Subject *template.Template
+	Body    *template.Template
+	// LockURL returns the URL of the page for the lock with id. If nil,
+	// emails don't link to locks.
+	LockURL func(id string) (url string)
+}
+
+// NewEmail returns an EmailWebhook for event. subject and body are
+// text/template templates that override the default ones if they're set.
+func NewEmail(event string, workspaceRegex *regexp.Regexp, repoRegex *regexp.Regexp, to []string, subject string, body string, client EmailClient) (*EmailWebhook, error) {
+	if len(to) == 0 {
+		return nil, errors.New("must specify \"to\" if using a webhook of \"kind: email\"")
+	}
+	for _, addr := range to {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return nil, errors.Wrapf(err, "parsing \"to\" address %q", addr)
+		}
+	}
+	if subject == "" {
+		subject = defaultEmailSubjects[event]
+	}
+	if body == "" {
+		body = defaultEmailBody
+	}
+	subjectTmpl, err := template.New("subject").Parse(subject)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing email \"subject\"")
+	}
+	bodyTmpl, err := template.New("body").Parse(body)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing email \"body\"")
+	}
+	return &EmailWebhook{
+		Client:         client,
+		WorkspaceRegex: workspaceRegex,
+		RepoRegex:      repoRegex,
+		To:             to,
+		Subject:        subjectTmpl,
+		Body:           bodyTmpl,
+	}, nil
+}
+
+// Send emails applyResult if its workspace and repo match.
+func (e *EmailWebhook) Send(log *logging.SimpleLogger, applyResult ApplyResult) error {
+	return e.send(EmailData{
+		Event:     ApplyEvent,
+		Repo:      applyResult.Repo,
+		Pull:      applyResult.Pull,
+		User:      applyResult.User.Username,
+		Path:      applyResult.Path,
+		Workspace: applyResult.Workspace,
+		Success:   applyResult.Success,
+		Summary:   applyResult.Summary,
+		LockURL:   e.lockURL(applyResult.Repo, applyResult.Path, applyResult.Workspace),
+	})
+}
+
+// SendApplyStart emails applyStart if its workspace and repo match.
+func (e *EmailWebhook) SendApplyStart(log *logging.SimpleLogger, applyStart ApplyStart) error {
+	return e.send(EmailData{
+		Event:     ApplyStartEvent,
+		Repo:      applyStart.Repo,
+		Pull:      applyStart.Pull,
+		User:      applyStart.User.Username,
+		Path:      applyStart.Path,
+		Workspace: applyStart.Workspace,
+		LockURL:   e.lockURL(applyStart.Repo, applyStart.Path, applyStart.Workspace),
+	})
+}
+
+// SendPlan emails planResult if its workspace and repo match. Failed plans
+// don't link to their lock since it's released when planning fails.
+func (e *EmailWebhook) SendPlan(log *logging.SimpleLogger, planResult PlanResult) error {
+	data := EmailData{
+		Event:     PlanEvent,
+		Repo:      planResult.Repo,
+		Pull:      planResult.Pull,
+		User:      planResult.User.Username,
+		Path:      planResult.Path,
+		Workspace: planResult.Workspace,
+		Success:   planResult.Success,
+		Summary:   planResult.Summary,
+	}
+	if planResult.Success {
+		data.LockURL = e.lockURL(planResult.Repo, planResult.Path, planResult.Workspace)
+	}
+	return e.send(data)
+}
+
+// SendLock emails lockResult if its workspace and repo match.
+func (e *EmailWebhook) SendLock(log *logging.SimpleLogger, lockResult LockResult) error {
+	data := e.lockData(LockEvent, lockResult)
+	if e.LockURL != nil {
+		data.LockURL = e.LockURL(locking.Key(lockResult.Lock.Project, lockResult.Lock.Workspace))
+	}
+	return e.send(data)
+}
+
+// SendUnlock emails lockResult if its workspace and repo match.
+func (e *EmailWebhook) SendUnlock(log *logging.SimpleLogger, lockResult LockResult) error {
+	data := e.lockData(UnlockEvent, lockResult)
+	data.Reason = lockResult.Reason
+	return e.send(data)
+}
+
+// SendDrift emails driftResult if its workspace and repo match.
+func (e *EmailWebhook) SendDrift(log *logging.SimpleLogger, driftResult DriftResult) error {
+	return e.send(EmailData{
+		Event:     DriftEvent,
+		Repo:      driftResult.Repo,
+		Path:      driftResult.Path,
+		Workspace: driftResult.Workspace,
+		Summary:   driftResult.Summary,
+	})
+}
+
+// lockData returns the fields lock and unlock emails have in common.
+func (e *EmailWebhook) lockData(event string, lockResult LockResult) EmailData {
+	lock := lockResult.Lock
+	return EmailData{
+		Event:     event,
+		Repo:      models.Repo{FullName: lock.Project.RepoFullName, Hostname: lock.Project.Hostname},
+		Pull:      lock.Pull,
+		User:      lock.User.Username,
+		Path:      lock.Project.Path,
+		Workspace: lock.Workspace,
+	}
+}
+
+// lockURL returns the URL of the lock on the project at path in repo.
+func (e *EmailWebhook) lockURL(repo models.Repo, path string, workspace string) string {
+	if e.LockURL == nil {
+		return ""
+	}
+	return e.LockURL(locking.Key(models.NewProject(repo.Hostname, repo.FullName, path), workspace))
+}
+
+// send executes the templates with data and sends the email if data's
+// workspace and repo match.
+func (e *EmailWebhook) send(data EmailData) error {
+	if !matches(e.WorkspaceRegex, e.RepoRegex, data.Workspace, data.Repo.FullName) {
+		return nil
+	}
+	var subject, body bytes.Buffer
+	if err := e.Subject.Execute(&subject, data); err != nil {
+		return errors.Wrap(err, "executing email subject template")
+	}
+	if err := e.Body.Execute(&body, data); err != nil {
+		return errors.Wrap(err, "executing email body template")
+	}
+	if err := e.Client.SendEmail(e.To, subject.String(), body.String()); err != nil {
+		return fmt.Errorf("sending %s email: %s", data.Event, err)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package webhooks
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The ways SMTPClient can secure its connection to the SMTP server.
+const (
+	// SMTPStartTLS connects without TLS and then upgrades the connection
+	// with the STARTTLS command, usually on port 587.
+	SMTPStartTLS = "starttls"
+	// SMTPTLS connects with TLS, usually on port 465.
+	SMTPTLS = "tls"
+	// SMTPNoTLS doesn't use TLS. It should only be used for relays on the
+	// same host or network.
+	SMTPNoTLS = "none"
+)
+
+// DefaultSMTPPort is the port SMTPClient connects to if one isn't set.
+const DefaultSMTPPort = 587
+
+//go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_email_client.go EmailClient
+
+// EmailClient sends emails.
+type EmailClient interface {
+	// SendEmail sends an email with subject and a plain text body to each
+	// address in to.
+	SendEmail(to []string, subject string, body string) error
+}
+
+// SMTPClient sends emails over SMTP.
+type SMTPClient struct {
+	// Host and Port are the address of the SMTP server.
+	Host string
+	Port int
+	// Username and Password are used to authenticate with the server if
+	// Username is set.
+	Username string
+	Password string
+	// From is the address emails are sent from.
+	From string
+	// TLS is one of SMTPStartTLS, SMTPTLS or SMTPNoTLS.
+	TLS string
+	// TLSConfig configures the TLS connection. If nil, Host's certificate
+	// is verified against the system's root CAs.
+	TLSConfig *tls.Config
+	// Timeout is how long sending each email can take.
+	Timeout time.Duration
+}
+
+// NewSMTPClient returns an SMTPClient. port defaults to DefaultSMTPPort and
+// tlsMode to SMTPStartTLS.
+func NewSMTPClient(host string, port int, username string, password string, from string, tlsMode string) (*SMTPClient, error) {
+	if host == "" {
+		return nil, errors.New("must specify \"host\" in the \"smtp\" config")
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, errors.Wrap(err, "parsing \"from\" in the \"smtp\" config")
+	}
+	if port == 0 {
+		port = DefaultSMTPPort
+	}
+	if tlsMode == "" {
+		tlsMode = SMTPStartTLS
+	}
+	switch tlsMode {
+	case SMTPStartTLS, SMTPTLS, SMTPNoTLS:
+	default:
+		return nil, fmt.Errorf("\"tls: %s\" not supported in the \"smtp\" config. Only %q, %q and %q are supported", tlsMode, SMTPStartTLS, SMTPTLS, SMTPNoTLS)
+	}
+	return &SMTPClient{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		From:     from,
+		TLS:      tlsMode,
+		Timeout:  30 * time.Second,
+	}, nil
+}
+
+// SendEmail sends the email over a new connection to the SMTP server.
+func (c *SMTPClient) SendEmail(to []string, subject string, body string) error {
+	from, err := mail.ParseAddress(c.From)
+	if err != nil {
+		return errors.Wrap(err, "parsing from address")
+	}
+	msg, err := c.message(from, to, subject, body)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	tlsConfig := &tls.Config{ServerName: c.Host}
+	if c.TLSConfig != nil {
+		tlsConfig = c.TLSConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = c.Host
+		}
+	}
+	dialer := &net.Dialer{Timeout: c.Timeout}
+	var conn net.Conn
+	if c.TLS == SMTPTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "connecting to %s", addr)
+	}
+	if c.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.Timeout)) // nolint: errcheck
+	}
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close() // nolint: errcheck
+		return errors.Wrapf(err, "connecting to %s", addr)
+	}
+	defer client.Close() // nolint: errcheck
+
+	if c.TLS == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s doesn't support STARTTLS. Set \"tls: %s\" if it only accepts TLS connections", addr, SMTPTLS)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return errors.Wrap(err, "starting TLS")
+		}
+	}
+	if c.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.Password, c.Host)); err != nil {
+			return errors.Wrap(err, "authenticating")
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return errors.Wrap(err, "sending MAIL command")
+	}
+	for _, addr := range to {
+		if err := client.Rcpt(addr); err != nil {
+			return errors.Wrapf(err, "sending RCPT command for %s", addr)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return errors.Wrap(err, "sending DATA command")
+	}
+	if _, err := w.Write(msg); err != nil {
+		return errors.Wrap(err, "writing message")
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "writing message")
+	}
+	return client.Quit()
+}
+
+// message returns the email with its headers. The body is quoted-printable
+// encoded so long lines and non-ASCII characters make it through any relay
+// and its line breaks are converted to CRLF.
+func (c *SMTPClient) message(from *mail.Address, to []string, subject string, body string) ([]byte, error) {
+	messageID, err := newDeliveryID()
+	if err != nil {
+		return nil, err
+	}
+	// Headers can't contain line breaks or recipients could be added to the
+	// email by whatever's templated into the subject.
+	subject = strings.NewReplacer("\r", " ", "\n", " ").Replace(subject)
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", c.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", messageID, domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	buf.WriteString("\r\n")
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
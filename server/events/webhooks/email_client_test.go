@@ -0,0 +1,254 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package webhooks_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io/ioutil"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// smtpServer is a local SMTP server that records the emails sent to it.
+type smtpServer struct {
+	listener net.Listener
+	// tlsConfig is used for STARTTLS if startTLS is true.
+	tlsConfig *tls.Config
+	startTLS  bool
+
+	mtx     sync.Mutex
+	auth    []string
+	from    string
+	to      []string
+	data    string
+	wg      sync.WaitGroup
+	usedTLS bool
+}
+
+// newSMTPServer starts an SMTP server on a random port. If implicitTLS is
+// true, connections must use TLS from the start. If startTLS is true, the
+// server supports STARTTLS.
+func newSMTPServer(t *testing.T, implicitTLS bool, startTLS bool) (*smtpServer, *x509.CertPool) {
+	// Borrow httptest's certificate for 127.0.0.1.
+	https := httptest.NewUnstartedServer(http.NotFoundHandler())
+	https.StartTLS()
+	https.Close()
+	tlsConfig := &tls.Config{Certificates: https.TLS.Certificates}
+	pool := x509.NewCertPool()
+	pool.AddCert(https.Certificate())
+
+	var l net.Listener
+	var err error
+	if implicitTLS {
+		l, err = tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	} else {
+		l, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	Ok(t, err)
+	s := &smtpServer{listener: l, tlsConfig: tlsConfig, startTLS: startTLS, usedTLS: implicitTLS}
+	s.wg.Add(1)
+	go s.serve()
+	return s, pool
+}
+
+func (s *smtpServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// close stops the server and waits for it to finish with its connection.
+func (s *smtpServer) close() {
+	s.listener.Close() // nolint: errcheck
+	s.wg.Wait()
+}
+
+// serve handles one connection.
+func (s *smtpServer) serve() {
+	defer s.wg.Done()
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()                                 // nolint: errcheck
+	conn.SetDeadline(time.Now().Add(10 * time.Second)) // nolint: errcheck
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n")) // nolint: errcheck
+	}
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		s.mtx.Lock()
+		switch cmd {
+		case "EHLO":
+			reply("250-localhost")
+			if s.startTLS {
+				reply("250-STARTTLS")
+			}
+			reply("250 AUTH PLAIN")
+		case "STARTTLS":
+			reply("220 ready")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				s.mtx.Unlock()
+				return
+			}
+			conn = tlsConn
+			r = bufio.NewReader(conn)
+			s.usedTLS = true
+		case "AUTH":
+			s.auth = append(s.auth, line)
+			reply("235 ok")
+		case "MAIL":
+			s.from = line
+			reply("250 ok")
+		case "RCPT":
+			s.to = append(s.to, line)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data []string
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					s.mtx.Unlock()
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data = append(data, l)
+			}
+			s.data = strings.Join(data, "")
+			reply("250 ok")
+		case "QUIT":
+			reply("221 bye")
+			s.mtx.Unlock()
+			return
+		default:
+			reply("502 not implemented")
+		}
+		s.mtx.Unlock()
+	}
+}
+
+// message parses the email that was sent and decodes its body.
+func (s *smtpServer) message(t *testing.T) (*mail.Message, string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	msg, err := mail.ReadMessage(strings.NewReader(s.data))
+	Ok(t, err)
+	body, err := ioutil.ReadAll(quotedprintable.NewReader(msg.Body))
+	Ok(t, err)
+	return msg, string(body)
+}
+
+func TestNewSMTPClient_Validation(t *testing.T) {
+	_, err := webhooks.NewSMTPClient("", 0, "", "", "atlantis@example.com", "")
+	ErrEquals(t, "must specify \"host\" in the \"smtp\" config", err)
+	_, err = webhooks.NewSMTPClient("smtp.example.com", 0, "", "", "", "")
+	ErrEquals(t, "parsing \"from\" in the \"smtp\" config: mail: no address", err)
+	_, err = webhooks.NewSMTPClient("smtp.example.com", 0, "", "", "atlantis@example.com", "ssl")
+	ErrEquals(t, "\"tls: ssl\" not supported in the \"smtp\" config. Only \"starttls\", \"tls\" and \"none\" are supported", err)
+}
+
+func TestNewSMTPClient_Defaults(t *testing.T) {
+	c, err := webhooks.NewSMTPClient("smtp.example.com", 0, "", "", "atlantis@example.com", "")
+	Ok(t, err)
+	Equals(t, 587, c.Port)
+	Equals(t, webhooks.SMTPStartTLS, c.TLS)
+}
+
+func TestSMTPClient_SendEmail(t *testing.T) {
+	cases := []struct {
+		tls         string
+		implicitTLS bool
+		startTLS    bool
+	}{
+		{webhooks.SMTPNoTLS, false, false},
+		{webhooks.SMTPStartTLS, false, true},
+		{webhooks.SMTPTLS, true, false},
+	}
+	for _, c := range cases {
+		t.Run(c.tls, func(t *testing.T) {
+			server, pool := newSMTPServer(t, c.implicitTLS, c.startTLS)
+			defer server.close()
+			client, err := webhooks.NewSMTPClient("127.0.0.1", server.port(), "user", "password", "Atlantis <atlantis@example.com>", c.tls)
+			Ok(t, err)
+			client.TLSConfig = &tls.Config{RootCAs: pool}
+
+			body := "Plan: 1 to add, 0 to change, 0 to destroy.\n" + strings.Repeat("long line ", 20) + "\n"
+			err = client.SendEmail([]string{"cab@example.com", "ops@example.com"}, "Atlantis apply succeeded", body)
+			Ok(t, err)
+			server.close()
+
+			Equals(t, c.tls != webhooks.SMTPNoTLS, server.usedTLS)
+			Equals(t, []string{"AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00user\x00password"))}, server.auth)
+			Equals(t, "MAIL FROM:<atlantis@example.com>", server.from)
+			Equals(t, []string{"RCPT TO:<cab@example.com>", "RCPT TO:<ops@example.com>"}, server.to)
+			msg, msgBody := server.message(t)
+			Equals(t, "Atlantis <atlantis@example.com>", msg.Header.Get("From"))
+			Equals(t, "cab@example.com, ops@example.com", msg.Header.Get("To"))
+			Equals(t, "Atlantis apply succeeded", msg.Header.Get("Subject"))
+			Equals(t, "text/plain; charset=utf-8", msg.Header.Get("Content-Type"))
+			Assert(t, strings.HasSuffix(msg.Header.Get("Message-ID"), "@example.com>"), "exp message id at example.com, got %q", msg.Header.Get("Message-ID"))
+			Equals(t, strings.Replace(body, "\n", "\r\n", -1), msgBody)
+		})
+	}
+}
+
+func TestSMTPClient_SendEmailSubjectLineBreaks(t *testing.T) {
+	t.Log("line breaks in the subject shouldn't be able to add headers")
+	server, _ := newSMTPServer(t, false, false)
+	defer server.close()
+	client, err := webhooks.NewSMTPClient("127.0.0.1", server.port(), "", "", "atlantis@example.com", webhooks.SMTPNoTLS)
+	Ok(t, err)
+
+	Ok(t, client.SendEmail([]string{"cab@example.com"}, "subject\r\nBcc: attacker@example.com", "body"))
+	server.close()
+	msg, _ := server.message(t)
+	Equals(t, "subject  Bcc: attacker@example.com", msg.Header.Get("Subject"))
+	Equals(t, "", msg.Header.Get("Bcc"))
+	Equals(t, 0, len(server.auth))
+}
+
+func TestSMTPClient_SendEmailNoStartTLS(t *testing.T) {
+	t.Log("should error rather than send the password unencrypted if the server doesn't support STARTTLS")
+	server, _ := newSMTPServer(t, false, false)
+	defer server.close()
+	client, err := webhooks.NewSMTPClient("127.0.0.1", server.port(), "user", "password", "atlantis@example.com", webhooks.SMTPStartTLS)
+	Ok(t, err)
+
+	err = client.SendEmail([]string{"cab@example.com"}, "subject", "body")
+	ErrEquals(t, "127.0.0.1:"+strconv.Itoa(server.port())+" doesn't support STARTTLS. Set \"tls: tls\" if it only accepts TLS connections", err)
+	Equals(t, 0, len(server.auth))
+}
@@ -0,0 +1,176 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package webhooks_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/events/webhooks/mocks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+var emailTo = []string{"cab@example.com"}
+
+func TestNewEmail_Validation(t *testing.T) {
+	r := regexp.MustCompile(".*")
+	_, err := webhooks.NewEmail(webhooks.ApplyEvent, r, nil, nil, "", "", nil)
+	ErrEquals(t, "must specify \"to\" if using a webhook of \"kind: email\"", err)
+	_, err = webhooks.NewEmail(webhooks.ApplyEvent, r, nil, []string{"not an address"}, "", "", nil)
+	ErrEquals(t, "parsing \"to\" address \"not an address\": mail: no angle-addr", err)
+	_, err = webhooks.NewEmail(webhooks.ApplyEvent, r, nil, emailTo, "{{.Repo", "", nil)
+	ErrEquals(t, "parsing email \"subject\": template: subject:1: unclosed action", err)
+}
+
+func TestEmailWebhook_Send(t *testing.T) {
+	t.Log("apply emails should link to the pull request and lock")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockEmailClient()
+	e := newEmailWebhook(t, client, webhooks.ApplyEvent, ".*", "", "")
+	result := applyResult
+	result.Summary = "Apply complete! Resources: 1 added, 0 changed, 0 destroyed."
+
+	Ok(t, e.Send(logging.NewNoopLogger(), result))
+	client.VerifyWasCalledOnce().SendEmail(emailTo, "Atlantis apply succeeded: runatlantis/atlantis vpc in production", `Repo:      runatlantis/atlantis
+Path:      vpc
+Workspace: production
+Pull:      https://github.com/runatlantis/atlantis/pull/1
+User:      user
+
+Apply complete! Resources: 1 added, 0 changed, 0 destroyed.
+
+Lock: https://atlantis.example.com/lock?id=github.com/runatlantis/atlantis/-/vpc/production
+`)
+}
+
+func TestEmailWebhook_SendPlanFailed(t *testing.T) {
+	t.Log("failed plan emails shouldn't link to the lock since it's been released")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockEmailClient()
+	e := newEmailWebhook(t, client, webhooks.PlanEvent, ".*", "", "")
+
+	Ok(t, e.SendPlan(logging.NewNoopLogger(), webhooks.PlanResult{
+		Repo:      applyResult.Repo,
+		Pull:      applyResult.Pull,
+		User:      applyResult.User,
+		Path:      "vpc",
+		Workspace: "production",
+	}))
+	client.VerifyWasCalledOnce().SendEmail(emailTo, "Atlantis plan failed: runatlantis/atlantis vpc in production", `Repo:      runatlantis/atlantis
+Path:      vpc
+Workspace: production
+Pull:      https://github.com/runatlantis/atlantis/pull/1
+User:      user
+`)
+}
+
+func TestEmailWebhook_SendLockAndUnlock(t *testing.T) {
+	RegisterMockTestingT(t)
+	client := mocks.NewMockEmailClient()
+	e := newEmailWebhook(t, client, webhooks.LockEvent, ".*", "", "")
+	lock := models.ProjectLock{
+		Project:   models.NewProject("github.com", "runatlantis/atlantis", "vpc"),
+		Pull:      applyResult.Pull,
+		User:      applyResult.User,
+		Workspace: "production",
+		Time:      time.Now(),
+	}
+
+	Ok(t, e.SendLock(logging.NewNoopLogger(), webhooks.LockResult{Lock: lock}))
+	client.VerifyWasCalledOnce().SendEmail(emailTo, "Atlantis locked runatlantis/atlantis vpc in production", `Repo:      runatlantis/atlantis
+Path:      vpc
+Workspace: production
+Pull:      https://github.com/runatlantis/atlantis/pull/1
+User:      user
+
+Lock: https://atlantis.example.com/lock?id=github.com/runatlantis/atlantis/-/vpc/production
+`)
+
+	e = newEmailWebhook(t, client, webhooks.UnlockEvent, ".*", "", "")
+	Ok(t, e.SendUnlock(logging.NewNoopLogger(), webhooks.LockResult{Lock: lock, Reason: webhooks.UnlockPullClosedReason}))
+	client.VerifyWasCalledOnce().SendEmail(emailTo, "Atlantis unlocked runatlantis/atlantis vpc in production", `Repo:      runatlantis/atlantis
+Path:      vpc
+Workspace: production
+Pull:      https://github.com/runatlantis/atlantis/pull/1
+User:      user
+Reason:    pull-closed
+`)
+}
+
+func TestEmailWebhook_SendDrift(t *testing.T) {
+	RegisterMockTestingT(t)
+	client := mocks.NewMockEmailClient()
+	e := newEmailWebhook(t, client, webhooks.DriftEvent, ".*", "", "")
+
+	Ok(t, e.SendDrift(logging.NewNoopLogger(), webhooks.DriftResult{
+		Repo:      applyResult.Repo,
+		Path:      "vpc",
+		Workspace: "production",
+		Summary:   "Plan: 1 to add, 0 to change, 0 to destroy.",
+	}))
+	client.VerifyWasCalledOnce().SendEmail(emailTo, "Atlantis detected drift: runatlantis/atlantis vpc in production", `Repo:      runatlantis/atlantis
+Path:      vpc
+Workspace: production
+
+Plan: 1 to add, 0 to change, 0 to destroy.
+`)
+}
+
+func TestEmailWebhook_CustomTemplates(t *testing.T) {
+	RegisterMockTestingT(t)
+	client := mocks.NewMockEmailClient()
+	e := newEmailWebhook(t, client, webhooks.ApplyStartEvent, ".*", "[CAB] {{.Workspace}} change starting", "{{.User}} is applying {{.Pull.URL}}")
+
+	Ok(t, e.SendApplyStart(logging.NewNoopLogger(), webhooks.ApplyStart{
+		Repo:      applyResult.Repo,
+		Pull:      applyResult.Pull,
+		User:      applyResult.User,
+		Path:      "vpc",
+		Workspace: "production",
+	}))
+	client.VerifyWasCalledOnce().SendEmail(emailTo, "[CAB] production change starting", "user is applying https://github.com/runatlantis/atlantis/pull/1")
+}
+
+func TestEmailWebhook_Filters(t *testing.T) {
+	RegisterMockTestingT(t)
+	client := mocks.NewMockEmailClient()
+	e := newEmailWebhook(t, client, webhooks.ApplyEvent, "^staging", "", "")
+
+	Ok(t, e.Send(logging.NewNoopLogger(), applyResult))
+	client.VerifyWasCalled(Never()).SendEmail(AnyStringSlice(), AnyString(), AnyString())
+}
+
+func TestEmailWebhook_TemplateError(t *testing.T) {
+	RegisterMockTestingT(t)
+	client := mocks.NewMockEmailClient()
+	e := newEmailWebhook(t, client, webhooks.ApplyEvent, ".*", "{{.Missing}}", "")
+
+	err := e.Send(logging.NewNoopLogger(), applyResult)
+	Assert(t, err != nil, "expected error")
+	client.VerifyWasCalled(Never()).SendEmail(AnyStringSlice(), AnyString(), AnyString())
+}
+
+func newEmailWebhook(t *testing.T, client webhooks.EmailClient, event string, workspaceRegex string, subject string, body string) *webhooks.EmailWebhook {
+	e, err := webhooks.NewEmail(event, regexp.MustCompile(workspaceRegex), nil, emailTo, subject, body, client)
+	Ok(t, err)
+	e.LockURL = func(id string) string {
+		return "https://atlantis.example.com/lock?id=" + id
+	}
+	return e
+}
@@ -0,0 +1,402 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/logging"
+)
+
+const HTTPKind = "http"
+
+// HTTPPayloadVersion is the version of the payloads sent by http webhooks.
+// It's incremented when fields are renamed or removed, but not when they're
+// added.
+const HTTPPayloadVersion = 1
+
+// Headers sent with each http webhook.
+const (
+	// HTTPSignatureHeader is the hex encoded HMAC-SHA256 of the request body
+	// keyed with the webhook's secret, prefixed with "sha256=".
+	HTTPSignatureHeader = "X-Atlantis-Signature"
+	// HTTPEventHeader is the event the webhook is for, ex. apply.
+	HTTPEventHeader = "X-Atlantis-Event"
+	// HTTPDeliveryHeader is a unique ID for the webhook. It's the same for
+	// each retry so receivers can ignore duplicates.
+	HTTPDeliveryHeader = "X-Atlantis-Delivery"
+)
+
+// Defaults for HTTPWebhook.
+const (
+	defaultHTTPRetries = 3
+	defaultHTTPBackoff = time.Second
+	defaultHTTPTimeout = 10 * time.Second
+	// DefaultHTTPQueueSize is how many webhooks can be waiting to be sent in
+	// the background before new ones are dropped.
+	DefaultHTTPQueueSize = 100
+)
+
+// HTTPPayload is the JSON body of http webhooks.
+type HTTPPayload struct {
+	// Version is HTTPPayloadVersion.
+	Version int `json:"version"`
+	// Event is the event the webhook is for, ex. apply.
+	Event string `json:"event"`
+	// Time is when the webhook was first sent.
+	Time      time.Time `json:"time"`
+	Repo      HTTPRepo  `json:"repo"`
+	Pull      *HTTPPull `json:"pull,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Workspace string    `json:"workspace"`
+	// Only the field for Event is set.
+	Apply      *HTTPApply      `json:"apply,omitempty"`
+	ApplyStart *HTTPApplyStart `json:"apply_start,omitempty"`
+	Drift      *HTTPDrift      `json:"drift,omitempty"`
+	Lock       *HTTPLock       `json:"lock,omitempty"`
+	Plan       *HTTPPlan       `json:"plan,omitempty"`
+	Unlock     *HTTPUnlock     `json:"unlock,omitempty"`
+}
+
+// HTTPRepo is the repo in HTTPPayload.
+type HTTPRepo struct {
+	// FullName is the owner and name of the repo, ex. "runatlantis/atlantis".
+	FullName string `json:"full_name"`
+	// Hostname is the hostname of the VCS install, ex. github.com.
+	Hostname string `json:"hostname"`
+	// URL is the URL the repo is cloned from. It's not set for lock and
+	// unlock events.
+	URL string `json:"url,omitempty"`
+}
+
+// HTTPPull is the pull request in HTTPPayload.
+type HTTPPull struct {
+	Num        int    `json:"num"`
+	URL        string `json:"url"`
+	Author     string `json:"author"`
+	Branch     string `json:"branch"`
+	HeadCommit string `json:"head_commit"`
+}
+
+// HTTPApply is set in the payloads of apply webhooks.
+type HTTPApply struct {
+	// Path is the dir of the project, relative to the repo root.
+	Path    string `json:"path"`
+	Success bool   `json:"success"`
+	// Summary is the summary line of the apply if it succeeded, ex.
+	// "Apply complete! Resources: 1 added, 0 changed, 0 destroyed."
+	Summary string `json:"summary,omitempty"`
+}
+
+// HTTPApplyStart is set in the payloads of apply-start webhooks.
+type HTTPApplyStart struct {
+	Path string `json:"path"`
+}
+
+// HTTPPlan is set in the payloads of plan webhooks.
+type HTTPPlan struct {
+	Path    string `json:"path"`
+	Success bool   `json:"success"`
+	// Summary is the summary line of the plan if it succeeded, ex.
+	// "Plan: 1 to add, 0 to change, 0 to destroy."
+	Summary string `json:"summary,omitempty"`
+}
+
+// HTTPLock is set in the payloads of lock webhooks.
+type HTTPLock struct {
+	Path string `json:"path"`
+	// Time is when the lock was acquired.
+	Time time.Time `json:"time"`
+}
+
+// HTTPUnlock is set in the payloads of unlock webhooks.
+type HTTPUnlock struct {
+	Path string `json:"path"`
+	// Reason is why the lock was released, ex. pull-closed.
+	Reason string `json:"reason"`
+}
+
+// HTTPDrift is set in the payloads of drift webhooks.
+type HTTPDrift struct {
+	// Path is the dir of the project, relative to the repo root.
+	Path string `json:"path"`
+	// Summary is the summary line of the plan, ex.
+	// "Plan: 1 to add, 0 to change, 0 to destroy."
+	Summary string `json:"summary"`
+}
+
+// HTTPWebhook POSTs JSON payloads to a URL.
+type HTTPWebhook struct {
+	URL string
+	// Secret is the key of the HMAC in the signature header.
+	Secret string
+	// WorkspaceRegex and RepoRegex filter which results are sent. RepoRegex
+	// is matched against the repo's full name, ex. runatlantis/atlantis.
+	WorkspaceRegex *regexp.Regexp
+	RepoRegex      *regexp.Regexp
+	Client         *http.Client
+	// Retries is how many times a request is retried if it fails with a
+	// network error or 5xx or 429 response.
+	Retries int
+	// Backoff is how long to wait before the first retry. It doubles after
+	// each one.
+	Backoff time.Duration
+	// queue holds the webhooks waiting to be sent in the background. If it's
+	// nil, webhooks are sent before the Send methods return.
+	queue chan httpDelivery
+}
+
+// httpDelivery is a webhook waiting to be sent.
+type httpDelivery struct {
+	log        *logging.SimpleLogger
+	event      string
+	deliveryID string
+	body       []byte
+}
+
+// NewHTTP returns an HTTPWebhook that POSTs to url. secret must be set.
+func NewHTTP(url string, secret string, workspaceRegex *regexp.Regexp, repoRegex *regexp.Regexp) (*HTTPWebhook, error) {
+	if url == "" {
+		return nil, errors.New("must specify \"url\" if using a webhook of \"kind: http\"")
+	}
+	if secret == "" {
+		return nil, errors.New("must specify \"secret\" if using a webhook of \"kind: http\"")
+	}
+	return &HTTPWebhook{
+		URL:            url,
+		Secret:         secret,
+		WorkspaceRegex: workspaceRegex,
+		RepoRegex:      repoRegex,
+		Client:         &http.Client{Timeout: defaultHTTPTimeout},
+		Retries:        defaultHTTPRetries,
+		Backoff:        defaultHTTPBackoff,
+	}, nil
+}
+
+// StartQueue makes h send webhooks in the background so the Send methods
+// don't block commands while the receiver is slow or being retried. At most
+// size webhooks can be waiting to be sent, after that new ones are dropped.
+func (h *HTTPWebhook) StartQueue(size int) {
+	h.queue = make(chan httpDelivery, size)
+	go func() {
+		for d := range h.queue {
+			if err := h.deliver(d); err != nil {
+				d.log.Warn("%s", err)
+			}
+		}
+	}()
+}
+
+// Send POSTs applyResult if its workspace and repo match.
+func (h *HTTPWebhook) Send(log *logging.SimpleLogger, applyResult ApplyResult) error {
+	if !matches(h.WorkspaceRegex, h.RepoRegex, applyResult.Workspace, applyResult.Repo.FullName) {
+		return nil
+	}
+	return h.post(log, HTTPPayload{
+		Event:     ApplyEvent,
+		Repo:      httpRepo(applyResult.Repo),
+		Pull:      httpPull(applyResult.Pull),
+		User:      applyResult.User.Username,
+		Workspace: applyResult.Workspace,
+		Apply:     &HTTPApply{Path: applyResult.Path, Success: applyResult.Success, Summary: applyResult.Summary},
+	})
+}
+
+// SendApplyStart POSTs applyStart if its workspace and repo match.
+func (h *HTTPWebhook) SendApplyStart(log *logging.SimpleLogger, applyStart ApplyStart) error {
+	if !matches(h.WorkspaceRegex, h.RepoRegex, applyStart.Workspace, applyStart.Repo.FullName) {
+		return nil
+	}
+	return h.post(log, HTTPPayload{
+		Event:      ApplyStartEvent,
+		Repo:       httpRepo(applyStart.Repo),
+		Pull:       httpPull(applyStart.Pull),
+		User:       applyStart.User.Username,
+		Workspace:  applyStart.Workspace,
+		ApplyStart: &HTTPApplyStart{Path: applyStart.Path},
+	})
+}
+
+// SendPlan POSTs planResult if its workspace and repo match.
+func (h *HTTPWebhook) SendPlan(log *logging.SimpleLogger, planResult PlanResult) error {
+	if !matches(h.WorkspaceRegex, h.RepoRegex, planResult.Workspace, planResult.Repo.FullName) {
+		return nil
+	}
+	return h.post(log, HTTPPayload{
+		Event:     PlanEvent,
+		Repo:      httpRepo(planResult.Repo),
+		Pull:      httpPull(planResult.Pull),
+		User:      planResult.User.Username,
+		Workspace: planResult.Workspace,
+		Plan:      &HTTPPlan{Path: planResult.Path, Success: planResult.Success, Summary: planResult.Summary},
+	})
+}
+
+// SendLock POSTs lockResult if its workspace and repo match.
+func (h *HTTPWebhook) SendLock(log *logging.SimpleLogger, lockResult LockResult) error {
+	payload, ok := h.lockPayload(LockEvent, lockResult)
+	if !ok {
+		return nil
+	}
+	payload.Lock = &HTTPLock{Path: lockResult.Lock.Project.Path, Time: lockResult.Lock.Time.UTC()}
+	return h.post(log, payload)
+}
+
+// SendUnlock POSTs lockResult if its workspace and repo match.
+func (h *HTTPWebhook) SendUnlock(log *logging.SimpleLogger, lockResult LockResult) error {
+	payload, ok := h.lockPayload(UnlockEvent, lockResult)
+	if !ok {
+		return nil
+	}
+	payload.Unlock = &HTTPUnlock{Path: lockResult.Lock.Project.Path, Reason: lockResult.Reason}
+	return h.post(log, payload)
+}
+
+// lockPayload returns the fields lock and unlock payloads have in common. ok
+// is false if the webhook shouldn't be sent for lockResult.
+func (h *HTTPWebhook) lockPayload(event string, lockResult LockResult) (payload HTTPPayload, ok bool) {
+	lock := lockResult.Lock
+	if !matches(h.WorkspaceRegex, h.RepoRegex, lock.Workspace, lock.Project.RepoFullName) {
+		return HTTPPayload{}, false
+	}
+	return HTTPPayload{
+		Event:     event,
+		Repo:      HTTPRepo{FullName: lock.Project.RepoFullName, Hostname: lock.Project.Hostname},
+		Pull:      httpPull(lock.Pull),
+		User:      lock.User.Username,
+		Workspace: lock.Workspace,
+	}, true
+}
+
+// SendDrift POSTs driftResult if its workspace and repo match.
+func (h *HTTPWebhook) SendDrift(log *logging.SimpleLogger, driftResult DriftResult) error {
+	if !matches(h.WorkspaceRegex, h.RepoRegex, driftResult.Workspace, driftResult.Repo.FullName) {
+		return nil
+	}
+	return h.post(log, HTTPPayload{
+		Event:     DriftEvent,
+		Repo:      httpRepo(driftResult.Repo),
+		Workspace: driftResult.Workspace,
+		Drift:     &HTTPDrift{Path: driftResult.Path, Summary: driftResult.Summary},
+	})
+}
+
+func httpRepo(repo models.Repo) HTTPRepo {
+	return HTTPRepo{FullName: repo.FullName, Hostname: repo.Hostname, URL: repo.SanitizedCloneURL}
+}
+
+func httpPull(pull models.PullRequest) *HTTPPull {
+	return &HTTPPull{Num: pull.Num, URL: pull.URL, Author: pull.Author, Branch: pull.Branch, HeadCommit: pull.HeadCommit}
+}
+
+// post sends payload, or queues it to be sent if the queue was started.
+func (h *HTTPWebhook) post(log *logging.SimpleLogger, payload HTTPPayload) error {
+	payload.Version = HTTPPayloadVersion
+	payload.Time = time.Now().UTC()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "marshalling payload")
+	}
+	deliveryID, err := newDeliveryID()
+	if err != nil {
+		return err
+	}
+	d := httpDelivery{log: log, event: payload.Event, deliveryID: deliveryID, body: body}
+	if h.queue == nil {
+		return h.deliver(d)
+	}
+
+	// The command's logger may be read once the command finishes so the
+	// queue logs with a copy that doesn't write to its history.
+	background := *log
+	background.KeepHistory = false
+	d.log = &background
+	select {
+	case h.queue <- d:
+		return nil
+	default:
+		return fmt.Errorf("dropping %s webhook to %s since its queue of %d webhooks is full", payload.Event, h.URL, cap(h.queue))
+	}
+}
+
+// deliver sends d, retrying with backoff if it fails.
+func (h *HTTPWebhook) deliver(d httpDelivery) error {
+	backoff := h.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := h.postOnce(d.event, d.deliveryID, d.body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= h.Retries {
+			return errors.Wrapf(err, "sending %s webhook to %s", d.event, h.URL)
+		}
+		d.log.Debug("retrying %s webhook to %s in %s: %s", d.event, h.URL, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// postOnce makes one request. retry is true if the error might not happen
+// if the request is retried.
+func (h *HTTPWebhook) postOnce(event string, deliveryID string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest("POST", h.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HTTPEventHeader, event)
+	req.Header.Set(HTTPDeliveryHeader, deliveryID)
+	req.Header.Set(HTTPSignatureHeader, HTTPSignature([]byte(h.Secret), body))
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()            // nolint: errcheck
+	io.Copy(ioutil.Discard, resp.Body) // nolint: errcheck
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("got response %d", resp.StatusCode)
+}
+
+// HTTPSignature returns the value of the signature header for body. Receivers
+// should compute it and compare it to the header with hmac.Equal.
+func HTTPSignature(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body) // nolint: errcheck
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// newDeliveryID returns a random ID for a webhook.
+func newDeliveryID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generating delivery id")
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,382 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package webhooks_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/events/models"
+	"github.com/runatlantis/atlantis/server/events/webhooks"
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+// receiver records the requests made to it and responds with each of codes
+// in turn, then 200.
+type receiver struct {
+	mtx      sync.Mutex
+	codes    []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mtx.Lock()
+	defer rc.mtx.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	code := http.StatusOK
+	if len(rc.codes) > 0 {
+		code, rc.codes = rc.codes[0], rc.codes[1:]
+	}
+	w.WriteHeader(code)
+}
+
+var applyResult = webhooks.ApplyResult{
+	Path:      "vpc",
+	Workspace: "production",
+	Repo: models.Repo{
+		FullName:          "runatlantis/atlantis",
+		Hostname:          "github.com",
+		SanitizedCloneURL: "https://github.com/runatlantis/atlantis.git",
+	},
+	Pull: models.PullRequest{
+		Num:        1,
+		URL:        "https://github.com/runatlantis/atlantis/pull/1",
+		Author:     "author",
+		Branch:     "branch",
+		HeadCommit: "abc123",
+	},
+	User:    models.User{Username: "user"},
+	Success: true,
+}
+
+func TestNewHTTP_Validation(t *testing.T) {
+	_, err := webhooks.NewHTTP("", "secret", regexp.MustCompile(".*"), nil)
+	ErrEquals(t, "must specify \"url\" if using a webhook of \"kind: http\"", err)
+	_, err = webhooks.NewHTTP("https://example.com", "", regexp.MustCompile(".*"), nil)
+	ErrEquals(t, "must specify \"secret\" if using a webhook of \"kind: http\"", err)
+}
+
+func TestHTTPWebhook_Send(t *testing.T) {
+	t.Log("should POST the signed payload")
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	h := newHTTPWebhook(t, server.URL, ".*", "")
+
+	Ok(t, h.Send(logging.NewNoopLogger(), applyResult))
+	Equals(t, 1, len(rc.requests))
+	req := rc.requests[0]
+	Equals(t, "POST", req.Method)
+	Equals(t, "application/json", req.Header.Get("Content-Type"))
+	Equals(t, "apply", req.Header.Get(webhooks.HTTPEventHeader))
+	Equals(t, 32, len(req.Header.Get(webhooks.HTTPDeliveryHeader)))
+	Equals(t, webhooks.HTTPSignature([]byte("secret"), rc.bodies[0]), req.Header.Get(webhooks.HTTPSignatureHeader))
+
+	var payload webhooks.HTTPPayload
+	Ok(t, json.Unmarshal(rc.bodies[0], &payload))
+	Assert(t, time.Since(payload.Time) < time.Minute, "exp time to be set, got %s", payload.Time)
+	payload.Time = time.Time{}
+	Equals(t, webhooks.HTTPPayload{
+		Version: 1,
+		Event:   "apply",
+		Repo: webhooks.HTTPRepo{
+			FullName: "runatlantis/atlantis",
+			Hostname: "github.com",
+			URL:      "https://github.com/runatlantis/atlantis.git",
+		},
+		Pull: &webhooks.HTTPPull{
+			Num:        1,
+			URL:        "https://github.com/runatlantis/atlantis/pull/1",
+			Author:     "author",
+			Branch:     "branch",
+			HeadCommit: "abc123",
+		},
+		User:      "user",
+		Workspace: "production",
+		Apply:     &webhooks.HTTPApply{Path: "vpc", Success: true},
+	}, payload)
+}
+
+func TestHTTPWebhook_SendDrift(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	h := newHTTPWebhook(t, server.URL, ".*", "")
+
+	Ok(t, h.SendDrift(logging.NewNoopLogger(), webhooks.DriftResult{
+		Repo:      applyResult.Repo,
+		Path:      "vpc",
+		Workspace: "default",
+		Summary:   "Plan: 1 to add, 0 to change, 0 to destroy.",
+	}))
+	Equals(t, 1, len(rc.requests))
+	Equals(t, "drift", rc.requests[0].Header.Get(webhooks.HTTPEventHeader))
+	var payload webhooks.HTTPPayload
+	Ok(t, json.Unmarshal(rc.bodies[0], &payload))
+	Equals(t, "drift", payload.Event)
+	Assert(t, payload.Pull == nil, "exp no pull in drift payload")
+	Equals(t, &webhooks.HTTPDrift{Path: "vpc", Summary: "Plan: 1 to add, 0 to change, 0 to destroy."}, payload.Drift)
+}
+
+func TestHTTPWebhook_SendPlan(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	h := newHTTPWebhook(t, server.URL, ".*", "")
+
+	Ok(t, h.SendPlan(logging.NewNoopLogger(), webhooks.PlanResult{
+		Repo:      applyResult.Repo,
+		Pull:      applyResult.Pull,
+		User:      applyResult.User,
+		Path:      "vpc",
+		Workspace: "production",
+		Success:   true,
+		Summary:   "Plan: 1 to add, 0 to change, 0 to destroy.",
+	}))
+	Equals(t, 1, len(rc.requests))
+	Equals(t, "plan", rc.requests[0].Header.Get(webhooks.HTTPEventHeader))
+	var payload webhooks.HTTPPayload
+	Ok(t, json.Unmarshal(rc.bodies[0], &payload))
+	Equals(t, "plan", payload.Event)
+	Equals(t, "user", payload.User)
+	Equals(t, 1, payload.Pull.Num)
+	Equals(t, &webhooks.HTTPPlan{Path: "vpc", Success: true, Summary: "Plan: 1 to add, 0 to change, 0 to destroy."}, payload.Plan)
+}
+
+func TestHTTPWebhook_SendApplyStart(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	h := newHTTPWebhook(t, server.URL, ".*", "")
+
+	Ok(t, h.SendApplyStart(logging.NewNoopLogger(), webhooks.ApplyStart{
+		Repo:      applyResult.Repo,
+		Pull:      applyResult.Pull,
+		User:      applyResult.User,
+		Path:      "vpc",
+		Workspace: "production",
+	}))
+	Equals(t, 1, len(rc.requests))
+	Equals(t, "apply-start", rc.requests[0].Header.Get(webhooks.HTTPEventHeader))
+	var payload webhooks.HTTPPayload
+	Ok(t, json.Unmarshal(rc.bodies[0], &payload))
+	Equals(t, "apply-start", payload.Event)
+	Equals(t, &webhooks.HTTPApplyStart{Path: "vpc"}, payload.ApplyStart)
+	Assert(t, payload.Apply == nil, "exp no apply in apply-start payload")
+}
+
+func TestHTTPWebhook_SendLockAndUnlock(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	h := newHTTPWebhook(t, server.URL, ".*", "^runatlantis/")
+	lockTime := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	lock := models.ProjectLock{
+		Project:   models.NewProject("github.com", "runatlantis/atlantis", "vpc"),
+		Pull:      applyResult.Pull,
+		User:      applyResult.User,
+		Workspace: "production",
+		Time:      lockTime,
+	}
+
+	Ok(t, h.SendLock(logging.NewNoopLogger(), webhooks.LockResult{Lock: lock}))
+	Ok(t, h.SendUnlock(logging.NewNoopLogger(), webhooks.LockResult{Lock: lock, Reason: webhooks.UnlockDiscardedReason}))
+	Equals(t, 2, len(rc.requests))
+	Equals(t, "lock", rc.requests[0].Header.Get(webhooks.HTTPEventHeader))
+	Equals(t, "unlock", rc.requests[1].Header.Get(webhooks.HTTPEventHeader))
+
+	var payload webhooks.HTTPPayload
+	Ok(t, json.Unmarshal(rc.bodies[0], &payload))
+	Equals(t, webhooks.HTTPRepo{FullName: "runatlantis/atlantis", Hostname: "github.com"}, payload.Repo)
+	Equals(t, "user", payload.User)
+	Equals(t, "production", payload.Workspace)
+	Equals(t, &webhooks.HTTPLock{Path: "vpc", Time: lockTime}, payload.Lock)
+	Assert(t, payload.Unlock == nil, "exp no unlock in lock payload")
+
+	payload = webhooks.HTTPPayload{}
+	Ok(t, json.Unmarshal(rc.bodies[1], &payload))
+	Equals(t, &webhooks.HTTPUnlock{Path: "vpc", Reason: "discarded"}, payload.Unlock)
+	Assert(t, payload.Lock == nil, "exp no lock in unlock payload")
+}
+
+func TestHTTPWebhook_SendLockFilters(t *testing.T) {
+	t.Log("lock webhooks should be filtered on the lock's repo")
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	h := newHTTPWebhook(t, server.URL, ".*", "^other/")
+	lock := models.ProjectLock{
+		Project:   models.NewProject("github.com", "runatlantis/atlantis", "vpc"),
+		Workspace: "production",
+	}
+	Ok(t, h.SendLock(logging.NewNoopLogger(), webhooks.LockResult{Lock: lock}))
+	Ok(t, h.SendUnlock(logging.NewNoopLogger(), webhooks.LockResult{Lock: lock, Reason: webhooks.UnlockErrorReason}))
+	Equals(t, 0, len(rc.requests))
+}
+
+func TestHTTPWebhook_Filters(t *testing.T) {
+	cases := []struct {
+		workspaceRegex string
+		repoRegex      string
+		expSent        bool
+	}{
+		{".*", "", true},
+		{"^prod", "", true},
+		{"^staging", "", false},
+		{".*", "^runatlantis/", true},
+		{".*", "^other/", false},
+		{"^staging", "^runatlantis/", false},
+	}
+	for _, c := range cases {
+		t.Run(c.workspaceRegex+" "+c.repoRegex, func(t *testing.T) {
+			rc := &receiver{}
+			server := httptest.NewServer(rc)
+			defer server.Close()
+			h := newHTTPWebhook(t, server.URL, c.workspaceRegex, c.repoRegex)
+			Ok(t, h.Send(logging.NewNoopLogger(), applyResult))
+			Equals(t, c.expSent, len(rc.requests) == 1)
+		})
+	}
+}
+
+func TestHTTPWebhook_Retries(t *testing.T) {
+	t.Log("should retry 5xx and 429 responses with the same delivery id")
+	rc := &receiver{codes: []int{http.StatusBadGateway, http.StatusTooManyRequests}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	h := newHTTPWebhook(t, server.URL, ".*", "")
+
+	Ok(t, h.Send(logging.NewNoopLogger(), applyResult))
+	Equals(t, 3, len(rc.requests))
+	Equals(t, rc.requests[0].Header.Get(webhooks.HTTPDeliveryHeader), rc.requests[2].Header.Get(webhooks.HTTPDeliveryHeader))
+	Equals(t, rc.bodies[0], rc.bodies[2])
+}
+
+func TestHTTPWebhook_RetriesExhausted(t *testing.T) {
+	rc := &receiver{codes: []int{500, 500, 500, 500}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	h := newHTTPWebhook(t, server.URL, ".*", "")
+
+	err := h.Send(logging.NewNoopLogger(), applyResult)
+	ErrEquals(t, "sending apply webhook to "+server.URL+": got response 500", err)
+	Equals(t, 4, len(rc.requests))
+}
+
+func TestHTTPWebhook_NoRetryOnClientError(t *testing.T) {
+	rc := &receiver{codes: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	h := newHTTPWebhook(t, server.URL, ".*", "")
+
+	err := h.Send(logging.NewNoopLogger(), applyResult)
+	ErrEquals(t, "sending apply webhook to "+server.URL+": got response 400", err)
+	Equals(t, 1, len(rc.requests))
+}
+
+func TestHTTPWebhook_Queue(t *testing.T) {
+	t.Log("when the queue is started webhooks should be sent in the background and dropped if the queue is full")
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	defer server.Close()
+	h := newHTTPWebhook(t, server.URL, ".*", "")
+	h.StartQueue(1)
+
+	// The first webhook is being sent and the second is waiting.
+	Ok(t, h.Send(logging.NewNoopLogger(), applyResult))
+	<-started
+	Ok(t, h.Send(logging.NewNoopLogger(), applyResult))
+	err := h.Send(logging.NewNoopLogger(), applyResult)
+	ErrEquals(t, "dropping apply webhook to "+server.URL+" since its queue of 1 webhooks is full", err)
+
+	close(release)
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the queued webhook")
+	}
+}
+
+func TestHTTPWebhook_QueueErrors(t *testing.T) {
+	t.Log("when a webhook sent in the background fails it should be logged but not to the command's history")
+	rc := &receiver{codes: []int{http.StatusBadRequest}}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	h := newHTTPWebhook(t, server.URL, ".*", "")
+	h.StartQueue(1)
+	out := &syncBuffer{}
+	log := logging.NewSimpleLogger("", stdlog.New(out, "", 0), true, logging.Debug)
+
+	Ok(t, h.Send(log, applyResult))
+	for i := 0; !strings.Contains(out.String(), "got response 400"); i++ {
+		if i == 500 {
+			t.Fatal("timed out waiting for the error to be logged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	Equals(t, "[WARN] : Sending apply webhook to "+server.URL+": got response 400\n", out.String())
+	Equals(t, "", log.History.String())
+}
+
+// syncBuffer is a bytes.Buffer that can be written to by a background
+// goroutine while the test reads it.
+type syncBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.String()
+}
+
+func TestHTTPSignature(t *testing.T) {
+	// Computed with: echo -n '{"a":1}' | openssl dgst -sha256 -hmac secret
+	Equals(t, "sha256=aa9e2e3575f5d7098b6caccd790888c36d5fdb63342a73bada2d6a51747a8494", webhooks.HTTPSignature([]byte("secret"), []byte(`{"a":1}`)))
+}
+
+func newHTTPWebhook(t *testing.T, url string, workspaceRegex string, repoRegex string) *webhooks.HTTPWebhook {
+	var repo *regexp.Regexp
+	if repoRegex != "" {
+		repo = regexp.MustCompile(repoRegex)
+	}
+	h, err := webhooks.NewHTTP(url, "secret", regexp.MustCompile(workspaceRegex), repo)
+	Ok(t, err)
+	h.Backoff = time.Millisecond
+	return h
+}
@@ -26,7 +26,10 @@ import (
 type SlackWebhook struct {
 	Client         SlackClient
 	WorkspaceRegex *regexp.Regexp
-	Channel        string
+	// RepoRegex is matched against the repo's full name. If nil, webhooks
+	// are sent for all repos.
+	RepoRegex *regexp.Regexp
+	Channel   string
 }
 
 func NewSlack(r *regexp.Regexp, channel string, client SlackClient) (*SlackWebhook, error) {
@@ -49,10 +52,19 @@ func NewSlack(r *regexp.Regexp, channel string, client SlackClient) (*SlackWebho
 	}, nil
 }
 
-// Send sends the webhook to Slack if the workspace matches the regex.
+// Send sends the webhook to Slack if the workspace and repo match.
 func (s *SlackWebhook) Send(log *logging.SimpleLogger, applyResult ApplyResult) error {
-	if !s.WorkspaceRegex.MatchString(applyResult.Workspace) {
+	if !matches(s.WorkspaceRegex, s.RepoRegex, applyResult.Workspace, applyResult.Repo.FullName) {
 		return nil
 	}
 	return s.Client.PostMessage(s.Channel, applyResult)
 }
+
+// SendDrift sends the drift webhook to Slack if the workspace and repo
+// match.
+func (s *SlackWebhook) SendDrift(log *logging.SimpleLogger, driftResult DriftResult) error {
+	if !matches(s.WorkspaceRegex, s.RepoRegex, driftResult.Workspace, driftResult.Repo.FullName) {
+		return nil
+	}
+	return s.Client.PostDriftMessage(s.Channel, driftResult)
+}
@@ -32,6 +32,7 @@ type SlackClient interface {
 	TokenIsSet() bool
 	ChannelExists(channelName string) (bool, error)
 	PostMessage(channel string, applyResult ApplyResult) error
+	PostDriftMessage(channel string, driftResult DriftResult) error
 }
 
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_underlying_slack_client.go UnderlyingSlackClient
@@ -116,3 +117,32 @@ func (d *DefaultSlackClient) createAttachments(applyResult ApplyResult) []slack.
 	}
 	return []slack.Attachment{attachment}
 }
+
+func (d *DefaultSlackClient) PostDriftMessage(channel string, driftResult DriftResult) error {
+	params := slack.NewPostMessageParameters()
+	params.Attachments = []slack.Attachment{
+		{
+			Color: slackFailureColour,
+			Text:  fmt.Sprintf("Drift detected in %s", driftResult.Repo.FullName),
+			Fields: []slack.AttachmentField{
+				{
+					Title: "Directory",
+					Value: driftResult.Path,
+					Short: true,
+				},
+				{
+					Title: "Workspace",
+					Value: driftResult.Workspace,
+					Short: true,
+				},
+				{
+					Title: "Plan",
+					Value: driftResult.Summary,
+				},
+			},
+		},
+	}
+	params.EscapeText = false
+	_, _, err := d.Slack.PostMessage(channel, "", params)
+	return err
+}
@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/events/webhooks/mocks"
 	"github.com/runatlantis/atlantis/server/logging"
@@ -66,3 +67,28 @@ func TestSend_NoopSuccess(t *testing.T) {
 	Ok(t, err)
 	client.VerifyWasCalled(Never()).PostMessage(channel, result)
 }
+
+func TestSend_RepoRegexNoMatch(t *testing.T) {
+	t.Log("Sending a hook whose repo regex doesn't match should succeed without posting")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	channel := "somechannel"
+	hook := webhooks.SlackWebhook{
+		Client:         client,
+		WorkspaceRegex: regexp.MustCompile(".*"),
+		RepoRegex:      regexp.MustCompile("^runatlantis/"),
+		Channel:        channel,
+	}
+	result := webhooks.ApplyResult{
+		Workspace: "production",
+		Repo:      models.Repo{FullName: "other/repo"},
+	}
+	err := hook.Send(logging.NewNoopLogger(), result)
+	Ok(t, err)
+	client.VerifyWasCalled(Never()).PostMessage(channel, result)
+
+	result.Repo.FullName = "runatlantis/atlantis"
+	err = hook.Send(logging.NewNoopLogger(), result)
+	Ok(t, err)
+	client.VerifyWasCalledOnce().PostMessage(channel, result)
+}
@@ -21,11 +21,44 @@ import (
 
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/redact"
 )
 
 const SlackKind = "slack"
 const ApplyEvent = "apply"
 
+// DriftEvent is sent when drift detection finds that a project's
+// infrastructure no longer matches its default branch.
+const DriftEvent = "drift"
+
+// PlanEvent is sent after each project is planned, whether it succeeded or
+// not.
+const PlanEvent = "plan"
+
+// ApplyStartEvent is sent before each project is applied.
+const ApplyStartEvent = "apply-start"
+
+// LockEvent and UnlockEvent are sent when a pull request locks a project and
+// when the lock is released.
+const (
+	LockEvent   = "lock"
+	UnlockEvent = "unlock"
+)
+
+// Reasons a lock is released.
+const (
+	// UnlockErrorReason is when planning the project errored.
+	UnlockErrorReason = "error"
+	// UnlockDiscardedReason is when the lock is discarded in the UI.
+	UnlockDiscardedReason = "discarded"
+	// UnlockPullClosedReason is when the pull request is closed or merged.
+	UnlockPullClosedReason = "pull-closed"
+)
+
+// slackEvents are the events Slack webhooks can be sent for. The others are
+// only supported by http and email webhooks.
+var slackEvents = map[string]bool{ApplyEvent: true, DriftEvent: true}
+
 //go:generate pegomock generate -m --use-experimental-model-gen --package mocks -o mocks/mock_sender.go Sender
 
 // Sender sends webhooks.
@@ -36,27 +69,135 @@ type Sender interface {
 
 // ApplyResult is the result of a terraform apply.
 type ApplyResult struct {
+	// Path is the dir of the project, relative to the repo root.
+	Path      string
 	Workspace string
 	Repo      models.Repo
 	Pull      models.PullRequest
 	User      models.User
 	Success   bool
+	// Summary is the summary line of the apply if it succeeded, ex.
+	// "Apply complete! Resources: 1 added, 0 changed, 0 destroyed."
+	Summary string
+}
+
+// DriftSender sends webhooks about drift.
+type DriftSender interface {
+	// SendDrift sends the webhook (if the implementation thinks it should).
+	SendDrift(log *logging.SimpleLogger, driftResult DriftResult) error
+}
+
+// DriftResult describes a project whose infrastructure has drifted from its
+// repo's default branch.
+type DriftResult struct {
+	Repo      models.Repo
+	Path      string
+	Workspace string
+	// Summary is the summary line of the plan, ex.
+	// "Plan: 1 to add, 0 to change, 0 to destroy."
+	Summary string
+}
+
+// PlanSender sends webhooks about plans.
+type PlanSender interface {
+	// SendPlan sends the webhook (if the implementation thinks it should).
+	SendPlan(log *logging.SimpleLogger, planResult PlanResult) error
+}
+
+// PlanResult is the result of planning a project.
+type PlanResult struct {
+	Repo      models.Repo
+	Pull      models.PullRequest
+	User      models.User
+	Path      string
+	Workspace string
+	Success   bool
+	// Summary is the summary line of the plan if it succeeded, ex.
+	// "Plan: 1 to add, 0 to change, 0 to destroy."
+	Summary string
+}
+
+// ApplyStartSender sends webhooks about applies that are starting.
+type ApplyStartSender interface {
+	// SendApplyStart sends the webhook (if the implementation thinks it
+	// should).
+	SendApplyStart(log *logging.SimpleLogger, applyStart ApplyStart) error
+}
+
+// ApplyStart describes a project that's about to be applied.
+type ApplyStart struct {
+	Repo      models.Repo
+	Pull      models.PullRequest
+	User      models.User
+	Path      string
+	Workspace string
+}
+
+// LockSender sends webhooks about project locks.
+type LockSender interface {
+	// SendLock sends the webhook for a lock that was acquired (if the
+	// implementation thinks it should).
+	SendLock(log *logging.SimpleLogger, lockResult LockResult) error
+	// SendUnlock sends the webhook for a lock that was released (if the
+	// implementation thinks it should).
+	SendUnlock(log *logging.SimpleLogger, lockResult LockResult) error
+}
+
+// LockResult describes a project lock that was acquired or released.
+type LockResult struct {
+	Lock models.ProjectLock
+	// Reason is why the lock was released, one of the Unlock*Reason consts.
+	// It's empty for locks that were acquired.
+	Reason string
 }
 
 // MultiWebhookSender sends multiple webhooks for each one it's configured for.
 type MultiWebhookSender struct {
-	Webhooks []Sender
+	Webhooks           []Sender
+	DriftWebhooks      []DriftSender
+	PlanWebhooks       []PlanSender
+	ApplyStartWebhooks []ApplyStartSender
+	LockWebhooks       []LockSender
+	UnlockWebhooks     []LockSender
+	// Redactor masks secrets in the output of terraform, ex. drift
+	// summaries, that's sent in webhooks. If nil, it's sent as is.
+	Redactor *redact.Redactor
+	// emailWebhooks are the email webhooks in the other fields. They need
+	// to be told how to link to locks.
+	emailWebhooks []*EmailWebhook
+}
+
+// eventSender is implemented by webhooks that can be sent for every event.
+type eventSender interface {
+	Sender
+	DriftSender
+	PlanSender
+	ApplyStartSender
+	LockSender
 }
 
 type Config struct {
 	Event          string
 	WorkspaceRegex string
-	Kind           string
-	Channel        string
+	// RepoRegex is matched against the full name of the repo, ex.
+	// runatlantis/atlantis. If empty, webhooks are sent for all repos.
+	RepoRegex string
+	Kind      string
+	Channel   string
+	// URL and Secret configure http webhooks.
+	URL    string
+	Secret string
+	// To, Subject and Body configure email webhooks. Subject and Body are
+	// text/template templates that override the defaults if they're set.
+	To      []string
+	Subject string
+	Body    string
 }
 
-func NewMultiWebhookSender(configs []Config, client SlackClient) (*MultiWebhookSender, error) {
-	var webhooks []Sender
+// NewMultiWebhookSender returns a MultiWebhookSender for configs. emailClient
+// can be nil if there aren't any email webhooks.
+func NewMultiWebhookSender(configs []Config, client SlackClient, emailClient EmailClient) (*MultiWebhookSender, error) {
+	m := &MultiWebhookSender{}
 	for _, c := range configs {
 		r, err := regexp.Compile(c.WorkspaceRegex)
 		if err != nil {
@@ -65,11 +206,24 @@ func NewMultiWebhookSender(configs []Config, client SlackClient) (*MultiWebhookS
 		if c.Kind == "" || c.Event == "" {
 			return nil, errors.New("must specify \"kind\" and \"event\" keys for webhooks")
 		}
-		if c.Event != ApplyEvent {
-			return nil, fmt.Errorf("\"event: %s\" not supported. Only \"event: %s\" is supported right now", c.Event, ApplyEvent)
+		switch c.Event {
+		case ApplyEvent, ApplyStartEvent, DriftEvent, LockEvent, PlanEvent, UnlockEvent:
+		default:
+			return nil, fmt.Errorf("\"event: %s\" not supported. Only \"event: %s\", \"event: %s\", \"event: %s\", \"event: %s\", \"event: %s\" and \"event: %s\" are supported right now",
+				c.Event, ApplyEvent, ApplyStartEvent, DriftEvent, LockEvent, PlanEvent, UnlockEvent)
+		}
+		var repoRegex *regexp.Regexp
+		if c.RepoRegex != "" {
+			repoRegex, err = regexp.Compile(c.RepoRegex)
+			if err != nil {
+				return nil, err
+			}
 		}
 		switch c.Kind {
 		case SlackKind:
+			if !slackEvents[c.Event] {
+				return nil, fmt.Errorf("\"event: %s\" is only supported by webhooks of \"kind: %s\" and \"kind: %s\"", c.Event, HTTPKind, EmailKind)
+			}
 			if !client.TokenIsSet() {
 				return nil, errors.New("must specify top-level \"slack-token\" if using a webhook of \"kind: slack\"")
 			}
@@ -80,22 +234,130 @@ func NewMultiWebhookSender(configs []Config, client SlackClient) (*MultiWebhookS
 			if err != nil {
 				return nil, err
 			}
-			webhooks = append(webhooks, slack)
+			slack.RepoRegex = repoRegex
+			if c.Event == DriftEvent {
+				m.DriftWebhooks = append(m.DriftWebhooks, slack)
+			} else {
+				m.Webhooks = append(m.Webhooks, slack)
+			}
+		case HTTPKind:
+			h, err := NewHTTP(c.URL, c.Secret, r, repoRegex)
+			if err != nil {
+				return nil, err
+			}
+			h.StartQueue(DefaultHTTPQueueSize)
+			m.add(c.Event, h)
+		case EmailKind:
+			if emailClient == nil {
+				return nil, errors.New("must specify the top-level \"smtp\" config if using a webhook of \"kind: email\"")
+			}
+			e, err := NewEmail(c.Event, r, repoRegex, c.To, c.Subject, c.Body, emailClient)
+			if err != nil {
+				return nil, err
+			}
+			m.emailWebhooks = append(m.emailWebhooks, e)
+			m.add(c.Event, e)
 		default:
-			return nil, fmt.Errorf("\"kind: %s\" not supported. Only \"kind: %s\" is supported right now", c.Kind, SlackKind)
+			return nil, fmt.Errorf("\"kind: %s\" not supported. Only \"kind: %s\", \"kind: %s\" and \"kind: %s\" are supported right now", c.Kind, SlackKind, HTTPKind, EmailKind)
 		}
 	}
 
-	return &MultiWebhookSender{
-		Webhooks: webhooks,
-	}, nil
+	return m, nil
+}
+
+// add adds s to the webhooks for event.
+func (w *MultiWebhookSender) add(event string, s eventSender) {
+	switch event {
+	case ApplyEvent:
+		w.Webhooks = append(w.Webhooks, s)
+	case ApplyStartEvent:
+		w.ApplyStartWebhooks = append(w.ApplyStartWebhooks, s)
+	case DriftEvent:
+		w.DriftWebhooks = append(w.DriftWebhooks, s)
+	case LockEvent:
+		w.LockWebhooks = append(w.LockWebhooks, s)
+	case PlanEvent:
+		w.PlanWebhooks = append(w.PlanWebhooks, s)
+	case UnlockEvent:
+		w.UnlockWebhooks = append(w.UnlockWebhooks, s)
+	}
+}
+
+// SetLockURL sets the function email webhooks use to link to the page of the
+// lock with id.
+func (w *MultiWebhookSender) SetLockURL(f func(id string) (url string)) {
+	for _, e := range w.emailWebhooks {
+		e.LockURL = f
+	}
+}
+
+// matches returns true if workspace matches workspaceRegex and repoFullName
+// matches repoRegex. A nil repoRegex matches all repos.
+func matches(workspaceRegex *regexp.Regexp, repoRegex *regexp.Regexp, workspace string, repoFullName string) bool {
+	if repoRegex != nil && !repoRegex.MatchString(repoFullName) {
+		return false
+	}
+	return workspaceRegex.MatchString(workspace)
 }
 
 // Send sends the webhook using its Webhooks.
 func (w *MultiWebhookSender) Send(log *logging.SimpleLogger, result ApplyResult) error {
+	result.Summary = w.Redactor.Redact(result.Summary)
 	for _, w := range w.Webhooks {
 		if err := w.Send(log, result); err != nil {
-			log.Warn("error sending slack webhook: %s", err)
+			log.Warn("error sending webhook: %s", err)
+		}
+	}
+	return nil
+}
+
+// SendDrift sends the webhook using its DriftWebhooks.
+func (w *MultiWebhookSender) SendDrift(log *logging.SimpleLogger, result DriftResult) error {
+	result.Summary = w.Redactor.Redact(result.Summary)
+	for _, w := range w.DriftWebhooks {
+		if err := w.SendDrift(log, result); err != nil {
+			log.Warn("error sending webhook: %s", err)
+		}
+	}
+	return nil
+}
+
+// SendPlan sends the webhook using its PlanWebhooks.
+func (w *MultiWebhookSender) SendPlan(log *logging.SimpleLogger, result PlanResult) error {
+	result.Summary = w.Redactor.Redact(result.Summary)
+	for _, w := range w.PlanWebhooks {
+		if err := w.SendPlan(log, result); err != nil {
+			log.Warn("error sending webhook: %s", err)
+		}
+	}
+	return nil
+}
+
+// SendApplyStart sends the webhook using its ApplyStartWebhooks.
+func (w *MultiWebhookSender) SendApplyStart(log *logging.SimpleLogger, applyStart ApplyStart) error {
+	for _, w := range w.ApplyStartWebhooks {
+		if err := w.SendApplyStart(log, applyStart); err != nil {
+			log.Warn("error sending webhook: %s", err)
+		}
+	}
+	return nil
+}
+
+// SendLock sends the webhook using its LockWebhooks.
+func (w *MultiWebhookSender) SendLock(log *logging.SimpleLogger, result LockResult) error {
+	for _, w := range w.LockWebhooks {
+		if err := w.SendLock(log, result); err != nil {
+			log.Warn("error sending webhook: %s", err)
+		}
+	}
+	return nil
+}
+
+// SendUnlock sends the webhook using its UnlockWebhooks.
+func (w *MultiWebhookSender) SendUnlock(log *logging.SimpleLogger, result LockResult) error {
+	for _, w := range w.UnlockWebhooks {
+		if err := w.SendUnlock(log, result); err != nil {
+			log.Warn("error sending webhook: %s", err)
 		}
 	}
 	return nil
@@ -14,6 +14,7 @@
 package webhooks_test
 
 import (
+	"regexp"
 	"strings"
 	"testing"
 
@@ -21,6 +22,7 @@ import (
 	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/events/webhooks/mocks"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/redact"
 	. "github.com/runatlantis/atlantis/testing"
 )
 
@@ -51,7 +53,7 @@ func TestNewWebhooksManager_InvalidRegex(t *testing.T) {
 	invalidRegex := "("
 	configs := validConfigs()
 	configs[0].WorkspaceRegex = invalidRegex
-	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	_, err := webhooks.NewMultiWebhookSender(configs, client, nil)
 	Assert(t, err != nil, "expected error")
 	Assert(t, strings.Contains(err.Error(), "error parsing regexp"), "expected regex error")
 }
@@ -62,7 +64,7 @@ func TestNewWebhooksManager_NoEvent(t *testing.T) {
 	client := mocks.NewMockSlackClient()
 	configs := validConfigs()
 	configs[0].Event = ""
-	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	_, err := webhooks.NewMultiWebhookSender(configs, client, nil)
 	Assert(t, err != nil, "expected error")
 	Equals(t, "must specify \"kind\" and \"event\" keys for webhooks", err.Error())
 }
@@ -76,9 +78,9 @@ func TestNewWebhooksManager_UnsupportedEvent(t *testing.T) {
 	unsupportedEvent := "badevent"
 	configs := validConfigs()
 	configs[0].Event = unsupportedEvent
-	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	_, err := webhooks.NewMultiWebhookSender(configs, client, nil)
 	Assert(t, err != nil, "expected error")
-	Equals(t, "\"event: badevent\" not supported. Only \"event: apply\" is supported right now", err.Error())
+	Equals(t, "\"event: badevent\" not supported. Only \"event: apply\", \"event: apply-start\", \"event: drift\", \"event: lock\", \"event: plan\" and \"event: unlock\" are supported right now", err.Error())
 }
 
 func TestNewWebhooksManager_NoKind(t *testing.T) {
@@ -87,7 +89,7 @@ func TestNewWebhooksManager_NoKind(t *testing.T) {
 	client := mocks.NewMockSlackClient()
 	configs := validConfigs()
 	configs[0].Kind = ""
-	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	_, err := webhooks.NewMultiWebhookSender(configs, client, nil)
 	Assert(t, err != nil, "expected error")
 	Equals(t, "must specify \"kind\" and \"event\" keys for webhooks", err.Error())
 }
@@ -101,9 +103,9 @@ func TestNewWebhooksManager_UnsupportedKind(t *testing.T) {
 	unsupportedKind := "badkind"
 	configs := validConfigs()
 	configs[0].Kind = unsupportedKind
-	_, err := webhooks.NewMultiWebhookSender(configs, client)
+	_, err := webhooks.NewMultiWebhookSender(configs, client, nil)
 	Assert(t, err != nil, "expected error")
-	Equals(t, "\"kind: badkind\" not supported. Only \"kind: slack\" is supported right now", err.Error())
+	Equals(t, "\"kind: badkind\" not supported. Only \"kind: slack\", \"kind: http\" and \"kind: email\" are supported right now", err.Error())
 }
 
 func TestNewWebhooksManager_NoConfigSuccess(t *testing.T) {
@@ -111,13 +113,13 @@ func TestNewWebhooksManager_NoConfigSuccess(t *testing.T) {
 	t.Log("passing any client should succeed")
 	var emptyConfigs []webhooks.Config
 	emptyToken := ""
-	m, err := webhooks.NewMultiWebhookSender(emptyConfigs, webhooks.NewSlackClient(emptyToken))
+	m, err := webhooks.NewMultiWebhookSender(emptyConfigs, webhooks.NewSlackClient(emptyToken), nil)
 	Ok(t, err)
 	Assert(t, m != nil, "manager shouldn't be nil")
 	Equals(t, 0, len(m.Webhooks))
 
 	t.Log("passing nil client hould succeed")
-	m, err = webhooks.NewMultiWebhookSender(emptyConfigs, nil)
+	m, err = webhooks.NewMultiWebhookSender(emptyConfigs, nil, nil)
 	Ok(t, err)
 	Assert(t, m != nil, "manager shouldn't be nil")
 	Equals(t, 0, len(m.Webhooks))
@@ -130,7 +132,7 @@ func TestNewWebhooksManager_SingleConfigSuccess(t *testing.T) {
 	When(client.ChannelExists(validChannel)).ThenReturn(true, nil)
 
 	configs := validConfigs()
-	m, err := webhooks.NewMultiWebhookSender(configs, client)
+	m, err := webhooks.NewMultiWebhookSender(configs, client, nil)
 	Ok(t, err)
 	Assert(t, m != nil, "manager shouldn't be nil")
 	Equals(t, 1, len(m.Webhooks))
@@ -148,12 +150,27 @@ func TestNewWebhooksManager_MultipleConfigSuccess(t *testing.T) {
 	for i := 0; i < nConfigs; i++ {
 		configs = append(configs, validConfig)
 	}
-	m, err := webhooks.NewMultiWebhookSender(configs, client)
+	m, err := webhooks.NewMultiWebhookSender(configs, client, nil)
 	Ok(t, err)
 	Assert(t, m != nil, "manager shouldn't be nil")
 	Equals(t, nConfigs, len(m.Webhooks))
 }
 
+func TestNewWebhooksManager_DriftConfig(t *testing.T) {
+	t.Log("Webhooks for drift events should only be sent drift results")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	When(client.TokenIsSet()).ThenReturn(true)
+	When(client.ChannelExists(validChannel)).ThenReturn(true, nil)
+
+	driftConfig := validConfig
+	driftConfig.Event = webhooks.DriftEvent
+	m, err := webhooks.NewMultiWebhookSender([]webhooks.Config{validConfig, driftConfig}, client, nil)
+	Ok(t, err)
+	Equals(t, 1, len(m.Webhooks))
+	Equals(t, 1, len(m.DriftWebhooks))
+}
+
 func TestSend_SingleSuccess(t *testing.T) {
 	t.Log("Sending one webhook should succeed")
 	RegisterMockTestingT(t)
@@ -186,3 +203,155 @@ func TestSend_MultipleSuccess(t *testing.T) {
 		s.VerifyWasCalledOnce().Send(logger, result)
 	}
 }
+
+func TestSendDrift_Success(t *testing.T) {
+	t.Log("Sending drift should only send to the drift webhooks")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	regex, err := regexp.Compile(".*")
+	Ok(t, err)
+	applyHook := &webhooks.SlackWebhook{Client: client, WorkspaceRegex: regex, Channel: "apply"}
+	driftHook := &webhooks.SlackWebhook{Client: client, WorkspaceRegex: regex, Channel: "drift"}
+	manager := webhooks.MultiWebhookSender{
+		Webhooks:      []webhooks.Sender{applyHook},
+		DriftWebhooks: []webhooks.DriftSender{driftHook},
+	}
+	result := webhooks.DriftResult{Workspace: "production"}
+	err = manager.SendDrift(logging.NewNoopLogger(), result)
+	Ok(t, err)
+	client.VerifyWasCalledOnce().PostDriftMessage("drift", result)
+	client.VerifyWasCalled(Never()).PostDriftMessage("apply", result)
+}
+
+func TestSendDrift_Redactor(t *testing.T) {
+	t.Log("Secrets in the drift summary should be masked")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	regex, err := regexp.Compile(".*")
+	Ok(t, err)
+	redactor, err := redact.New(nil)
+	Ok(t, err)
+	redactor.AddSecret("hunter2")
+	manager := webhooks.MultiWebhookSender{
+		DriftWebhooks: []webhooks.DriftSender{&webhooks.SlackWebhook{Client: client, WorkspaceRegex: regex, Channel: "drift"}},
+		Redactor:      redactor,
+	}
+	err = manager.SendDrift(logging.NewNoopLogger(), webhooks.DriftResult{Workspace: "production", Summary: "password: hunter2"})
+	Ok(t, err)
+	client.VerifyWasCalledOnce().PostDriftMessage("drift", webhooks.DriftResult{Workspace: "production", Summary: "password: [REDACTED]"})
+}
+
+func TestNewWebhooksManager_HTTPConfig(t *testing.T) {
+	t.Log("http webhooks should be created for apply and drift events")
+	m, err := webhooks.NewMultiWebhookSender([]webhooks.Config{
+		{Event: webhooks.ApplyEvent, WorkspaceRegex: ".*", RepoRegex: "^runatlantis/", Kind: webhooks.HTTPKind, URL: "https://example.com", Secret: "secret"},
+		{Event: webhooks.DriftEvent, WorkspaceRegex: ".*", Kind: webhooks.HTTPKind, URL: "https://example.com", Secret: "secret"},
+	}, nil, nil)
+	Ok(t, err)
+	Equals(t, 1, len(m.Webhooks))
+	Equals(t, 1, len(m.DriftWebhooks))
+	h := m.Webhooks[0].(*webhooks.HTTPWebhook)
+	Equals(t, "^runatlantis/", h.RepoRegex.String())
+}
+
+func TestNewWebhooksManager_InvalidRepoRegex(t *testing.T) {
+	_, err := webhooks.NewMultiWebhookSender([]webhooks.Config{
+		{Event: webhooks.ApplyEvent, WorkspaceRegex: ".*", RepoRegex: "(", Kind: webhooks.HTTPKind, URL: "https://example.com", Secret: "secret"},
+	}, nil, nil)
+	Assert(t, err != nil, "expected error")
+	Assert(t, strings.Contains(err.Error(), "error parsing regexp"), "expected regex error")
+}
+
+func TestNewWebhooksManager_SlackUnsupportedEvent(t *testing.T) {
+	t.Log("slack webhooks should only support apply and drift events")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockSlackClient()
+	When(client.TokenIsSet()).ThenReturn(true)
+	When(client.ChannelExists(validChannel)).ThenReturn(true, nil)
+
+	planConfig := validConfig
+	planConfig.Event = webhooks.PlanEvent
+	_, err := webhooks.NewMultiWebhookSender([]webhooks.Config{planConfig}, client, nil)
+	ErrEquals(t, "\"event: plan\" is only supported by webhooks of \"kind: http\" and \"kind: email\"", err)
+}
+
+func TestNewWebhooksManager_HTTPEvents(t *testing.T) {
+	t.Log("http webhooks should be routed by their event")
+	var configs []webhooks.Config
+	for _, event := range []string{webhooks.PlanEvent, webhooks.ApplyStartEvent, webhooks.LockEvent, webhooks.UnlockEvent, webhooks.UnlockEvent} {
+		configs = append(configs, webhooks.Config{Event: event, WorkspaceRegex: ".*", Kind: webhooks.HTTPKind, URL: "https://example.com", Secret: "secret"})
+	}
+	m, err := webhooks.NewMultiWebhookSender(configs, nil, nil)
+	Ok(t, err)
+	Equals(t, 0, len(m.Webhooks))
+	Equals(t, 1, len(m.PlanWebhooks))
+	Equals(t, 1, len(m.ApplyStartWebhooks))
+	Equals(t, 1, len(m.LockWebhooks))
+	Equals(t, 2, len(m.UnlockWebhooks))
+}
+
+// fakePlanSender records the plan results it's sent.
+type fakePlanSender struct {
+	results []webhooks.PlanResult
+}
+
+func (f *fakePlanSender) SendPlan(_ *logging.SimpleLogger, result webhooks.PlanResult) error {
+	f.results = append(f.results, result)
+	return nil
+}
+
+func TestSendPlan_Redactor(t *testing.T) {
+	t.Log("Secrets in the plan summary should be masked")
+	redactor, err := redact.New(nil)
+	Ok(t, err)
+	redactor.AddSecret("hunter2")
+	sender := &fakePlanSender{}
+	manager := webhooks.MultiWebhookSender{
+		PlanWebhooks: []webhooks.PlanSender{sender},
+		Redactor:     redactor,
+	}
+	err = manager.SendPlan(logging.NewNoopLogger(), webhooks.PlanResult{Workspace: "production", Summary: "password: hunter2"})
+	Ok(t, err)
+	Equals(t, []webhooks.PlanResult{{Workspace: "production", Summary: "password: [REDACTED]"}}, sender.results)
+}
+
+func TestNewWebhooksManager_EmailConfig(t *testing.T) {
+	t.Log("email webhooks should be routed by their event and told how to link to locks")
+	RegisterMockTestingT(t)
+	client := mocks.NewMockEmailClient()
+	m, err := webhooks.NewMultiWebhookSender([]webhooks.Config{
+		{Event: webhooks.ApplyEvent, WorkspaceRegex: ".*", Kind: webhooks.EmailKind, To: []string{"cab@example.com"}},
+		{Event: webhooks.PlanEvent, WorkspaceRegex: ".*", Kind: webhooks.EmailKind, To: []string{"cab@example.com"}},
+	}, nil, client)
+	Ok(t, err)
+	Equals(t, 1, len(m.Webhooks))
+	Equals(t, 1, len(m.PlanWebhooks))
+
+	m.SetLockURL(func(id string) string { return "lock-" + id })
+	e := m.Webhooks[0].(*webhooks.EmailWebhook)
+	Equals(t, "lock-id", e.LockURL("id"))
+}
+
+func TestNewWebhooksManager_EmailNoSMTP(t *testing.T) {
+	_, err := webhooks.NewMultiWebhookSender([]webhooks.Config{
+		{Event: webhooks.ApplyEvent, WorkspaceRegex: ".*", Kind: webhooks.EmailKind, To: []string{"cab@example.com"}},
+	}, nil, nil)
+	ErrEquals(t, "must specify the top-level \"smtp\" config if using a webhook of \"kind: email\"", err)
+}
+
+func TestSend_Redactor(t *testing.T) {
+	t.Log("Secrets in the apply summary should be masked")
+	RegisterMockTestingT(t)
+	sender := mocks.NewMockSender()
+	redactor, err := redact.New(nil)
+	Ok(t, err)
+	redactor.AddSecret("hunter2")
+	manager := webhooks.MultiWebhookSender{
+		Webhooks: []webhooks.Sender{sender},
+		Redactor: redactor,
+	}
+	logger := logging.NewNoopLogger()
+	err = manager.Send(logger, webhooks.ApplyResult{Summary: "password: hunter2"})
+	Ok(t, err)
+	sender.VerifyWasCalledOnce().Send(logger, webhooks.ApplyResult{Summary: "password: [REDACTED]"})
+}
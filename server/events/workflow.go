@@ -0,0 +1,267 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/run"
+	"github.com/runatlantis/atlantis/server/events/terraform"
+	"gopkg.in/yaml.v2"
+)
+
+// The names of the steps a workflow can have.
+const (
+	InitStepName  = "init"
+	PlanStepName  = "plan"
+	ApplyStepName = "apply"
+	RunStepName   = "run"
+	EnvStepName   = "env"
+)
+
+// DefaultWorkflowName is the name of the server-side workflow that's used for
+// projects that don't specify one.
+const DefaultWorkflowName = "default"
+
+// Workflow replaces the steps Atlantis runs for plan and apply. Stages that
+// aren't set use the default steps.
+type Workflow struct {
+	Plan  *Stage `yaml:"plan"`
+	Apply *Stage `yaml:"apply"`
+}
+
+// Stage is the steps of one command.
+type Stage struct {
+	Steps []Step `yaml:"steps"`
+}
+
+// Step is a single step of a workflow stage.
+type Step struct {
+	// Name is one of init, plan, apply, run or env.
+	Name string
+	// ExtraArgs are appended to the terraform command of init, plan and
+	// apply steps.
+	ExtraArgs []string
+	// RunCommand is the shell command of run steps.
+	RunCommand string
+	// EnvName is the name of the environment variable env steps set. It's
+	// set to EnvValue or, if EnvCommand is set, to its output.
+	EnvName    string
+	EnvValue   string
+	EnvCommand string
+}
+
+// stepArgsYAML is used to parse the args of steps written as maps.
+type stepArgsYAML struct {
+	ExtraArgs []string `yaml:"extra_args"`
+	Name      string   `yaml:"name"`
+	Value     string   `yaml:"value"`
+	Command   string   `yaml:"command"`
+}
+
+// UnmarshalYAML parses steps, which are either the name of a step, ex.
+// "init", or a map with one key, ex. {run: "tflint"} or
+// {plan: {extra_args: ["-lock=false"]}}.
+func (s *Step) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		switch name {
+		case InitStepName, PlanStepName, ApplyStepName:
+			*s = Step{Name: name}
+			return nil
+		case RunStepName, EnvStepName:
+			return fmt.Errorf("%q steps must be maps, ex. {%s: ...}", name, name)
+		default:
+			return fmt.Errorf("unknown step %q", name)
+		}
+	}
+
+	var runStep map[string]string
+	if err := unmarshal(&runStep); err == nil && len(runStep) == 1 && runStep[RunStepName] != "" {
+		*s = Step{Name: RunStepName, RunCommand: runStep[RunStepName]}
+		return nil
+	}
+
+	var step map[string]stepArgsYAML
+	if err := unmarshal(&step); err != nil {
+		return errors.Wrap(err, "parsing step")
+	}
+	if len(step) != 1 {
+		return fmt.Errorf("steps must have exactly one key but got %d", len(step))
+	}
+	for name, args := range step {
+		switch name {
+		case InitStepName, PlanStepName, ApplyStepName:
+			if args.Name != "" || args.Value != "" || args.Command != "" {
+				return fmt.Errorf("%q steps only support extra_args", name)
+			}
+			*s = Step{Name: name, ExtraArgs: args.ExtraArgs}
+		case EnvStepName:
+			if args.Name == "" || (args.Value == "") == (args.Command == "") || len(args.ExtraArgs) > 0 {
+				return fmt.Errorf("%q steps must have a name and one of value or command", name)
+			}
+			*s = Step{Name: name, EnvName: args.Name, EnvValue: args.Value, EnvCommand: args.Command}
+		case RunStepName:
+			return fmt.Errorf("%q steps must be a command, ex. {%s: \"make\"}", name, name)
+		default:
+			return fmt.Errorf("unknown step %q", name)
+		}
+	}
+	return nil
+}
+
+// PlanSteps returns the steps to run for plan.
+func (w Workflow) PlanSteps() []Step {
+	if w.Plan != nil {
+		return w.Plan.Steps
+	}
+	return []Step{{Name: InitStepName}, {Name: PlanStepName}}
+}
+
+// ApplySteps returns the steps to run for apply.
+func (w Workflow) ApplySteps() []Step {
+	if w.Apply != nil {
+		return w.Apply.Steps
+	}
+	return []Step{{Name: ApplyStepName}}
+}
+
+// runsInit returns true if w runs its own steps for command, in which case
+// they replace the default init. w can be nil.
+func (w *Workflow) runsInit(command CommandName) bool {
+	if w == nil {
+		return false
+	}
+	// Plan stages always run in place of the default init, even if they
+	// leave out the init step.
+	return command == Plan || w.Apply != nil
+}
+
+// workflowsFileYAML is used to parse the server-side workflows file.
+type workflowsFileYAML struct {
+	Workflows map[string]Workflow `yaml:"workflows"`
+}
+
+// ReadWorkflowsFile reads the server-side workflows file at path. It has the
+// same format as the workflows section of atlantis.yaml.
+func ReadWorkflowsFile(path string) (map[string]Workflow, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading workflows file")
+	}
+	var file workflowsFileYAML
+	if err := yaml.UnmarshalStrict(raw, &file); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", path)
+	}
+	return file.Workflows, nil
+}
+
+// resolveWorkflow returns the workflow the project config uses. Workflows
+// defined in the project config take precedence over serverWorkflows. If the
+// config doesn't name a workflow, the server's default workflow is used. It
+// returns nil if there's no workflow, in which case the default steps are
+// used.
+func resolveWorkflow(config ProjectConfig, serverWorkflows map[string]Workflow) (*Workflow, error) {
+	name := config.WorkflowName
+	if name == "" {
+		if w, ok := serverWorkflows[DefaultWorkflowName]; ok {
+			return &w, nil
+		}
+		return nil, nil
+	}
+	if w, ok := config.Workflows[name]; ok {
+		return &w, nil
+	}
+	if w, ok := serverWorkflows[name]; ok {
+		return &w, nil
+	}
+	return nil, fmt.Errorf("workflow %q isn't defined in %s or on the server", name, ProjectConfigFile)
+}
+
+// workflowRun runs the steps of a workflow for a project.
+type workflowRun struct {
+	ctx       *CommandContext
+	terraform terraform.Client
+	run       run.Runner
+	config    ProjectConfig
+	// env is the environment of run steps. Its Vars are set by env steps.
+	env run.Env
+}
+
+// runSteps runs steps in order and returns the combined output of every
+// step except init, the same as what's shown without a workflow. If a step
+// fails, the output so far is included in the error.
+func (w *workflowRun) runSteps(steps []Step) (string, error) {
+	var outputs []string
+	for _, step := range steps {
+		output, err := w.runStep(step)
+		if err != nil {
+			return "", fmt.Errorf("running %s step: %s\n%s", step.Name, err, strings.Join(append(outputs, output), "\n"))
+		}
+		if step.Name != InitStepName && output != "" {
+			outputs = append(outputs, output)
+		}
+	}
+	return strings.Join(outputs, "\n"), nil
+}
+
+func (w *workflowRun) runStep(step Step) (string, error) {
+	workspace := w.ctx.Command.Workspace
+	projectDir := w.env.Dir
+	terraformVersion := w.env.TerraformVersion
+	switch step.Name {
+	case InitStepName:
+		// init was only introduced in 0.9.
+		if terraform.MustConstraint(">= 0.9.0").Check(terraformVersion) {
+			outputs, err := w.terraform.Init(w.ctx.Log, projectDir, workspace, append(w.config.GetExtraArguments(InitStepName), step.ExtraArgs...), terraformVersion)
+			return strings.Join(outputs, "\n"), err
+		}
+		getCmd := append(append([]string{"get", "-no-color"}, w.config.GetExtraArguments("get")...), step.ExtraArgs...)
+		return w.terraform.RunCommandWithVersion(w.ctx.Log, projectDir, getCmd, terraformVersion, workspace)
+	case PlanStepName:
+		userVar := fmt.Sprintf("%s=%s", atlantisUserTFVar, w.ctx.User.Username)
+		planCmd := []string{"plan", "-refresh", "-no-color", "-out", w.env.PlanFile, "-var", userVar}
+		extraArgs := append(append([]string{}, w.config.GetExtraArguments(PlanStepName)...), step.ExtraArgs...)
+		planCmd = append(planCmd, planArgs(w.ctx, projectDir, extraArgs)...)
+		return w.terraform.RunCommandWithVersion(w.ctx.Log, projectDir, planCmd, terraformVersion, workspace)
+	case ApplyStepName:
+		applyCmd := append(append(append([]string{"apply", "-no-color"}, w.config.GetExtraArguments(ApplyStepName)...), step.ExtraArgs...), w.ctx.Command.Flags...)
+		return w.terraform.RunCommandWithVersion(w.ctx.Log, projectDir, append(applyCmd, w.env.PlanFile), terraformVersion, workspace)
+	case RunStepName:
+		return w.run.Execute(w.ctx.Log, []string{step.RunCommand}, w.env, RunStepName)
+	case EnvStepName:
+		value := step.EnvValue
+		if step.EnvCommand != "" {
+			output, err := w.run.Execute(w.ctx.Log, []string{step.EnvCommand}, w.env, EnvStepName)
+			if err != nil {
+				return "", err
+			}
+			value = strings.TrimSpace(output)
+		}
+		// Copy the vars so envs passed to earlier steps aren't modified.
+		vars := map[string]string{step.EnvName: value}
+		for name, v := range w.env.Vars {
+			if name != step.EnvName {
+				vars[name] = v
+			}
+		}
+		w.env.Vars = vars
+		return "", nil
+	default:
+		return "", fmt.Errorf("unknown step %q", step.Name)
+	}
+}
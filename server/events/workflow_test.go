@@ -0,0 +1,146 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package events_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/events"
+	. "github.com/runatlantis/atlantis/testing"
+	"gopkg.in/yaml.v2"
+)
+
+func TestStep_UnmarshalYAML(t *testing.T) {
+	cases := []struct {
+		description string
+		input       string
+		exp         events.Step
+		expErr      string
+	}{
+		{
+			"name only",
+			"init",
+			events.Step{Name: "init"},
+			"",
+		},
+		{
+			"extra args",
+			"plan: {extra_args: [-lock=false]}",
+			events.Step{Name: "plan", ExtraArgs: []string{"-lock=false"}},
+			"",
+		},
+		{
+			"run",
+			"run: make test",
+			events.Step{Name: "run", RunCommand: "make test"},
+			"",
+		},
+		{
+			"env with value",
+			"env: {name: STAGE, value: prod}",
+			events.Step{Name: "env", EnvName: "STAGE", EnvValue: "prod"},
+			"",
+		},
+		{
+			"env with command",
+			"env: {name: STAGE, command: cat stage}",
+			events.Step{Name: "env", EnvName: "STAGE", EnvCommand: "cat stage"},
+			"",
+		},
+		{
+			"unknown name",
+			"validate",
+			events.Step{},
+			`unknown step "validate"`,
+		},
+		{
+			"run without a command",
+			"run",
+			events.Step{},
+			`"run" steps must be maps, ex. {run: ...}`,
+		},
+		{
+			"env with value and command",
+			"env: {name: STAGE, value: prod, command: cat stage}",
+			events.Step{},
+			`"env" steps must have a name and one of value or command`,
+		},
+		{
+			"multiple keys",
+			"{init: {}, plan: {}}",
+			events.Step{},
+			"steps must have exactly one key but got 2",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.description, func(t *testing.T) {
+			var step events.Step
+			err := yaml.Unmarshal([]byte(c.input), &step)
+			if c.expErr != "" {
+				ErrEquals(t, c.expErr, err)
+				return
+			}
+			Ok(t, err)
+			Equals(t, c.exp, step)
+		})
+	}
+}
+
+func TestWorkflow_DefaultSteps(t *testing.T) {
+	t.Log("stages that aren't set should use the default steps")
+	w := events.Workflow{Apply: &events.Stage{Steps: []events.Step{{Name: "run", RunCommand: "echo"}, {Name: "apply"}}}}
+	Equals(t, []events.Step{{Name: "init"}, {Name: "plan"}}, w.PlanSteps())
+	Equals(t, []events.Step{{Name: "run", RunCommand: "echo"}, {Name: "apply"}}, w.ApplySteps())
+}
+
+func TestReadWorkflowsFile(t *testing.T) {
+	t.Log("should parse the workflows in the file by name")
+	tmp, err := ioutil.TempFile("", "")
+	Ok(t, err)
+	defer os.Remove(tmp.Name()) // nolint: errcheck
+	_, err = tmp.WriteString(`
+workflows:
+  default:
+    apply:
+      steps:
+      - run: ./notify.sh
+      - apply
+`)
+	Ok(t, err)
+	Ok(t, tmp.Close())
+
+	workflows, err := events.ReadWorkflowsFile(tmp.Name())
+	Ok(t, err)
+	Equals(t, map[string]events.Workflow{
+		"default": {Apply: &events.Stage{Steps: []events.Step{
+			{Name: "run", RunCommand: "./notify.sh"},
+			{Name: "apply"},
+		}}},
+	}, workflows)
+}
+
+func TestReadWorkflowsFile_Invalid(t *testing.T) {
+	t.Log("should error if the file has unknown keys")
+	tmp, err := ioutil.TempFile("", "")
+	Ok(t, err)
+	defer os.Remove(tmp.Name()) // nolint: errcheck
+	_, err = tmp.WriteString("workflow: {}\n")
+	Ok(t, err)
+	Ok(t, tmp.Close())
+
+	_, err = events.ReadWorkflowsFile(tmp.Name())
+	Assert(t, err != nil, "exp an error")
+}
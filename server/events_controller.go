@@ -14,8 +14,16 @@
 package server
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/lkysow/go-gitlab"
@@ -23,10 +31,29 @@ import (
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/metrics"
 )
 
 const githubHeader = "X-Github-Event"
 const gitlabHeader = "X-Gitlab-Event"
+const githubDeliveryHeader = "X-Github-Delivery"
+const gitlabDeliveryHeader = "X-Gitlab-Event-UUID"
+
+// githubEnterpriseHostHeader is set by GitHub Enterprise to the hostname of
+// the install that sent the webhook. It isn't set by github.com.
+const githubEnterpriseHostHeader = "X-Github-Enterprise-Host"
+
+// gitlabInstanceHeader is set by newer versions of GitLab to the URL of the
+// install that sent the webhook.
+const gitlabInstanceHeader = "X-Gitlab-Instance"
+
+// deliveryTTL is how long we remember a webhook delivery for when checking
+// for duplicates.
+const deliveryTTL = 24 * time.Hour
+
+// DeliveryPruneInterval is how often deliveries older than deliveryTTL are
+// forgotten and their archived payloads removed.
+const DeliveryPruneInterval = time.Hour
 
 // EventsController handles all webhook requests which signify 'events' in the
 // VCS host, ex. GitHub. It's split out from Server to make testing easier.
@@ -36,56 +63,101 @@ type EventsController struct {
 	Logger        *logging.SimpleLogger
 	Parser        events.EventParsing
 	CommentParser events.CommentParsing
-	// GithubWebHookSecret is the secret added to this webhook via the GitHub
-	// UI that identifies this call as coming from GitHub. If empty, no
-	// request validation is done.
-	GithubWebHookSecret    []byte
+	// GithubWebHookSecrets maps the hostname of each GitHub install we
+	// support to the secret added to its webhooks via the GitHub UI that
+	// identifies the call as coming from that install. If a secret is empty,
+	// no request validation is done for that install.
+	GithubWebHookSecrets   map[string][]byte
 	GithubRequestValidator GithubRequestValidator
 	GitlabRequestParser    GitlabRequestParser
-	// GitlabWebHookSecret is the secret added to this webhook via the GitLab
-	// UI that identifies this call as coming from GitLab. If empty, no
-	// request validation is done.
-	GitlabWebHookSecret []byte
-	RepoWhitelist       *events.RepoWhitelist
+	// GitlabWebHookSecrets maps the hostname of each GitLab install we
+	// support to the secret added to its webhooks via the GitLab UI. If a
+	// secret is empty, no request validation is done for that install.
+	GitlabWebHookSecrets map[string][]byte
+	RepoWhitelist        *events.RepoWhitelist
 	// SupportedVCSHosts is which VCS hosts Atlantis was configured upon
 	// startup to support.
 	SupportedVCSHosts []vcs.Host
 	VCSClient         vcs.ClientProxy
+	// DeliveryRecorder is used to detect webhook deliveries that we've
+	// already processed.
+	DeliveryRecorder DeliveryRecorder
+	// DeliveryArchive stores the raw webhook payloads so they can be replayed.
+	// If nil, payloads aren't stored.
+	DeliveryArchive *DeliveryArchive
+	// Metrics counts the webhooks we receive. If nil, they aren't counted.
+	Metrics *metrics.Metrics
 }
 
 // Post handles POST webhook requests.
 func (e *EventsController) Post(w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get(githubHeader) != "" {
+		e.Metrics.WebhookReceived("github", r.Header.Get(githubHeader))
 		if !e.supportsHost(vcs.Github) {
 			e.respond(w, logging.Debug, http.StatusBadRequest, "Ignoring request since not configured to support GitHub")
 			return
 		}
-		e.handleGithubPost(w, r)
+		body, ok := e.readBody(w, r)
+		if !ok {
+			return
+		}
+		e.handleGithubPost(w, r, body)
 		return
 	} else if r.Header.Get(gitlabHeader) != "" {
+		e.Metrics.WebhookReceived("gitlab", r.Header.Get(gitlabHeader))
 		if !e.supportsHost(vcs.Gitlab) {
 			e.respond(w, logging.Debug, http.StatusBadRequest, "Ignoring request since not configured to support GitLab")
 			return
 		}
-		e.handleGitlabPost(w, r)
+		body, ok := e.readBody(w, r)
+		if !ok {
+			return
+		}
+		e.handleGitlabPost(w, r, body)
 		return
 	}
 	e.respond(w, logging.Debug, http.StatusBadRequest, "Ignoring request")
 }
 
-func (e *EventsController) handleGithubPost(w http.ResponseWriter, r *http.Request) {
+func (e *EventsController) handleGithubPost(w http.ResponseWriter, r *http.Request, body []byte) {
+	// GitHub Enterprise tells us which install the webhook is from. If it's
+	// not set then it's from github.com.
+	hostname := r.Header.Get(githubEnterpriseHostHeader)
+	if hostname == "" {
+		hostname = "github.com"
+	}
+	hostname, secret, ok := e.webhookSecret(e.GithubWebHookSecrets, hostname)
+	if !ok {
+		e.respond(w, logging.Warn, http.StatusBadRequest, "Ignoring request from GitHub host %q since it isn't configured", hostname)
+		return
+	}
+
 	// Validate the request against the optional webhook secret.
-	payload, err := e.GithubRequestValidator.Validate(r, e.GithubWebHookSecret)
+	payload, err := e.GithubRequestValidator.Validate(r, secret)
 	if err != nil {
 		e.respond(w, logging.Warn, http.StatusBadRequest, err.Error())
 		return
 	}
+	if !e.checkPayloadHost(w, e.GithubWebHookSecrets, hostname, payload) {
+		return
+	}
+
+	deliveryID := r.Header.Get(githubDeliveryHeader)
+	if !e.recordDelivery(w, r, vcs.Github, deliveryID, body, e.isReplay(r)) {
+		return
+	}
 
-	githubReqID := "X-Github-Delivery=" + r.Header.Get("X-Github-Delivery")
+	githubReqID := "X-Github-Delivery=" + deliveryID
+	// Our version of go-github doesn't support check run events so we parse
+	// them ourselves.
+	if github.WebHookType(r) == "check_run" {
+		e.HandleGithubCheckRunEvent(w, payload, githubReqID, deliveryID)
+		return
+	}
 	event, _ := github.ParseWebHook(github.WebHookType(r), payload)
 	switch event := event.(type) {
 	case *github.IssueCommentEvent:
-		e.HandleGithubCommentEvent(w, event, githubReqID)
+		e.HandleGithubCommentEvent(w, event, githubReqID, deliveryID)
 	case *github.PullRequestEvent:
 		e.HandleGithubPullRequestEvent(w, event, githubReqID)
 	default:
@@ -95,7 +167,7 @@ func (e *EventsController) handleGithubPost(w http.ResponseWriter, r *http.Reque
 
 // HandleGithubCommentEvent handles comment events from GitHub where Atlantis
 // commands can come from. It's exported to make testing easier.
-func (e *EventsController) HandleGithubCommentEvent(w http.ResponseWriter, event *github.IssueCommentEvent, githubReqID string) {
+func (e *EventsController) HandleGithubCommentEvent(w http.ResponseWriter, event *github.IssueCommentEvent, githubReqID string, deliveryID string) {
 	if event.GetAction() != "created" {
 		e.respond(w, logging.Debug, http.StatusOK, "Ignoring comment event since action was not created %s", githubReqID)
 		return
@@ -111,7 +183,65 @@ func (e *EventsController) HandleGithubCommentEvent(w http.ResponseWriter, event
 	// calls to get that information but we need this code path to be generic.
 	// Later on in CommandHandler we detect that this is a GitHub event and
 	// make the necessary calls to get the headRepo.
-	e.handleCommentEvent(w, baseRepo, models.Repo{}, user, pullNum, event.Comment.GetBody(), vcs.Github)
+	e.handleCommentEvent(w, baseRepo, models.Repo{}, user, pullNum, event.Comment.GetBody(), vcs.Github, deliveryID)
+}
+
+// githubCheckRunEvent is the subset of a GitHub check_run webhook payload
+// that we use.
+type githubCheckRunEvent struct {
+	Action   string `json:"action"`
+	CheckRun struct {
+		ExternalID   string `json:"external_id"`
+		PullRequests []struct {
+			Number int `json:"number"`
+		} `json:"pull_requests"`
+	} `json:"check_run"`
+	RequestedAction struct {
+		Identifier string `json:"identifier"`
+	} `json:"requested_action"`
+	Repository *github.Repository `json:"repository"`
+	Sender     *github.User       `json:"sender"`
+}
+
+// HandleGithubCheckRunEvent runs apply when the apply action is requested on
+// a check run we created. It's exported to make testing easier.
+func (e *EventsController) HandleGithubCheckRunEvent(w http.ResponseWriter, payload []byte, githubReqID string, deliveryID string) {
+	var event githubCheckRunEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		e.respond(w, logging.Error, http.StatusBadRequest, "Failed parsing check run event: %s %s", err, githubReqID)
+		return
+	}
+	if event.Action != "requested_action" || event.RequestedAction.Identifier != events.ApplyCheckRunAction {
+		e.respond(w, logging.Debug, http.StatusOK, "Ignoring check run event since it's not an apply request %s", githubReqID)
+		return
+	}
+	projectPath, workspace, ok := events.ParseCheckRunExternalID(event.CheckRun.ExternalID)
+	if !ok || len(event.CheckRun.PullRequests) == 0 {
+		e.respond(w, logging.Debug, http.StatusOK, "Ignoring check run event since it's not for an Atlantis project in a pull request %s", githubReqID)
+		return
+	}
+	baseRepo, err := e.Parser.ParseGithubRepo(event.Repository)
+	if err != nil {
+		e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing repo data: %s %s", err, githubReqID)
+		return
+	}
+	if !e.RepoWhitelist.IsWhitelisted(baseRepo.FullName, baseRepo.Hostname) {
+		e.respond(w, logging.Warn, http.StatusForbidden, "Repo not whitelisted")
+		return
+	}
+	user := models.User{Username: event.Sender.GetLogin()}
+	pullNum := event.CheckRun.PullRequests[0].Number
+	cmd := &events.Command{
+		Name:         events.Apply,
+		Workspace:    workspace,
+		WorkspaceSet: true,
+		Dirs:         []string{projectPath},
+	}
+
+	// Like comments, we execute the command asynchronously and look up the
+	// head repo in CommandHandler.
+	fmt.Fprintln(w, "Processing...")
+	e.executeCommand(baseRepo, models.Repo{}, user, pullNum, cmd, vcs.Github, deliveryID)
 }
 
 // HandleGithubPullRequestEvent will delete any locks associated with the pull
@@ -148,15 +278,49 @@ func (e *EventsController) handlePullRequestEvent(w http.ResponseWriter, repo mo
 	fmt.Fprintln(w, "Pull request cleaned successfully")
 }
 
-func (e *EventsController) handleGitlabPost(w http.ResponseWriter, r *http.Request) {
-	event, err := e.GitlabRequestParser.Validate(r, e.GitlabWebHookSecret)
+func (e *EventsController) handleGitlabPost(w http.ResponseWriter, r *http.Request, body []byte) {
+	// Only newer versions of GitLab tell us which install the webhook is
+	// from so otherwise we use the project's URL. The payload hasn't been
+	// validated yet but it's validated against that install's secret next
+	// and then checkPayloadHost ensures the project is really on that install.
+	instanceURL := r.Header.Get(gitlabInstanceHeader)
+	if instanceURL == "" {
+		instanceURL = parseWebhookHosts(body).Project.WebURL
+	}
+	hostname, secret, ok := e.webhookSecret(e.GitlabWebHookSecrets, urlHostname(instanceURL))
+	if !ok {
+		e.respond(w, logging.Warn, http.StatusBadRequest, "Ignoring request from GitLab host %q since it isn't configured", hostname)
+		return
+	}
+
+	// We don't archive the secret GitLab sends so a replayed delivery is
+	// authenticated by the replay token instead.
+	replay := e.isReplay(r)
+	if replay {
+		secret = nil
+	}
+	event, err := e.GitlabRequestParser.Validate(r, secret)
 	if err != nil {
 		e.respond(w, logging.Warn, http.StatusBadRequest, err.Error())
 		return
 	}
+	if !e.checkPayloadHost(w, e.GitlabWebHookSecrets, hostname, body) {
+		return
+	}
+
+	// Older versions of GitLab don't send a delivery id. In that case we use
+	// a hash of the payload since redeliveries have an identical body.
+	deliveryID := r.Header.Get(gitlabDeliveryHeader)
+	if deliveryID == "" {
+		hash := sha256.Sum256(body)
+		deliveryID = hex.EncodeToString(hash[:])
+	}
+	if !e.recordDelivery(w, r, vcs.Gitlab, deliveryID, body, replay) {
+		return
+	}
 	switch event := event.(type) {
 	case gitlab.MergeCommentEvent:
-		e.HandleGitlabCommentEvent(w, event)
+		e.HandleGitlabCommentEvent(w, event, deliveryID)
 	case gitlab.MergeEvent:
 		e.HandleGitlabMergeRequestEvent(w, event)
 	default:
@@ -167,17 +331,17 @@ func (e *EventsController) handleGitlabPost(w http.ResponseWriter, r *http.Reque
 
 // HandleGitlabCommentEvent handles comment events from GitLab where Atlantis
 // commands can come from. It's exported to make testing easier.
-func (e *EventsController) HandleGitlabCommentEvent(w http.ResponseWriter, event gitlab.MergeCommentEvent) {
+func (e *EventsController) HandleGitlabCommentEvent(w http.ResponseWriter, event gitlab.MergeCommentEvent, deliveryID string) {
 	baseRepo, headRepo, user, err := e.Parser.ParseGitlabMergeCommentEvent(event)
 	if err != nil {
 		e.respond(w, logging.Error, http.StatusBadRequest, "Error parsing webhook: %s", err)
 		return
 	}
-	e.handleCommentEvent(w, baseRepo, headRepo, user, event.MergeRequest.IID, event.ObjectAttributes.Note, vcs.Gitlab)
+	e.handleCommentEvent(w, baseRepo, headRepo, user, event.MergeRequest.IID, event.ObjectAttributes.Note, vcs.Gitlab, deliveryID)
 }
 
-func (e *EventsController) handleCommentEvent(w http.ResponseWriter, baseRepo models.Repo, headRepo models.Repo, user models.User, pullNum int, comment string, vcsHost vcs.Host) {
-	parseResult := e.CommentParser.Parse(comment, vcsHost)
+func (e *EventsController) handleCommentEvent(w http.ResponseWriter, baseRepo models.Repo, headRepo models.Repo, user models.User, pullNum int, comment string, vcsHost vcs.Host, deliveryID string) {
+	parseResult := e.CommentParser.Parse(comment, vcsHost, baseRepo.Hostname)
 	if parseResult.Ignore {
 		truncated := comment
 		truncateLen := 40
@@ -215,7 +379,18 @@ func (e *EventsController) handleCommentEvent(w http.ResponseWriter, baseRepo mo
 	// We use a goroutine so that this function returns and the connection is
 	// closed.
 	fmt.Fprintln(w, "Processing...")
-	go e.CommandRunner.ExecuteCommand(baseRepo, headRepo, user, pullNum, parseResult.Command, vcsHost)
+	e.executeCommand(baseRepo, headRepo, user, pullNum, parseResult.Command, vcsHost, deliveryID)
+}
+
+// executeCommand runs cmd asynchronously with a new run ID. The run ID is
+// logged with deliveryID so the logs of the run can be found from the
+// webhook.
+func (e *EventsController) executeCommand(baseRepo models.Repo, headRepo models.Repo, user models.User, pullNum int, cmd *events.Command, vcsHost vcs.Host, deliveryID string) {
+	trace := events.NewTrace(deliveryID)
+	e.Logger.With("delivery_id", trace.DeliveryID).
+		With("run_id", trace.RunID).
+		Info("running command on %s#%d with run ID %s", baseRepo.FullName, pullNum, trace.RunID)
+	go e.CommandRunner.ExecuteCommand(baseRepo, headRepo, user, pullNum, cmd, vcsHost, trace)
 }
 
 // HandleGitlabMergeRequestEvent will delete any locks associated with the pull
@@ -230,6 +405,128 @@ func (e *EventsController) HandleGitlabMergeRequestEvent(w http.ResponseWriter,
 	e.handlePullRequestEvent(w, repo, pull, vcs.Gitlab)
 }
 
+// readBody reads the request body and then resets it so it can be read
+// again by the request validators. It returns false if the body couldn't be
+// read, in which case it has already responded to the request.
+func (e *EventsController) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Body == nil {
+		return nil, true
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		e.respond(w, logging.Warn, http.StatusBadRequest, "Could not read body: %s", err)
+		return nil, false
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+// recordDelivery records that the delivery with id was received and archives
+// its payload if configured to. It returns false if we've already processed
+// this delivery, in which case it has already responded to the request.
+// Deliveries that are being replayed are never treated as duplicates.
+func (e *EventsController) recordDelivery(w http.ResponseWriter, r *http.Request, host vcs.Host, id string, body []byte, replay bool) bool {
+	if id == "" {
+		return true
+	}
+	if replay {
+		e.Logger.Info("replaying delivery %s", id)
+		return true
+	}
+
+	duplicate, err := e.DeliveryRecorder.RecordDelivery(fmt.Sprintf("%s/%s", host, id), deliveryTTL)
+	if err != nil {
+		// We'd rather risk processing a delivery twice than drop it.
+		e.Logger.Warn("unable to record delivery %s: %s", id, err)
+	} else if duplicate {
+		e.respond(w, logging.Info, http.StatusOK, "Ignoring duplicate delivery %s", id)
+		return false
+	}
+
+	if e.DeliveryArchive != nil {
+		err := e.DeliveryArchive.Save(ArchivedDelivery{
+			ID:         id,
+			Header:     r.Header,
+			Body:       body,
+			ReceivedAt: time.Now(),
+		})
+		if err != nil {
+			e.Logger.Warn("unable to archive delivery %s: %s", id, err)
+		}
+	}
+	return true
+}
+
+// PruneDeliveries forgets the deliveries received more than deliveryTTL ago
+// and removes their archived payloads, since we keep them for as long as we
+// remember their ids. It's run every DeliveryPruneInterval rather than on
+// each request so handling webhooks doesn't slow down as they pile up.
+func (e *EventsController) PruneDeliveries() {
+	if err := e.DeliveryRecorder.PruneDeliveries(deliveryTTL); err != nil {
+		e.Logger.Warn("unable to prune delivery records: %s", err)
+	}
+	if e.DeliveryArchive != nil {
+		if err := e.DeliveryArchive.Prune(deliveryTTL); err != nil {
+			e.Logger.Warn("unable to prune archived deliveries: %s", err)
+		}
+	}
+}
+
+// isReplay returns true if r was sent by "atlantis replay". Anyone who can
+// send us webhooks can set the replay header so we only trust it if it
+// matches the archive's replay token.
+func (e *EventsController) isReplay(r *http.Request) bool {
+	header := r.Header.Get(ReplayHeader)
+	if header == "" {
+		return false
+	}
+	if e.DeliveryArchive == nil {
+		e.Logger.Warn("ignoring %s header since webhook payloads aren't being stored", ReplayHeader)
+		return false
+	}
+	token, err := e.DeliveryArchive.ReplayToken()
+	if err != nil {
+		e.Logger.Warn("ignoring %s header: %s", ReplayHeader, err)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(header), []byte(token)) != 1 {
+		e.Logger.Warn("ignoring %s header since it doesn't match the replay token", ReplayHeader)
+		return false
+	}
+	return true
+}
+
+// webhookSecret returns the secret for the VCS install at hostname from
+// secrets. If there's only one install configured, its secret is used no
+// matter the hostname so single install setups keep working even if the
+// hostname can't be determined. It returns false if there's no secret for the
+// install which means we don't support it.
+func (e *EventsController) webhookSecret(secrets map[string][]byte, hostname string) (string, []byte, bool) {
+	hostname, ok := vcs.InstallHostname(secrets, hostname)
+	return hostname, secrets[hostname], ok
+}
+
+// checkPayloadHost ensures that when we support multiple installs of a VCS
+// host, the repo in a validated webhook payload is on the install whose secret
+// we validated it with. Otherwise someone with the secret for one install
+// could run commands against repos on another. It returns false if the check
+// failed, in which case it has already responded to the request.
+func (e *EventsController) checkPayloadHost(w http.ResponseWriter, secrets map[string][]byte, hostname string, payload []byte) bool {
+	if len(secrets) <= 1 {
+		return true
+	}
+	hosts := parseWebhookHosts(payload)
+	cloneURL := hosts.Repository.CloneURL
+	if cloneURL == "" {
+		cloneURL = hosts.Project.GitHTTPURL
+	}
+	if repoHostname := urlHostname(cloneURL); repoHostname != hostname {
+		e.respond(w, logging.Warn, http.StatusBadRequest, "Ignoring request since repo host %q doesn't match webhook host %q", repoHostname, hostname)
+		return false
+	}
+	return true
+}
+
 // supportsHost returns true if h is in e.SupportedVCSHosts and false otherwise.
 func (e *EventsController) supportsHost(h vcs.Host) bool {
 	for _, supported := range e.SupportedVCSHosts {
@@ -240,6 +537,38 @@ func (e *EventsController) supportsHost(h vcs.Host) bool {
 	return false
 }
 
+// webhookHosts is the subset of GitHub and GitLab webhook payloads we use to
+// determine which install a webhook is for.
+type webhookHosts struct {
+	// Repository is set in GitHub payloads.
+	Repository struct {
+		CloneURL string `json:"clone_url"`
+	} `json:"repository"`
+	// Project is set in GitLab payloads.
+	Project struct {
+		WebURL     string `json:"web_url"`
+		GitHTTPURL string `json:"git_http_url"`
+	} `json:"project"`
+}
+
+// parseWebhookHosts parses payload. If it isn't valid JSON, the fields are
+// left empty.
+func parseWebhookHosts(payload []byte) webhookHosts {
+	var hosts webhookHosts
+	json.Unmarshal(payload, &hosts) // nolint: errcheck
+	return hosts
+}
+
+// urlHostname returns the hostname of rawURL without the port or an empty
+// string if it can't be parsed.
+func urlHostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
 func (e *EventsController) respond(w http.ResponseWriter, lvl logging.LogLevel, code int, format string, args ...interface{}) {
 	response := fmt.Sprintf(format, args...)
 	e.Logger.Log(lvl, response)
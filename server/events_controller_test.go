@@ -19,6 +19,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -35,11 +36,13 @@ import (
 	vcsmocks "github.com/runatlantis/atlantis/server/events/vcs/mocks"
 	"github.com/runatlantis/atlantis/server/logging"
 	"github.com/runatlantis/atlantis/server/mocks"
+	smatchers "github.com/runatlantis/atlantis/server/mocks/matchers"
 	. "github.com/runatlantis/atlantis/testing"
 )
 
 const githubHeader = "X-Github-Event"
 const gitlabHeader = "X-Gitlab-Event"
+const githubEnterpriseHostHeader = "X-Github-Enterprise-Host"
 
 var secret = []byte("secret")
 var eventsReq *http.Request
@@ -141,7 +144,7 @@ func TestPost_GitlabCommentInvalidCommand(t *testing.T) {
 	e, _, gl, _, _, _, _, cp := setup(t)
 	eventsReq.Header.Set(gitlabHeader, "value")
 	When(gl.Validate(eventsReq, secret)).ThenReturn(gitlab.MergeCommentEvent{}, nil)
-	When(cp.Parse("", vcs.Gitlab)).ThenReturn(events.CommentParseResult{Ignore: true})
+	When(cp.Parse("", vcs.Gitlab, "")).ThenReturn(events.CommentParseResult{Ignore: true})
 	w := httptest.NewRecorder()
 	e.Post(w, eventsReq)
 	responseContains(t, w, http.StatusOK, "Ignoring non-command comment: \"\"")
@@ -154,7 +157,7 @@ func TestPost_GithubCommentInvalidCommand(t *testing.T) {
 	event := `{"action": "created"}`
 	When(v.Validate(eventsReq, secret)).ThenReturn([]byte(event), nil)
 	When(p.ParseGithubIssueCommentEvent(matchers.AnyPtrToGithubIssueCommentEvent())).ThenReturn(models.Repo{}, models.User{}, 1, nil)
-	When(cp.Parse("", vcs.Github)).ThenReturn(events.CommentParseResult{Ignore: true})
+	When(cp.Parse("", vcs.Github, "")).ThenReturn(events.CommentParseResult{Ignore: true})
 	w := httptest.NewRecorder()
 	e.Post(w, eventsReq)
 	responseContains(t, w, http.StatusOK, "Ignoring non-command comment: \"\"")
@@ -165,13 +168,15 @@ func TestPost_GitlabCommentNotWhitelisted(t *testing.T) {
 	RegisterMockTestingT(t)
 	vcsClient := vcsmocks.NewMockClientProxy()
 	e := server.EventsController{
-		Logger:              logging.NewNoopLogger(),
-		CommentParser:       &events.CommentParser{},
-		GitlabRequestParser: &server.DefaultGitlabRequestParser{},
-		Parser:              &events.EventParser{},
-		SupportedVCSHosts:   []vcs.Host{vcs.Gitlab},
-		RepoWhitelist:       &events.RepoWhitelist{},
-		VCSClient:           vcsClient,
+		Logger:               logging.NewNoopLogger(),
+		CommentParser:        &events.CommentParser{},
+		GitlabRequestParser:  &server.DefaultGitlabRequestParser{},
+		GitlabWebHookSecrets: map[string][]byte{"gitlab.com": nil},
+		Parser:               &events.EventParser{GitlabCreds: map[string]vcs.GitlabCredentials{"gitlab.com": {}}},
+		SupportedVCSHosts:    []vcs.Host{vcs.Gitlab},
+		RepoWhitelist:        &events.RepoWhitelist{},
+		VCSClient:            vcsClient,
+		DeliveryRecorder:     mocks.NewMockDeliveryRecorder(),
 	}
 	requestJSON, err := ioutil.ReadFile(filepath.Join("testfixtures", "gitlabMergeCommentEvent_notWhitelisted.json"))
 	Ok(t, err)
@@ -195,11 +200,13 @@ func TestPost_GithubCommentNotWhitelisted(t *testing.T) {
 	e := server.EventsController{
 		Logger:                 logging.NewNoopLogger(),
 		GithubRequestValidator: &server.DefaultGithubRequestValidator{},
+		GithubWebHookSecrets:   map[string][]byte{"github.com": nil},
 		CommentParser:          &events.CommentParser{},
-		Parser:                 &events.EventParser{},
+		Parser:                 &events.EventParser{GithubCreds: map[string]vcs.GithubCredentials{"github.com": &vcs.GithubUserCredentials{}}},
 		SupportedVCSHosts:      []vcs.Host{vcs.Github},
 		RepoWhitelist:          &events.RepoWhitelist{},
 		VCSClient:              vcsClient,
+		DeliveryRecorder:       mocks.NewMockDeliveryRecorder(),
 	}
 	requestJSON, err := ioutil.ReadFile(filepath.Join("testfixtures", "githubIssueCommentEvent_notWhitelisted.json"))
 	Ok(t, err)
@@ -222,7 +229,7 @@ func TestPost_GitlabCommentResponse(t *testing.T) {
 	e, _, gl, _, _, _, vcsClient, cp := setup(t)
 	eventsReq.Header.Set(gitlabHeader, "value")
 	When(gl.Validate(eventsReq, secret)).ThenReturn(gitlab.MergeCommentEvent{}, nil)
-	When(cp.Parse("", vcs.Gitlab)).ThenReturn(events.CommentParseResult{CommentResponse: "a comment"})
+	When(cp.Parse("", vcs.Gitlab, "")).ThenReturn(events.CommentParseResult{CommentResponse: "a comment"})
 	w := httptest.NewRecorder()
 	e.Post(w, eventsReq)
 	vcsClient.VerifyWasCalledOnce().CreateComment(models.Repo{}, 0, "a comment", vcs.Gitlab)
@@ -238,7 +245,7 @@ func TestPost_GithubCommentResponse(t *testing.T) {
 	baseRepo := models.Repo{}
 	user := models.User{}
 	When(p.ParseGithubIssueCommentEvent(matchers.AnyPtrToGithubIssueCommentEvent())).ThenReturn(baseRepo, user, 1, nil)
-	When(cp.Parse("", vcs.Github)).ThenReturn(events.CommentParseResult{CommentResponse: "a comment"})
+	When(cp.Parse("", vcs.Github, "")).ThenReturn(events.CommentParseResult{CommentResponse: "a comment"})
 	w := httptest.NewRecorder()
 
 	e.Post(w, eventsReq)
@@ -257,7 +264,7 @@ func TestPost_GitlabCommentSuccess(t *testing.T) {
 
 	// wait for 200ms so goroutine is called
 	time.Sleep(200 * time.Millisecond)
-	cr.VerifyWasCalledOnce().ExecuteCommand(models.Repo{}, models.Repo{}, models.User{}, 0, nil, vcs.Gitlab)
+	cr.VerifyWasCalledOnce().ExecuteCommand(matchers.EqModelsRepo(models.Repo{}), matchers.EqModelsRepo(models.Repo{}), matchers.EqModelsUser(models.User{}), EqInt(0), matchers.AnyPtrToEventsCommand(), matchers.EqVcsHost(vcs.Gitlab), matchers.AnyEventsTrace())
 }
 
 func TestPost_GithubCommentSuccess(t *testing.T) {
@@ -265,19 +272,58 @@ func TestPost_GithubCommentSuccess(t *testing.T) {
 	e, v, _, p, cr, _, _, cp := setup(t)
 	eventsReq.Header.Set(githubHeader, "issue_comment")
 	event := `{"action": "created"}`
+	eventsReq.Header.Set("X-Github-Delivery", "72d3162e")
 	When(v.Validate(eventsReq, secret)).ThenReturn([]byte(event), nil)
 	baseRepo := models.Repo{}
 	user := models.User{}
 	cmd := events.Command{}
 	When(p.ParseGithubIssueCommentEvent(matchers.AnyPtrToGithubIssueCommentEvent())).ThenReturn(baseRepo, user, 1, nil)
-	When(cp.Parse("", vcs.Github)).ThenReturn(events.CommentParseResult{Command: &cmd})
+	When(cp.Parse("", vcs.Github, "")).ThenReturn(events.CommentParseResult{Command: &cmd})
 	w := httptest.NewRecorder()
 	e.Post(w, eventsReq)
 	responseContains(t, w, http.StatusOK, "Processing...")
 
 	// wait for 200ms so goroutine is called
 	time.Sleep(200 * time.Millisecond)
-	cr.VerifyWasCalledOnce().ExecuteCommand(baseRepo, baseRepo, user, 1, &cmd, vcs.Github)
+	_, _, _, _, _, _, trace := cr.VerifyWasCalledOnce().ExecuteCommand(matchers.EqModelsRepo(baseRepo), matchers.EqModelsRepo(baseRepo), matchers.EqModelsUser(user), EqInt(1), matchers.EqPtrToEventsCommand(&cmd), matchers.EqVcsHost(vcs.Github), matchers.AnyEventsTrace()).GetCapturedArguments()
+	t.Log("should pass on the delivery id and a new run id")
+	Equals(t, "72d3162e", trace.DeliveryID)
+	Assert(t, trace.RunID != "", "exp run id to be set")
+}
+
+func TestPost_GithubCheckRunNotApply(t *testing.T) {
+	t.Log("when the event is a check run event that isn't an apply request we ignore it")
+	e, v, _, _, _, _, _, _ := setup(t)
+	eventsReq.Header.Set(githubHeader, "check_run")
+	event := `{"action": "rerequested", "check_run": {"external_id": "default/vpc"}}`
+	When(v.Validate(eventsReq, secret)).ThenReturn([]byte(event), nil)
+	w := httptest.NewRecorder()
+	e.Post(w, eventsReq)
+	responseContains(t, w, http.StatusOK, "Ignoring check run event since it's not an apply request")
+}
+
+func TestPost_GithubCheckRunApply(t *testing.T) {
+	t.Log("when the apply action is requested on a check run we run apply for its project")
+	e, v, _, p, cr, _, _, _ := setup(t)
+	eventsReq.Header.Set(githubHeader, "check_run")
+	event := `{
+  "action": "requested_action",
+  "check_run": {"external_id": "staging/vpc", "pull_requests": [{"number": 2}]},
+  "requested_action": {"identifier": "apply"},
+  "repository": {"full_name": "owner/repo"},
+  "sender": {"login": "user"}
+}`
+	When(v.Validate(eventsReq, secret)).ThenReturn([]byte(event), nil)
+	baseRepo := models.Repo{FullName: "owner/repo"}
+	When(p.ParseGithubRepo(matchers.AnyPtrToGithubRepository())).ThenReturn(baseRepo, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, eventsReq)
+	responseContains(t, w, http.StatusOK, "Processing...")
+
+	// wait for 200ms so goroutine is called
+	time.Sleep(200 * time.Millisecond)
+	cmd := events.Command{Name: events.Apply, Workspace: "staging", WorkspaceSet: true, Dirs: []string{"vpc"}}
+	cr.VerifyWasCalledOnce().ExecuteCommand(matchers.EqModelsRepo(baseRepo), matchers.EqModelsRepo(models.Repo{}), matchers.EqModelsUser(models.User{Username: "user"}), EqInt(2), matchers.EqPtrToEventsCommand(&cmd), matchers.EqVcsHost(vcs.Github), matchers.AnyEventsTrace())
 }
 
 func TestPost_GithubPullRequestNotClosed(t *testing.T) {
@@ -409,6 +455,217 @@ func TestPost_GitlabMergeRequestSuccess(t *testing.T) {
 	responseContains(t, w, http.StatusOK, "Pull request cleaned successfully")
 }
 
+func TestPost_GithubDuplicateDelivery(t *testing.T) {
+	t.Log("when the delivery was already processed we ignore it")
+	e, v, _, p, _, _, _, _ := setup(t)
+	dr := mocks.NewMockDeliveryRecorder()
+	e.DeliveryRecorder = dr
+	eventsReq.Header.Set(githubHeader, "issue_comment")
+	eventsReq.Header.Set("X-Github-Delivery", "72d3162e")
+	When(v.Validate(eventsReq, secret)).ThenReturn([]byte(`{"action": "created"}`), nil)
+	When(dr.RecordDelivery(AnyString(), smatchers.AnyTimeDuration())).ThenReturn(true, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, eventsReq)
+	responseContains(t, w, http.StatusOK, "Ignoring duplicate delivery 72d3162e")
+	dr.VerifyWasCalledOnce().RecordDelivery(EqString("Github/72d3162e"), smatchers.AnyTimeDuration())
+	p.VerifyWasCalled(Never()).ParseGithubIssueCommentEvent(matchers.AnyPtrToGithubIssueCommentEvent())
+}
+
+func TestPost_GithubReplayedDelivery(t *testing.T) {
+	t.Log("when the delivery is being replayed we don't check if it's a duplicate")
+	e, v, _, _, _, _, _, _ := setup(t)
+	dr := mocks.NewMockDeliveryRecorder()
+	e.DeliveryRecorder = dr
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	e.DeliveryArchive = &server.DeliveryArchive{Dir: dir}
+	token, err := e.DeliveryArchive.ReplayToken()
+	Ok(t, err)
+	eventsReq.Header.Set(githubHeader, "issue_comment")
+	eventsReq.Header.Set("X-Github-Delivery", "72d3162e")
+	eventsReq.Header.Set(server.ReplayHeader, token)
+	When(v.Validate(eventsReq, secret)).ThenReturn([]byte(`{"action": "deleted"}`), nil)
+	When(dr.RecordDelivery(AnyString(), smatchers.AnyTimeDuration())).ThenReturn(true, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, eventsReq)
+	responseContains(t, w, http.StatusOK, "Ignoring comment event since action was not created")
+	dr.VerifyWasCalled(Never()).RecordDelivery(AnyString(), smatchers.AnyTimeDuration())
+}
+
+func TestPost_GitlabReplayedDelivery(t *testing.T) {
+	t.Log("when a gitlab delivery is being replayed it's authenticated by the replay token instead of the secret")
+	e, _, gl, p, _, _, _, _ := setup(t)
+	dr := mocks.NewMockDeliveryRecorder()
+	e.DeliveryRecorder = dr
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	e.DeliveryArchive = &server.DeliveryArchive{Dir: dir}
+	token, err := e.DeliveryArchive.ReplayToken()
+	Ok(t, err)
+	eventsReq, _ = http.NewRequest("POST", "", bytes.NewBufferString("body"))
+	eventsReq.Header.Set(gitlabHeader, "value")
+	eventsReq.Header.Set(server.ReplayHeader, token)
+	event := gitlab.MergeEvent{}
+	When(gl.Validate(eventsReq, nil)).ThenReturn(event, nil)
+	When(p.ParseGitlabMergeEvent(event)).ThenReturn(models.PullRequest{State: models.Closed}, models.Repo{}, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, eventsReq)
+	responseContains(t, w, http.StatusOK, "Pull request cleaned successfully")
+	gl.VerifyWasCalledOnce().Validate(eventsReq, nil)
+	dr.VerifyWasCalled(Never()).RecordDelivery(AnyString(), smatchers.AnyTimeDuration())
+}
+
+func TestPost_GithubForgedReplay(t *testing.T) {
+	t.Log("when the replay header doesn't match the replay token we still check if it's a duplicate")
+	for _, archived := range []bool{false, true} {
+		e, v, _, p, _, _, _, _ := setup(t)
+		dr := mocks.NewMockDeliveryRecorder()
+		e.DeliveryRecorder = dr
+		if archived {
+			dir, err := ioutil.TempDir("", "")
+			Ok(t, err)
+			defer os.RemoveAll(dir) // nolint: errcheck
+			e.DeliveryArchive = &server.DeliveryArchive{Dir: dir}
+		}
+		eventsReq, _ = http.NewRequest("GET", "", bytes.NewBuffer(nil))
+		eventsReq.Header.Set(githubHeader, "issue_comment")
+		eventsReq.Header.Set("X-Github-Delivery", "72d3162e")
+		eventsReq.Header.Set(server.ReplayHeader, "true")
+		When(v.Validate(eventsReq, secret)).ThenReturn([]byte(`{"action": "created"}`), nil)
+		When(dr.RecordDelivery(AnyString(), smatchers.AnyTimeDuration())).ThenReturn(true, nil)
+		w := httptest.NewRecorder()
+		e.Post(w, eventsReq)
+		responseContains(t, w, http.StatusOK, "Ignoring duplicate delivery 72d3162e")
+		p.VerifyWasCalled(Never()).ParseGithubIssueCommentEvent(matchers.AnyPtrToGithubIssueCommentEvent())
+	}
+}
+
+func TestPost_GitlabDuplicateDelivery(t *testing.T) {
+	t.Log("when gitlab doesn't send a delivery id we use a hash of the body")
+	e, _, gl, p, _, _, _, _ := setup(t)
+	dr := mocks.NewMockDeliveryRecorder()
+	e.DeliveryRecorder = dr
+	eventsReq, _ = http.NewRequest("POST", "", bytes.NewBufferString("body"))
+	eventsReq.Header.Set(gitlabHeader, "value")
+	When(gl.Validate(eventsReq, secret)).ThenReturn(gitlab.MergeEvent{}, nil)
+	When(dr.RecordDelivery(AnyString(), smatchers.AnyTimeDuration())).ThenReturn(true, nil)
+	w := httptest.NewRecorder()
+	e.Post(w, eventsReq)
+	responseContains(t, w, http.StatusOK, "Ignoring duplicate delivery")
+	// This is the sha256 of "body".
+	dr.VerifyWasCalledOnce().RecordDelivery(EqString("Gitlab/230d8358dc8e8890b4c58deeb62912ee2f20357ae92a5cc861b98e68fe31acb5"), smatchers.AnyTimeDuration())
+	p.VerifyWasCalled(Never()).ParseGitlabMergeEvent(gitlab.MergeEvent{})
+}
+
+func TestPost_ArchivesDelivery(t *testing.T) {
+	t.Log("when configured to archive deliveries we store the body and headers")
+	e, v, _, _, _, _, _, _ := setup(t)
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	e.DeliveryArchive = &server.DeliveryArchive{Dir: dir}
+	event := `{"action": "deleted"}`
+	eventsReq, _ = http.NewRequest("POST", "", bytes.NewBufferString(event))
+	eventsReq.Header.Set(githubHeader, "issue_comment")
+	eventsReq.Header.Set("X-Github-Delivery", "72d3162e")
+	When(v.Validate(eventsReq, secret)).ThenReturn([]byte(event), nil)
+	w := httptest.NewRecorder()
+	e.Post(w, eventsReq)
+	responseContains(t, w, http.StatusOK, "Ignoring comment event since action was not created")
+
+	d, err := e.DeliveryArchive.Load("72d3162e")
+	Ok(t, err)
+	Equals(t, event, string(d.Body))
+	Equals(t, "issue_comment", d.Header.Get(githubHeader))
+}
+
+func TestPruneDeliveries(t *testing.T) {
+	t.Log("pruning should forget old deliveries and remove their archived payloads")
+	e, _, _, _, _, _, _, _ := setup(t)
+	dir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(dir) // nolint: errcheck
+	e.DeliveryArchive = &server.DeliveryArchive{Dir: dir}
+	Ok(t, e.DeliveryArchive.Save(server.ArchivedDelivery{ID: "old"}))
+	Ok(t, e.DeliveryArchive.Save(server.ArchivedDelivery{ID: "new"}))
+	old := time.Now().Add(-25 * time.Hour)
+	Ok(t, os.Chtimes(filepath.Join(dir, "old.json"), old, old))
+
+	e.PruneDeliveries()
+	e.DeliveryRecorder.(*mocks.MockDeliveryRecorder).VerifyWasCalledOnce().PruneDeliveries(24 * time.Hour)
+	_, err = e.DeliveryArchive.Load("old")
+	Assert(t, err != nil, "exp old delivery to be pruned")
+	_, err = e.DeliveryArchive.Load("new")
+	Ok(t, err)
+}
+
+func TestPost_GithubMultipleHosts(t *testing.T) {
+	t.Log("when there are multiple GitHub hosts we validate with the secret of the host that sent the webhook")
+	e, v, _, _, _, _, _, _ := setup(t)
+	e.GithubWebHookSecrets = map[string][]byte{
+		"github.com":           []byte("github-secret"),
+		"github.mycompany.com": []byte("ghe-secret"),
+	}
+	event := `{"repository": {"clone_url": "https://github.mycompany.com/owner/repo.git"}}`
+	eventsReq.Header.Set(githubHeader, "value")
+	eventsReq.Header.Set(githubEnterpriseHostHeader, "github.mycompany.com")
+	When(v.Validate(eventsReq, []byte("ghe-secret"))).ThenReturn([]byte(event), nil)
+	w := httptest.NewRecorder()
+	e.Post(w, eventsReq)
+	responseContains(t, w, http.StatusOK, "Ignoring unsupported event")
+	v.VerifyWasCalledOnce().Validate(eventsReq, []byte("ghe-secret"))
+}
+
+func TestPost_GithubUnconfiguredHost(t *testing.T) {
+	t.Log("when a webhook is from a GitHub host that isn't configured we return a 400")
+	e, v, _, _, _, _, _, _ := setup(t)
+	e.GithubWebHookSecrets = map[string][]byte{
+		"github.com":           []byte("github-secret"),
+		"github.mycompany.com": []byte("ghe-secret"),
+	}
+	eventsReq.Header.Set(githubHeader, "issue_comment")
+	eventsReq.Header.Set(githubEnterpriseHostHeader, "github.other.com")
+	w := httptest.NewRecorder()
+	e.Post(w, eventsReq)
+	responseContains(t, w, http.StatusBadRequest, `Ignoring request from GitHub host "github.other.com" since it isn't configured`)
+	v.VerifyWasCalled(Never()).Validate(smatchers.AnyPtrToHttpRequest(), smatchers.AnySliceOfByte())
+}
+
+func TestPost_GithubRepoHostMismatch(t *testing.T) {
+	t.Log("when there are multiple GitHub hosts and the repo isn't on the host that sent the webhook we return a 400")
+	e, v, _, _, _, _, _, _ := setup(t)
+	e.GithubWebHookSecrets = map[string][]byte{
+		"github.com":           []byte("github-secret"),
+		"github.mycompany.com": []byte("ghe-secret"),
+	}
+	event := `{"repository": {"clone_url": "https://github.com/owner/repo.git"}}`
+	eventsReq.Header.Set(githubHeader, "issue_comment")
+	eventsReq.Header.Set(githubEnterpriseHostHeader, "github.mycompany.com")
+	When(v.Validate(eventsReq, []byte("ghe-secret"))).ThenReturn([]byte(event), nil)
+	w := httptest.NewRecorder()
+	e.Post(w, eventsReq)
+	responseContains(t, w, http.StatusBadRequest, `Ignoring request since repo host "github.com" doesn't match webhook host "github.mycompany.com"`)
+}
+
+func TestPost_GitlabMultipleHosts(t *testing.T) {
+	t.Log("when there are multiple GitLab hosts we determine the host from the project's url")
+	e, _, gl, _, _, _, _, _ := setup(t)
+	e.GitlabWebHookSecrets = map[string][]byte{
+		"gitlab.com":           []byte("gitlab-secret"),
+		"gitlab.mycompany.com": []byte("gl-secret"),
+	}
+	body := `{"project": {"web_url": "https://gitlab.mycompany.com/owner/repo", "git_http_url": "https://gitlab.mycompany.com/owner/repo.git"}}`
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer([]byte(body)))
+	req.Header.Set(gitlabHeader, "value")
+	When(gl.Validate(req, []byte("gl-secret"))).ThenReturn([]byte(`{"not an event": ""}`), nil)
+	w := httptest.NewRecorder()
+	e.Post(w, req)
+	responseContains(t, w, http.StatusOK, "Ignoring unsupported event")
+	gl.VerifyWasCalledOnce().Validate(req, []byte("gl-secret"))
+}
+
 func setup(t *testing.T) (server.EventsController, *mocks.MockGithubRequestValidator, *mocks.MockGitlabRequestParser, *emocks.MockEventParsing, *emocks.MockCommandRunner, *emocks.MockPullCleaner, *vcsmocks.MockClientProxy, *emocks.MockCommentParsing) {
 	RegisterMockTestingT(t)
 	eventsReq, _ = http.NewRequest("GET", "", bytes.NewBuffer(nil))
@@ -426,14 +683,15 @@ func setup(t *testing.T) (server.EventsController, *mocks.MockGithubRequestValid
 		CommentParser:          cp,
 		CommandRunner:          cr,
 		PullCleaner:            c,
-		GithubWebHookSecret:    secret,
+		GithubWebHookSecrets:   map[string][]byte{"github.com": secret},
 		SupportedVCSHosts:      []vcs.Host{vcs.Github, vcs.Gitlab},
-		GitlabWebHookSecret:    secret,
+		GitlabWebHookSecrets:   map[string][]byte{"gitlab.com": secret},
 		GitlabRequestParser:    gl,
 		RepoWhitelist: &events.RepoWhitelist{
 			Whitelist: "*",
 		},
-		VCSClient: vcsmock,
+		VCSClient:        vcsmock,
+		DeliveryRecorder: mocks.NewMockDeliveryRecorder(),
 	}
 	return e, v, gl, p, cr, c, vcsmock, cp
 }
@@ -0,0 +1,173 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/runatlantis/atlantis/server/events/locking"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+)
+
+// ReadinessCheck is something that must work for Atlantis to serve requests.
+type ReadinessCheck struct {
+	// Name identifies the check in the /readyz response, ex. "boltdb".
+	Name string
+	// Check returns an error if the check fails.
+	Check func() error
+}
+
+// HealthController serves the /healthz, /readyz and /version routes that are
+// used to probe Atlantis, ex. by Kubernetes.
+type HealthController struct {
+	AtlantisVersion string
+	// TerraformVersion is the version of the terraform binary in our $PATH.
+	// It's empty if there isn't one.
+	TerraformVersion string
+	// ReadinessChecks are run by /readyz.
+	ReadinessChecks []ReadinessCheck
+}
+
+// ReadyzResponse is the body of /readyz responses.
+type ReadyzResponse struct {
+	// Status is "ready" if all the checks passed and "not ready" otherwise.
+	Status string `json:"status"`
+	// Checks maps the name of each check to "ok" or its error.
+	Checks map[string]string `json:"checks"`
+}
+
+// VersionResponse is the body of /version responses.
+type VersionResponse struct {
+	AtlantisVersion  string `json:"atlantis_version"`
+	TerraformVersion string `json:"terraform_version"`
+}
+
+// Healthz is the /healthz route. It responds with 200 as long as the process
+// can serve requests.
+func (h *HealthController) Healthz(w http.ResponseWriter, _ *http.Request) {
+	h.respondJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz is the /readyz route. It responds with 200 if all the readiness
+// checks pass and 503 otherwise.
+func (h *HealthController) Readyz(w http.ResponseWriter, _ *http.Request) {
+	resp := ReadyzResponse{Status: "ready", Checks: make(map[string]string)}
+	code := http.StatusOK
+	for _, c := range h.ReadinessChecks {
+		if err := c.Check(); err != nil {
+			resp.Checks[c.Name] = err.Error()
+			resp.Status = "not ready"
+			code = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[c.Name] = "ok"
+	}
+	h.respondJSON(w, code, resp)
+}
+
+// Version is the /version route.
+func (h *HealthController) Version(w http.ResponseWriter, _ *http.Request) {
+	h.respondJSON(w, http.StatusOK, VersionResponse{
+		AtlantisVersion:  h.AtlantisVersion,
+		TerraformVersion: h.TerraformVersion,
+	})
+}
+
+func (h *HealthController) respondJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v) // nolint: errcheck
+}
+
+// LockerCheck checks that locks can be read from BoltDB.
+func LockerCheck(locker locking.Locker) ReadinessCheck {
+	return ReadinessCheck{Name: "boltdb", Check: func() error {
+		_, err := locker.List()
+		return errors.Wrap(err, "reading locks")
+	}}
+}
+
+// TerraformCheck checks that there's a terraform binary in our $PATH.
+func TerraformCheck() ReadinessCheck {
+	return ReadinessCheck{Name: "terraform", Check: func() error {
+		_, err := exec.LookPath("terraform")
+		return err
+	}}
+}
+
+// DataDirCheck checks that files can be created in dataDir.
+func DataDirCheck(dataDir string) ReadinessCheck {
+	return ReadinessCheck{Name: "data-dir", Check: func() error {
+		f, err := ioutil.TempFile(dataDir, ".readyz")
+		if err != nil {
+			return errors.Wrap(err, "writing to data dir")
+		}
+		f.Close()           // nolint: errcheck
+		os.Remove(f.Name()) // nolint: errcheck
+		return nil
+	}}
+}
+
+// credentialsChecker is implemented by GitHub credentials that can't return
+// a token without making a request, ex. GitHub Apps. We check them with
+// CheckCredentials instead so probes don't mint tokens.
+type credentialsChecker interface {
+	CheckCredentials() error
+}
+
+// VCSCredentialsCheck checks that we have a token for each GitHub and GitLab
+// host. For GitHub Apps it checks that the app's key is valid and that the
+// last attempt to mint a token didn't fail, without making any requests.
+func VCSCredentialsCheck(githubCreds map[string]vcs.GithubCredentials, gitlabCreds map[string]vcs.GitlabCredentials) ReadinessCheck {
+	return ReadinessCheck{Name: "vcs-credentials", Check: func() error {
+		var hostnames []string
+		for hostname := range githubCreds {
+			hostnames = append(hostnames, hostname)
+		}
+		sort.Strings(hostnames)
+		for _, hostname := range hostnames {
+			if checker, ok := githubCreds[hostname].(credentialsChecker); ok {
+				if err := checker.CheckCredentials(); err != nil {
+					return errors.Wrapf(err, "checking credentials for GitHub host %q", hostname)
+				}
+				continue
+			}
+			token, err := githubCreds[hostname].GetToken()
+			if err != nil {
+				return errors.Wrapf(err, "getting token for GitHub host %q", hostname)
+			}
+			if token == "" {
+				return fmt.Errorf("no token for GitHub host %q", hostname)
+			}
+		}
+		hostnames = nil
+		for hostname := range gitlabCreds {
+			hostnames = append(hostnames, hostname)
+		}
+		sort.Strings(hostnames)
+		for _, hostname := range hostnames {
+			if gitlabCreds[hostname].Token == "" {
+				return fmt.Errorf("no token for GitLab host %q", hostname)
+			}
+		}
+		return nil
+	}}
+}
@@ -0,0 +1,162 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package server_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/petergtz/pegomock"
+	"github.com/runatlantis/atlantis/server"
+	"github.com/runatlantis/atlantis/server/events/locking/mocks"
+	"github.com/runatlantis/atlantis/server/events/vcs"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestHealthz(t *testing.T) {
+	t.Log("healthz should always return 200")
+	h := server.HealthController{}
+	w := httptest.NewRecorder()
+	h.Healthz(w, httptest.NewRequest("GET", "/healthz", nil))
+	Equals(t, http.StatusOK, w.Code)
+	Equals(t, "application/json", w.Header().Get("Content-Type"))
+	Equals(t, "{\"status\":\"ok\"}\n", w.Body.String())
+}
+
+func TestVersion(t *testing.T) {
+	t.Log("version should return the atlantis and terraform versions")
+	h := server.HealthController{
+		AtlantisVersion:  "0.4.0",
+		TerraformVersion: "0.11.7",
+	}
+	w := httptest.NewRecorder()
+	h.Version(w, httptest.NewRequest("GET", "/version", nil))
+	Equals(t, http.StatusOK, w.Code)
+	var resp server.VersionResponse
+	Ok(t, json.NewDecoder(w.Body).Decode(&resp))
+	Equals(t, server.VersionResponse{AtlantisVersion: "0.4.0", TerraformVersion: "0.11.7"}, resp)
+}
+
+func TestReadyz_Ready(t *testing.T) {
+	t.Log("readyz should return 200 if all the checks pass")
+	h := server.HealthController{
+		ReadinessChecks: []server.ReadinessCheck{
+			{Name: "a", Check: func() error { return nil }},
+			{Name: "b", Check: func() error { return nil }},
+		},
+	}
+	resp, code := readyz(t, h)
+	Equals(t, http.StatusOK, code)
+	Equals(t, server.ReadyzResponse{
+		Status: "ready",
+		Checks: map[string]string{"a": "ok", "b": "ok"},
+	}, resp)
+}
+
+func TestReadyz_NotReady(t *testing.T) {
+	t.Log("readyz should return 503 and the errors if any check fails")
+	h := server.HealthController{
+		ReadinessChecks: []server.ReadinessCheck{
+			{Name: "a", Check: func() error { return nil }},
+			{Name: "b", Check: func() error { return errors.New("err") }},
+		},
+	}
+	resp, code := readyz(t, h)
+	Equals(t, http.StatusServiceUnavailable, code)
+	Equals(t, server.ReadyzResponse{
+		Status: "not ready",
+		Checks: map[string]string{"a": "ok", "b": "err"},
+	}, resp)
+}
+
+func TestLockerCheck(t *testing.T) {
+	RegisterMockTestingT(t)
+	l := mocks.NewMockLocker()
+	Ok(t, server.LockerCheck(l).Check())
+
+	When(l.List()).ThenReturn(nil, errors.New("err"))
+	ErrEquals(t, "reading locks: err", server.LockerCheck(l).Check())
+}
+
+func TestDataDirCheck(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "")
+	Ok(t, err)
+	defer os.RemoveAll(tmpDir) // nolint: errcheck
+
+	Ok(t, server.DataDirCheck(tmpDir).Check())
+	files, err := ioutil.ReadDir(tmpDir)
+	Ok(t, err)
+	Equals(t, 0, len(files))
+
+	err = server.DataDirCheck(filepath.Join(tmpDir, "missing")).Check()
+	Assert(t, err != nil, "expected error for missing data dir")
+}
+
+func TestVCSCredentialsCheck(t *testing.T) {
+	githubCreds := map[string]vcs.GithubCredentials{
+		"github.com": &vcs.GithubUserCredentials{User: "user", Token: "token"},
+	}
+	gitlabCreds := map[string]vcs.GitlabCredentials{
+		"gitlab.com": {User: "user", Token: "token"},
+	}
+	Ok(t, server.VCSCredentialsCheck(githubCreds, gitlabCreds).Check())
+
+	githubCreds["github.example.com"] = &vcs.GithubUserCredentials{User: "user"}
+	ErrEquals(t, `no token for GitHub host "github.example.com"`, server.VCSCredentialsCheck(githubCreds, gitlabCreds).Check())
+
+	delete(githubCreds, "github.example.com")
+	gitlabCreds["gitlab.example.com"] = vcs.GitlabCredentials{User: "user"}
+	ErrEquals(t, `no token for GitLab host "gitlab.example.com"`, server.VCSCredentialsCheck(githubCreds, gitlabCreds).Check())
+}
+
+// checkedCredentials fails the test if a token is requested since that could
+// make a request.
+type checkedCredentials struct {
+	vcs.GithubUserCredentials
+	t   *testing.T
+	err error
+}
+
+func (c *checkedCredentials) GetToken() (string, error) {
+	c.t.Fatal("GetToken shouldn't be called for credentials that can be checked")
+	return "", nil
+}
+
+func (c *checkedCredentials) CheckCredentials() error {
+	return c.err
+}
+
+func TestVCSCredentialsCheck_GithubApp(t *testing.T) {
+	t.Log("credentials that can be checked without a token should be")
+	creds := &checkedCredentials{t: t}
+	githubCreds := map[string]vcs.GithubCredentials{"github.com": creds}
+	Ok(t, server.VCSCredentialsCheck(githubCreds, nil).Check())
+
+	creds.err = errors.New("creating installation token: unexpected status 401")
+	ErrEquals(t, `checking credentials for GitHub host "github.com": creating installation token: unexpected status 401`, server.VCSCredentialsCheck(githubCreds, nil).Check())
+}
+
+func readyz(t *testing.T, h server.HealthController) (server.ReadyzResponse, int) {
+	w := httptest.NewRecorder()
+	h.Readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+	var resp server.ReadyzResponse
+	Ok(t, json.NewDecoder(w.Body).Decode(&resp))
+	return resp, w.Code
+}
@@ -13,5 +13,58 @@
 //
 package logging_test
 
-// todo: actually test
-// purposefully empty to trigger coverage report
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/logging"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestSimpleLogger_Text(t *testing.T) {
+	t.Log("the text format shouldn't include fields")
+	var buf bytes.Buffer
+	l := logging.NewSimpleLogger("owner/repo#1", log.New(&buf, "", 0), true, logging.Info).With("run_id", "run")
+	l.Info("planning %s", "dir")
+	Equals(t, "[INFO] owner/repo#1: Planning dir\n", buf.String())
+}
+
+func TestSimpleLogger_JSON(t *testing.T) {
+	t.Log("the JSON format should include the logger's fields")
+	var buf bytes.Buffer
+	l := logging.NewSimpleLogger("owner/repo#1", log.New(&buf, "", 0), true, logging.Info)
+	l.Format = logging.JSON
+	l = l.With("run_id", "run")
+	l.SetField("project", "dir")
+	l.SetField("workspace", "")
+	l.Warn("planning %s", "dir")
+
+	var entry map[string]string
+	Ok(t, json.Unmarshal(buf.Bytes(), &entry))
+	Assert(t, entry["time"] != "", "exp time to be set")
+	delete(entry, "time")
+	Equals(t, map[string]string{
+		"level":   "warn",
+		"source":  "owner/repo#1",
+		"msg":     "Planning dir",
+		"run_id":  "run",
+		"project": "dir",
+	}, entry)
+}
+
+func TestSimpleLogger_WithSharesHistory(t *testing.T) {
+	t.Log("loggers created with With should save to the same history")
+	l := logging.NewSimpleLogger("source", log.New(&bytes.Buffer{}, "", 0), true, logging.Info)
+	l.Info("first")
+	l.With("project", "dir").Debug("second")
+	l.SetField("project", "other")
+	Equals(t, "[INFO] First\n[DEBUG] Second\n", l.History.String())
+}
+
+func TestToLogFormat(t *testing.T) {
+	Equals(t, logging.JSON, logging.ToLogFormat("json"))
+	Equals(t, logging.Text, logging.ToLogFormat("text"))
+	Equals(t, logging.Text, logging.ToLogFormat("invalid"))
+}
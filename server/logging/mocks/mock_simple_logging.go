@@ -83,6 +83,18 @@ func (mock *MockSimpleLogging) GetLevel() logging.LogLevel {
 	return ret0
 }
 
+func (mock *MockSimpleLogging) GetFormat() logging.LogFormat {
+	params := []pegomock.Param{}
+	result := pegomock.GetGenericMockFrom(mock).Invoke("GetFormat", params, []reflect.Type{reflect.TypeOf((*logging.LogFormat)(nil)).Elem()})
+	var ret0 logging.LogFormat
+	if len(result) != 0 {
+		if result[0] != nil {
+			ret0 = result[0].(logging.LogFormat)
+		}
+	}
+	return ret0
+}
+
 func (mock *MockSimpleLogging) VerifyWasCalledOnce() *VerifierSimpleLogging {
 	return &VerifierSimpleLogging{mock, pegomock.Times(1), nil}
 }
@@ -333,3 +345,20 @@ func (c *SimpleLogging_GetLevel_OngoingVerification) GetCapturedArguments() {
 
 func (c *SimpleLogging_GetLevel_OngoingVerification) GetAllCapturedArguments() {
 }
+
+func (verifier *VerifierSimpleLogging) GetFormat() *SimpleLogging_GetFormat_OngoingVerification {
+	params := []pegomock.Param{}
+	methodInvocations := pegomock.GetGenericMockFrom(verifier.mock).Verify(verifier.inOrderContext, verifier.invocationCountMatcher, "GetFormat", params)
+	return &SimpleLogging_GetFormat_OngoingVerification{mock: verifier.mock, methodInvocations: methodInvocations}
+}
+
+type SimpleLogging_GetFormat_OngoingVerification struct {
+	mock              *MockSimpleLogging
+	methodInvocations []pegomock.MethodInvocation
+}
+
+func (c *SimpleLogging_GetFormat_OngoingVerification) GetCapturedArguments() {
+}
+
+func (c *SimpleLogging_GetFormat_OngoingVerification) GetAllCapturedArguments() {
+}
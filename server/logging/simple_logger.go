@@ -16,10 +16,13 @@ package logging
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
+	"time"
 	"unicode"
 )
 
@@ -37,6 +40,8 @@ type SimpleLogging interface {
 	Underlying() *log.Logger
 	// GetLevel returns the current log level.
 	GetLevel() LogLevel
+	// GetFormat returns the format log entries are written in.
+	GetFormat() LogFormat
 }
 
 // SimpleLogger wraps the standard logger with leveled logging
@@ -48,12 +53,17 @@ type SimpleLogger struct {
 	// context, for example a pull request id.
 	Source string
 	// History stores all log entries ever written using
-	// this logger. This is safe for short-lived loggers
-	// like those used during plan/apply commands.
-	History     bytes.Buffer
+	// this logger and the loggers created from it with With.
+	// This is safe for short-lived loggers like those used
+	// during plan/apply commands.
+	History     *bytes.Buffer
 	Logger      *log.Logger
 	KeepHistory bool
 	Level       LogLevel
+	// Format is the format log entries are written in.
+	Format LogFormat
+	// fields are added to each log entry written in the JSON format.
+	fields map[string]string
 }
 
 type LogLevel int
@@ -65,6 +75,17 @@ const (
 	Error
 )
 
+// LogFormat is the format log entries are written in.
+type LogFormat int
+
+const (
+	// Text writes entries like "[INFO] source: Message".
+	Text LogFormat = iota
+	// JSON writes each entry as a JSON object on its own line so they can
+	// be searched by field.
+	JSON
+)
+
 // NewSimpleLogger creates a new logger.
 // source is added as a prefix to each log entry. It's useful if you want to
 // trace a log entry back to a specific context, for example a pull request id.
@@ -85,12 +106,22 @@ func NewSimpleLogger(source string, logger *log.Logger, keepHistory bool, level
 	}
 	return &SimpleLogger{
 		Source:      source,
+		History:     new(bytes.Buffer),
 		Logger:      logger,
 		Level:       level,
 		KeepHistory: keepHistory,
 	}
 }
 
+// NewJSONLogger creates a logger that writes entries to stderr in the JSON
+// format. source and level are the same as in NewSimpleLogger.
+func NewJSONLogger(source string, level LogLevel) *SimpleLogger {
+	// Entries have their own time field so the logger doesn't add one.
+	l := NewSimpleLogger(source, log.New(os.Stderr, "", 0), false, level)
+	l.Format = JSON
+	return l
+}
+
 // NewNoopLogger creates a logger instance that discards all logs and never
 // writes them. Used for testing.
 func NewNoopLogger() *SimpleLogger {
@@ -98,6 +129,7 @@ func NewNoopLogger() *SimpleLogger {
 	logger.SetOutput(ioutil.Discard)
 	return &SimpleLogger{
 		Source:      "",
+		History:     new(bytes.Buffer),
 		Logger:      logger,
 		Level:       Info,
 		KeepHistory: false,
@@ -120,6 +152,45 @@ func ToLogLevel(levelStr string) LogLevel {
 	return Info
 }
 
+// ToLogFormat converts a log format string, "text" or "json", to a
+// LogFormat. If the string doesn't match a format, it will return Text.
+func ToLogFormat(formatStr string) LogFormat {
+	if formatStr == "json" {
+		return JSON
+	}
+	return Text
+}
+
+// With returns a logger that adds key with value to each entry written in
+// the JSON format, ex. With("project", "vpc"). It writes to the same place
+// as l and shares its history.
+func (l *SimpleLogger) With(key string, value string) *SimpleLogger {
+	fields := make(map[string]string, len(l.fields)+1)
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+	fields[key] = value
+	return &SimpleLogger{
+		Source:      l.Source,
+		History:     l.History,
+		Logger:      l.Logger,
+		KeepHistory: l.KeepHistory,
+		Level:       l.Level,
+		Format:      l.Format,
+		fields:      fields,
+	}
+}
+
+// SetField sets key to value in each entry l writes in the JSON format from
+// now on. Entries don't have the field if value is empty. Use With instead if
+// l is shared.
+func (l *SimpleLogger) SetField(key string, value string) {
+	if l.fields == nil {
+		l.fields = make(map[string]string)
+	}
+	l.fields[key] = value
+}
+
 // Debug logs at debug level.
 func (l *SimpleLogger) Debug(format string, a ...interface{}) {
 	l.Log(Debug, format, a...)
@@ -150,7 +221,7 @@ func (l *SimpleLogger) Log(level LogLevel, format string, a ...interface{}) {
 		// Calling .Output instead of Printf so we can change the calldepth
 		// param to 3. The default is 2 which would identify the log as coming
 		// from this file and line every time instead of our caller's.
-		l.Logger.Output(3, fmt.Sprintf("[%s] %s: %s\n", levelStr, l.Source, msg)) // nolint: errcheck
+		l.Logger.Output(3, l.format(levelStr, msg)) // nolint: errcheck
 	}
 
 	// Keep history at all log levels.
@@ -169,6 +240,32 @@ func (l *SimpleLogger) GetLevel() LogLevel {
 	return l.Level
 }
 
+// GetFormat returns the format the logger writes entries in.
+func (l *SimpleLogger) GetFormat() LogFormat {
+	return l.Format
+}
+
+// format returns the log entry for msg at level.
+func (l *SimpleLogger) format(level string, msg string) string {
+	if l.Format != JSON {
+		return fmt.Sprintf("[%s] %s: %s\n", level, l.Source, msg)
+	}
+	entry := map[string]string{
+		"time":   time.Now().UTC().Format(time.RFC3339Nano),
+		"level":  strings.ToLower(level),
+		"source": l.Source,
+		"msg":    msg,
+	}
+	for k, v := range l.fields {
+		if v != "" {
+			entry[k] = v
+		}
+	}
+	// Marshalling a map of strings can't fail.
+	b, _ := json.Marshal(entry)
+	return string(b) + "\n"
+}
+
 func (l *SimpleLogger) saveToHistory(level string, msg string) {
 	l.History.WriteString(fmt.Sprintf("[%s] %s\n", level, msg))
 }
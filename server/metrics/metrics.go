@@ -0,0 +1,140 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package metrics
+
+import "time"
+
+// Command results.
+const (
+	SuccessResult = "success"
+	FailureResult = "failure"
+	ErrorResult   = "error"
+)
+
+// durationBuckets are the buckets, in seconds, of the duration histograms.
+// Terraform commands can take from seconds to tens of minutes.
+var durationBuckets = []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1200, 1800}
+
+// vcsBuckets are the buckets, in seconds, of VCS API call durations.
+var vcsBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics are the metrics Atlantis records. Its methods do nothing if it's
+// nil so they can be called without checking if metrics are being recorded.
+type Metrics struct {
+	// Registry serves the metrics.
+	Registry *Registry
+
+	webhooks           *Counter
+	commands           *Counter
+	commandsInProgress *Gauge
+	terraformDuration  *Histogram
+	locks              *Gauge
+	vcsDuration        *Histogram
+	vcsErrors          *Counter
+	driftProjects      *Gauge
+	driftLastRun       *Gauge
+}
+
+// New returns metrics registered in a new registry.
+func New() *Metrics {
+	r := NewRegistry()
+	return &Metrics{
+		Registry:           r,
+		webhooks:           r.NewCounter("atlantis_webhooks_received_total", "Webhooks received by VCS host and event type.", "vcs", "event"),
+		commands:           r.NewCounter("atlantis_commands_total", "Commands run by name and result.", "command", "result"),
+		commandsInProgress: r.NewGauge("atlantis_commands_in_progress", "Commands being run."),
+		terraformDuration:  r.NewHistogram("atlantis_terraform_duration_seconds", "Duration of terraform init, plan and apply by repo.", durationBuckets, "command", "repo"),
+		locks:              r.NewGauge("atlantis_locks", "Project and workspace locks held by pull requests."),
+		vcsDuration:        r.NewHistogram("atlantis_vcs_api_duration_seconds", "Duration of VCS API calls by host and method.", vcsBuckets, "vcs", "hostname", "method"),
+		vcsErrors:          r.NewCounter("atlantis_vcs_api_errors_total", "VCS API calls that errored by host and method.", "vcs", "hostname", "method"),
+		driftProjects:      r.NewGauge("atlantis_drift_projects", "Project workspaces checked by the last drift detection run by status.", "status"),
+		driftLastRun:       r.NewGauge("atlantis_drift_last_run_timestamp_seconds", "When drift detection last finished as a Unix timestamp."),
+	}
+}
+
+// WebhookReceived records a webhook of type event from a vcs host, ex.
+// "github" and "pull_request".
+func (m *Metrics) WebhookReceived(vcs string, event string) {
+	if m == nil {
+		return
+	}
+	m.webhooks.Inc(vcs, event)
+}
+
+// CommandStarted records that a command started running. CommandEnded must
+// be called when it ends.
+func (m *Metrics) CommandStarted() {
+	if m == nil {
+		return
+	}
+	m.commandsInProgress.Add(1)
+}
+
+// CommandEnded records that a command stopped running.
+func (m *Metrics) CommandEnded() {
+	if m == nil {
+		return
+	}
+	m.commandsInProgress.Add(-1)
+}
+
+// CommandFinished records the result of a command, ex. "plan" and
+// SuccessResult.
+func (m *Metrics) CommandFinished(command string, result string) {
+	if m == nil {
+		return
+	}
+	m.commands.Inc(command, result)
+}
+
+// TerraformRan records how long a terraform command, ex. "plan", took for
+// repo.
+func (m *Metrics) TerraformRan(command string, repo string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.terraformDuration.Observe(d.Seconds(), command, repo)
+}
+
+// SetLocks sets the number of locks held.
+func (m *Metrics) SetLocks(n int) {
+	if m == nil {
+		return
+	}
+	m.locks.Set(float64(n))
+}
+
+// VCSCalled records a call to method of a vcs host's API and whether it
+// errored.
+func (m *Metrics) VCSCalled(vcs string, hostname string, method string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.vcsDuration.Observe(d.Seconds(), vcs, hostname, method)
+	if err != nil {
+		m.vcsErrors.Inc(vcs, hostname, method)
+	}
+}
+
+// DriftChecked records the result of a drift detection run that finished
+// at lastRun.
+func (m *Metrics) DriftChecked(drifted int, clean int, errored int, lastRun time.Time) {
+	if m == nil {
+		return
+	}
+	m.driftProjects.Set(float64(drifted), "drifted")
+	m.driftProjects.Set(float64(clean), "clean")
+	m.driftProjects.Set(float64(errored), "error")
+	m.driftLastRun.Set(float64(lastRun.Unix()))
+}
@@ -0,0 +1,64 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package metrics_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/runatlantis/atlantis/server/metrics"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestMetrics_Nil(t *testing.T) {
+	t.Log("should do nothing if metrics are nil")
+	var m *metrics.Metrics
+	m.WebhookReceived("github", "pull_request")
+	m.CommandStarted()
+	m.CommandEnded()
+	m.CommandFinished("plan", metrics.SuccessResult)
+	m.TerraformRan("plan", "owner/repo", time.Second)
+	m.SetLocks(1)
+	m.VCSCalled("github", "github.com", "CreateComment", time.Second, nil)
+	m.DriftChecked(1, 2, 3, time.Now())
+}
+
+func TestMetrics_Record(t *testing.T) {
+	m := metrics.New()
+	m.WebhookReceived("github", "pull_request")
+	m.CommandStarted()
+	m.CommandStarted()
+	m.CommandEnded()
+	m.CommandFinished("plan", metrics.FailureResult)
+	m.VCSCalled("gitlab", "gitlab.com", "UpdateStatus", 100*time.Millisecond, errors.New("err"))
+	m.DriftChecked(1, 2, 0, time.Unix(1500000000, 0))
+
+	var buf bytes.Buffer
+	Ok(t, m.Registry.Write(&buf))
+	for _, exp := range []string{
+		`atlantis_webhooks_received_total{vcs="github",event="pull_request"} 1`,
+		`atlantis_commands_in_progress 1`,
+		`atlantis_commands_total{command="plan",result="failure"} 1`,
+		`atlantis_vcs_api_duration_seconds_count{vcs="gitlab",hostname="gitlab.com",method="UpdateStatus"} 1`,
+		`atlantis_vcs_api_errors_total{vcs="gitlab",hostname="gitlab.com",method="UpdateStatus"} 1`,
+		`atlantis_drift_projects{status="drifted"} 1`,
+		`atlantis_drift_projects{status="clean"} 2`,
+		`atlantis_drift_last_run_timestamp_seconds 1.5e+09`,
+	} {
+		Assert(t, strings.Contains(buf.String(), exp+"\n"), "exp %q in output:\n%s", exp, buf.String())
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+// Package metrics records metrics about what Atlantis is doing and serves
+// them in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// contentType is the content type of the Prometheus text format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// helpEscaper and labelValueEscaper escape the characters the text format
+// doesn't allow in help text and label values.
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Registry holds metrics and serves them over HTTP.
+type Registry struct {
+	mutex     sync.Mutex
+	metrics   []*vec
+	onCollect []func()
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// NewCounter registers a counter called name that's partitioned by labels.
+func (r *Registry) NewCounter(name string, help string, labels ...string) *Counter {
+	return &Counter{r.register(name, help, "counter", labels, nil)}
+}
+
+// NewGauge registers a gauge called name that's partitioned by labels.
+func (r *Registry) NewGauge(name string, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(name, help, "gauge", labels, nil)}
+}
+
+// NewHistogram registers a histogram called name that's partitioned by
+// labels. buckets are the upper bounds of its buckets in increasing order.
+func (r *Registry) NewHistogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{r.register(name, help, "histogram", labels, buckets)}
+}
+
+// OnCollect adds a function that's called before the metrics are served, ex.
+// to set gauges that are expensive to keep up to date.
+func (r *Registry) OnCollect(f func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.onCollect = append(r.onCollect, f)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	r.Write(w) // nolint: errcheck
+}
+
+// Write writes the metrics to w in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mutex.Lock()
+	onCollect := r.onCollect
+	metrics := r.metrics
+	r.mutex.Unlock()
+
+	for _, f := range onCollect {
+		f()
+	}
+	for _, m := range metrics {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Registry) register(name string, help string, typ string, labels []string, buckets []float64) *vec {
+	v := &vec{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.metrics = append(r.metrics, v)
+	return v
+}
+
+// Counter is a value that only goes up, ex. the number of requests.
+type Counter struct {
+	v *vec
+}
+
+// Inc adds one to the counter for labelValues.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta, which must not be negative, to the counter for
+// labelValues.
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	c.v.update(labelValues, func(s *series) { s.value += delta })
+}
+
+// Gauge is a value that can go up and down, ex. the number of locks.
+type Gauge struct {
+	v *vec
+}
+
+// Set sets the gauge for labelValues to value.
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.v.update(labelValues, func(s *series) { s.value = value })
+}
+
+// Add adds delta to the gauge for labelValues.
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.v.update(labelValues, func(s *series) { s.value += delta })
+}
+
+// Histogram counts observations, ex. durations, in buckets.
+type Histogram struct {
+	v *vec
+}
+
+// Observe adds value to the histogram for labelValues.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.v.update(labelValues, func(s *series) {
+		if s.bucketCounts == nil {
+			s.bucketCounts = make([]uint64, len(h.v.buckets))
+		}
+		for i, upper := range h.v.buckets {
+			if value <= upper {
+				s.bucketCounts[i]++
+			}
+		}
+		s.count++
+		s.value += value
+	})
+}
+
+// vec is a metric and its values for each combination of label values.
+type vec struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+
+	mutex  sync.Mutex
+	series map[string]*series
+}
+
+// series is the value of a metric for one combination of label values. For
+// histograms, value is the sum of the observations.
+type series struct {
+	labelValues  []string
+	value        float64
+	bucketCounts []uint64
+	count        uint64
+}
+
+func (v *vec) update(labelValues []string, f func(s *series)) {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s has %d labels but got %d values", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		v.series[key] = s
+	}
+	f(s)
+}
+
+func (v *vec) write(w io.Writer) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	var keys []string
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s %s\n", v.name, helpEscaper.Replace(v.help))
+	fmt.Fprintf(&b, "# TYPE %s %s\n", v.name, v.typ)
+	for _, k := range keys {
+		s := v.series[k]
+		if v.typ != "histogram" {
+			fmt.Fprintf(&b, "%s%s %s\n", v.name, v.labelPairs(s.labelValues, ""), formatFloat(s.value))
+			continue
+		}
+		for i, upper := range v.buckets {
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", v.name, v.labelPairs(s.labelValues, formatFloat(upper)), s.bucketCounts[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket%s %d\n", v.name, v.labelPairs(s.labelValues, "+Inf"), s.count)
+		fmt.Fprintf(&b, "%s_sum%s %s\n", v.name, v.labelPairs(s.labelValues, ""), formatFloat(s.value))
+		fmt.Fprintf(&b, "%s_count%s %d\n", v.name, v.labelPairs(s.labelValues, ""), s.count)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// labelPairs returns the labels of a series, ex. {repo="owner/repo"}. If le
+// isn't empty it's added as the upper bound of a histogram bucket.
+func (v *vec) labelPairs(labelValues []string, le string) string {
+	var pairs []string
+	for i, l := range v.labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, l, labelValueEscaper.Replace(labelValues[i])))
+	}
+	if le != "" {
+		pairs = append(pairs, fmt.Sprintf(`le="%s"`, le))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,90 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+package metrics_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/runatlantis/atlantis/server/metrics"
+	. "github.com/runatlantis/atlantis/testing"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	t.Log("should write each metric in the text format sorted by label values")
+	r := metrics.NewRegistry()
+	c := r.NewCounter("requests_total", "Requests.", "method")
+	g := r.NewGauge("locks", "Locks held.")
+	h := r.NewHistogram("duration_seconds", "Durations.", []float64{1, 5}, "repo")
+	c.Inc("POST")
+	c.Add(2, "GET")
+	g.Set(3)
+	g.Add(-1)
+	h.Observe(0.5, "owner/repo")
+	h.Observe(3, "owner/repo")
+	h.Observe(10, "owner/repo")
+
+	var buf bytes.Buffer
+	Ok(t, r.Write(&buf))
+	Equals(t, `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{method="GET"} 2
+requests_total{method="POST"} 1
+# HELP locks Locks held.
+# TYPE locks gauge
+locks 2
+# HELP duration_seconds Durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{repo="owner/repo",le="1"} 1
+duration_seconds_bucket{repo="owner/repo",le="5"} 2
+duration_seconds_bucket{repo="owner/repo",le="+Inf"} 3
+duration_seconds_sum{repo="owner/repo"} 13.5
+duration_seconds_count{repo="owner/repo"} 3
+`, buf.String())
+}
+
+func TestRegistry_EscapesLabelValues(t *testing.T) {
+	r := metrics.NewRegistry()
+	r.NewCounter("events_total", "Events.", "event").Inc("a \"b\"\n\\c")
+	var buf bytes.Buffer
+	Ok(t, r.Write(&buf))
+	Equals(t, `# HELP events_total Events.
+# TYPE events_total counter
+events_total{event="a \"b\"\n\\c"} 1
+`, buf.String())
+}
+
+func TestRegistry_WrongLabelCount(t *testing.T) {
+	t.Log("should panic if the number of label values is wrong")
+	r := metrics.NewRegistry()
+	c := r.NewCounter("events_total", "Events.", "event")
+	defer func() {
+		Assert(t, recover() != nil, "exp panic")
+	}()
+	c.Inc()
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	t.Log("should call the OnCollect functions before serving the metrics")
+	r := metrics.NewRegistry()
+	g := r.NewGauge("locks", "Locks held.")
+	r.OnCollect(func() { g.Set(5) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	Equals(t, 200, w.Code)
+	Equals(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	Equals(t, "# HELP locks Locks held.\n# TYPE locks gauge\nlocks 5\n", w.Body.String())
+}
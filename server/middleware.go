@@ -26,17 +26,25 @@ func NewRequestLogger(logger *logging.SimpleLogger) *RequestLogger {
 	return &RequestLogger{logger}
 }
 
+// quietPaths are requested often by monitoring systems so we don't log them.
+var quietPaths = map[string]bool{
+	"/metrics": true,
+	"/healthz": true,
+	"/readyz":  true,
+}
+
 // RequestLogger logs requests and their response codes.
 type RequestLogger struct {
 	logger *logging.SimpleLogger
 }
 
 // ServeHTTP implements the middleware function. It logs a request at INFO
-// level unless it's a request to /static/*.
+// level unless it's a request to /static/*, a scrape of /metrics or a health
+// probe.
 func (l *RequestLogger) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	next(rw, r)
 	res := rw.(negroni.ResponseWriter)
-	if !strings.HasPrefix(r.URL.RequestURI(), "/static") {
+	if !strings.HasPrefix(r.URL.RequestURI(), "/static") && !quietPaths[r.URL.Path] {
 		l.logger.Info("%d | %s %s", res.Status(), r.Method, r.URL.RequestURI())
 	}
 }
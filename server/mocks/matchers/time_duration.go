@@ -0,0 +1,20 @@
+package matchers
+
+import (
+	"reflect"
+	time "time"
+
+	"github.com/petergtz/pegomock"
+)
+
+func AnyTimeDuration() time.Duration {
+	pegomock.RegisterMatcher(pegomock.NewAnyMatcher(reflect.TypeOf((*(time.Duration))(nil)).Elem()))
+	var nullValue time.Duration
+	return nullValue
+}
+
+func EqTimeDuration(value time.Duration) time.Duration {
+	pegomock.RegisterMatcher(&pegomock.EqMatcher{Value: value})
+	var nullValue time.Duration
+	return nullValue
+}
@@ -0,0 +1,165 @@
+// Copyright 2017 HootSuite Media Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the License);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an AS IS BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// Modified hereafter by contributors to runatlantis/atlantis.
+//
+// Package redact masks secrets in text before it leaves Atlantis in comments,
+// webhooks and logs.
+package redact
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Mask replaces each secret.
+const Mask = "[REDACTED]"
+
+// minSecretLength is the length secrets must be to be masked. Shorter values,
+// ex. "1" or "true", would mask unrelated text.
+const minSecretLength = 4
+
+// DefaultPatterns match the tokens GitHub and GitLab issue and private keys.
+// They're used in addition to the patterns passed to New.
+var DefaultPatterns = []string{
+	// GitHub personal access, OAuth, app installation and refresh tokens.
+	`\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+	// GitHub app installation tokens in the old format.
+	`\bv1\.[0-9a-f]{40}\b`,
+	// GitLab personal access tokens.
+	`\bglpat-[A-Za-z0-9_-]{20,}`,
+	`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`,
+}
+
+// envSecretRegex matches the names of environment variables whose values are
+// secrets, ex. AWS_SECRET_ACCESS_KEY or GITHUB_TOKEN.
+var envSecretRegex = regexp.MustCompile(`(?i)(^|_)(SECRET|TOKEN|PASSWORD)(_|$)`)
+
+// Redactor masks known secrets and text that matches its patterns. Its
+// methods do nothing if it's nil so they can be called without checking if
+// redaction is configured. It's safe for concurrent use.
+type Redactor struct {
+	patterns []*regexp.Regexp
+	// parent is the Redactor this one was created from with Child. Its
+	// secrets are masked too.
+	parent *Redactor
+
+	mtx     sync.RWMutex
+	secrets []string
+}
+
+// New returns a Redactor that masks text matching DefaultPatterns and
+// patterns, which are regular expressions.
+func New(patterns []string) (*Redactor, error) {
+	r := &Redactor{}
+	for _, p := range append(DefaultPatterns, patterns...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing redact pattern %q", p)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// Child returns a Redactor that masks everything r does as well as the
+// secrets added to it. Those aren't added to r so they're forgotten along
+// with the child, ex. the values of a pull request's variables once its
+// command finishes.
+func (r *Redactor) Child() *Redactor {
+	if r == nil {
+		return nil
+	}
+	return &Redactor{patterns: r.patterns, parent: r}
+}
+
+// AddSecret masks secret from now on. Secrets shorter than four characters
+// are ignored.
+func (r *Redactor) AddSecret(secret string) {
+	if r == nil || len(secret) < minSecretLength {
+		return
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for _, s := range r.secrets {
+		if s == secret {
+			return
+		}
+	}
+	r.secrets = append(r.secrets, secret)
+}
+
+// AddEnvSecrets masks the values of the variables in environ, which are in
+// the "key=value" format of os.Environ, whose names look like they hold
+// secrets, ex. *_SECRET, *_TOKEN and *_PASSWORD.
+func (r *Redactor) AddEnvSecrets(environ []string) {
+	for _, kv := range environ {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 && envSecretRegex.MatchString(parts[0]) {
+			r.AddSecret(parts[1])
+		}
+	}
+}
+
+// Redact returns s with its secrets masked.
+func (r *Redactor) Redact(s string) string {
+	if r == nil || s == "" {
+		return s
+	}
+	for _, secret := range r.allSecrets() {
+		s = strings.Replace(s, secret, Mask, -1)
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllLiteralString(s, Mask)
+	}
+	return s
+}
+
+// allSecrets returns the secrets of r and its parents, longest first so a
+// secret that contains another is masked whole.
+func (r *Redactor) allSecrets() []string {
+	var secrets []string
+	for ; r != nil; r = r.parent {
+		r.mtx.RLock()
+		secrets = append(secrets, r.secrets...)
+		r.mtx.RUnlock()
+	}
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+	return secrets
+}
+
+// Writer returns a writer that masks secrets in each write before writing it
+// to w. It's meant to wrap the output of a log.Logger, which writes each
+// entry in one call.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &writer{r: r, w: w}
+}
+
+type writer struct {
+	r *Redactor
+	w io.Writer
+}
+
+// Write writes the redacted p. It returns len(p) on success since callers
+// expect the length they wrote, not the length of the redacted text.
+func (w *writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.r.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -19,11 +19,13 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
@@ -35,11 +37,14 @@ import (
 	"github.com/runatlantis/atlantis/server/events"
 	"github.com/runatlantis/atlantis/server/events/locking"
 	"github.com/runatlantis/atlantis/server/events/locking/boltdb"
+	"github.com/runatlantis/atlantis/server/events/policy"
 	"github.com/runatlantis/atlantis/server/events/run"
 	"github.com/runatlantis/atlantis/server/events/terraform"
 	"github.com/runatlantis/atlantis/server/events/vcs"
 	"github.com/runatlantis/atlantis/server/events/webhooks"
 	"github.com/runatlantis/atlantis/server/logging"
+	"github.com/runatlantis/atlantis/server/metrics"
+	"github.com/runatlantis/atlantis/server/redact"
 	"github.com/runatlantis/atlantis/server/static"
 	"github.com/urfave/cli"
 	"github.com/urfave/negroni"
@@ -61,15 +66,41 @@ type Server struct {
 	LockDetailTemplate TemplateWriter
 	SSLCertFile        string
 	SSLKeyFile         string
+	// DriftDetector is nil if drift detection is disabled.
+	DriftDetector *events.DriftDetector
+	DriftInterval time.Duration
+	DriftTemplate TemplateWriter
+	// Metrics are served on /metrics.
+	Metrics *metrics.Metrics
+	// HealthController serves /healthz, /readyz and /version.
+	HealthController *HealthController
+	// LockWebhooks are sent when a lock is discarded in the UI. If nil, they
+	// aren't sent.
+	LockWebhooks webhooks.LockSender
+	// Webhooks are told the URLs of lock pages when the server starts so
+	// emails can link to them. If nil, they aren't.
+	Webhooks *webhooks.MultiWebhookSender
 }
 
 // UserConfig holds config values passed in by the user.
 // The mapstructure tags correspond to flags in cmd/server.go and are used when
 // the config is parsed from a YAML file.
 type UserConfig struct {
-	AllowForkPRs        bool   `mapstructure:"allow-fork-prs"`
-	AtlantisURL         string `mapstructure:"atlantis-url"`
-	DataDir             string `mapstructure:"data-dir"`
+	AllowForkPRs bool   `mapstructure:"allow-fork-prs"`
+	AtlantisURL  string `mapstructure:"atlantis-url"`
+	DataDir      string `mapstructure:"data-dir"`
+	// DriftInterval is how often to check for drift, ex. "6h". If empty,
+	// drift isn't checked.
+	DriftInterval string `mapstructure:"drift-interval"`
+	// GithubAppID is the id of the GitHub App to authenticate as. If set,
+	// GithubAppKeyFile must also be set and GithubUser/GithubToken aren't used.
+	GithubAppID int `mapstructure:"gh-app-id"`
+	// GithubAppInstallationID is the installation of the app to act as. If 0,
+	// the app must be installed exactly once.
+	GithubAppInstallationID int    `mapstructure:"gh-app-installation-id"`
+	GithubAppKeyFile        string `mapstructure:"gh-app-key-file"`
+	// GithubChecks is whether to create GitHub check runs for each project.
+	GithubChecks        bool   `mapstructure:"gh-checks"`
 	GithubHostname      string `mapstructure:"gh-hostname"`
 	GithubToken         string `mapstructure:"gh-token"`
 	GithubUser          string `mapstructure:"gh-user"`
@@ -78,16 +109,37 @@ type UserConfig struct {
 	GitlabToken         string `mapstructure:"gitlab-token"`
 	GitlabUser          string `mapstructure:"gitlab-user"`
 	GitlabWebHookSecret string `mapstructure:"gitlab-webhook-secret"`
+	LogFormat           string `mapstructure:"log-format"`
 	LogLevel            string `mapstructure:"log-level"`
-	Port                int    `mapstructure:"port"`
-	RepoWhitelist       string `mapstructure:"repo-whitelist"`
+	// PolicyDir is the directory of policy files that plans are checked
+	// against. If empty, plans aren't checked.
+	PolicyDir string `mapstructure:"policy-dir"`
+	// PolicyOwners is a comma separated list of usernames that can override
+	// failed policy checks.
+	PolicyOwners string `mapstructure:"policy-owners"`
+	Port         int    `mapstructure:"port"`
+	// RedactPatterns are regular expressions that match secrets to mask in
+	// comments, webhooks and logs.
+	RedactPatterns []string `mapstructure:"redact-patterns"`
+	RepoWhitelist  string   `mapstructure:"repo-whitelist"`
 	// RequireApproval is whether to require pull request approval before
 	// allowing terraform apply's to be run.
-	RequireApproval bool            `mapstructure:"require-approval"`
-	SlackToken      string          `mapstructure:"slack-token"`
-	SSLCertFile     string          `mapstructure:"ssl-cert-file"`
-	SSLKeyFile      string          `mapstructure:"ssl-key-file"`
-	Webhooks        []WebhookConfig `mapstructure:"webhooks"`
+	RequireApproval bool   `mapstructure:"require-approval"`
+	SlackToken      string `mapstructure:"slack-token"`
+	// SMTP configures the server email webhooks are sent through.
+	SMTP        SMTPConfig `mapstructure:"smtp"`
+	SSLCertFile string     `mapstructure:"ssl-cert-file"`
+	SSLKeyFile  string     `mapstructure:"ssl-key-file"`
+	// StoreWebhookPayloads is whether to store the raw payloads of incoming
+	// webhooks so they can be replayed with "atlantis replay".
+	StoreWebhookPayloads bool `mapstructure:"store-webhook-payloads"`
+	// VCSHosts configures GitHub Enterprise and GitLab installs in addition
+	// to those configured with the gh-* and gitlab-* flags.
+	VCSHosts []VCSHostConfig `mapstructure:"vcs-hosts"`
+	Webhooks []WebhookConfig `mapstructure:"webhooks"`
+	// WorkflowsFile is a YAML file of workflows that projects can use. If
+	// empty, only the workflows in atlantis.yaml files can be used.
+	WorkflowsFile string `mapstructure:"workflows-file"`
 }
 
 // Config holds config for server that isn't passed in by the user.
@@ -96,6 +148,47 @@ type Config struct {
 	AtlantisVersion  string
 }
 
+// GithubVCSHostKind and GitlabVCSHostKind are the valid values of
+// VCSHostConfig.Kind.
+const (
+	GithubVCSHostKind = "github"
+	GitlabVCSHostKind = "gitlab"
+)
+
+// VCSHostConfig is nested within UserConfig. It's used to configure each
+// GitHub and GitLab install we support.
+type VCSHostConfig struct {
+	// Kind is the type of install, either github or gitlab.
+	Kind string `mapstructure:"kind"`
+	// Hostname is the hostname of the install, ex. github.mycompany.com.
+	// GitLab hostnames can include a scheme, ex. http://gitlab.mycompany.com.
+	// Defaults to github.com or gitlab.com.
+	Hostname      string `mapstructure:"hostname"`
+	User          string `mapstructure:"user"`
+	Token         string `mapstructure:"token"`
+	WebhookSecret string `mapstructure:"webhook-secret"`
+	// AppID, AppKeyFile and AppInstallationID configure a GitHub install to
+	// authenticate as a GitHub App instead of with User and Token.
+	AppID             int    `mapstructure:"app-id"`
+	AppKeyFile        string `mapstructure:"app-key-file"`
+	AppInstallationID int    `mapstructure:"app-installation-id"`
+}
+
+// SMTPConfig is nested within UserConfig. It's used to configure the SMTP
+// server that email webhooks are sent through.
+type SMTPConfig struct {
+	Host string `mapstructure:"host"`
+	// Port defaults to 587.
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// From is the address emails are sent from, ex.
+	// "Atlantis <atlantis@mycompany.com>".
+	From string `mapstructure:"from"`
+	// TLS is starttls (the default), tls or none.
+	TLS string `mapstructure:"tls"`
+}
+
 // WebhookConfig is nested within UserConfig. It's used to configure webhooks.
 type WebhookConfig struct {
 	// Event is the type of event we should send this webhook for, ex. apply.
@@ -104,49 +197,126 @@ type WebhookConfig struct {
 	// that is being modified for this event. If the regex matches, we'll
 	// send the webhook, ex. "production.*".
 	WorkspaceRegex string `mapstructure:"workspace-regex"`
-	// Kind is the type of webhook we should send, ex. slack.
+	// RepoRegex is a regex that is matched against the full name of the
+	// repo, ex. "runatlantis/.*". If empty, webhooks are sent for all repos.
+	RepoRegex string `mapstructure:"repo-regex"`
+	// Kind is the type of webhook we should send, ex. slack or http.
 	Kind string `mapstructure:"kind"`
 	// Channel is the channel to send this webhook to. It only applies to
 	// slack webhooks. Should be without '#'.
 	Channel string `mapstructure:"channel"`
+	// URL is where http webhooks are POSTed.
+	URL string `mapstructure:"url"`
+	// Secret is used to sign the payloads of http webhooks.
+	Secret string `mapstructure:"secret"`
+	// To are the addresses email webhooks are sent to.
+	To []string `mapstructure:"to"`
+	// Subject and Body are Go templates that override the default subject
+	// and body of email webhooks.
+	Subject string `mapstructure:"subject"`
+	Body    string `mapstructure:"body"`
 }
 
 // NewServer returns a new server. If there are issues starting the server or
 // its dependencies an error will be returned. This is like the main() function
 // for the server CLI command because it injects all the dependencies.
 func NewServer(userConfig UserConfig, config Config) (*Server, error) {
-	var supportedVCSHosts []vcs.Host
-	var githubClient *vcs.GithubClient
-	var gitlabClient *vcs.GitlabClient
-	if userConfig.GithubUser != "" {
-		supportedVCSHosts = append(supportedVCSHosts, vcs.Github)
-		var err error
-		githubClient, err = vcs.NewGithubClient(userConfig.GithubHostname, userConfig.GithubUser, userConfig.GithubToken)
-		if err != nil {
-			return nil, err
-		}
+	// Hosts configured with flags are handled the same as those configured
+	// in the vcs-hosts section of the config file.
+	var vcsHosts []VCSHostConfig
+	if userConfig.GithubUser != "" || userConfig.GithubAppID != 0 {
+		vcsHosts = append(vcsHosts, VCSHostConfig{
+			Kind:              GithubVCSHostKind,
+			Hostname:          userConfig.GithubHostname,
+			User:              userConfig.GithubUser,
+			Token:             userConfig.GithubToken,
+			WebhookSecret:     userConfig.GithubWebHookSecret,
+			AppID:             userConfig.GithubAppID,
+			AppKeyFile:        userConfig.GithubAppKeyFile,
+			AppInstallationID: userConfig.GithubAppInstallationID,
+		})
 	}
 	if userConfig.GitlabUser != "" {
-		supportedVCSHosts = append(supportedVCSHosts, vcs.Gitlab)
-		gitlabClient = &vcs.GitlabClient{
-			Client: gitlab.NewClient(nil, userConfig.GitlabToken),
-		}
-		// If not using gitlab.com we need to set the URL to the API.
-		if userConfig.GitlabHostname != "gitlab.com" {
-			// Check if they've also provided a scheme so we don't prepend it
-			// again.
-			scheme := "https"
-			schemeSplit := strings.Split(userConfig.GitlabHostname, "://")
-			if len(schemeSplit) > 1 {
-				scheme = schemeSplit[0]
-				userConfig.GitlabHostname = schemeSplit[1]
+		vcsHosts = append(vcsHosts, VCSHostConfig{
+			Kind:          GitlabVCSHostKind,
+			Hostname:      userConfig.GitlabHostname,
+			User:          userConfig.GitlabUser,
+			Token:         userConfig.GitlabToken,
+			WebhookSecret: userConfig.GitlabWebHookSecret,
+		})
+	}
+	vcsHosts = append(vcsHosts, userConfig.VCSHosts...)
+
+	redactor, err := redact.New(userConfig.RedactPatterns)
+	if err != nil {
+		return nil, errors.Wrap(err, "initializing redaction")
+	}
+	for _, h := range vcsHosts {
+		redactor.AddSecret(h.Token)
+		redactor.AddSecret(h.WebhookSecret)
+	}
+	redactor.AddSecret(userConfig.SlackToken)
+	redactor.AddEnvSecrets(os.Environ())
+
+	// All of these are keyed by hostname.
+	githubClients := make(map[string]vcs.Client)
+	githubPullGetters := make(vcs.GithubClientSet)
+	githubCreds := make(map[string]vcs.GithubCredentials)
+	githubSecrets := make(map[string][]byte)
+	gitlabClients := make(map[string]vcs.Client)
+	gitlabMergeRequestGetters := make(vcs.GitlabClientSet)
+	gitlabCreds := make(map[string]vcs.GitlabCredentials)
+	gitlabSecrets := make(map[string][]byte)
+	// The comment users are the names users mention to run commands, ex.
+	// "@atlantis plan".
+	githubCommentUsers := make(map[string]string)
+	gitlabCommentUsers := make(map[string]string)
+	for _, h := range vcsHosts {
+		switch h.Kind {
+		case GithubVCSHostKind:
+			if h.Hostname == "" {
+				h.Hostname = "github.com"
+			}
+			if _, ok := githubClients[h.Hostname]; ok {
+				return nil, fmt.Errorf("GitHub host %q is configured more than once", h.Hostname)
+			}
+			creds, commentUser, err := newGithubCredentials(h)
+			if err != nil {
+				return nil, errors.Wrapf(err, "configuring GitHub host %q", h.Hostname)
+			}
+			client, err := vcs.NewGithubClient(h.Hostname, creds)
+			if err != nil {
+				return nil, err
 			}
-			apiURL := fmt.Sprintf("%s://%s/api/v4/", scheme, userConfig.GitlabHostname)
-			if err := gitlabClient.Client.SetBaseURL(apiURL); err != nil {
-				return nil, errors.Wrapf(err, "setting GitLab API URL: %s", apiURL)
+			githubClients[h.Hostname] = client
+			githubPullGetters[h.Hostname] = client
+			githubCreds[h.Hostname] = creds
+			githubSecrets[h.Hostname] = []byte(h.WebhookSecret)
+			githubCommentUsers[h.Hostname] = commentUser
+		case GitlabVCSHostKind:
+			hostname, client, err := newGitlabClient(h)
+			if err != nil {
+				return nil, err
 			}
+			if _, ok := gitlabClients[hostname]; ok {
+				return nil, fmt.Errorf("GitLab host %q is configured more than once", hostname)
+			}
+			gitlabClients[hostname] = client
+			gitlabMergeRequestGetters[hostname] = client
+			gitlabCreds[hostname] = vcs.GitlabCredentials{User: h.User, Token: h.Token}
+			gitlabSecrets[hostname] = []byte(h.WebhookSecret)
+			gitlabCommentUsers[hostname] = h.User
+		default:
+			return nil, fmt.Errorf("invalid vcs host kind %q, must be one of %q or %q", h.Kind, GithubVCSHostKind, GitlabVCSHostKind)
 		}
 	}
+	var supportedVCSHosts []vcs.Host
+	if len(githubClients) > 0 {
+		supportedVCSHosts = append(supportedVCSHosts, vcs.Github)
+	}
+	if len(gitlabClients) > 0 {
+		supportedVCSHosts = append(supportedVCSHosts, vcs.Gitlab)
+	}
 	var webhooksConfig []webhooks.Config
 	for _, c := range userConfig.Webhooks {
 		config := webhooks.Config{
@@ -154,14 +324,35 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 			Event:          c.Event,
 			Kind:           c.Kind,
 			WorkspaceRegex: c.WorkspaceRegex,
+			RepoRegex:      c.RepoRegex,
+			URL:            c.URL,
+			Secret:         c.Secret,
+			To:             c.To,
+			Subject:        c.Subject,
+			Body:           c.Body,
 		}
+		redactor.AddSecret(c.Secret)
 		webhooksConfig = append(webhooksConfig, config)
 	}
-	webhooksManager, err := webhooks.NewMultiWebhookSender(webhooksConfig, webhooks.NewSlackClient(userConfig.SlackToken))
+	var emailClient webhooks.EmailClient
+	if userConfig.SMTP.Host != "" {
+		smtp := userConfig.SMTP
+		smtpClient, err := webhooks.NewSMTPClient(smtp.Host, smtp.Port, smtp.Username, smtp.Password, smtp.From, smtp.TLS)
+		if err != nil {
+			return nil, errors.Wrap(err, "initializing smtp client")
+		}
+		redactor.AddSecret(smtp.Password)
+		emailClient = smtpClient
+	}
+	webhooksManager, err := webhooks.NewMultiWebhookSender(webhooksConfig, webhooks.NewSlackClient(userConfig.SlackToken), emailClient)
 	if err != nil {
 		return nil, errors.Wrap(err, "initializing webhooks")
 	}
-	vcsClient := vcs.NewDefaultClientProxy(githubClient, gitlabClient)
+	webhooksManager.Redactor = redactor
+	serverMetrics := metrics.New()
+	vcsClient := vcs.NewDefaultClientProxy(githubClients, gitlabClients)
+	vcsClient.Metrics = serverMetrics
+	vcsClient.Redactor = redactor
 	commitStatusUpdater := &events.DefaultCommitStatusUpdater{Client: vcsClient}
 	terraformClient, err := terraform.NewClient(userConfig.DataDir)
 	// The flag.Lookup call is to detect if we're running in a unit test. If we
@@ -176,6 +367,11 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		return nil, err
 	}
 	lockingClient := locking.NewClient(boltdb)
+	serverMetrics.Registry.OnCollect(func() {
+		if locks, err := lockingClient.List(); err == nil {
+			serverMetrics.SetLocks(len(locks))
+		}
+	})
 	run := &run.Run{}
 	configReader := &events.ProjectConfigManager{}
 	workspaceLocker := events.NewDefaultAtlantisWorkspaceLocker()
@@ -187,74 +383,163 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		Run:          run,
 		ConfigReader: configReader,
 		Terraform:    terraformClient,
+		Metrics:      serverMetrics,
+		Webhooks:     webhooksManager,
+	}
+	if userConfig.WorkflowsFile != "" {
+		projectPreExecute.Workflows, err = events.ReadWorkflowsFile(userConfig.WorkflowsFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading workflows")
+		}
+	}
+	var policyChecker *policy.Checker
+	if userConfig.PolicyDir != "" {
+		policyChecker, err = policy.NewChecker(userConfig.PolicyDir)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading policies")
+		}
+	}
+	var policyOwners []string
+	for _, owner := range strings.Split(userConfig.PolicyOwners, ",") {
+		if owner = strings.TrimSpace(owner); owner != "" {
+			policyOwners = append(policyOwners, owner)
+		}
 	}
 	applyExecutor := &events.ApplyExecutor{
-		VCSClient:         vcsClient,
-		Terraform:         terraformClient,
-		RequireApproval:   userConfig.RequireApproval,
-		Run:               run,
-		AtlantisWorkspace: workspace,
-		ProjectPreExecute: projectPreExecute,
-		Webhooks:          webhooksManager,
+		VCSClient:          vcsClient,
+		Terraform:          terraformClient,
+		RequireApproval:    userConfig.RequireApproval,
+		Run:                run,
+		AtlantisWorkspace:  workspace,
+		ProjectPreExecute:  projectPreExecute,
+		Webhooks:           webhooksManager,
+		PolicyOwners:       policyOwners,
+		Metrics:            serverMetrics,
+		ApplyStartWebhooks: webhooksManager,
 	}
 	planExecutor := &events.PlanExecutor{
-		VCSClient:         vcsClient,
-		Terraform:         terraformClient,
-		Run:               run,
-		Workspace:         workspace,
-		ProjectPreExecute: projectPreExecute,
-		Locker:            lockingClient,
-		ProjectFinder:     &events.DefaultProjectFinder{},
+		VCSClient:               vcsClient,
+		Terraform:               terraformClient,
+		Run:                     run,
+		Workspace:               workspace,
+		ProjectPreExecute:       projectPreExecute,
+		Locker:                  lockingClient,
+		ProjectFinder:           &events.DefaultProjectFinder{},
+		AtlantisWorkspaceLocker: workspaceLocker,
+		PolicyChecker:           policyChecker,
+		KnownProjects:           boltdb,
+		Metrics:                 serverMetrics,
+		Webhooks:                webhooksManager,
+		LockWebhooks:            webhooksManager,
+	}
+	logger := logging.NewSimpleLogger("server", nil, false, logging.ToLogLevel(userConfig.LogLevel))
+	if logging.ToLogFormat(userConfig.LogFormat) == logging.JSON {
+		logger = logging.NewJSONLogger("server", logging.ToLogLevel(userConfig.LogLevel))
+	}
+	// All our loggers write to this logger's output, including those created
+	// for each command.
+	logger.Logger.SetOutput(redactor.Writer(os.Stderr))
+	for _, unmigrated := range boltdb.UnmigratedLocks() {
+		logger.Warn("%s. It will block its project until it's discarded in the UI", unmigrated)
 	}
 	pullClosedExecutor := &events.PullClosedExecutor{
 		VCSClient: vcsClient,
 		Locker:    lockingClient,
 		Workspace: workspace,
+		Webhooks:  webhooksManager,
+		Logger:    logger,
 	}
-	logger := logging.NewSimpleLogger("server", nil, false, logging.ToLogLevel(userConfig.LogLevel))
 	eventParser := &events.EventParser{
-		GithubUser:  userConfig.GithubUser,
-		GithubToken: userConfig.GithubToken,
-		GitlabUser:  userConfig.GitlabUser,
-		GitlabToken: userConfig.GitlabToken,
+		GithubCreds: githubCreds,
+		GitlabCreds: gitlabCreds,
 	}
 	commentParser := &events.CommentParser{
-		GithubUser:  userConfig.GithubUser,
-		GithubToken: userConfig.GithubToken,
-		GitlabUser:  userConfig.GitlabUser,
-		GitlabToken: userConfig.GitlabToken,
+		GithubUsers: githubCommentUsers,
+		GitlabUsers: gitlabCommentUsers,
 	}
 	commandHandler := &events.CommandHandler{
-		ApplyExecutor:            applyExecutor,
-		PlanExecutor:             planExecutor,
-		LockURLGenerator:         planExecutor,
-		EventParser:              eventParser,
-		VCSClient:                vcsClient,
-		GithubPullGetter:         githubClient,
-		GitlabMergeRequestGetter: gitlabClient,
-		CommitStatusUpdater:      commitStatusUpdater,
-		AtlantisWorkspaceLocker:  workspaceLocker,
-		MarkdownRenderer:         markdownRenderer,
-		Logger:                   logger,
-		AllowForkPRs:             userConfig.AllowForkPRs,
-		AllowForkPRsFlag:         config.AllowForkPRsFlag,
+		ApplyExecutor:           applyExecutor,
+		PlanExecutor:            planExecutor,
+		LockURLGenerator:        planExecutor,
+		EventParser:             eventParser,
+		VCSClient:               vcsClient,
+		CommitStatusUpdater:     commitStatusUpdater,
+		AtlantisWorkspaceLocker: workspaceLocker,
+		MarkdownRenderer:        markdownRenderer,
+		Logger:                  logger,
+		AllowForkPRs:            userConfig.AllowForkPRs,
+		AllowForkPRsFlag:        config.AllowForkPRsFlag,
+		Metrics:                 serverMetrics,
+		Redactor:                redactor,
+	}
+	// The getters are left nil if that VCS host isn't supported so the
+	// command handler can error out.
+	if len(githubPullGetters) > 0 {
+		commandHandler.GithubPullGetter = githubPullGetters
+		if userConfig.GithubChecks {
+			commandHandler.GithubChecks = &events.GithubChecksUpdater{Creator: githubPullGetters, Redactor: redactor}
+		}
+	}
+	if len(gitlabMergeRequestGetters) > 0 {
+		commandHandler.GitlabMergeRequestGetter = gitlabMergeRequestGetters
 	}
 	repoWhitelist := &events.RepoWhitelist{
 		Whitelist: userConfig.RepoWhitelist,
 	}
+	var deliveryArchive *DeliveryArchive
+	if userConfig.StoreWebhookPayloads {
+		deliveryArchive = &DeliveryArchive{
+			Dir: filepath.Join(userConfig.DataDir, DeliveriesDirName),
+		}
+	}
 	eventsController := &EventsController{
 		CommandRunner:          commandHandler,
 		PullCleaner:            pullClosedExecutor,
 		Parser:                 eventParser,
 		CommentParser:          commentParser,
 		Logger:                 logger,
-		GithubWebHookSecret:    []byte(userConfig.GithubWebHookSecret),
+		GithubWebHookSecrets:   githubSecrets,
 		GithubRequestValidator: &DefaultGithubRequestValidator{},
 		GitlabRequestParser:    &DefaultGitlabRequestParser{},
-		GitlabWebHookSecret:    []byte(userConfig.GitlabWebHookSecret),
+		GitlabWebHookSecrets:   gitlabSecrets,
 		RepoWhitelist:          repoWhitelist,
 		SupportedVCSHosts:      supportedVCSHosts,
 		VCSClient:              vcsClient,
+		DeliveryRecorder:       boltdb,
+		DeliveryArchive:        deliveryArchive,
+		Metrics:                serverMetrics,
+	}
+	var driftInterval time.Duration
+	var driftDetector *events.DriftDetector
+	if userConfig.DriftInterval != "" {
+		driftInterval, err = time.ParseDuration(userConfig.DriftInterval)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing drift interval")
+		}
+		driftDetector = &events.DriftDetector{
+			Projects:          boltdb,
+			RepoWhitelist:     repoWhitelist,
+			EventParser:       eventParser,
+			Workspace:         workspace,
+			ProjectPreExecute: projectPreExecute,
+			Terraform:         terraformClient,
+			Webhooks:          webhooksManager,
+			Logger:            logger,
+			Metrics:           serverMetrics,
+			Redactor:          redactor,
+		}
+	}
+	healthController := &HealthController{
+		AtlantisVersion: config.AtlantisVersion,
+		ReadinessChecks: []ReadinessCheck{
+			LockerCheck(lockingClient),
+			TerraformCheck(),
+			DataDirCheck(userConfig.DataDir),
+			VCSCredentialsCheck(githubCreds, gitlabCreds),
+		},
+	}
+	if terraformClient != nil {
+		healthController.TerraformVersion = terraformClient.Version().String()
 	}
 	router := mux.NewRouter()
 	return &Server{
@@ -270,9 +555,72 @@ func NewServer(userConfig UserConfig, config Config) (*Server, error) {
 		LockDetailTemplate: lockTemplate,
 		SSLKeyFile:         userConfig.SSLKeyFile,
 		SSLCertFile:        userConfig.SSLCertFile,
+		DriftDetector:      driftDetector,
+		DriftInterval:      driftInterval,
+		DriftTemplate:      driftTemplate,
+		Metrics:            serverMetrics,
+		HealthController:   healthController,
+		LockWebhooks:       webhooksManager,
+		Webhooks:           webhooksManager,
 	}, nil
 }
 
+// newGithubCredentials returns the credentials for the GitHub install h and
+// the name users mention to run commands on it.
+func newGithubCredentials(h VCSHostConfig) (vcs.GithubCredentials, string, error) {
+	if h.AppID == 0 {
+		if h.User == "" || h.Token == "" {
+			return nil, "", errors.New("user and token, or app-id and app-key-file must be set")
+		}
+		return &vcs.GithubUserCredentials{User: h.User, Token: h.Token}, h.User, nil
+	}
+	keyPEM, err := ioutil.ReadFile(h.AppKeyFile)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "reading GitHub App private key")
+	}
+	creds, err := vcs.NewGithubAppCredentials(h.Hostname, int64(h.AppID), keyPEM, int64(h.AppInstallationID))
+	if err != nil {
+		return nil, "", err
+	}
+	slug, err := creds.Slug()
+	if err != nil {
+		return nil, "", err
+	}
+	return creds, slug, nil
+}
+
+// newGitlabClient returns the client for the GitLab install h and its
+// hostname without any scheme.
+func newGitlabClient(h VCSHostConfig) (string, *vcs.GitlabClient, error) {
+	if h.User == "" || h.Token == "" {
+		return "", nil, fmt.Errorf("configuring GitLab host %q: user and token must be set", h.Hostname)
+	}
+	client := &vcs.GitlabClient{
+		Client: gitlab.NewClient(nil, h.Token),
+	}
+	hostname := h.Hostname
+	if hostname == "" || hostname == "gitlab.com" {
+		return "gitlab.com", client, nil
+	}
+	// Check if they've also provided a scheme so we don't prepend it again.
+	scheme := "https"
+	schemeSplit := strings.Split(hostname, "://")
+	if len(schemeSplit) > 1 {
+		scheme = schemeSplit[0]
+		hostname = schemeSplit[1]
+	}
+	apiURL := fmt.Sprintf("%s://%s/api/v4/", scheme, hostname)
+	if err := client.Client.SetBaseURL(apiURL); err != nil {
+		return "", nil, errors.Wrapf(err, "setting GitLab API URL: %s", apiURL)
+	}
+	// Repos are keyed by hostname without the port since that's what's
+	// parsed from their clone urls.
+	if u, err := url.Parse(apiURL); err == nil {
+		hostname = u.Hostname()
+	}
+	return hostname, client, nil
+}
+
 // Start creates the routes and starts serving traffic.
 func (s *Server) Start() error {
 	s.Router.HandleFunc("/", s.Index).Methods("GET").MatcherFunc(func(r *http.Request, rm *mux.RouteMatch) bool {
@@ -280,15 +628,24 @@ func (s *Server) Start() error {
 	})
 	s.Router.PathPrefix("/static/").Handler(http.FileServer(&assetfs.AssetFS{Asset: static.Asset, AssetDir: static.AssetDir, AssetInfo: static.AssetInfo}))
 	s.Router.HandleFunc("/events", s.postEvents).Methods("POST")
+	s.Router.HandleFunc("/drift", s.Drift).Methods("GET")
+	s.Router.Handle("/metrics", s.Metrics.Registry).Methods("GET")
+	s.Router.HandleFunc("/healthz", s.HealthController.Healthz).Methods("GET")
+	s.Router.HandleFunc("/readyz", s.HealthController.Readyz).Methods("GET")
+	s.Router.HandleFunc("/version", s.HealthController.Version).Methods("GET")
 	s.Router.HandleFunc("/locks", s.DeleteLockRoute).Methods("DELETE").Queries("id", "{id:.*}")
 	lockRoute := s.Router.HandleFunc("/lock", s.GetLockRoute).Methods("GET").Queries("id", "{id}").Name(LockRouteName)
 	// function that planExecutor can use to construct detail view url
 	// injecting this here because this is the earliest routes are created
-	s.CommandHandler.SetLockURL(func(lockID string) string {
+	lockURL := func(lockID string) string {
 		// ignoring error since guaranteed to succeed if "id" is specified
 		u, _ := lockRoute.URL("id", url.QueryEscape(lockID))
 		return s.AtlantisURL + u.RequestURI()
-	})
+	}
+	s.CommandHandler.SetLockURL(lockURL)
+	if s.Webhooks != nil {
+		s.Webhooks.SetLockURL(lockURL)
+	}
 	n := negroni.New(&negroni.Recovery{
 		Logger:     log.New(os.Stdout, "", log.LstdFlags),
 		PrintStack: false,
@@ -302,6 +659,15 @@ func (s *Server) Start() error {
 	// Stop on SIGINTs and SIGTERMs.
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
+	if s.DriftDetector != nil {
+		go s.DriftDetector.Start(s.DriftInterval)
+	}
+	go func() {
+		for range time.Tick(DeliveryPruneInterval) {
+			s.EventsController.PruneDeliveries()
+		}
+	}()
+
 	server := &http.Server{Addr: fmt.Sprintf(":%d", s.Port), Handler: n}
 	go func() {
 		s.Logger.Warn("Atlantis started - listening on port %v", s.Port)
@@ -342,6 +708,7 @@ func (s *Server) Index(w http.ResponseWriter, _ *http.Request) {
 		lockURL, _ := s.Router.Get(LockRouteName).URL("id", url.QueryEscape(id))
 		lockResults = append(lockResults, LockIndexData{
 			LockURL:      lockURL.String(),
+			Hostname:     v.Project.Hostname,
 			RepoFullName: v.Project.RepoFullName,
 			PullNum:      v.Pull.Num,
 			Time:         v.Time,
@@ -351,9 +718,44 @@ func (s *Server) Index(w http.ResponseWriter, _ *http.Request) {
 	s.IndexTemplate.Execute(w, IndexData{
 		Locks:           lockResults,
 		AtlantisVersion: s.AtlantisVersion,
+		DriftEnabled:    s.DriftDetector != nil,
 	})
 }
 
+// Drift is the /drift route. It renders the results of the last drift check.
+func (s *Server) Drift(w http.ResponseWriter, _ *http.Request) {
+	if s.DriftDetector == nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Drift detection isn't enabled")
+		return
+	}
+	results, lastRun := s.DriftDetector.Results()
+	data := DriftData{
+		LastRun:         lastRun,
+		AtlantisVersion: s.AtlantisVersion,
+	}
+	for _, r := range results {
+		if r.Drifted {
+			data.DriftedCount++
+		}
+		if r.Error != "" {
+			data.ErrorCount++
+		}
+		data.Results = append(data.Results, DriftResultData{
+			Hostname:     r.Project.Hostname,
+			RepoFullName: r.Project.RepoFullName,
+			Path:         r.Project.Path,
+			Workspace:    r.Workspace,
+			Drifted:      r.Drifted,
+			Summary:      r.Summary,
+			Error:        r.Error,
+			Output:       r.Output,
+			Time:         r.Time,
+		})
+	}
+	s.DriftTemplate.Execute(w, data) // nolint: errcheck
+}
+
 // GetLockRoute is the GET /locks/{id} route. It renders the lock detail view.
 func (s *Server) GetLockRoute(w http.ResponseWriter, r *http.Request) {
 	id, ok := mux.Vars(r)["id"]
@@ -387,14 +789,16 @@ func (s *Server) GetLock(w http.ResponseWriter, _ *http.Request, id string) {
 		return
 	}
 
-	// Extract the repo owner and repo name.
-	repo := strings.Split(lock.Project.RepoFullName, "/")
+	// Extract the repo owner and repo name. GitLab owners can contain /'s if
+	// the repo is in a subgroup so we split on the last one.
+	nameIdx := strings.LastIndex(lock.Project.RepoFullName, "/")
 
 	l := LockDetailData{
 		LockKeyEncoded:  id,
 		LockKey:         idUnencoded,
-		RepoOwner:       repo[0],
-		RepoName:        repo[1],
+		Hostname:        lock.Project.Hostname,
+		RepoOwner:       lock.Project.RepoFullName[:nameIdx],
+		RepoName:        lock.Project.RepoFullName[nameIdx+1:],
 		PullRequestLink: lock.Pull.URL,
 		LockedBy:        lock.Pull.Author,
 		Workspace:       lock.Workspace,
@@ -431,6 +835,9 @@ func (s *Server) DeleteLock(w http.ResponseWriter, _ *http.Request, id string) {
 		s.respond(w, logging.Warn, http.StatusNotFound, "No lock found at that id", idUnencoded)
 		return
 	}
+	if s.LockWebhooks != nil {
+		s.LockWebhooks.SendUnlock(s.Logger, webhooks.LockResult{Lock: *lock, Reason: webhooks.UnlockDiscardedReason}) // nolint: errcheck
+	}
 	s.respond(w, logging.Info, http.StatusOK, "Deleted lock id %s", idUnencoded)
 }
 
@@ -26,6 +26,7 @@ import (
 	"github.com/gorilla/mux"
 	. "github.com/petergtz/pegomock"
 	"github.com/runatlantis/atlantis/server"
+	"github.com/runatlantis/atlantis/server/events"
 	"github.com/runatlantis/atlantis/server/events/locking/mocks"
 	"github.com/runatlantis/atlantis/server/events/models"
 	"github.com/runatlantis/atlantis/server/logging"
@@ -69,6 +70,7 @@ func TestIndex_Success(t *testing.T) {
 				Num: 9,
 			},
 			Project: models.Project{
+				Hostname:     "github.com",
 				RepoFullName: "owner/repo",
 			},
 			Time: now,
@@ -93,6 +95,7 @@ func TestIndex_Success(t *testing.T) {
 		Locks: []server.LockIndexData{
 			{
 				LockURL:      "",
+				Hostname:     "github.com",
 				RepoFullName: "owner/repo",
 				PullNum:      9,
 				Time:         now,
@@ -103,6 +106,31 @@ func TestIndex_Success(t *testing.T) {
 	responseContains(t, w, http.StatusOK, "")
 }
 
+func TestDrift_Disabled(t *testing.T) {
+	t.Log("the drift page should return a 404 if drift detection isn't enabled")
+	s := server.Server{}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	w := httptest.NewRecorder()
+	s.Drift(w, req)
+	responseContains(t, w, http.StatusNotFound, "Drift detection isn't enabled")
+}
+
+func TestDrift_NotRunYet(t *testing.T) {
+	t.Log("the drift page should render with no results if drift hasn't been checked yet")
+	RegisterMockTestingT(t)
+	dt := sMocks.NewMockTemplateWriter()
+	s := server.Server{
+		DriftDetector:   &events.DriftDetector{},
+		DriftTemplate:   dt,
+		AtlantisVersion: "0.3.1",
+	}
+	req, _ := http.NewRequest("GET", "", bytes.NewBuffer(nil))
+	w := httptest.NewRecorder()
+	s.Drift(w, req)
+	dt.VerifyWasCalledOnce().Execute(w, server.DriftData{AtlantisVersion: "0.3.1"})
+	responseContains(t, w, http.StatusOK, "")
+}
+
 func TestGetLockRoute_NoLockID(t *testing.T) {
 	t.Log("If there is no lock ID in the request then we should get a 400")
 	eventsReq, _ = http.NewRequest("GET", "", bytes.NewBuffer(nil))
@@ -154,7 +182,7 @@ func TestGetLock_Success(t *testing.T) {
 	RegisterMockTestingT(t)
 	l := mocks.NewMockLocker()
 	When(l.GetLock("id")).ThenReturn(&models.ProjectLock{
-		Project:   models.Project{RepoFullName: "owner/repo", Path: "path"},
+		Project:   models.Project{Hostname: "github.com", RepoFullName: "owner/repo", Path: "path"},
 		Pull:      models.PullRequest{URL: "url", Author: "lkysow"},
 		Workspace: "workspace",
 	}, nil)
@@ -169,6 +197,7 @@ func TestGetLock_Success(t *testing.T) {
 	tmpl.VerifyWasCalledOnce().Execute(w, server.LockDetailData{
 		LockKeyEncoded:  "id",
 		LockKey:         "id",
+		Hostname:        "github.com",
 		RepoOwner:       "owner",
 		RepoName:        "repo",
 		PullRequestLink: "url",
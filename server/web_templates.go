@@ -32,6 +32,7 @@ type TemplateWriter interface {
 // LockIndexData holds the fields needed to display the index view for locks.
 type LockIndexData struct {
 	LockURL      string
+	Hostname     string
 	RepoFullName string
 	PullNum      int
 	Time         time.Time
@@ -41,6 +42,9 @@ type LockIndexData struct {
 type IndexData struct {
 	Locks           []LockIndexData
 	AtlantisVersion string
+	// DriftEnabled is true if drift detection is enabled so we link to its
+	// page.
+	DriftEnabled bool
 }
 
 var indexTemplate = template.Must(template.New("index.html.tmpl").Parse(`
@@ -79,13 +83,16 @@ var indexTemplate = template.Must(template.New("index.html.tmpl").Parse(`
   </nav>
   <div class="navbar-spacer"></div>
   <br>
+  {{ if .DriftEnabled }}
+  <p><a href="/drift">View drift detection results</a></p>
+  {{ end }}
   <section>
     <p class="title-heading small"><strong>Locks</strong></p>
     {{ if .Locks }}
     {{ range .Locks }}
       <a href="{{.LockURL}}">
         <div class="twelve columns button content lock-row">
-        <div class="list-title">{{.RepoFullName}} - <span class="heading-font-size">#{{.PullNum}}</span></div>
+        <div class="list-title">{{.Hostname}}/{{.RepoFullName}} - <span class="heading-font-size">#{{.PullNum}}</span></div>
         <div class="list-status"><code>Locked</code></div>
         <div class="list-timestamp"><span class="heading-font-size">{{.Time}}</span></div>
         </div>
@@ -108,6 +115,7 @@ type LockDetailData struct {
 	UnlockURL       string
 	LockKeyEncoded  string
 	LockKey         string
+	Hostname        string
 	RepoOwner       string
 	RepoName        string
 	PullRequestLink string
@@ -143,6 +151,7 @@ var lockTemplate = template.Must(template.New("lock.html.tmpl").Parse(`
     <br>
     <section>
       <div class="eight columns">
+        <h6><code>Hostname</code>: <strong>{{.Hostname}}</strong></h6>
         <h6><code>Repo Owner</code>: <strong>{{.RepoOwner}}</strong></h6>
         <h6><code>Repo Name</code>: <strong>{{.RepoName}}</strong></h6>
         <h6><code>Pull Request Link</code>: <a href="{{.PullRequestLink}}" target="_blank"><strong>{{.PullRequestLink}}</strong></a></h6>
@@ -218,3 +227,74 @@ v{{ .AtlantisVersion }}
 </body>
 </html>
 `))
+
+// DriftResultData holds the fields needed to display the result of checking
+// a project and workspace for drift.
+type DriftResultData struct {
+	Hostname     string
+	RepoFullName string
+	Path         string
+	Workspace    string
+	Drifted      bool
+	Summary      string
+	Error        string
+	Output       string
+	Time         time.Time
+}
+
+// DriftData holds the data for rendering the drift page.
+type DriftData struct {
+	Results []DriftResultData
+	// LastRun is when drift was last checked. It's zero if it hasn't been
+	// checked yet.
+	LastRun         time.Time
+	DriftedCount    int
+	ErrorCount      int
+	AtlantisVersion string
+}
+
+var driftTemplate = template.Must(template.New("drift.html.tmpl").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>atlantis</title>
+  <meta name="description" content="">
+  <meta name="author" content="">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <link rel="stylesheet" href="/static/css/normalize.css">
+  <link rel="stylesheet" href="/static/css/skeleton.css">
+  <link rel="stylesheet" href="/static/css/custom.css">
+  <link rel="icon" type="image/png" href="/static/images/atlantis-icon.png">
+</head>
+<body>
+<div class="container">
+  <section class="header">
+    <a title="atlantis" href="/"><img src="/static/images/atlantis-icon.png"/></a>
+    <p class="title-heading">atlantis</p>
+  </section>
+  <div class="navbar-spacer"></div>
+  <br>
+  <section>
+    <p class="title-heading small"><strong>Drift</strong></p>
+    {{ if .LastRun.IsZero }}
+    <p class="placeholder">Drift hasn't been checked yet.</p>
+    {{ else }}
+    <p>Last checked at {{ .LastRun }}: {{ .DriftedCount }} of {{ len .Results }} project workspace(s) drifted and {{ .ErrorCount }} couldn't be checked.</p>
+    {{ range .Results }}
+      <div class="twelve columns content lock-row">
+        <div class="list-title">{{.Hostname}}/{{.RepoFullName}} - <span class="heading-font-size">{{.Path}} ({{.Workspace}})</span></div>
+        <div class="list-status">{{ if .Error }}<code>Error</code>{{ else if .Drifted }}<code>Drifted</code>{{ else }}<code>No drift</code>{{ end }}</div>
+        <div class="list-timestamp"><span class="heading-font-size">{{.Time}}</span></div>
+        {{ if .Error }}<pre>{{.Error}}</pre>{{ else if .Drifted }}<p>{{.Summary}}</p><pre>{{.Output}}</pre>{{ end }}
+      </div>
+    {{ end }}
+    {{ end }}
+  </section>
+</div>
+<footer>
+v{{ .AtlantisVersion }}
+</footer>
+</body>
+</html>
+`))